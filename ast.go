@@ -0,0 +1,329 @@
+package scss
+
+type stylesheet struct {
+	file  string
+	src   []byte
+	stmts []stmt
+}
+
+type stmt interface {
+	location() Location
+}
+
+type expr interface {
+	location() Location
+}
+
+type node struct{ loc Location }
+
+func (n node) location() Location { return n.loc }
+
+// interp is text that may contain #{} interpolation. Each part is either a
+// string or an expr.
+type interp struct {
+	parts []interface{}
+}
+
+func (it *interp) addText(s string) {
+	if s == "" {
+		return
+	}
+	if n := len(it.parts); n > 0 {
+		if prev, ok := it.parts[n-1].(string); ok {
+			it.parts[n-1] = prev + s
+			return
+		}
+	}
+	it.parts = append(it.parts, s)
+}
+
+func (it *interp) addExpr(e expr) {
+	it.parts = append(it.parts, e)
+}
+
+// plain returns the text of it if it has no interpolation.
+func (it interp) plain() (string, bool) {
+	switch len(it.parts) {
+	case 0:
+		return "", true
+	case 1:
+		s, ok := it.parts[0].(string)
+		return s, ok
+	}
+	return "", false
+}
+
+// Statements.
+
+type styleRule struct {
+	node
+	selector interp
+	children []stmt
+}
+
+type declaration struct {
+	node
+	name     interp
+	value    expr
+	children []stmt
+	custom   bool
+}
+
+type varDecl struct {
+	node
+	namespace string
+	name      string
+	value     expr
+	isDefault bool
+	isGlobal  bool
+}
+
+type atRule struct {
+	node
+	name     string
+	prelude  interp
+	children []stmt
+	hasBody  bool
+}
+
+// commentStmt is a loud comment, which is copied to the output.
+type commentStmt struct {
+	node
+	text interp
+}
+
+type mediaRule struct {
+	node
+	query    interp
+	children []stmt
+}
+
+type supportsRule struct {
+	node
+	condition interp
+	children  []stmt
+}
+
+type callableDecl struct {
+	node
+	name   string
+	params *paramList
+	body   []stmt
+}
+
+type mixinDecl struct{ callableDecl }
+
+type functionDecl struct{ callableDecl }
+
+type includeStmt struct {
+	node
+	namespace string
+	name      string
+	args      *argInvocation
+	content   *callableDecl
+}
+
+type contentStmt struct {
+	node
+	args *argInvocation
+}
+
+type returnStmt struct {
+	node
+	value expr
+}
+
+type debugStmt struct {
+	node
+	value expr
+}
+
+type warnStmt struct {
+	node
+	value expr
+}
+
+type errorStmt struct {
+	node
+	value expr
+}
+
+type ifClause struct {
+	cond expr
+	body []stmt
+}
+
+type ifStmt struct {
+	node
+	clauses  []ifClause
+	elseBody []stmt
+	hasElse  bool
+}
+
+type eachStmt struct {
+	node
+	vars []string
+	list expr
+	body []stmt
+}
+
+type forStmt struct {
+	node
+	variable  string
+	from, to  expr
+	inclusive bool
+	body      []stmt
+}
+
+type whileStmt struct {
+	node
+	cond expr
+	body []stmt
+}
+
+type extendStmt struct {
+	node
+	selector interp
+	optional bool
+}
+
+type importArg struct {
+	node
+	url       string
+	plain     interp
+	isPlain   bool
+	modifiers interp
+}
+
+type importStmt struct {
+	node
+	imports []*importArg
+}
+
+type configVar struct {
+	node
+	name      string
+	value     expr
+	isDefault bool
+}
+
+type useStmt struct {
+	node
+	url       string
+	namespace string
+	config    []*configVar
+}
+
+type forwardStmt struct {
+	node
+	url    string
+	prefix string
+	show   map[string]bool
+	hide   map[string]bool
+	config []*configVar
+}
+
+type atRootStmt struct {
+	node
+	selector *interp
+	with     map[string]bool
+	without  map[string]bool
+	children []stmt
+}
+
+// Expressions.
+
+type literalExpr struct {
+	node
+	value Value
+}
+
+type stringExpr struct {
+	node
+	text   interp
+	quoted bool
+}
+
+type variableExpr struct {
+	node
+	namespace string
+	name      string
+}
+
+type funcCallExpr struct {
+	node
+	namespace string
+	name      string
+	args      *argInvocation
+}
+
+type ifExpr struct {
+	node
+	args *argInvocation
+}
+
+type binaryExpr struct {
+	node
+	op          string
+	left, right expr
+	allowsSlash bool
+}
+
+type unaryExpr struct {
+	node
+	op      string
+	operand expr
+}
+
+type listExpr struct {
+	node
+	items     []expr
+	separator Separator
+	bracketed bool
+}
+
+type mapExpr struct {
+	node
+	keys, values []expr
+}
+
+type parenExpr struct {
+	node
+	inner expr
+}
+
+type selectorExpr struct{ node }
+
+// calcExpr is a calculation: a call of calc(), min(), max() or clamp() whose
+// arguments are parsed as calc() parses them rather than as Sass
+// expressions.
+type calcExpr struct {
+	node
+	name string
+	args []expr
+}
+
+// calcOpExpr is an arithmetic operation in a calculation.
+type calcOpExpr struct {
+	node
+	op          string
+	left, right expr
+}
+
+type param struct {
+	name string
+	def  expr
+}
+
+type paramList struct {
+	params []param
+	rest   string
+}
+
+type argInvocation struct {
+	positional []expr
+	names      []string
+	named      map[string]expr
+	rest       expr
+	kwRest     expr
+}
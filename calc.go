@@ -0,0 +1,336 @@
+package scss
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/logan/scss/css3"
+)
+
+// Calculation is a calc(), min(), max() or clamp() whose arguments couldn't
+// be simplified to a single number, as in "calc(100% - 10px)". It can be
+// stored in variables and passed to functions like any other value, and is
+// output as written, but can't be used in arithmetic.
+type Calculation struct {
+	Name string
+	Args []Value
+}
+
+func (c *Calculation) TypeName() string { return "calculation" }
+func (c *Calculation) Truthy() bool     { return true }
+
+func (c *Calculation) Equal(other Value) bool {
+	o, ok := other.(*Calculation)
+	if !ok || c.Name != o.Name || len(c.Args) != len(o.Args) {
+		return false
+	}
+	for i, arg := range c.Args {
+		if !arg.Equal(o.Args[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Calculation) String() string {
+	s, _ := c.format(false, true)
+	return s
+}
+
+// format returns c as CSS. Unless inspecting, a number with units CSS can't
+// express is an error.
+func (c *Calculation) format(compressed, inspect bool) (string, error) {
+	args := make([]string, len(c.Args))
+	for i, arg := range c.Args {
+		s, err := calcArgString(arg, compressed, inspect)
+		if err != nil {
+			return "", err
+		}
+		args[i] = s
+	}
+	sep := ", "
+	if compressed {
+		sep = ","
+	}
+	return c.Name + "(" + strings.Join(args, sep) + ")", nil
+}
+
+// calcOperation is an operation in a calculation that can't be worked out
+// until it's used, as the "-" in "calc(100% - 10px)".
+type calcOperation struct {
+	op          string
+	left, right Value
+}
+
+func (o *calcOperation) TypeName() string { return "calculation" }
+func (o *calcOperation) Truthy() bool     { return true }
+
+func (o *calcOperation) Equal(other Value) bool {
+	p, ok := other.(*calcOperation)
+	return ok && o.op == p.op && o.left.Equal(p.left) && o.right.Equal(p.right)
+}
+
+func (o *calcOperation) String() string {
+	s, _ := calcArgString(o, false, true)
+	return s
+}
+
+func calcPrecedence(op string) int {
+	if op == "*" || op == "/" {
+		return 2
+	}
+	return 1
+}
+
+func calcArgString(v Value, compressed, inspect bool) (string, error) {
+	switch v := v.(type) {
+	case *Number:
+		if inspect {
+			return v.String(), nil
+		}
+		if isFinite(v.Value) {
+			return toCSS(v, compressed)
+		}
+		return nonFiniteString(v, compressed), nil
+	case *Calculation:
+		return v.format(compressed, inspect)
+	case *calcOperation:
+		left, err := calcArgString(v.left, compressed, inspect)
+		if err != nil {
+			return "", err
+		}
+		if l, ok := v.left.(*calcOperation); ok && calcPrecedence(l.op) < calcPrecedence(v.op) {
+			left = "(" + left + ")"
+		}
+		right, err := calcArgString(v.right, compressed, inspect)
+		if err != nil {
+			return "", err
+		}
+		if r, ok := v.right.(*calcOperation); ok && (v.op == "/" || v.op != "+" && calcPrecedence(r.op) == 1) {
+			right = "(" + right + ")"
+		} else if r, ok := v.right.(*Number); ok && !inspect && !isFinite(r.Value) && !r.Unitless() && calcPrecedence(v.op) == 2 {
+			right = "(" + right + ")"
+		}
+		if compressed && calcPrecedence(v.op) == 2 {
+			return left + v.op + right, nil
+		}
+		return left + " " + v.op + " " + right, nil
+	}
+	return cssString(v), nil
+}
+
+func isFinite(v float64) bool { return !math.IsInf(v, 0) && !math.IsNaN(v) }
+
+// nonFiniteString returns n, which is infinite or NaN, in the terms CSS
+// calculations have for such values, as in "-infinity * 1px".
+func nonFiniteString(n *Number, compressed bool) string {
+	s := "NaN"
+	switch {
+	case math.IsInf(n.Value, 1):
+		s = "infinity"
+	case math.IsInf(n.Value, -1):
+		s = "-infinity"
+	}
+	mul, div := " * 1", " / 1"
+	if compressed {
+		mul, div = "*1", "/1"
+	}
+	for _, u := range n.Numerators {
+		s += mul + u
+	}
+	for _, u := range n.Denominators {
+		s += div + u
+	}
+	return s
+}
+
+// calcCompatible reports whether a and b can be added or compared exactly.
+func calcCompatible(a, b *Number) bool {
+	if a.Unitless() || b.Unitless() {
+		return a.Unitless() && b.Unitless()
+	}
+	_, err := b.convert(a.Numerators, a.Denominators)
+	return err == nil
+}
+
+// checkCalcCompatible returns an error if a and b can never be added, as
+// lengths and times can't. Percentages and unknown units may turn out to be
+// compatible with any other, and relative lengths such as "em" with any
+// length.
+func checkCalcCompatible(a, b *Number) error {
+	if calcCompatible(a, b) {
+		return nil
+	}
+	simple := func(n *Number) bool { return len(n.Numerators) == 1 && len(n.Denominators) == 0 }
+	if simple(a) && simple(b) {
+		d1, known1 := css3.UnitDimension(a.Numerators[0])
+		d2, known2 := css3.UnitDimension(b.Numerators[0])
+		if !known1 || !known2 || d1 == css3.PercentageDimension || d2 == css3.PercentageDimension || d1 == d2 {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s and %s are incompatible.", a, b)
+}
+
+// checkCalcArg returns v if it may be used in a calculation.
+func checkCalcArg(v Value) (Value, error) {
+	switch v := v.(type) {
+	case *Number:
+		return v.withoutSlash(), nil
+	case *Calculation:
+		if v.Name == "calc" {
+			// A nested calc() is just its argument, in parentheses if
+			// that's needed.
+			return v.Args[0], nil
+		}
+		return v, nil
+	case *calcOperation:
+		return v, nil
+	case *String:
+		if !v.Quoted {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("Value %s can't be used in a calculation.", v)
+}
+
+// simplifyCalcOperation applies op to left and right if they're numbers
+// that can be combined, and otherwise keeps the operation for the browser.
+func simplifyCalcOperation(op string, left, right Value) (Value, error) {
+	ln, lok := left.(*Number)
+	rn, rok := right.(*Number)
+	if op == "*" || op == "/" {
+		if lok && rok {
+			return numberOperation(op, ln, rn)
+		}
+		return &calcOperation{op, left, right}, nil
+	}
+	if lok && rok {
+		if calcCompatible(ln, rn) {
+			return numberOperation(op, ln, rn)
+		}
+		if err := checkCalcCompatible(ln, rn); err != nil {
+			return nil, err
+		}
+	}
+	if rok && rn.Value < 0 {
+		// "1px + -10%" is written "1px - 10%".
+		right = &Number{Value: -rn.Value, Numerators: rn.Numerators, Denominators: rn.Denominators}
+		if op == "+" {
+			op = "-"
+		} else {
+			op = "+"
+		}
+	}
+	return &calcOperation{op, left, right}, nil
+}
+
+// simplifyCalculation returns the value of the calculation name(args): a
+// number if its arguments can be worked out, and otherwise a Calculation.
+func simplifyCalculation(name string, args []Value) (Value, error) {
+	if name == "calc" {
+		switch args[0].(type) {
+		case *Number, *Calculation:
+			return args[0], nil
+		}
+		return &Calculation{Name: name, Args: args}, nil
+	}
+	numbers := make([]*Number, 0, len(args))
+	for _, arg := range args {
+		if n, ok := arg.(*Number); ok {
+			numbers = append(numbers, n)
+		}
+	}
+	for i := 1; i < len(numbers); i++ {
+		if err := checkCalcCompatible(numbers[0], numbers[i]); err != nil {
+			return nil, err
+		}
+	}
+	if len(numbers) < len(args) {
+		return &Calculation{Name: name, Args: args}, nil
+	}
+	for _, n := range numbers[1:] {
+		if !calcCompatible(numbers[0], n) {
+			return &Calculation{Name: name, Args: args}, nil
+		}
+	}
+	less := func(a, b *Number) bool {
+		v, _ := b.convert(a.Numerators, a.Denominators)
+		return a.Value < v && !fuzzyEqual(a.Value, v)
+	}
+	switch name {
+	case "min", "max":
+		result := numbers[0]
+		for _, n := range numbers[1:] {
+			if name == "min" && less(n, result) || name == "max" && less(result, n) {
+				result = n
+			}
+		}
+		return result, nil
+	}
+	result := numbers[1]
+	if less(numbers[2], result) {
+		result = numbers[2]
+	}
+	if less(result, numbers[0]) {
+		result = numbers[0]
+	}
+	return result, nil
+}
+
+// evalCalculation evaluates a calculation, simplifying it as far as it can
+// be without knowing where it's used.
+func (e *evaluator) evalCalculation(x *calcExpr) Value {
+	args := make([]Value, len(x.args))
+	for i, arg := range x.args {
+		args[i] = e.evalCalcArg(arg)
+	}
+	v, err := simplifyCalculation(x.name, args)
+	if err != nil {
+		panic(errorf(x.loc, "%s", err.Error()))
+	}
+	return v
+}
+
+func (e *evaluator) evalCalcArg(x expr) Value {
+	var v Value
+	var err error
+	switch x := x.(type) {
+	case *calcExpr:
+		v, err = checkCalcArg(e.evalCalculation(x))
+	case *calcOpExpr:
+		v, err = simplifyCalcOperation(x.op, e.evalCalcArg(x.left), e.evalCalcArg(x.right))
+	case *parenExpr:
+		v = e.evalCalcArg(x.inner)
+		if s, ok := v.(*String); ok {
+			// Keep the grouping of an interpolated operand.
+			v = &String{Text: "(" + s.Text + ")"}
+		}
+	case *unaryExpr:
+		v, err = simplifyCalcOperation("*", NewNumber(-1, ""), e.evalCalcArg(x.operand))
+	default:
+		v, err = checkCalcArg(e.eval(x))
+	}
+	if err != nil {
+		panic(errorf(x.location(), "%s", err.Error()))
+	}
+	return v
+}
+
+// calcArgs returns the arguments of a calculation as meta.calc-args() does,
+// with any operations as unquoted strings.
+func calcArgs(c *Calculation) *List {
+	items := make([]Value, len(c.Args))
+	for i, arg := range c.Args {
+		switch arg := arg.(type) {
+		case *calcOperation:
+			items[i] = &String{Text: arg.String()}
+		case *String:
+			items[i] = &String{Text: arg.Text}
+		default:
+			items[i] = arg
+		}
+	}
+	return &List{Items: items, Separator: CommaSeparator}
+}
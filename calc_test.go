@@ -0,0 +1,77 @@
+package scss
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCalculations(t *testing.T) {
+	eval := func(expr string) string {
+		result, err := CompileString("@use \"sass:meta\";\n$w: calc(100% - 10px);\na { b: "+expr+"; }", Options{})
+		if err != nil {
+			return err.Error()
+		}
+		return result.CSS[len("a {\n  b: ") : len(result.CSS)-len(";\n}")]
+	}
+
+	Convey("calculations of numbers are simplified", t, func() {
+		So(eval("calc(10px + 2 * 5px)"), ShouldEqual, "20px")
+		So(eval("calc(1in - 2px)"), ShouldEqual, "0.9791666667in")
+		So(eval("calc(calc(1px + 2px) * 2)"), ShouldEqual, "6px")
+		So(eval("calc(pi * 1px)"), ShouldEqual, "3.1415926536px")
+		So(eval("min(1in, 20px)"), ShouldEqual, "20px")
+		So(eval("clamp(1px, 5px, 3px)"), ShouldEqual, "3px")
+		So(eval("min(1px, 2px) + 1"), ShouldEqual, "2px")
+	})
+
+	Convey("calculations that can't be simplified are values", t, func() {
+		So(eval("$w"), ShouldEqual, "calc(100% - 10px)")
+		So(eval("meta.type-of($w)"), ShouldEqual, "calculation")
+		So(eval("$w == calc(100% - 10px)"), ShouldEqual, "true")
+		So(eval("min(1rem, 20px)"), ShouldEqual, "min(1rem, 20px)")
+		So(eval("calc(1em + 1foo)"), ShouldEqual, "calc(1em + 1foo)")
+		So(eval("clamp(1px, 5%, 3px)"), ShouldEqual, "clamp(1px, 5%, 3px)")
+		So(eval("calc(1px - -10%)"), ShouldEqual, "calc(1px + 10%)")
+		So(eval("calc($w / 2)"), ShouldEqual, "calc((100% - 10px) / 2)")
+		So(eval("calc(-$w)"), ShouldEqual, "calc(-1 * (100% - 10px))")
+		So(eval("calc(2 * (100% - 10px))"), ShouldEqual, "calc(2 * (100% - 10px))")
+		So(eval("min(10px, calc(1em - 1px))"), ShouldEqual, "min(10px, 1em - 1px)")
+	})
+
+	Convey("infinite and NaN results are written as calculations", t, func() {
+		So(eval("calc(1 / 0)"), ShouldEqual, "calc(infinity)")
+		So(eval("calc(-1px / 0)"), ShouldEqual, "calc(-infinity * 1px)")
+		So(eval("calc(0 / 0)"), ShouldEqual, "calc(NaN)")
+		So(eval("calc(100% - 1px / 0)"), ShouldEqual, "calc(100% - infinity * 1px)")
+		So(eval("calc(var(--x) / (1px / 0))"), ShouldEqual, "calc(var(--x) / (infinity * 1px))")
+	})
+
+	Convey("var() and interpolation are allowed", t, func() {
+		So(eval("calc(var(--x) + 1px)"), ShouldEqual, "calc(var(--x) + 1px)")
+		So(eval("calc(#{\"var(--x)\"} * 2)"), ShouldEqual, "calc(var(--x) * 2)")
+		So(eval("calc(1px + (#{\"a\"}))"), ShouldEqual, "calc(1px + (a))")
+	})
+
+	Convey("min() and max() are Sass functions when they aren't calculations", t, func() {
+		So(eval("min((3, 1, 2)...)"), ShouldEqual, "1")
+	})
+
+	Convey("meta.calc-name() and meta.calc-args()", t, func() {
+		So(eval("meta.calc-name($w)"), ShouldEqual, "\"calc\"")
+		So(eval("meta.calc-args($w)"), ShouldEqual, "100% - 10px")
+		So(eval("meta.calc-args(clamp(1px, 5%, 3px))"), ShouldEqual, "1px, 5%, 3px")
+		So(eval("meta.calc-name(1px)"), ShouldEqual, "stdin:3:8: $calc: 1px is not a calculation.")
+	})
+
+	Convey("errors", t, func() {
+		So(eval("calc(1px + 1s)"), ShouldEqual, "stdin:3:17: 1px and 1s are incompatible.")
+		So(eval("min(1px, 1s)"), ShouldEqual, "stdin:3:8: 1px and 1s are incompatible.")
+		So(eval("calc(1em + 1s)"), ShouldEqual, "stdin:3:17: 1em and 1s are incompatible.")
+		So(eval("calc(1px+2px)"), ShouldEqual, "stdin:3:16: \"+\" and \"-\" must be surrounded by whitespace in calculations.")
+		So(eval("calc(red)"), ShouldEqual, "stdin:3:13: Value red can't be used in a calculation.")
+		So(eval("$w + 1"), ShouldEqual, "stdin:3:10: Undefined operation \"calc(100% - 10px) + 1\".")
+		So(eval("-$w"), ShouldEqual, "stdin:3:8: Undefined operation \"-calc(100% - 10px)\".")
+		So(eval("clamp(1px, 2px)"), ShouldEqual, "stdin:3:8: clamp() requires exactly 3 arguments.")
+	})
+}
@@ -0,0 +1,242 @@
+package scss
+
+import (
+	"strings"
+)
+
+// Function is a function implemented in Go that stylesheets can call. An
+// error it returns is reported at the location of the call.
+type Function func(args []Value) (Value, error)
+
+// callable is a mixin, a function or a content block.
+type callable struct {
+	name   string
+	params *paramList
+	body   []stmt
+	env    *environment
+
+	// content is the content block passed to the mixin that a content
+	// block was written in, which @content within it refers to.
+	content *callable
+
+	fn Function
+
+	// builtin implements a built-in function or mixin that needs the
+	// evaluator, like meta.call().
+	builtin builtinFunction
+}
+
+// builtinFunction is a function or mixin implemented in Go with access to
+// the evaluator. It's called in the caller's state.
+type builtinFunction func(e *evaluator, args []Value, loc Location) (Value, error)
+
+// maxCallDepth bounds recursion through mixins and functions.
+const maxCallDepth = 1000
+
+type evaluatedArgs struct {
+	positional []Value
+	names      []string
+	named      map[string]Value
+	separator  Separator
+}
+
+func (e *evaluator) evalArgs(a *argInvocation) *evaluatedArgs {
+	args := &evaluatedArgs{named: make(map[string]Value), separator: UndecidedSeparator}
+	for _, arg := range a.positional {
+		args.positional = append(args.positional, e.eval(arg))
+	}
+	for _, name := range a.names {
+		args.names = append(args.names, name)
+		args.named[name] = e.eval(a.named[name])
+	}
+	if a.rest != nil {
+		rest := e.eval(a.rest)
+		switch rest := rest.(type) {
+		case *Map:
+			e.addKeywords(args, rest, a.rest.location())
+		case *ArgList:
+			args.positional = append(args.positional, rest.Items...)
+			args.separator = rest.Separator
+			if rest.Keywords != nil {
+				e.addKeywords(args, rest.Keywords, a.rest.location())
+			}
+		case *List:
+			args.positional = append(args.positional, rest.Items...)
+			args.separator = rest.Separator
+		default:
+			args.positional = append(args.positional, rest)
+		}
+	}
+	if a.kwRest != nil {
+		m, ok := e.eval(a.kwRest).(*Map)
+		if !ok {
+			panic(errorf(a.kwRest.location(), "Variable keyword arguments must be a map."))
+		}
+		e.addKeywords(args, m, a.kwRest.location())
+	}
+	return args
+}
+
+func (e *evaluator) addKeywords(args *evaluatedArgs, m *Map, loc Location) {
+	for i, key := range m.Keys {
+		s, ok := key.(*String)
+		if !ok {
+			panic(errorf(loc, "Variable keyword argument map must have string keys.\n%s is not a string in %s.", key, m))
+		}
+		name := normName(s.Text)
+		if _, dup := args.named[name]; !dup {
+			args.names = append(args.names, name)
+		}
+		args.named[name] = m.Values[i]
+	}
+}
+
+func pluralize(n int, singular, plural string) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
+
+// bindArgs declares c's parameters in the current scope. Default values are
+// evaluated there too, so they can refer to earlier parameters.
+func (e *evaluator) bindArgs(c *callable, args *evaluatedArgs, loc Location) {
+	params := c.params.params
+	if len(args.positional) > len(params) && c.params.rest == "" {
+		panic(errorf(loc, "Only %d %s allowed, but %d %s passed.",
+			len(params), pluralize(len(params), "argument", "arguments"),
+			len(args.positional), pluralize(len(args.positional), "was", "were")))
+	}
+	used := 0
+	for i, p := range params {
+		v, named := args.named[p.name]
+		switch {
+		case i < len(args.positional):
+			if named {
+				panic(errorf(loc, "Argument $%s was passed both by position and by name.", p.name))
+			}
+			v = args.positional[i]
+		case named:
+			used++
+		case p.def != nil:
+			v = e.eval(p.def)
+		default:
+			panic(errorf(loc, "Missing argument $%s.", p.name))
+		}
+		e.env.scope.vars[p.name] = v
+	}
+	if c.params.rest != "" {
+		rest := &ArgList{Keywords: NewMap()}
+		rest.Separator = args.separator
+		if rest.Separator == UndecidedSeparator {
+			rest.Separator = CommaSeparator
+		}
+		if len(args.positional) > len(params) {
+			rest.Items = args.positional[len(params):]
+		}
+		for _, name := range args.names {
+			if !c.hasParam(name) {
+				rest.Keywords.Set(&String{Text: name}, args.named[name])
+			}
+		}
+		e.env.scope.vars[c.params.rest] = rest
+		return
+	}
+	if used < len(args.named) {
+		for _, name := range args.names {
+			if !c.hasParam(name) {
+				panic(errorf(loc, "No argument named $%s.", name))
+			}
+		}
+	}
+}
+
+func (c *callable) hasParam(name string) bool {
+	for _, p := range c.params.params {
+		if p.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// enter switches to a new scope within c's environment for a call to c.
+func (e *evaluator) enter(c *callable, loc Location) {
+	if len(e.stack) >= maxCallDepth {
+		panic(errorf(loc, "Stack overflow."))
+	}
+	member := c.name + "()"
+	if c.name == "@content" {
+		member = c.name
+	}
+	e.stack = append(e.stack, stackFrame{member, loc})
+	e.env = c.env.withScope(newScope(c.env.scope))
+}
+
+func (e *evaluator) callFunction(c *callable, args *evaluatedArgs, loc Location) Value {
+	if c.fn != nil || c.builtin != nil {
+		return e.callGoFunction(c, args, loc)
+	}
+	saved := e.evalState
+	defer func() { e.evalState = saved }()
+	e.enter(c, loc)
+	e.bindArgs(c, args, loc)
+	e.inFunction = true
+	e.inMixin = false
+	if v := e.execStmts(c.body); v != nil {
+		return v
+	}
+	panic(errorf(loc, "Function finished without @return."))
+}
+
+func (e *evaluator) includeMixin(c *callable, args *evaluatedArgs, content *callable, loc Location) {
+	if c.builtin != nil {
+		e.callGoFunction(c, args, loc)
+		return
+	}
+	saved := e.evalState
+	defer func() { e.evalState = saved }()
+	e.enter(c, loc)
+	e.bindArgs(c, args, loc)
+	e.content = content
+	e.inMixin = true
+	e.execStmts(c.body)
+}
+
+func (e *evaluator) execContent(s *contentStmt) {
+	c := e.content
+	if c == nil {
+		return
+	}
+	args := &evaluatedArgs{named: make(map[string]Value)}
+	if s.args != nil {
+		args = e.evalArgs(s.args)
+	}
+	saved := e.evalState
+	defer func() { e.evalState = saved }()
+	e.enter(c, s.loc)
+	e.bindArgs(c, args, s.loc)
+	e.content = c.content
+	e.execStmts(c.body)
+}
+
+// plainFunction formats a call to a function that isn't defined as a plain
+// CSS function call.
+func (e *evaluator) plainFunction(call *funcCallExpr) Value {
+	args := e.evalArgs(call.args)
+	if len(args.named) > 0 {
+		panic(errorf(call.loc, "Plain CSS functions don't support keyword arguments."))
+	}
+	parts := make([]string, len(args.positional))
+	for i, arg := range args.positional {
+		parts[i] = e.serialize(arg, call.loc)
+	}
+	text := call.name + "(" + strings.Join(parts, separatorText(CommaSeparator, e.compressed)) + ")"
+	if isRelativeColor(args.positional) {
+		return staticColor(relativeColorText(call.name, args.positional[0].(*List)))
+	}
+	if strings.EqualFold(call.name, "color-mix") {
+		return staticColor(text)
+	}
+	return &String{Text: text}
+}
@@ -0,0 +1,699 @@
+package scss
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/logan/scss/css3"
+)
+
+// newHSLColor returns the color with hue h in degrees, saturation s and
+// lightness l as percentages, and alpha a.
+func newHSLColor(h, s, l, a float64) *Color {
+	return &Color{Color: *css3.HSLA(normHue(h)/360, s/100, l/100, a)}
+}
+
+func newHWBColor(h, w, b, a float64) *Color {
+	return &Color{Color: *css3.HWBA(normHue(h)/360, w/100, b/100, a)}
+}
+
+func normHue(h float64) float64 {
+	if h = math.Mod(h, 360); h < 0 {
+		h += 360
+	}
+	return h
+}
+
+// hsl returns the color's hue in degrees, and its saturation and lightness
+// as percentages.
+func (c *Color) hsl() (h, s, l float64) {
+	h, s, l = c.ToHSL()
+	return h * 360, s * 100, l * 100
+}
+
+func (c *Color) hwb() (h, w, b float64) {
+	h, w, b = c.ToHWB()
+	return h * 360, w * 100, b * 100
+}
+
+// expectInRange returns the value of the number v, which must be between
+// min and max. The range is shown with unit if it isn't.
+func expectInRange(v Value, name string, min, max float64, unit string) (float64, error) {
+	n, err := ExpectNumber(v, name)
+	if err != nil {
+		return 0, err
+	}
+	if (n.Value < min && !fuzzyEqual(n.Value, min)) || (n.Value > max && !fuzzyEqual(n.Value, max)) {
+		return 0, fmt.Errorf("$%s: Expected %s to be within %s%s and %s%s.",
+			name, n, formatNumber(min, false), unit, formatNumber(max, false), unit)
+	}
+	return clamp(n.Value, min, max), nil
+}
+
+// expectHue returns an angle in degrees. Unitless numbers are degrees.
+func expectHue(v Value, name string) (float64, error) {
+	n, err := ExpectNumber(v, name)
+	if err != nil {
+		return 0, err
+	}
+	if n.Unitless() {
+		return n.Value, nil
+	}
+	deg, err := n.ConvertTo([]string{"deg"}, nil)
+	if err != nil {
+		return 0, fmt.Errorf("$%s: Expected %s to have an angle unit (deg, grad, rad, turn).", name, n)
+	}
+	return deg.Value, nil
+}
+
+// channelValue returns a number as a fraction of max, reading percentages
+// as fractions of 100%.
+func channelValue(v Value, name string, max float64) (float64, error) {
+	n, err := ExpectNumber(v, name)
+	if err != nil {
+		return 0, err
+	}
+	if n.HasUnit("%") {
+		return n.Value / 100, nil
+	}
+	return n.Value / max, nil
+}
+
+// isSpecialArg reports whether v is an unquoted string, like var(--x), that
+// a color function can't evaluate, so that it must be output as plain CSS.
+func isSpecialArg(v Value) bool {
+	s, ok := v.(*String)
+	return ok && !s.Quoted
+}
+
+func plainCall(name string, args []Value) Value {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		parts[i] = cssString(arg)
+	}
+	return &String{Text: name + "(" + strings.Join(parts, ", ") + ")"}
+}
+
+// colorCall returns a call to a color function that can't be evaluated,
+// such as rgb() or hsl(). It's written in the whitespace-separated syntax
+// of CSS Color 4 if it was given that way, or if it needs that syntax for
+// a "none" channel, and otherwise with commas.
+func colorCall(name string, channels []Value, spaced bool) Value {
+	if len(channels) < 3 {
+		return plainCall(name, channels)
+	}
+	for _, v := range channels {
+		if s, ok := v.(*String); ok && !s.Quoted && strings.EqualFold(s.Text, "none") {
+			spaced = true
+		}
+	}
+	if !spaced {
+		return plainCall(name, channels)
+	}
+	parts := make([]string, 3)
+	for i, v := range channels[:3] {
+		parts[i] = cssString(v)
+	}
+	text := name + "(" + strings.Join(parts, " ")
+	if len(channels) == 4 {
+		text += " / " + cssString(channels[3])
+	}
+	return &String{Text: text + ")"}
+}
+
+// isRelativeColor reports whether the arguments of a color function are
+// written in the relative color syntax, as in "rgb(from red r g b)".
+func isRelativeColor(args []Value) bool {
+	if len(args) != 1 {
+		return false
+	}
+	l, ok := args[0].(*List)
+	if !ok || l.Separator != SpaceSeparator || l.Bracketed || len(l.Items) == 0 {
+		return false
+	}
+	s, ok := l.Items[0].(*String)
+	return ok && !s.Quoted && strings.EqualFold(s.Text, "from")
+}
+
+// relativeColorText returns the CSS for a call to the color function name
+// whose argument, l, is written in the relative color syntax, with spaces
+// around the slash before alpha.
+func relativeColorText(name string, l *List) string {
+	items := append([]Value(nil), l.Items...)
+	if slash := slashOperands(items[len(items)-1]); slash != nil {
+		items = append(items[:len(items)-1], slash[0], &String{Text: "/"}, slash[1])
+	}
+	return name + "(" + cssString(&List{Items: items, Separator: SpaceSeparator}) + ")"
+}
+
+// staticColor returns the color that a plain CSS color function call, such
+// as color-mix() or one in the relative color syntax, resolves to if all
+// its inputs are known. Colors outside sRGB are written in the color space
+// they were worked out in, and other calls are output as they are.
+func staticColor(text string) Value {
+	c := css3.ColorFromString(text)
+	if c == nil || c.CurrentColor || c.Missing != [4]bool{} {
+		return &String{Text: text}
+	}
+	rgb := c.To(css3.SRGB)
+	if !rgb.InGamut(css3.SRGB) {
+		return &String{Text: c.String()}
+	}
+	return &Color{Color: *css3.RGBA(clamp(rgb.R, 0, 1), clamp(rgb.G, 0, 1), clamp(rgb.B, 0, 1), rgb.A)}
+}
+
+// slashOperands returns the operands of a slash-separated value, such as
+// "0/0.5" or "0 / var(--a)", or nil if v isn't one.
+func slashOperands(v Value) *[2]Value {
+	switch v := v.(type) {
+	case *Number:
+		if v.slash != nil {
+			return &[2]Value{v.slash[0], v.slash[1]}
+		}
+	case *String:
+		return v.slash
+	}
+	return nil
+}
+
+// colorArgs collects the arguments of rgb() or hsl(): the channels, given
+// either separately, as a space-separated list, or as a list whose last
+// element is a slash-separated channel and alpha, and any named arguments.
+// It reports whether they were given as a space-separated list.
+func colorArgs(args []Value, names []string) ([]Value, bool, error) {
+	rest := args[0].(*ArgList)
+	values := rest.Items
+	spaced := false
+	if len(values) == 1 {
+		if l, ok := values[0].(*List); ok && l.Separator == SpaceSeparator && !l.Bracketed {
+			values = append([]Value(nil), l.Items...)
+			spaced = true
+			if slash := slashOperands(values[len(values)-1]); slash != nil {
+				values[len(values)-1] = slash[0]
+				values = append(values, slash[1])
+			}
+		}
+	}
+	if len(rest.Keywords.Keys) > 0 {
+		positional := len(values)
+		values = append(values, make([]Value, len(names)-positional)...)
+		for i, key := range rest.Keywords.Keys {
+			name := key.(*String).Text
+			index := -1
+			for j, n := range names {
+				if n == name {
+					index = j
+				}
+			}
+			if index < positional {
+				return nil, false, fmt.Errorf("No argument named $%s.", name)
+			}
+			values[index] = rest.Keywords.Values[i]
+		}
+		if values[len(names)-1] == nil {
+			values = values[:len(names)-1]
+		}
+	}
+	for i, v := range values {
+		if v == nil {
+			return nil, false, fmt.Errorf("Missing argument $%s.", names[i])
+		}
+	}
+	return values, spaced, nil
+}
+
+func rgbFunction(name string) Function {
+	return func(args []Value) (Value, error) {
+		if rest := args[0].(*ArgList); len(rest.Keywords.Keys) == 0 && isRelativeColor(rest.Items) {
+			return staticColor(relativeColorText(name, rest.Items[0].(*List))), nil
+		}
+		values, spaced, err := colorArgs(args, []string{"red", "green", "blue", "alpha"})
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range values {
+			if isSpecialArg(v) {
+				return colorCall(name, values, spaced), nil
+			}
+		}
+		if len(values) == 2 {
+			c, err := ExpectColor(values[0], "color")
+			if err != nil {
+				return nil, err
+			}
+			a, err := channelValue(values[1], "alpha", 1)
+			if err != nil {
+				return nil, err
+			}
+			return withAlpha(c, a), nil
+		}
+		if len(values) < 3 || len(values) > 4 {
+			return nil, fmt.Errorf("Only 4 arguments allowed, but %d were passed.", len(values))
+		}
+		var channels [4]float64
+		channels[3] = 1
+		for i, v := range values {
+			max := 255.0
+			if i == 3 {
+				max = 1
+			}
+			if channels[i], err = channelValue(v, []string{"red", "green", "blue", "alpha"}[i], max); err != nil {
+				return nil, err
+			}
+		}
+		return &Color{Color: *css3.RGBA(clamp(channels[0], 0, 1), clamp(channels[1], 0, 1), clamp(channels[2], 0, 1), channels[3])}, nil
+	}
+}
+
+func hslFunction(name string) Function {
+	return func(args []Value) (Value, error) {
+		if rest := args[0].(*ArgList); len(rest.Keywords.Keys) == 0 && isRelativeColor(rest.Items) {
+			return staticColor(relativeColorText(name, rest.Items[0].(*List))), nil
+		}
+		values, spaced, err := colorArgs(args, []string{"hue", "saturation", "lightness", "alpha"})
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range values {
+			if isSpecialArg(v) {
+				return colorCall(name, values, spaced), nil
+			}
+		}
+		if len(values) < 3 || len(values) > 4 {
+			return nil, fmt.Errorf("Missing argument $lightness.")
+		}
+		h, err := expectHue(values[0], "hue")
+		if err != nil {
+			return nil, err
+		}
+		s, err := ExpectNumber(values[1], "saturation")
+		if err != nil {
+			return nil, err
+		}
+		l, err := ExpectNumber(values[2], "lightness")
+		if err != nil {
+			return nil, err
+		}
+		a := 1.0
+		if len(values) == 4 {
+			if a, err = channelValue(values[3], "alpha", 1); err != nil {
+				return nil, err
+			}
+		}
+		return newHSLColor(h, clamp(s.Value, 0, 100), clamp(l.Value, 0, 100), a), nil
+	}
+}
+
+func withAlpha(c *Color, a float64) *Color {
+	result := &Color{Color: c.Color}
+	result.A = clamp(a, 0, 1)
+	return result
+}
+
+// colorChannel is a channel that color.adjust(), color.scale() and
+// color.change() can set. Channels of different spaces can't be mixed.
+type colorChannel struct {
+	space string
+	max   float64
+	unit  string
+}
+
+var colorChannels = map[string]colorChannel{
+	"red":        {"rgb", 255, ""},
+	"green":      {"rgb", 255, ""},
+	"blue":       {"rgb", 255, ""},
+	"hue":        {"hsl", 0, "deg"},
+	"saturation": {"hsl", 100, "%"},
+	"lightness":  {"hsl", 100, "%"},
+	"whiteness":  {"hwb", 100, "%"},
+	"blackness":  {"hwb", 100, "%"},
+	"alpha":      {"", 1, ""},
+}
+
+var colorSpaceNames = map[string]string{"rgb": "RGB", "hsl": "HSL", "hwb": "HWB"}
+
+type colorUpdate int
+
+const (
+	adjustColor colorUpdate = iota
+	scaleColor
+	changeColor
+)
+
+// updateColor implements color.adjust(), color.scale() and color.change().
+func updateColor(update colorUpdate) Function {
+	return func(args []Value) (Value, error) {
+		c, err := ExpectColor(args[0], "color")
+		if err != nil {
+			return nil, err
+		}
+		rest := args[1].(*ArgList)
+		if len(rest.Items) > 0 {
+			return nil, fmt.Errorf("Only one positional argument is allowed. All other arguments must be passed by name.")
+		}
+		values := make(map[string]float64)
+		space := ""
+		for i, key := range rest.Keywords.Keys {
+			name := key.(*String).Text
+			ch, ok := colorChannels[name]
+			if !ok || (update == scaleColor && name == "hue") {
+				return nil, fmt.Errorf("No argument named $%s.", name)
+			}
+			if ch.space != "" {
+				if space != "" && space != ch.space {
+					return nil, fmt.Errorf("%s parameters may not be passed along with %s parameters.", colorSpaceNames[space], colorSpaceNames[ch.space])
+				}
+				space = ch.space
+			}
+			v := rest.Keywords.Values[i]
+			var value float64
+			switch {
+			case name == "hue":
+				value, err = expectHue(v, name)
+			case update == scaleColor:
+				if n, ok := v.(*Number); ok && !n.HasUnit("%") {
+					return nil, fmt.Errorf("$%s: Expected %s to have unit \"%%\".", name, n)
+				}
+				value, err = expectInRange(v, name, -100, 100, "%")
+				value /= 100
+			case update == adjustColor:
+				value, err = expectInRange(v, name, -ch.max, ch.max, ch.unit)
+			default:
+				value, err = expectInRange(v, name, 0, ch.max, ch.unit)
+			}
+			if err != nil {
+				return nil, err
+			}
+			values[name] = value
+		}
+		apply := func(name string, current float64) float64 {
+			v, ok := values[name]
+			if !ok {
+				return current
+			}
+			max := colorChannels[name].max
+			switch update {
+			case adjustColor:
+				if name == "hue" {
+					return current + v
+				}
+				return clamp(current+v, 0, max)
+			case scaleColor:
+				if v > 0 {
+					return current + (max-current)*v
+				}
+				return current + current*v
+			}
+			return v
+		}
+		alpha := apply("alpha", c.A)
+		switch space {
+		case "rgb":
+			r := apply("red", c.R*255)
+			g := apply("green", c.G*255)
+			b := apply("blue", c.B*255)
+			return &Color{Color: *css3.RGBA(r/255, g/255, b/255, alpha)}, nil
+		case "hsl":
+			h, s, l := c.hsl()
+			return newHSLColor(apply("hue", h), apply("saturation", s), apply("lightness", l), alpha), nil
+		case "hwb":
+			h, w, b := c.hwb()
+			return newHWBColor(h, apply("whiteness", w), apply("blackness", b), alpha), nil
+		}
+		return withAlpha(c, alpha), nil
+	}
+}
+
+// mixColors mixes two colors in the proportion weight of the first, taking
+// their alpha into account as Sass does.
+func mixColors(c1, c2 *Color, weight float64) *Color {
+	w := weight*2 - 1
+	a := c1.A - c2.A
+	var w1 float64
+	if w*a == -1 {
+		w1 = (w + 1) / 2
+	} else {
+		w1 = ((w+a)/(1+w*a) + 1) / 2
+	}
+	w2 := 1 - w1
+	return &Color{Color: *css3.RGBA(
+		c1.R*w1+c2.R*w2,
+		c1.G*w1+c2.G*w2,
+		c1.B*w1+c2.B*w2,
+		c1.A*weight+c2.A*(1-weight),
+	)}
+}
+
+// hslAdjuster returns a global function like lighten() that adjusts one
+// HSL channel of a color by an amount within 0-100%, negated if sign is
+// negative.
+func hslAdjuster(channel string, sign float64) Function {
+	return func(args []Value) (Value, error) {
+		c, err := ExpectColor(args[0], "color")
+		if err != nil {
+			return nil, err
+		}
+		amount, err := expectInRange(args[1], "amount", 0, 100, "%")
+		if err != nil {
+			return nil, err
+		}
+		h, s, l := c.hsl()
+		if channel == "saturation" {
+			s = clamp(s+sign*amount, 0, 100)
+		} else {
+			l = clamp(l+sign*amount, 0, 100)
+		}
+		return newHSLColor(h, s, l, c.A), nil
+	}
+}
+
+func alphaAdjuster(sign float64) Function {
+	return func(args []Value) (Value, error) {
+		c, err := ExpectColor(args[0], "color")
+		if err != nil {
+			return nil, err
+		}
+		amount, err := expectInRange(args[1], "amount", 0, 1, "")
+		if err != nil {
+			return nil, err
+		}
+		return withAlpha(c, c.A+sign*amount), nil
+	}
+}
+
+// channelGetter returns a function returning one channel of a color.
+func channelGetter(get func(c *Color) *Number) Function {
+	return func(args []Value) (Value, error) {
+		c, err := ExpectColor(args[0], "color")
+		if err != nil {
+			return nil, err
+		}
+		return get(c), nil
+	}
+}
+
+// filterOr returns a function that, when passed a number rather than a
+// color, is the CSS filter function of the same name.
+func filterOr(name string, fn Function) Function {
+	return func(args []Value) (Value, error) {
+		if n, ok := args[0].(*Number); ok {
+			return plainCall(name, []Value{n}), nil
+		}
+		return fn(args)
+	}
+}
+
+func init() {
+	for _, name := range []string{"rgb", "rgba"} {
+		defineGlobal(name+"($channels...)", rgbFunction(name))
+	}
+	for _, name := range []string{"hsl", "hsla"} {
+		defineGlobal(name+"($channels...)", hslFunction(name))
+	}
+
+	m := builtinModule("color")
+	m.define("red($color)", channelGetter(func(c *Color) *Number { return NewNumber(float64(c.Red()), "") }), "red")
+	m.define("green($color)", channelGetter(func(c *Color) *Number { return NewNumber(float64(c.Green()), "") }), "green")
+	m.define("blue($color)", channelGetter(func(c *Color) *Number { return NewNumber(float64(c.Blue()), "") }), "blue")
+	m.define("hue($color)", channelGetter(func(c *Color) *Number {
+		h, _, _ := c.hsl()
+		return NewNumber(h, "deg")
+	}), "hue")
+	m.define("saturation($color)", channelGetter(func(c *Color) *Number {
+		_, s, _ := c.hsl()
+		return NewNumber(s, "%")
+	}), "saturation")
+	m.define("lightness($color)", channelGetter(func(c *Color) *Number {
+		_, _, l := c.hsl()
+		return NewNumber(l, "%")
+	}), "lightness")
+	m.define("whiteness($color)", channelGetter(func(c *Color) *Number {
+		_, w, _ := c.hwb()
+		return NewNumber(w, "%")
+	}))
+	m.define("blackness($color)", channelGetter(func(c *Color) *Number {
+		_, _, b := c.hwb()
+		return NewNumber(b, "%")
+	}))
+	alpha := channelGetter(func(c *Color) *Number { return NewNumber(c.A, "") })
+	m.define("alpha($color)", alpha, "alpha")
+	m.define("opacity($color)", filterOr("opacity", alpha), "opacity")
+
+	m.define("hwb($hue, $whiteness, $blackness, $alpha: 1)", func(args []Value) (Value, error) {
+		h, err := expectHue(args[0], "hue")
+		if err != nil {
+			return nil, err
+		}
+		w, err := expectInRange(args[1], "whiteness", 0, 100, "%")
+		if err != nil {
+			return nil, err
+		}
+		b, err := expectInRange(args[2], "blackness", 0, 100, "%")
+		if err != nil {
+			return nil, err
+		}
+		a, err := channelValue(args[3], "alpha", 1)
+		if err != nil {
+			return nil, err
+		}
+		return newHWBColor(h, w, b, a), nil
+	})
+
+	m.define("adjust($color, $kwargs...)", updateColor(adjustColor), "adjust-color")
+	m.define("scale($color, $kwargs...)", updateColor(scaleColor), "scale-color")
+	m.define("change($color, $kwargs...)", updateColor(changeColor), "change-color")
+
+	m.define("mix($color1, $color2, $weight: 50%)", func(args []Value) (Value, error) {
+		c1, err := ExpectColor(args[0], "color1")
+		if err != nil {
+			return nil, err
+		}
+		c2, err := ExpectColor(args[1], "color2")
+		if err != nil {
+			return nil, err
+		}
+		weight, err := expectInRange(args[2], "weight", 0, 100, "%")
+		if err != nil {
+			return nil, err
+		}
+		return mixColors(c1, c2, weight/100), nil
+	}, "mix")
+
+	m.define("complement($color)", func(args []Value) (Value, error) {
+		c, err := ExpectColor(args[0], "color")
+		if err != nil {
+			return nil, err
+		}
+		h, s, l := c.hsl()
+		return newHSLColor(h+180, s, l, c.A), nil
+	}, "complement")
+
+	m.define("grayscale($color)", filterOr("grayscale", func(args []Value) (Value, error) {
+		c, err := ExpectColor(args[0], "color")
+		if err != nil {
+			return nil, err
+		}
+		h, _, l := c.hsl()
+		return newHSLColor(h, 0, l, c.A), nil
+	}), "grayscale")
+
+	m.define("invert($color, $weight: 100%)", func(args []Value) (Value, error) {
+		weight, err := expectInRange(args[1], "weight", 0, 100, "%")
+		if err != nil {
+			return nil, err
+		}
+		if n, ok := args[0].(*Number); ok {
+			if weight != 100 {
+				return nil, fmt.Errorf("Only one argument may be passed to the plain-CSS invert() function.")
+			}
+			return plainCall("invert", []Value{n}), nil
+		}
+		c, err := ExpectColor(args[0], "color")
+		if err != nil {
+			return nil, err
+		}
+		inverse := &Color{Color: *css3.RGBA(1-c.R, 1-c.G, 1-c.B, c.A)}
+		return mixColors(inverse, c, weight/100), nil
+	}, "invert")
+
+	m.define("ie-hex-str($color)", func(args []Value) (Value, error) {
+		c, err := ExpectColor(args[0], "color")
+		if err != nil {
+			return nil, err
+		}
+		a := int(math.Round(clamp(c.A, 0, 1) * 255))
+		return &String{Text: fmt.Sprintf("#%02X%02X%02X%02X", a, c.Red(), c.Green(), c.Blue())}, nil
+	}, "ie-hex-str")
+
+	m.define("contrast($color1, $color2)", func(args []Value) (Value, error) {
+		c1, err := ExpectColor(args[0], "color1")
+		if err != nil {
+			return nil, err
+		}
+		c2, err := ExpectColor(args[1], "color2")
+		if err != nil {
+			return nil, err
+		}
+		return NewNumber(c1.ContrastRatio(&c2.Color), ""), nil
+	})
+	m.define("most-readable($color, $candidates...)", func(args []Value) (Value, error) {
+		c, err := ExpectColor(args[0], "color")
+		if err != nil {
+			return nil, err
+		}
+		candidates := args[1].(*ArgList).Items
+		if len(candidates) == 1 {
+			if l, ok := candidates[0].(*List); ok {
+				candidates = l.Items
+			}
+		}
+		if len(candidates) == 0 {
+			return nil, fmt.Errorf("$candidates: At least one color must be passed.")
+		}
+		var best *Color
+		bestRatio := 0.0
+		for _, v := range candidates {
+			candidate, err := ExpectColor(v, "candidates")
+			if err != nil {
+				return nil, err
+			}
+			if ratio := c.ContrastRatio(&candidate.Color); ratio > bestRatio {
+				best, bestRatio = candidate, ratio
+			}
+		}
+		return best, nil
+	})
+
+	defineGlobal("lighten($color, $amount)", hslAdjuster("lightness", 1))
+	defineGlobal("darken($color, $amount)", hslAdjuster("lightness", -1))
+	defineGlobal("desaturate($color, $amount)", hslAdjuster("saturation", -1))
+	saturate := hslAdjuster("saturation", 1)
+	defineGlobal("saturate($color, $amount: null)", func(args []Value) (Value, error) {
+		if args[1] == Null {
+			if n, ok := args[0].(*Number); ok {
+				return plainCall("saturate", []Value{n}), nil
+			}
+			return nil, fmt.Errorf("Missing argument $amount.")
+		}
+		return saturate(args)
+	})
+	defineGlobal("adjust-hue($color, $degrees)", func(args []Value) (Value, error) {
+		c, err := ExpectColor(args[0], "color")
+		if err != nil {
+			return nil, err
+		}
+		degrees, err := expectHue(args[1], "degrees")
+		if err != nil {
+			return nil, err
+		}
+		h, s, l := c.hsl()
+		return newHSLColor(h+degrees, s, l, c.A), nil
+	})
+	for _, name := range []string{"opacify", "fade-in"} {
+		defineGlobal(name+"($color, $amount)", alphaAdjuster(1))
+	}
+	for _, name := range []string{"transparentize", "fade-out"} {
+		defineGlobal(name+"($color, $amount)", alphaAdjuster(-1))
+	}
+}
@@ -0,0 +1,111 @@
+package scss
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestColorFunctions(t *testing.T) {
+	eval := func(expr string) string {
+		result, err := CompileString("@use \"sass:color\";\na { b: "+expr+"; }", Options{})
+		if err != nil {
+			return err.Error()
+		}
+		return result.CSS[len("a {\n  b: ") : len(result.CSS)-len(";\n}")]
+	}
+
+	Convey("constructors", t, func() {
+		So(eval("rgb(255, 0, 0)"), ShouldEqual, "red")
+		So(eval("rgba(255, 0, 0, 0.5)"), ShouldEqual, "rgba(255, 0, 0, 0.5)")
+		So(eval("rgb(0 255 0 / 50%)"), ShouldEqual, "rgba(0, 255, 0, 0.5)")
+		So(eval("rgba(#336699, 0.4)"), ShouldEqual, "rgba(51, 102, 153, 0.4)")
+		So(eval("rgb(100%, 50%, 0%)"), ShouldEqual, "#ff8000")
+		So(eval("hsl(120, 100%, 25%)"), ShouldEqual, "green")
+		So(eval("hsla(0deg, 100%, 50%, 0.5)"), ShouldEqual, "rgba(255, 0, 0, 0.5)")
+		So(eval("color.hwb(0, 0%, 50%)"), ShouldEqual, "maroon")
+		So(eval("rgb($blue: 255, $green: 0, $red: 0)"), ShouldEqual, "blue")
+		So(eval("rgb(var(--r), 0, 0)"), ShouldEqual, "rgb(var(--r), 0, 0)")
+		So(eval("rgb(0 0 0 / var(--a))"), ShouldEqual, "rgb(0 0 0 / var(--a))")
+		So(eval("rgb(none 20 30)"), ShouldEqual, "rgb(none 20 30)")
+		So(eval("hsl(var(--h) 50% 50%)"), ShouldEqual, "hsl(var(--h) 50% 50%)")
+	})
+
+	Convey("color-mix() and relative colors", t, func() {
+		So(eval("color-mix(in srgb, red 40%, blue)"), ShouldEqual, "#660099")
+		So(eval("color-mix(in oklch, #336699, white)"), ShouldEqual, "#97b1cd")
+		So(eval("color-mix(in srgb, red, var(--x))"), ShouldEqual, "color-mix(in srgb, red, var(--x))")
+		So(eval("color-mix(in oklch, red, lime)"), ShouldEqual, "oklch(0.747197 0.276255 85.864613)")
+		So(eval("color-mix(in oklch, red 40%, blue)"), ShouldEqual, "oklch(0.52239 0.291002 314.124766)")
+		So(eval("color-mix(in lab, red, blue)"), ShouldEqual, "lab(41.929422 74.546146 -21.069372)")
+		So(eval("rgb(from #336699 b g r)"), ShouldEqual, "#996633")
+		So(eval("rgb(from red r g b / 50%)"), ShouldEqual, "rgba(255, 0, 0, 0.5)")
+		So(eval("hsl(from red h s 25%)"), ShouldEqual, "maroon")
+		So(eval("hwb(from red h 0% 50%)"), ShouldEqual, "maroon")
+		So(eval("rgb(from var(--x) r g b)"), ShouldEqual, "rgb(from var(--x) r g b)")
+		So(eval("rgb(from var(--x) r g b / 50%)"), ShouldEqual, "rgb(from var(--x) r g b / 50%)")
+		So(eval("lab(from var(--x) l a b / 50%)"), ShouldEqual, "lab(from var(--x) l a b / 50%)")
+		So(eval("hsl(from #336699 calc(h + 180) s l)"), ShouldEqual, "#996633")
+		So(eval("rgb(from red r g b / calc(alpha / 2))"), ShouldEqual, "rgba(255, 0, 0, 0.5)")
+		So(eval("rgb(from red calc(r * 2) g b)"), ShouldEqual, "color(srgb 2 0 0)")
+	})
+
+	Convey("channels", t, func() {
+		So(eval("red(#336699)"), ShouldEqual, "51")
+		So(eval("color.green(#336699)"), ShouldEqual, "102")
+		So(eval("blue(#336699)"), ShouldEqual, "153")
+		So(eval("hue(#336699)"), ShouldEqual, "210deg")
+		So(eval("saturation(#336699)"), ShouldEqual, "50%")
+		So(eval("lightness(#336699)"), ShouldEqual, "40%")
+		So(eval("color.whiteness(#336699)"), ShouldEqual, "20%")
+		So(eval("color.blackness(#336699)"), ShouldEqual, "40%")
+		So(eval("alpha(rgba(0, 0, 0, 0.25))"), ShouldEqual, "0.25")
+		So(eval("opacity(50%)"), ShouldEqual, "opacity(50%)")
+	})
+
+	Convey("contrast", t, func() {
+		So(eval("color.contrast(black, white)"), ShouldEqual, "21")
+		So(eval("color.contrast(#777, white)"), ShouldEqual, "4.4780894536")
+		So(eval("color.contrast(red, red)"), ShouldEqual, "1")
+		So(eval("color.most-readable(#336699, #fff, #000, #69c)"), ShouldEqual, "#fff")
+		So(eval("color.most-readable(#eee, (#fff, #000, #69c))"), ShouldEqual, "#000")
+		So(eval("color.most-readable(#eee, 1)"), ShouldEqual, "stdin:2:8: $candidates: 1 is not a color.")
+		So(eval("color.most-readable(#eee)"), ShouldEqual, "stdin:2:8: $candidates: At least one color must be passed.")
+	})
+
+	Convey("adjusting", t, func() {
+		So(eval("lighten(#336699, 20%)"), ShouldEqual, "#6699cc")
+		So(eval("darken(#336699, 20%)"), ShouldEqual, "#1a334d")
+		So(eval("saturate(#336699, 20%)"), ShouldEqual, "#1f66ad")
+		So(eval("desaturate(#336699, 20%)"), ShouldEqual, "#476685")
+		So(eval("adjust-hue(#336699, 180deg)"), ShouldEqual, "#996633")
+		So(eval("complement(#336699)"), ShouldEqual, "#996633")
+		So(eval("grayscale(#336699)"), ShouldEqual, "#666666")
+		So(eval("invert(#336699)"), ShouldEqual, "#cc9966")
+		So(eval("transparentize(#336699, 0.5)"), ShouldEqual, "rgba(51, 102, 153, 0.5)")
+		So(eval("opacify(rgba(#336699, 0.5), 0.25)"), ShouldEqual, "rgba(51, 102, 153, 0.75)")
+		So(eval("mix(#ff0000, #0000ff)"), ShouldEqual, "purple")
+		So(eval("mix(#ff0000, #0000ff, 25%)"), ShouldEqual, "#4000bf")
+		So(eval("mix(rgba(255, 0, 0, 0.5), #0000ff)"), ShouldEqual, "rgba(64, 0, 191, 0.75)")
+		So(eval("ie-hex-str(rgba(#336699, 0.5))"), ShouldEqual, "#80336699")
+	})
+
+	Convey("adjust, scale and change", t, func() {
+		So(eval("color.adjust(#336699, $red: 10, $blue: -200)"), ShouldEqual, "#3d6600")
+		So(eval("color.adjust(#336699, $hue: 30deg)"), ShouldEqual, "#333399")
+		So(eval("adjust-color(#336699, $alpha: -0.5)"), ShouldEqual, "rgba(51, 102, 153, 0.5)")
+		So(eval("color.scale(#336699, $lightness: 50%)"), ShouldEqual, "#8cb3d9")
+		So(eval("color.scale(#336699, $red: -100%)"), ShouldEqual, "#006699")
+		So(eval("color.change(#336699, $saturation: 0%)"), ShouldEqual, "#666666")
+		So(eval("color.change(#336699, $whiteness: 0%)"), ShouldEqual, "#004d99")
+
+		So(eval("color.adjust(#336699, $red: 10, $hue: 10deg)"), ShouldEqual,
+			"stdin:2:8: RGB parameters may not be passed along with HSL parameters.")
+		So(eval("color.scale(#336699, $red: 10)"), ShouldEqual,
+			"stdin:2:8: $red: Expected 10 to have unit \"%\".")
+		So(eval("lighten(#336699, 120%)"), ShouldEqual,
+			"stdin:2:8: $amount: Expected 120% to be within 0% and 100%.")
+		So(eval("color.adjust(#336699, 10)"), ShouldEqual,
+			"stdin:2:8: Only one positional argument is allowed. All other arguments must be passed by name.")
+	})
+}
@@ -0,0 +1,167 @@
+package scss
+
+import (
+	"net/http"
+)
+
+type OutputStyle int
+
+const (
+	Expanded OutputStyle = iota
+	Compressed
+)
+
+// Importer loads stylesheets that aren't found on the file system. Import
+// returns the canonical name of the stylesheet url refers to, loaded from
+// the stylesheet named prev, and its contents. An empty name means the
+// importer doesn't recognize url.
+type Importer interface {
+	Import(url, prev string) (name string, contents []byte, err error)
+}
+
+type ImporterFunc func(url, prev string) (string, []byte, error)
+
+func (f ImporterFunc) Import(url, prev string) (string, []byte, error) { return f(url, prev) }
+
+type Options struct {
+	OutputStyle OutputStyle
+
+	// LoadPaths are directories of the file system searched for stylesheets
+	// that aren't found relative to the stylesheet loading them.
+	LoadPaths []string
+
+	// Importers are consulted, in order, for stylesheets that aren't found
+	// relative to the stylesheet loading them, before LoadPaths.
+	Importers []Importer
+
+	// Functions are Go functions callable from stylesheets, keyed by their
+	// signatures, as in "asset-url($path, $version: null)". They override
+	// built-in functions of the same name. A function whose key is just its
+	// name takes any number of positional arguments.
+	Functions map[string]Function
+
+	// Logger receives the messages of @debug and @warn rules and
+	// deprecation warnings. If nil, they're written to standard error.
+	Logger Logger
+
+	// OmitCharset suppresses the @charset rule, or in compressed output the
+	// byte-order mark, otherwise emitted when the CSS isn't plain ASCII.
+	OmitCharset bool
+
+	// SourceMap requests a Source Map v3 document in Result.SourceMap,
+	// mapping each rule and declaration to the stylesheet it came from.
+	SourceMap bool
+
+	// SourceMapContents includes the text of every stylesheet in the
+	// source map, so that it can be used without them.
+	SourceMapContents bool
+
+	// SourceMapRoot is prepended by source map consumers to the names of
+	// the stylesheets.
+	SourceMapRoot string
+
+	// SourceMapURL is where the source map will be served from. If set, a
+	// sourceMappingURL comment referring to it ends the CSS.
+	SourceMapURL string
+
+	// SourceMapEmbed ends the CSS with a sourceMappingURL comment holding
+	// the whole source map as a data URI, instead of SourceMapURL.
+	SourceMapEmbed bool
+
+	// CheckContrast, if set, warns about style rules whose color and
+	// background-color don't contrast as much as the WCAG level requires.
+	CheckContrast ContrastLevel
+}
+
+type Result struct {
+	CSS string
+
+	// LoadedFiles are the names of every stylesheet loaded, starting with
+	// the entry stylesheet.
+	LoadedFiles []string
+
+	Warnings []*Warning
+
+	// SourceMap is the JSON source map, if Options.SourceMap was set.
+	SourceMap string
+}
+
+// Compile compiles the stylesheet with the given name in fs.
+func Compile(fs http.FileSystem, name string, opts Options) (*Result, error) {
+	f, err := fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	file, err := openFile(fs, f, name)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	return compile(fs, &loadedFile{name: name, src: file.Bytes}, &opts)
+}
+
+// CompileString compiles the stylesheet src. Since it has no location, the
+// stylesheets it loads are found by importers only.
+func CompileString(src string, opts Options) (*Result, error) {
+	return compile(nil, &loadedFile{name: "stdin", src: []byte(src)}, &opts)
+}
+
+func compile(fs http.FileSystem, f *loadedFile, opts *Options) (result *Result, err error) {
+	defer recoverError(&err)
+	e := newEvaluator(fs, opts)
+	e.fromImporter[f.name] = fs == nil
+	e.run(f)
+	if opts.CheckContrast != NoContrastCheck {
+		e.checkContrast(opts.CheckContrast)
+	}
+	result = &Result{LoadedFiles: e.loadedFiles, Warnings: e.warnings}
+	result.CSS, result.SourceMap = e.output()
+	return result, nil
+}
+
+// output returns the CSS and, if requested, its source map.
+func (e *evaluator) output() (string, string) {
+	sourceMap := e.opts.SourceMap || e.opts.SourceMapEmbed
+	s := &serializer{compressed: e.compressed, sourceMap: sourceMap}
+	for _, n := range e.imports {
+		s.writeNode(n, 0)
+		if !s.compressed {
+			s.WriteByte('\n')
+		}
+	}
+	s.writeRoot(e.root)
+	css := s.String()
+	if !e.opts.OmitCharset && !isASCII(css) {
+		if e.compressed {
+			css = "\ufeff" + css
+			for i := 0; i < len(s.mappings) && s.mappings[i].line == 0; i++ {
+				s.mappings[i].column++
+			}
+		} else {
+			css = "@charset \"UTF-8\";\n" + css
+			for i := range s.mappings {
+				s.mappings[i].line++
+			}
+		}
+	}
+	if !sourceMap {
+		return css, ""
+	}
+	js := e.buildSourceMap(s.mappings)
+	if comment := e.sourceMappingURL(js); comment != "" {
+		if !e.compressed {
+			css += "\n"
+		}
+		css += "\n" + comment
+	}
+	return css, js
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
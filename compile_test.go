@@ -0,0 +1,168 @@
+package scss
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCompile(t *testing.T) {
+	shouldCompileTo := func(actual interface{}, expected ...interface{}) string {
+		result, err := CompileString(actual.(string), Options{})
+		if msg := ShouldBeNil(err); msg != "" {
+			return msg
+		}
+		return ShouldEqual(result.CSS, expected[0])
+	}
+
+	Convey("style rules", t, func() {
+		So("a { color: red; }", shouldCompileTo, "a {\n  color: red;\n}")
+		So("a { b { c: d; } }", shouldCompileTo, "a b {\n  c: d;\n}")
+		So("a { &:hover { c: d; } }", shouldCompileTo, "a:hover {\n  c: d;\n}")
+		So("a, b { c, d { e: f; } }", shouldCompileTo, "a c, a d, b c, b d {\n  e: f;\n}")
+		So("a { font: { family: x; size: 1px; } }", shouldCompileTo,
+			"a {\n  font-family: x;\n  font-size: 1px;\n}")
+	})
+
+	Convey("custom properties", t, func() {
+		So("$x: 1px; a { --a: #{$x}  $x + 1 ; --b:{ c: d }; }", shouldCompileTo,
+			"a {\n  --a: 1px  $x + 1;\n  --b:{ c: d };\n}")
+		So("a { --a: var(--b, #{1 + 1}); }", shouldCompileTo, "a {\n  --a: var(--b, 2);\n}")
+	})
+
+	Convey("url()", t, func() {
+		So("a { b: url( x.png ); }", shouldCompileTo, "a {\n  b: url(x.png);\n}")
+		So(`$p: "/img"; a { b: url($p + "/x.png"); }`, shouldCompileTo, "a {\n  b: url(\"/img/x.png\");\n}")
+		So(`$p: "/img"; a { b: url(#{$p}/x.png); }`, shouldCompileTo, "a {\n  b: url(/img/x.png);\n}")
+		So(`$u: "a.js"; a { b: url(//cdn/#{$u}) c; }`, shouldCompileTo, "a {\n  b: url(//cdn/a.js) c;\n}")
+		So(`$p: "/img"; a { b: url(#{if(true, $p, x)}/y.png); }`, shouldCompileTo, "a {\n  b: url(/img/y.png);\n}")
+	})
+
+	Convey("variables, mixins and functions", t, func() {
+		So("$x: 1px; a { b: $x * 2; }", shouldCompileTo, "a {\n  b: 2px;\n}")
+		So("@mixin m($a, $b: 2) { c: $a $b; } a { @include m(1); }", shouldCompileTo, "a {\n  c: 1 2;\n}")
+		So("@function f($n) { @return $n + 1; } a { b: f(1); }", shouldCompileTo, "a {\n  b: 2;\n}")
+		So("@mixin m { a { @content; } } @include m { b: c; }", shouldCompileTo, "a {\n  b: c;\n}")
+	})
+
+	Convey("control flow", t, func() {
+		So("@each $i in a, b { .#{$i} { x: y; } }", shouldCompileTo, ".a {\n  x: y;\n}\n\n.b {\n  x: y;\n}")
+		So("@for $i from 1 to 3 { a { b: $i; } }", shouldCompileTo, "a {\n  b: 1;\n}\n\na {\n  b: 2;\n}")
+		So("@if 1 > 2 { a { b: c; } } @else { d { e: f; } }", shouldCompileTo, "d {\n  e: f;\n}")
+	})
+
+	Convey("extend", t, func() {
+		So("%p { a: b; } .x { @extend %p; }", shouldCompileTo, ".x {\n  a: b;\n}")
+		So(".a { b: c; } .d { @extend .a; }", shouldCompileTo, ".a, .d {\n  b: c;\n}")
+	})
+
+	Convey("media", t, func() {
+		So("a { @media screen { b: c; } }", shouldCompileTo, "@media screen {\n  a {\n    b: c;\n  }\n}")
+	})
+
+	Convey("comments", t, func() {
+		So("a { b: c; // d\n  e: f; }", shouldCompileTo, "a {\n  b: c;\n  e: f;\n}")
+		So("// a { b: c; }\nd { e: url(http://x/y); f: \"//g\"; }", shouldCompileTo,
+			"d {\n  e: url(http://x/y);\n  f: \"//g\";\n}")
+		So("/* a */\nb { /* c */ d: e /* f */; }", shouldCompileTo, "/* a */\nb {\n  /* c */\n  d: e;\n}")
+		So("$x: 2; /* x is #{$x * 2} */", shouldCompileTo, "/* x is 4 */")
+		So("a {\n  b: c;\n    /* d\n       e */\n}", shouldCompileTo, "a {\n  b: c;\n  /* d\n     e */\n}")
+		So("@function f() { /* x */ @return 1; } a { b: f(); }", shouldCompileTo, "a {\n  b: 1;\n}")
+
+		result, err := CompileString("/*! keep */ /* drop */ a { /*! b */ c: d; }", Options{OutputStyle: Compressed})
+		So(err, ShouldBeNil)
+		So(result.CSS, ShouldEqual, "/*! keep */a{/*! b */c:d}")
+	})
+
+	Convey("output style", t, func() {
+		result, err := CompileString("a { b: 0.5; c: red; }", Options{OutputStyle: Compressed})
+		So(err, ShouldBeNil)
+		So(result.CSS, ShouldEqual, "a{b:.5;c:red}")
+
+		result, err = CompileString("a { b: #ff0000 #ffffff #aabbcc darkgoldenrod rgba(0, 0, 0, 0); c: #FF0000; }", Options{OutputStyle: Compressed})
+		So(err, ShouldBeNil)
+		So(result.CSS, ShouldEqual, "a{b:red #fff #abc #b8860b #0000;c:red}")
+		So("a { b: #FF0000 darkgoldenrod rgba(0, 0, 0, 0); }", shouldCompileTo, "a {\n  b: #FF0000 darkgoldenrod rgba(0, 0, 0, 0);\n}")
+	})
+
+	Convey("charset", t, func() {
+		So(`a { b: "é"; }`, shouldCompileTo, "@charset \"UTF-8\";\na {\n  b: \"é\";\n}")
+		result, err := CompileString(`a { b: "é"; }`, Options{OmitCharset: true})
+		So(err, ShouldBeNil)
+		So(result.CSS, ShouldEqual, "a {\n  b: \"é\";\n}")
+	})
+
+	Convey("loading files", t, func() {
+		fs := mapFS{
+			"main.scss":        `@use "lib/vars"; @import "b"; a { c: vars.$x; }`,
+			"lib/_vars.scss":   `$x: 1px;`,
+			"_b.scss":          `b { d: e; }`,
+			"shared/_mix.scss": `@mixin m { f: g; }`,
+			"other.scss":       `@use "mix"; h { @include mix.m; }`,
+		}
+		result, err := Compile(fs, "main.scss", Options{})
+		So(err, ShouldBeNil)
+		So(result.CSS, ShouldEqual, "b {\n  d: e;\n}\n\na {\n  c: 1px;\n}")
+		So(result.LoadedFiles, ShouldResemble, []string{"main.scss", "lib/_vars.scss", "_b.scss"})
+
+		result, err = Compile(fs, "other.scss", Options{LoadPaths: []string{"shared"}})
+		So(err, ShouldBeNil)
+		So(result.CSS, ShouldEqual, "h {\n  f: g;\n}")
+
+		_, err = Compile(fs, "missing.scss", Options{})
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("importers", t, func() {
+		importer := ImporterFunc(func(url, prev string) (string, []byte, error) {
+			switch url {
+			case "theme":
+				return "theme.scss", []byte("$c: blue;"), nil
+			case "broken":
+				return "", nil, errors.New("broken")
+			}
+			return "", nil, nil
+		})
+		result, err := CompileString(`@use "theme"; a { b: theme.$c; }`, Options{Importers: []Importer{importer}})
+		So(err, ShouldBeNil)
+		So(result.CSS, ShouldEqual, "a {\n  b: blue;\n}")
+		So(result.LoadedFiles, ShouldResemble, []string{"stdin", "theme.scss"})
+
+		_, err = CompileString(`@use "broken";`, Options{Importers: []Importer{importer}})
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("custom functions", t, func() {
+		double := func(args []Value) (Value, error) {
+			n, ok := args[0].(*Number)
+			if !ok {
+				return nil, errors.New("not a number")
+			}
+			return NewNumber(n.Value*2, n.Unit()), nil
+		}
+		opts := Options{Functions: map[string]Function{"double": double}}
+		result, err := CompileString("a { b: double(2px); }", opts)
+		So(err, ShouldBeNil)
+		So(result.CSS, ShouldEqual, "a {\n  b: 4px;\n}")
+
+		_, err = CompileString("a {\n  b: double(c);\n}", opts)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "stdin:2:6: not a number")
+	})
+
+	Convey("errors", t, func() {
+		_, err := CompileString("a {\n  b: $undefined;\n}", Options{})
+		So(err, ShouldNotBeNil)
+		e, ok := err.(*Error)
+		So(ok, ShouldBeTrue)
+		So(e.Line, ShouldEqual, 2)
+		So(e.Column, ShouldEqual, 6)
+	})
+
+	Convey("warnings", t, func() {
+		result, err := CompileString("$x: 1; a { b: $x / 2; }", Options{Logger: SilentLogger})
+		So(err, ShouldBeNil)
+		So(len(result.Warnings), ShouldEqual, 1)
+	})
+}
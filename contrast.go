@@ -0,0 +1,68 @@
+package scss
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/logan/scss/css3"
+)
+
+// ContrastLevel is a WCAG 2 conformance level for the contrast of text.
+type ContrastLevel int
+
+const (
+	NoContrastCheck ContrastLevel = iota
+	ContrastAA
+	ContrastAAA
+)
+
+func (l ContrastLevel) String() string {
+	if l == ContrastAAA {
+		return "AAA"
+	}
+	return "AA"
+}
+
+func (l ContrastLevel) minimum() float64 {
+	if l == ContrastAAA {
+		return css3.ContrastAAA
+	}
+	return css3.ContrastAA
+}
+
+// declaredColor returns the color of the last declaration of the property
+// name in rule, if it's an opaque color known statically.
+func declaredColor(rule *cssNode, name string) (*css3.Color, *cssNode) {
+	for i := len(rule.children) - 1; i >= 0; i-- {
+		n := rule.children[i]
+		if n.kind != declNode || !strings.EqualFold(n.name, name) {
+			continue
+		}
+		c := css3.ColorFromString(n.value)
+		if c == nil || c.CurrentColor || c.A < 1 || c.Missing[3] {
+			return nil, nil
+		}
+		return c, n
+	}
+	return nil, nil
+}
+
+// checkContrast warns about the style rules whose color and
+// background-color contrast less than level requires.
+func (e *evaluator) checkContrast(level ContrastLevel) {
+	e.walkRules(e.root, func(n *cssNode) {
+		fg, decl := declaredColor(n, "color")
+		bg, bgDecl := declaredColor(n, "background-color")
+		if fg == nil || bg == nil {
+			return
+		}
+		if ratio := fg.ContrastRatio(bg); ratio < level.minimum() {
+			e.logWarning(&Warning{
+				Message: fmt.Sprintf("Contrast ratio %s:1 between color %s and background-color %s is below the WCAG %s minimum of %s:1.",
+					formatNumber(math.Floor(ratio*100)/100, false), decl.value, bgDecl.value, level, formatNumber(level.minimum(), false)),
+				Location: decl.loc,
+			})
+		}
+	})
+}
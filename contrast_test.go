@@ -0,0 +1,42 @@
+package scss
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCheckContrast(t *testing.T) {
+	check := func(src string, level ContrastLevel) []string {
+		result, err := CompileString(src, Options{CheckContrast: level, Logger: SilentLogger})
+		if err != nil {
+			return []string{err.Error()}
+		}
+		messages := []string{}
+		for _, w := range result.Warnings {
+			messages = append(messages, w.String())
+		}
+		return messages
+	}
+
+	Convey("Low contrast rules are flagged", t, func() {
+		src := "a {\n  color: #777;\n  background-color: white;\n}\nb { color: #595959; background-color: #fff; }"
+		So(check(src, NoContrastCheck), ShouldResemble, []string{})
+		So(check(src, ContrastAA), ShouldResemble, []string{
+			"stdin:2:3: Contrast ratio 4.47:1 between color #777 and background-color white is below the WCAG AA minimum of 4.5:1.",
+		})
+		So(check(src, ContrastAAA), ShouldResemble, []string{
+			"stdin:2:3: Contrast ratio 4.47:1 between color #777 and background-color white is below the WCAG AAA minimum of 7:1.",
+		})
+	})
+
+	Convey("Only known, opaque pairs in the same rule are checked", t, func() {
+		So(check("a { color: #999; b { background-color: #fff; } }", ContrastAA), ShouldResemble, []string{})
+		So(check("a { color: var(--fg); background-color: #fff; }", ContrastAA), ShouldResemble, []string{})
+		So(check("a { color: rgba(0, 0, 0, 0.2); background-color: #fff; }", ContrastAA), ShouldResemble, []string{})
+		So(check("a { color: #999; background-color: #fff; color: #000; }", ContrastAA), ShouldResemble, []string{})
+		So(check("$fg: darken(white, 10%); a { color: $fg; background-color: white !important; }", ContrastAA), ShouldResemble, []string{
+			"stdin:1:30: Contrast ratio 1.24:1 between color #e6e6e6 and background-color white !important is below the WCAG AA minimum of 4.5:1.",
+		})
+	})
+}
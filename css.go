@@ -0,0 +1,234 @@
+package scss
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/logan/scss/css3"
+)
+
+type cssKind int
+
+const (
+	rootNode cssKind = iota
+	ruleNode
+	declNode
+	atRuleNode
+	mediaNode
+	importNode
+	commentNode
+)
+
+// cssNode is a node of the CSS output tree.
+type cssNode struct {
+	kind     cssKind
+	parent   *cssNode
+	children []*cssNode
+	loc      Location
+
+	// selector is the selector of a style rule. Rules within @keyframes
+	// have their raw selector in value instead.
+	selector css3.SelectorList
+
+	// name is the name of a declaration or at-rule, and value is the
+	// declaration's value, the at-rule's prelude, the media query or the
+	// text of a comment.
+	name  string
+	value string
+
+	// media is the media query list a style rule is nested in, which
+	// determines which @extend rules apply to it.
+	media []string
+
+	hasBody  bool
+	groupEnd bool
+}
+
+func (n *cssNode) add(child *cssNode) *cssNode {
+	child.parent = n
+	n.children = append(n.children, child)
+	return child
+}
+
+// copyRule returns an empty style rule with the same selector as n, used
+// to hold declarations in rules like @media that bubble out of n.
+func (n *cssNode) copyRule() *cssNode {
+	return &cssNode{kind: ruleNode, selector: n.selector, value: n.value, media: n.media, loc: n.loc}
+}
+
+// invisible reports whether a node produces no output. Style rules and
+// media rules without visible children are omitted.
+func (n *cssNode) invisible() bool {
+	switch n.kind {
+	case ruleNode:
+		if n.selector != nil && len(n.selector) == 0 {
+			return true
+		}
+	case mediaNode:
+	default:
+		return false
+	}
+	for _, child := range n.children {
+		if !child.invisible() {
+			return false
+		}
+	}
+	return true
+}
+
+type serializer struct {
+	bytes.Buffer
+	compressed bool
+
+	// sourceMap enables recording where each node's output starts. line
+	// and column are the position at the end of the first scanned bytes.
+	sourceMap    bool
+	mappings     []mapping
+	scanned      int
+	line, column int
+}
+
+// mark records that the output about to be written was generated from loc.
+func (s *serializer) mark(loc Location) {
+	if !s.sourceMap || loc.Line == 0 {
+		return
+	}
+	b := s.Bytes()[s.scanned:]
+	for i := bytes.IndexByte(b, '\n'); i >= 0; i = bytes.IndexByte(b, '\n') {
+		s.line++
+		s.column = 0
+		b = b[i+1:]
+	}
+	s.column += utf16Len(b)
+	s.scanned = s.Len()
+	s.mappings = append(s.mappings, mapping{line: s.line, column: s.column, loc: loc})
+}
+
+func (s *serializer) indent(depth int) {
+	if !s.compressed {
+		s.WriteString(strings.Repeat("  ", depth))
+	}
+}
+
+func (s *serializer) space() {
+	if !s.compressed {
+		s.WriteByte(' ')
+	}
+}
+
+func (s *serializer) writeNode(n *cssNode, depth int) {
+	s.indent(depth)
+	s.mark(n.loc)
+	switch n.kind {
+	case declNode:
+		s.WriteString(n.name)
+		s.WriteByte(':')
+		if !strings.HasPrefix(n.name, "--") || n.value == "" {
+			s.space()
+		}
+		s.WriteString(n.value)
+		return
+	case commentNode:
+		s.writeComment(n, depth)
+		return
+	case importNode:
+		s.WriteString("@import ")
+		s.WriteString(n.value)
+		s.WriteByte(';')
+		return
+	case ruleNode:
+		if n.selector != nil {
+			s.WriteString(n.selector.Format(s.compressed))
+		} else {
+			s.WriteString(n.value)
+		}
+	case mediaNode:
+		s.WriteString("@media ")
+		s.WriteString(n.value)
+	case atRuleNode:
+		s.WriteByte('@')
+		s.WriteString(n.name)
+		if n.value != "" {
+			s.WriteByte(' ')
+			s.WriteString(n.value)
+		}
+		if !n.hasBody {
+			s.WriteByte(';')
+			return
+		}
+	}
+	s.space()
+	s.WriteByte('{')
+	s.writeChildren(n, depth+1)
+	s.WriteByte('}')
+}
+
+// writeComment writes a comment, reindenting the lines after the first to
+// keep their indentation relative to the comment's start.
+func (s *serializer) writeComment(n *cssNode, depth int) {
+	lines := strings.Split(n.value, "\n")
+	if s.compressed || len(lines) == 1 {
+		s.WriteString(n.value)
+		return
+	}
+	strip := n.loc.Column - 1
+	for _, line := range lines[1:] {
+		if trimmed := strings.TrimLeft(line, " "); trimmed != "" {
+			strip = min(strip, len(line)-len(trimmed))
+		}
+	}
+	s.WriteString(lines[0])
+	for _, line := range lines[1:] {
+		s.WriteByte('\n')
+		if strings.TrimLeft(line, " ") != "" {
+			s.indent(depth)
+			s.WriteString(line[max(strip, 0):])
+		}
+	}
+}
+
+func (s *serializer) writeChildren(n *cssNode, depth int) {
+	var prev *cssNode
+	for _, child := range n.children {
+		if child.invisible() {
+			continue
+		}
+		if prev != nil && prev.kind == declNode {
+			s.WriteByte(';')
+		}
+		if !s.compressed {
+			s.WriteByte('\n')
+		}
+		s.writeNode(child, depth)
+		prev = child
+	}
+	if prev == nil {
+		return
+	}
+	if !s.compressed {
+		if prev.kind == declNode {
+			s.WriteByte(';')
+		}
+		s.WriteByte('\n')
+		s.indent(depth - 1)
+	}
+}
+
+// writeRoot writes the top-level nodes, separating groups of nodes that came
+// from different top-level statements with blank lines.
+func (s *serializer) writeRoot(root *cssNode) {
+	var prev *cssNode
+	for _, child := range root.children {
+		if child.invisible() {
+			continue
+		}
+		if prev != nil && !s.compressed {
+			s.WriteByte('\n')
+			if prev.groupEnd {
+				s.WriteByte('\n')
+			}
+		}
+		s.writeNode(child, 0)
+		prev = child
+	}
+}
@@ -0,0 +1,624 @@
+package css3
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	BadPreludeErr        = errors.New("invalid at-rule prelude")
+	UnknownDescriptorErr = errors.New("unknown descriptor")
+	BadDescriptorErr     = errors.New("invalid descriptor")
+	MissingDescriptorErr = errors.New("missing descriptor")
+	BadKeyframeErr       = errors.New("invalid keyframe")
+)
+
+// The at-rules below arrive as AtRuleNodes, whose bodies are parsed as
+// component values since their meaning depends on the rule. The functions
+// that interpret them return what they could make sense of along with an
+// error for each part that was invalid and left out, as a browser would.
+
+// parseRuleBody parses the body of an at-rule as the contents of a block:
+// declarations, at-rules and qualified rules.
+func parseRuleBody(body []Node) ([]Node, []error) {
+	p := nodeParser(body)
+	nodes := p.ParseDeclarationList()
+	var errs []error
+	for _, d := range p.Diagnostics() {
+		errs = append(errs, fmt.Errorf("%w: %s", d.Err, d.Message))
+	}
+	return nodes, errs
+}
+
+// ruleDeclarations returns the declarations in the body of an at-rule,
+// with an error for anything else.
+func ruleDeclarations(body []Node, in string) ([]*DeclarationNode, []error) {
+	nodes, errs := parseRuleBody(body)
+	var decls []*DeclarationNode
+	for _, n := range nodes {
+		if d, ok := n.(*DeclarationNode); ok {
+			decls = append(decls, d)
+		} else {
+			errs = append(errs, fmt.Errorf("%w: only declarations are allowed in %s", SyntaxErr, in))
+		}
+	}
+	return decls, errs
+}
+
+func badDescriptor(d *DeclarationNode) error {
+	return fmt.Errorf("%w: %q", BadDescriptorErr, d.Name+": "+d.ValueText())
+}
+
+// cssWideKeywords can't be used as names, such as those of font families
+// or keyframes.
+var cssWideKeywords = map[string]bool{
+	"initial": true, "inherit": true, "unset": true, "revert": true, "revert-layer": true, "default": true,
+}
+
+// FontFaceRule is an @font-face rule. Descriptors holds all its valid
+// descriptors in order, including those that are also broken out.
+type FontFaceRule struct {
+	Family        string
+	Sources       []FontSource
+	UnicodeRanges []UnicodeRange
+	Descriptors   []*DeclarationNode
+}
+
+// FontSource is one of the fonts listed by an @font-face src descriptor:
+// either a URL, with any format() and tech() hints, or the name of a font
+// given by local().
+type FontSource struct {
+	URL     string
+	Formats []string
+	Techs   []string
+	Local   string
+}
+
+// fontFaceDescriptors are the descriptors allowed in @font-face, with a
+// function that checks their values, or nil if they aren't checked.
+var fontFaceDescriptors = map[string]func([]Node) bool{
+	"font-family":             nil,
+	"src":                     nil,
+	"unicode-range":           nil,
+	"font-style":              validFontStyle,
+	"font-weight":             rangeOf(validFontWeight),
+	"font-stretch":            rangeOf(validFontStretch),
+	"font-display":            keywordOf("auto", "block", "swap", "fallback", "optional"),
+	"font-feature-settings":   nil,
+	"font-variation-settings": nil,
+	"font-named-instance":     nil,
+	"font-language-override":  nil,
+	"ascent-override":         overrideValue,
+	"descent-override":        overrideValue,
+	"line-gap-override":       overrideValue,
+	"size-adjust":             overrideValue,
+}
+
+// ParseFontFace interprets an @font-face rule. Its font-family and src
+// descriptors are required.
+func ParseFontFace(rule *AtRuleNode) (*FontFaceRule, []error) {
+	var errs []error
+	if len(trimWhitespace(rule.Prelude)) > 0 {
+		errs = append(errs, fmt.Errorf("%w: @font-face takes no prelude", BadPreludeErr))
+	}
+	decls, bodyErrs := ruleDeclarations(rule.Body, "@font-face")
+	errs = append(errs, bodyErrs...)
+	ff := &FontFaceRule{}
+	for _, d := range decls {
+		name := toLower(d.Name)
+		check, known := fontFaceDescriptors[name]
+		var family string
+		var sources []FontSource
+		var ranges []UnicodeRange
+		valid := true
+		switch {
+		case !known:
+			errs = append(errs, fmt.Errorf("%w: %q in @font-face", UnknownDescriptorErr, d.Name))
+			continue
+		case d.Important:
+			errs = append(errs, fmt.Errorf("%w: !important isn't allowed in @font-face", BadDescriptorErr))
+			continue
+		case name == "font-family":
+			family, valid = familyName(splitArguments(d.Values))
+		case name == "src":
+			var srcErrs []error
+			sources, srcErrs = fontSources(d.Values)
+			errs = append(errs, srcErrs...)
+			valid = len(sources) > 0
+		case name == "unicode-range":
+			ranges, valid = unicodeRanges(d.Values)
+		case check != nil:
+			args := splitArguments(d.Values)
+			valid = len(args) == 1 && check(args[0])
+		}
+		if !valid {
+			// As in browsers, an invalid descriptor leaves any earlier valid
+			// one in effect.
+			if name != "src" {
+				errs = append(errs, badDescriptor(d))
+			}
+			continue
+		}
+		switch name {
+		case "font-family":
+			ff.Family = family
+		case "src":
+			ff.Sources = sources
+		case "unicode-range":
+			ff.UnicodeRanges = ranges
+		}
+		ff.Descriptors = append(ff.Descriptors, d)
+	}
+	if ff.Family == "" {
+		errs = append(errs, fmt.Errorf("%w: @font-face needs a font-family", MissingDescriptorErr))
+	}
+	if len(ff.Sources) == 0 {
+		errs = append(errs, fmt.Errorf("%w: @font-face needs a src", MissingDescriptorErr))
+	}
+	return ff, errs
+}
+
+// familyName returns the font family name given by a string or by a
+// sequence of identifiers, which must be the only argument.
+func familyName(args [][]Node) (string, bool) {
+	if len(args) != 1 || len(args[0]) == 0 {
+		return "", false
+	}
+	if t, ok := args[0][0].(*TokenNode); ok && t.TokenType == StringToken {
+		return t.Value.(string), len(args[0]) == 1
+	}
+	var words []string
+	for _, n := range args[0] {
+		t, ok := n.(*TokenNode)
+		if !ok || t.TokenType != IdentToken {
+			return "", false
+		}
+		words = append(words, string(t.Value.(Identifier)))
+	}
+	if len(words) == 1 && (cssWideKeywords[toLower(words[0])] || genericFamilies[toLower(words[0])]) {
+		return "", false
+	}
+	return strings.Join(words, " "), true
+}
+
+var genericFamilies = map[string]bool{
+	"serif": true, "sans-serif": true, "monospace": true, "cursive": true, "fantasy": true, "system-ui": true, "math": true,
+}
+
+// fontSources returns the valid sources in the value of a src descriptor,
+// with an error for each that isn't.
+func fontSources(values []Node) ([]FontSource, []error) {
+	var sources []FontSource
+	var errs []error
+	for _, arg := range splitArguments(values) {
+		if src, ok := fontSource(arg); ok {
+			sources = append(sources, src)
+		} else {
+			errs = append(errs, fmt.Errorf("%w: %q isn't a font source", BadDescriptorErr, strings.TrimSpace(nodesString(arg))))
+		}
+	}
+	return sources, errs
+}
+
+func fontSource(terms []Node) (FontSource, bool) {
+	var src FontSource
+	if len(terms) == 0 {
+		return src, false
+	}
+	switch n := terms[0].(type) {
+	case *TokenNode:
+		if n.TokenType != UrlToken {
+			return src, false
+		}
+		src.URL = n.Value.(string)
+	case *FunctionNode:
+		switch toLower(n.Name) {
+		case "local":
+			name, ok := familyName(splitArguments(n.Values))
+			src.Local = name
+			return src, ok && len(terms) == 1
+		case "url", "src":
+			args := splitArguments(n.Values)
+			if len(args) != 1 || len(args[0]) != 1 || !nodeIsTokenType(args[0][0], StringToken) {
+				return src, false
+			}
+			src.URL = args[0][0].(*TokenNode).Value.(string)
+		default:
+			return src, false
+		}
+	default:
+		return src, false
+	}
+	hints := terms[1:]
+	if len(hints) > 0 {
+		if fn, ok := hints[0].(*FunctionNode); ok && toLower(fn.Name) == "format" {
+			formats, ok := hintList(fn, true)
+			if !ok {
+				return src, false
+			}
+			src.Formats, hints = formats, hints[1:]
+		}
+	}
+	if len(hints) > 0 {
+		if fn, ok := hints[0].(*FunctionNode); ok && toLower(fn.Name) == "tech" {
+			techs, ok := hintList(fn, false)
+			if !ok {
+				return src, false
+			}
+			src.Techs, hints = techs, hints[1:]
+		}
+	}
+	return src, len(hints) == 0
+}
+
+// hintList returns the comma-separated identifiers, or strings if allowed,
+// that are the arguments of fn.
+func hintList(fn *FunctionNode, strs bool) ([]string, bool) {
+	var hints []string
+	for _, arg := range splitArguments(fn.Values) {
+		if len(arg) != 1 {
+			return nil, false
+		}
+		t, ok := arg[0].(*TokenNode)
+		switch {
+		case ok && t.TokenType == IdentToken:
+			hints = append(hints, string(t.Value.(Identifier)))
+		case ok && t.TokenType == StringToken && strs:
+			hints = append(hints, t.Value.(string))
+		default:
+			return nil, false
+		}
+	}
+	return hints, true
+}
+
+func unicodeRanges(values []Node) ([]UnicodeRange, bool) {
+	var ranges []UnicodeRange
+	for _, arg := range splitArguments(values) {
+		if len(arg) != 1 || !nodeIsTokenType(arg[0], UnicodeRangeToken) {
+			return nil, false
+		}
+		r := arg[0].(*TokenNode).Value.(UnicodeRange)
+		if r.Start > r.End || r.End > 0x10ffff {
+			return nil, false
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, true
+}
+
+func keywordOf(keywords ...string) func([]Node) bool {
+	return func(terms []Node) bool {
+		if len(terms) != 1 {
+			return false
+		}
+		ident, ok := identValue(terms[0])
+		for _, k := range keywords {
+			if ok && ident == k {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// rangeOf returns a check for one value, or two giving a range, that are
+// each valid according to valid.
+func rangeOf(valid func(Node) bool) func([]Node) bool {
+	return func(terms []Node) bool {
+		if len(terms) == 0 || len(terms) > 2 {
+			return false
+		}
+		for _, t := range terms {
+			if !valid(t) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// isMathOf reports whether n is a valid math function such as calc() whose
+// result is of dimension d. One whose type is only known once it's used, as
+// when it contains var(), may be of any dimension.
+func isMathOf(n Node, d Dimension) bool {
+	fn, ok := n.(*FunctionNode)
+	if !ok {
+		return false
+	}
+	calc, err := ParseCalc(fn)
+	if err == nil {
+		calc, err = SimplifyCalc(calc)
+	}
+	if err != nil {
+		return false
+	}
+	v, ok := calc.(*CalcValue)
+	if !ok {
+		return true
+	}
+	vd, ok := UnitDimension(v.Unit)
+	return ok && vd == d
+}
+
+func validFontWeight(n Node) bool {
+	if isMathOf(n, NumberDimension) {
+		return true
+	}
+	if ident, ok := identValue(n); ok {
+		return ident == "normal" || ident == "bold"
+	}
+	num, ok := n.(*NumberNode)
+	return ok && num.Type == "number" && num.Float64() >= 1 && num.Float64() <= 1000
+}
+
+var fontStretchKeywords = keywordOf("normal", "ultra-condensed", "extra-condensed", "condensed", "semi-condensed",
+	"semi-expanded", "expanded", "extra-expanded", "ultra-expanded")
+
+func validFontStretch(n Node) bool {
+	if fontStretchKeywords([]Node{n}) || isMathOf(n, PercentageDimension) {
+		return true
+	}
+	num, ok := n.(*NumberNode)
+	return ok && num.Type == "percentage" && num.Float64() >= 0
+}
+
+func validFontStyle(terms []Node) bool {
+	if len(terms) == 0 {
+		return false
+	}
+	switch ident, _ := identValue(terms[0]); ident {
+	case "normal", "italic":
+		return len(terms) == 1
+	case "oblique":
+		if len(terms) > 3 {
+			return false
+		}
+		for _, t := range terms[1:] {
+			if isMathOf(t, AngleDimension) {
+				continue
+			}
+			num, ok := t.(*NumberNode)
+			if !ok || num.Type != "dimension" {
+				return false
+			}
+			if d, ok := UnitDimension(num.Unit); !ok || d != AngleDimension {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func overrideValue(terms []Node) bool {
+	if len(terms) != 1 {
+		return false
+	}
+	if ident, ok := identValue(terms[0]); ok {
+		return ident == "normal"
+	}
+	if isMathOf(terms[0], PercentageDimension) {
+		return true
+	}
+	num, ok := terms[0].(*NumberNode)
+	return ok && num.Type == "percentage" && num.Float64() >= 0
+}
+
+// KeyframesRule is a @keyframes rule, or a vendor-prefixed one such as
+// @-webkit-keyframes, in which case Prefix is "-webkit-".
+type KeyframesRule struct {
+	Name      string
+	Prefix    string
+	Keyframes []*Keyframe
+}
+
+// Keyframe is one of the rules in @keyframes. Offsets are its selectors as
+// percentages, with "from" as 0 and "to" as 100.
+type Keyframe struct {
+	Offsets      []float64
+	Declarations []*DeclarationNode
+}
+
+// ParseKeyframes interprets a @keyframes rule. Keyframes with an invalid
+// selector are left out, as are declarations marked !important, which
+// keyframes ignore.
+func ParseKeyframes(rule *AtRuleNode) (*KeyframesRule, []error) {
+	kf := &KeyframesRule{}
+	var errs []error
+	name := toLower(rule.Name)
+	if strings.HasPrefix(name, "-") {
+		if i := strings.Index(name[1:], "-"); i >= 0 {
+			kf.Prefix, name = name[:i+2], name[i+2:]
+		}
+	}
+	if name != "keyframes" {
+		return kf, []error{fmt.Errorf("%w: @%s isn't @keyframes", BadPreludeErr, rule.Name)}
+	}
+	prelude := trimWhitespace(rule.Prelude)
+	valid := false
+	if len(prelude) == 1 {
+		if t, ok := prelude[0].(*TokenNode); ok {
+			switch t.TokenType {
+			case StringToken:
+				kf.Name = t.Value.(string)
+				valid = true
+			case IdentToken:
+				kf.Name = string(t.Value.(Identifier))
+				valid = !cssWideKeywords[toLower(kf.Name)] && toLower(kf.Name) != "none"
+			}
+		}
+	}
+	if !valid {
+		errs = append(errs, fmt.Errorf("%w: %q isn't a keyframes name", BadPreludeErr, strings.TrimSpace(nodesString(rule.Prelude))))
+	}
+
+	nodes, bodyErrs := parseRuleBody(rule.Body)
+	errs = append(errs, bodyErrs...)
+	for _, n := range nodes {
+		r, ok := n.(*QualifiedRuleNode)
+		if !ok {
+			errs = append(errs, fmt.Errorf("%w: only keyframes are allowed in @keyframes", SyntaxErr))
+			continue
+		}
+		offsets, ok := keyframeOffsets(r.Prelude)
+		if !ok {
+			errs = append(errs, fmt.Errorf("%w: %q isn't a keyframe selector", BadKeyframeErr, strings.TrimSpace(nodesString(r.Prelude))))
+			continue
+		}
+		frame := &Keyframe{Offsets: offsets}
+		decls, declErrs := ruleDeclarations(r.Body, "keyframes")
+		errs = append(errs, declErrs...)
+		for _, d := range decls {
+			if d.Important {
+				errs = append(errs, fmt.Errorf("%w: %q is ignored since it's !important", BadKeyframeErr, d.Name))
+				continue
+			}
+			frame.Declarations = append(frame.Declarations, d)
+		}
+		kf.Keyframes = append(kf.Keyframes, frame)
+	}
+	return kf, errs
+}
+
+func keyframeOffsets(prelude []Node) ([]float64, bool) {
+	var offsets []float64
+	for _, arg := range splitArguments(prelude) {
+		if len(arg) != 1 {
+			return nil, false
+		}
+		if ident, ok := identValue(arg[0]); ok {
+			switch ident {
+			case "from":
+				offsets = append(offsets, 0)
+				continue
+			case "to":
+				offsets = append(offsets, 100)
+				continue
+			}
+			return nil, false
+		}
+		num, ok := arg[0].(*NumberNode)
+		if !ok || num.Type != "percentage" || num.Float64() < 0 || num.Float64() > 100 {
+			return nil, false
+		}
+		offsets = append(offsets, num.Float64())
+	}
+	return offsets, true
+}
+
+// PageRule is a @page rule. With no selectors, it applies to every page.
+type PageRule struct {
+	Selectors    []PageSelector
+	Declarations []*DeclarationNode
+	MarginBoxes  []*MarginBox
+}
+
+// PageSelector selects pages by their type, if Name is set, and by the
+// pseudo-classes :first, :left, :right and :blank.
+type PageSelector struct {
+	Name    string
+	Pseudos []string
+}
+
+func (s PageSelector) String() string {
+	var buf strings.Builder
+	buf.WriteString(escapeIdent(s.Name))
+	for _, p := range s.Pseudos {
+		buf.WriteString(":" + p)
+	}
+	return buf.String()
+}
+
+// MarginBox is one of the at-rules in @page for the boxes in the page
+// margins, such as @top-left.
+type MarginBox struct {
+	Name         string
+	Declarations []*DeclarationNode
+}
+
+var marginBoxes = map[string]bool{
+	"top-left-corner": true, "top-left": true, "top-center": true, "top-right": true, "top-right-corner": true,
+	"bottom-left-corner": true, "bottom-left": true, "bottom-center": true, "bottom-right": true, "bottom-right-corner": true,
+	"left-top": true, "left-middle": true, "left-bottom": true,
+	"right-top": true, "right-middle": true, "right-bottom": true,
+}
+
+var pagePseudoClasses = map[string]bool{"first": true, "left": true, "right": true, "blank": true}
+
+// ParsePage interprets a @page rule. If its selector is invalid, the rule
+// doesn't apply and is returned as nil.
+func ParsePage(rule *AtRuleNode) (*PageRule, []error) {
+	selectors, ok := pageSelectors(rule.Prelude)
+	if !ok {
+		return nil, []error{fmt.Errorf("%w: %q isn't a page selector", BadPreludeErr, strings.TrimSpace(nodesString(rule.Prelude)))}
+	}
+	page := &PageRule{Selectors: selectors}
+	nodes, errs := parseRuleBody(rule.Body)
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *DeclarationNode:
+			page.Declarations = append(page.Declarations, n)
+		case *AtRuleNode:
+			name := toLower(n.Name)
+			if !marginBoxes[name] {
+				errs = append(errs, fmt.Errorf("%w: @%s isn't a margin box", SyntaxErr, n.Name))
+				continue
+			}
+			if n.Body == nil {
+				errs = append(errs, fmt.Errorf("%w: @%s needs a block", SyntaxErr, n.Name))
+				continue
+			}
+			if len(trimWhitespace(n.Prelude)) > 0 {
+				errs = append(errs, fmt.Errorf("%w: @%s takes no prelude", BadPreludeErr, n.Name))
+				continue
+			}
+			decls, declErrs := ruleDeclarations(n.Body, "@"+name)
+			errs = append(errs, declErrs...)
+			page.MarginBoxes = append(page.MarginBoxes, &MarginBox{Name: name, Declarations: decls})
+		default:
+			errs = append(errs, fmt.Errorf("%w: only declarations and margin boxes are allowed in @page", SyntaxErr))
+		}
+	}
+	return page, errs
+}
+
+// pageSelectors parses a comma-separated list of page selectors, such as
+// "toc:first, :blank", in which whitespace may only surround the commas.
+func pageSelectors(prelude []Node) ([]PageSelector, bool) {
+	var selectors []PageSelector
+	groups := [][]Node{nil}
+	for _, n := range prelude {
+		if nodeIsTokenType(n, CommaToken) {
+			groups = append(groups, nil)
+		} else {
+			groups[len(groups)-1] = append(groups[len(groups)-1], n)
+		}
+	}
+	if len(groups) == 1 && len(trimWhitespace(groups[0])) == 0 {
+		return nil, true
+	}
+	for _, group := range groups {
+		terms := trimWhitespace(group)
+		if len(terms) == 0 {
+			return nil, false
+		}
+		var sel PageSelector
+		if t, ok := terms[0].(*TokenNode); ok && t.TokenType == IdentToken {
+			sel.Name, terms = string(t.Value.(Identifier)), terms[1:]
+		}
+		for len(terms) > 0 {
+			pseudo, ok := "", len(terms) > 1 && nodeIsTokenType(terms[0], ColonToken)
+			if ok {
+				pseudo, ok = identValue(terms[1])
+			}
+			if !ok || !pagePseudoClasses[pseudo] {
+				return nil, false
+			}
+			sel.Pseudos, terms = append(sel.Pseudos, pseudo), terms[2:]
+		}
+		selectors = append(selectors, sel)
+	}
+	return selectors, true
+}
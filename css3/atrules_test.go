@@ -0,0 +1,141 @@
+package css3
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestAtRules(t *testing.T) {
+	atRule := func(s string) *AtRuleNode {
+		return testParser(s).ParseRule().(*AtRuleNode)
+	}
+	errorsAre := func(errs []error, targets ...error) {
+		So(len(errs), ShouldEqual, len(targets))
+		for i, target := range targets {
+			So(errors.Is(errs[i], target), ShouldBeTrue)
+		}
+	}
+
+	Convey("@font-face rules", t, func() {
+		ff, errs := ParseFontFace(atRule(`@font-face {
+			font-family: "My Font";
+			src: local(My  Font), url(a.woff2) format("woff2") tech(variations), url("b.ttf") format(truetype, opentype);
+			unicode-range: U+0000-00FF, U+4??;
+			font-weight: 100 900;
+			font-display: swap;
+		}`))
+		So(len(errs), ShouldEqual, 0)
+		So(ff.Family, ShouldEqual, "My Font")
+		So(ff.Sources, ShouldResemble, []FontSource{
+			{Local: "My Font"},
+			{URL: "a.woff2", Formats: []string{"woff2"}, Techs: []string{"variations"}},
+			{URL: "b.ttf", Formats: []string{"truetype", "opentype"}},
+		})
+		So(ff.UnicodeRanges, ShouldResemble, []UnicodeRange{{0, 0xff}, {0x400, 0x4ff}})
+		So(len(ff.Descriptors), ShouldEqual, 5)
+
+		ff, errs = ParseFontFace(atRule(`@font-face { font-family: Open Sans; src: url(a) format(woff), bad(b), url(c) x }`))
+		errorsAre(errs, BadDescriptorErr, BadDescriptorErr)
+		So(ff.Family, ShouldEqual, "Open Sans")
+		So(ff.Sources, ShouldResemble, []FontSource{{URL: "a", Formats: []string{"woff"}}})
+	})
+
+	Convey("Invalid @font-face descriptors are reported and dropped", t, func() {
+		ff, errs := ParseFontFace(atRule(`@font-face x {
+			font-family: serif;
+			colour: red;
+			font-display: fast;
+			font-weight: 400, 700;
+			font-style: oblique 10deg 20px;
+			size-adjust: -5%;
+			unicode-range: U+FF-00;
+			font-stretch: 50% !important;
+		}`))
+		errorsAre(errs, BadPreludeErr, BadDescriptorErr, UnknownDescriptorErr, BadDescriptorErr, BadDescriptorErr,
+			BadDescriptorErr, BadDescriptorErr, BadDescriptorErr, BadDescriptorErr, MissingDescriptorErr, MissingDescriptorErr)
+		So(len(ff.Descriptors), ShouldEqual, 0)
+
+		_, errs = ParseFontFace(atRule(`@font-face { font-family: x; src: local(x); font-style: oblique -10deg 0.5turn; ascent-override: normal; a { } }`))
+		errorsAre(errs, SyntaxErr)
+	})
+
+	Convey("Invalid @font-face descriptors leave earlier ones in effect", t, func() {
+		ff, errs := ParseFontFace(atRule(`@font-face { font-family: "x"; font-family: serif; src: url(a); src: bogus; }`))
+		errorsAre(errs, BadDescriptorErr, BadDescriptorErr)
+		So(ff.Family, ShouldEqual, "x")
+		So(ff.Sources, ShouldResemble, []FontSource{{URL: "a"}})
+	})
+
+	Convey("@font-face bodies are parsed without being reserialized", t, func() {
+		ff, errs := ParseFontFace(atRule(`@font-face { font-family: a\ b; src: url(x\)y.woff) format("woff") }`))
+		So(len(errs), ShouldEqual, 0)
+		So(ff.Family, ShouldEqual, "a b")
+		So(ff.Sources, ShouldResemble, []FontSource{{URL: "x)y.woff", Formats: []string{"woff"}}})
+	})
+
+	Convey("@font-face descriptors may use math functions", t, func() {
+		_, errs := ParseFontFace(atRule(`@font-face { font-family: x; src: local(x);
+			font-weight: calc(400) max(500, var(--w)); font-stretch: calc(50% + 10%);
+			font-style: oblique calc(5deg * 2); size-adjust: min(90%, 95%); }`))
+		So(len(errs), ShouldEqual, 0)
+		_, errs = ParseFontFace(atRule(`@font-face { font-family: x; src: local(x); font-weight: calc(1px); size-adjust: calc(2); }`))
+		errorsAre(errs, BadDescriptorErr, BadDescriptorErr)
+	})
+
+	Convey("@keyframes rules", t, func() {
+		kf, errs := ParseKeyframes(atRule(`@keyframes fade { from { opacity: 0 } 50%, 75% { opacity: .5 } TO { opacity: 1 } }`))
+		So(len(errs), ShouldEqual, 0)
+		So(kf.Name, ShouldEqual, "fade")
+		So(kf.Prefix, ShouldEqual, "")
+		So(len(kf.Keyframes), ShouldEqual, 3)
+		So(kf.Keyframes[1].Offsets, ShouldResemble, []float64{50, 75})
+		So(kf.Keyframes[2].Offsets, ShouldResemble, []float64{100})
+		So(kf.Keyframes[2].Declarations[0].Name, ShouldEqual, "opacity")
+
+		kf, errs = ParseKeyframes(atRule(`@-webkit-keyframes "spin" { 0% { a: b } }`))
+		So(len(errs), ShouldEqual, 0)
+		So(kf.Name, ShouldEqual, "spin")
+		So(kf.Prefix, ShouldEqual, "-webkit-")
+	})
+
+	Convey("Invalid keyframes are reported and dropped", t, func() {
+		kf, errs := ParseKeyframes(atRule(`@keyframes none { 150% { a: b } from, x { a: b } to { a: b !important; c: d } e: f; }`))
+		errorsAre(errs, BadPreludeErr, BadKeyframeErr, BadKeyframeErr, BadKeyframeErr, SyntaxErr)
+		So(len(kf.Keyframes), ShouldEqual, 1)
+		So(len(kf.Keyframes[0].Declarations), ShouldEqual, 1)
+
+		_, errs = ParseKeyframes(atRule(`@media x { }`))
+		errorsAre(errs, BadPreludeErr)
+	})
+
+	Convey("@page rules", t, func() {
+		page, errs := ParsePage(atRule(`@page toc:first, :Left:blank { margin: 1in; @top-center { content: "x" } @bottom-right-corner { } }`))
+		So(len(errs), ShouldEqual, 0)
+		So(page.Selectors, ShouldResemble, []PageSelector{{Name: "toc", Pseudos: []string{"first"}}, {Pseudos: []string{"left", "blank"}}})
+		So(page.Selectors[1].String(), ShouldEqual, ":left:blank")
+		So(len(page.Declarations), ShouldEqual, 1)
+		So(len(page.MarginBoxes), ShouldEqual, 2)
+		So(page.MarginBoxes[0].Name, ShouldEqual, "top-center")
+		So(page.MarginBoxes[0].Declarations[0].ValueText(), ShouldEqual, `"x"`)
+
+		page, errs = ParsePage(atRule(`@page { a: b; @middle { } p { } @top-left x { } }`))
+		errorsAre(errs, SyntaxErr, SyntaxErr, BadPreludeErr)
+		So(len(page.Selectors), ShouldEqual, 0)
+		So(len(page.Declarations), ShouldEqual, 1)
+
+		page, errs = ParsePage(atRule(`@page { @top-left; margin: 1in }`))
+		errorsAre(errs, SyntaxErr)
+		So(len(page.MarginBoxes), ShouldEqual, 0)
+		So(len(page.Declarations), ShouldEqual, 1)
+	})
+
+	Convey("@page rules with invalid selectors don't apply", t, func() {
+		for _, s := range []string{`@page :hover { }`, `@page a :first { }`, `@page a, { }`, `@page a b { }`} {
+			page, errs := ParsePage(atRule(s))
+			So(page, ShouldBeNil)
+			errorsAre(errs, BadPreludeErr)
+		}
+	})
+}
@@ -0,0 +1,750 @@
+package css3
+
+import (
+	"bytes"
+	"iter"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ByteTokenizer is a fast path for tokenizing input that is already in
+// memory. It produces the same tokens as Tokenizer, but reads a []byte
+// directly and returns ByteTokens, which refer to the input rather than
+// copying out of it. Tokenizing doesn't allocate; escapes are only decoded
+// when a token's value is asked for.
+type ByteTokenizer struct {
+	src []byte
+	pos int
+
+	// KeepComments makes Next return comments as CommentTokens, whose value
+	// is the text between the delimiters, instead of skipping them.
+	KeepComments bool
+}
+
+func NewByteTokenizer(src []byte) *ByteTokenizer {
+	return &ByteTokenizer{src: src}
+}
+
+// Reset starts tokenizing src from the beginning, so a ByteTokenizer can be
+// reused.
+func (tk *ByteTokenizer) Reset(src []byte) {
+	tk.src, tk.pos = src, 0
+}
+
+type byteTokenFlags uint8
+
+const (
+	// The value contains escapes or NULs that must be decoded.
+	flagEscaped byteTokenFlags = 1 << iota
+	// The unit of a dimension contains escapes or NULs.
+	flagUnitEscaped
+	// The value was read from a quoted string.
+	flagQuoted
+	// A hash token's name is an identifier.
+	flagIdentifier
+	// A numeric token's number has a fraction or exponent.
+	flagFloat
+)
+
+// ByteToken is a token read by a ByteTokenizer. Start and End are the byte
+// offsets of the token in the input, which must not be modified while the
+// token is in use.
+type ByteToken struct {
+	Type       TokenType
+	Start, End int
+
+	src                  []byte
+	valueStart, valueEnd int
+	unitEnd              int
+	rangeStart, rangeEnd rune
+	flags                byteTokenFlags
+}
+
+// Raw returns the bytes of the input the token was read from.
+func (t ByteToken) Raw() []byte { return t.src[t.Start:t.End] }
+
+// Value returns the token's decoded value: the name of an identifier,
+// function, at-keyword or hash, the contents of a string or URL, the
+// representation of a number, the code point of a delimiter, or the text
+// of a comment. Other tokens have an empty value.
+func (t ByteToken) Value() string {
+	b := t.src[t.valueStart:t.valueEnd]
+	if t.isVerbatim(b) {
+		return string(b)
+	}
+	return string(t.AppendValue(nil))
+}
+
+// AppendValue appends the token's decoded value to dst, and returns the
+// extended buffer.
+func (t ByteToken) AppendValue(dst []byte) []byte {
+	b := t.src[t.valueStart:t.valueEnd]
+	switch {
+	case t.isVerbatim(b):
+		return append(dst, b...)
+	case t.Type == CommentToken:
+		return appendPreprocessed(dst, b)
+	}
+	return appendDecoded(dst, b, t.flags&flagQuoted != 0)
+}
+
+// isVerbatim reports whether the token's value b needs no decoding.
+func (t ByteToken) isVerbatim(b []byte) bool {
+	if t.Type == CommentToken && bytes.IndexAny(b, "\r\f\x00") >= 0 {
+		return false
+	}
+	return t.flags&flagEscaped == 0 && utf8.Valid(b)
+}
+
+// ValueEqualFold reports whether the token's decoded value equals s,
+// ignoring case. It doesn't allocate.
+func (t ByteToken) ValueEqualFold(s string) bool {
+	b := t.src[t.valueStart:t.valueEnd]
+	quoted := t.flags&flagQuoted != 0
+	i := 0
+	for _, want := range s {
+		var r rune
+		for r = -1; r < 0; {
+			if i >= len(b) {
+				return false
+			}
+			r, i = decodeRune(b, i, quoted)
+		}
+		if r != want && unicode.ToLower(r) != unicode.ToLower(want) {
+			return false
+		}
+	}
+	for i < len(b) {
+		var r rune
+		if r, i = decodeRune(b, i, quoted); r >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Unit returns the decoded unit of a dimension, "%" for a percentage, and
+// an empty string for any other token.
+func (t ByteToken) Unit() string {
+	b := t.src[t.valueEnd:t.unitEnd]
+	if t.flags&flagUnitEscaped == 0 && utf8.Valid(b) {
+		return string(b)
+	}
+	return string(appendDecoded(nil, b, false))
+}
+
+// IsIdentifier reports whether a hash token's name is a valid identifier,
+// as in an ID selector.
+func (t ByteToken) IsIdentifier() bool { return t.flags&flagIdentifier != 0 }
+
+// Delim returns the code point of a delimiter token.
+func (t ByteToken) Delim() rune { return rune(t.src[t.Start]) }
+
+// UnicodeRange returns the range of a unicode-range token.
+func (t ByteToken) UnicodeRange() UnicodeRange {
+	return UnicodeRange{t.rangeStart, t.rangeEnd}
+}
+
+// Numeric parses the number of a numeric token. Numbers aren't parsed while
+// tokenizing, so a number out of range is only reported here.
+func (t ByteToken) Numeric() (*Numeric, error) {
+	num := new(Numeric)
+	if t.flags&flagFloat != 0 {
+		num.NumberType = Float
+	}
+	if err := num.parse(string(t.src[t.valueStart:t.valueEnd])); err != nil {
+		return nil, err
+	}
+	num.Unit = t.Unit()
+	return num, nil
+}
+
+// Token converts t to the equivalent Token returned by Tokenizer.
+func (t ByteToken) Token() *Token {
+	switch t.Type {
+	case IdentToken:
+		return NewToken(t.Type, Identifier(t.Value()))
+	case HashToken:
+		if t.IsIdentifier() {
+			return NewToken(t.Type, Identifier(t.Value()))
+		}
+		return NewToken(t.Type, t.Value())
+	case FunctionToken, AtKeywordToken, StringToken, BadStringToken, UrlToken, CommentToken:
+		return NewToken(t.Type, t.Value())
+	case DelimToken:
+		return NewDelimToken(t.Delim())
+	case NumberToken, PercentageToken, DimensionToken:
+		num, err := t.Numeric()
+		if err != nil {
+			return NewErrorToken(err)
+		}
+		return NewToken(t.Type, num)
+	case UnicodeRangeToken:
+		return NewToken(t.Type, t.UnicodeRange())
+	default:
+		return NewToken(t.Type, nil)
+	}
+}
+
+// Tokens returns an iterator over the remaining tokens. It ends at the end
+// of the input, which isn't yielded.
+func (tk *ByteTokenizer) Tokens() iter.Seq[ByteToken] {
+	return func(yield func(ByteToken) bool) {
+		for {
+			t := tk.Next()
+			if t.Type == EOFToken || !yield(t) {
+				return
+			}
+		}
+	}
+}
+
+var (
+	commentEnd = []byte("*/")
+	cdo        = []byte("!--")
+	cdc        = []byte("->")
+)
+
+// Next returns the next token, or an EOFToken at the end of the input.
+func (tk *ByteTokenizer) Next() ByteToken {
+	src := tk.src
+	t := ByteToken{Start: tk.pos, src: src}
+	for tk.pos+1 < len(src) && src[tk.pos] == '/' && src[tk.pos+1] == '*' {
+		t.Start, t.valueStart = tk.pos, tk.pos+2
+		if end := bytes.Index(src[t.valueStart:], commentEnd); end >= 0 {
+			t.valueEnd = t.valueStart + end
+			tk.pos = t.valueEnd + len(commentEnd)
+		} else {
+			t.valueEnd, tk.pos = len(src), len(src)
+		}
+		if tk.KeepComments {
+			t.Type, t.End = CommentToken, tk.pos
+			return t
+		}
+	}
+	t.Start, t.valueStart, t.valueEnd = tk.pos, 0, 0
+	if tk.pos >= len(src) {
+		t.Type, t.End = EOFToken, tk.pos
+		return t
+	}
+	c, i := src[tk.pos], tk.pos+1
+	switch c {
+	case ' ', '\t', '\n', '\r', '\f':
+		t.Type, i = WhitespaceToken, skipSpace(src, i)
+	case '"', '\'':
+		i = t.string(i, c)
+	case '#':
+		if i < len(src) && (isNameByte(src[i]) || startsEscapeAt(src, i)) {
+			if startsIdentAt(src, i) {
+				t.flags |= flagIdentifier
+			}
+			t.Type, i = HashToken, t.name(i)
+		} else {
+			i = t.delim(i)
+		}
+	case ',':
+		t.Type = CommaToken
+	case ':':
+		t.Type = ColonToken
+	case ';':
+		t.Type = SemicolonToken
+	case '<':
+		if bytes.HasPrefix(src[i:], cdo) {
+			t.Type, i = CDOToken, i+len(cdo)
+		} else {
+			i = t.delim(i)
+		}
+	case '@':
+		if startsIdentAt(src, i) {
+			t.Type, i = AtKeywordToken, t.name(i)
+		} else {
+			i = t.delim(i)
+		}
+	case '\\':
+		if startsEscapeAt(src, i-1) {
+			i = t.identLike(i - 1)
+		} else {
+			// Technically this is a parse error.
+			i = t.delim(i)
+		}
+	case '(':
+		t.Type = LParenToken
+	case ')':
+		t.Type = RParenToken
+	case '[':
+		t.Type = LSquareToken
+	case ']':
+		t.Type = RSquareToken
+	case '{':
+		t.Type = LCurlyToken
+	case '}':
+		t.Type = RCurlyToken
+	case '$':
+		i = t.delimOrMatch(i, SuffixMatchToken)
+	case '*':
+		i = t.delimOrMatch(i, SubstringMatchToken)
+	case '^':
+		i = t.delimOrMatch(i, PrefixMatchToken)
+	case '~':
+		i = t.delimOrMatch(i, IncludeMatchToken)
+	case '|':
+		switch {
+		case i < len(src) && src[i] == '=':
+			t.Type, i = DashMatchToken, i+1
+		case i < len(src) && src[i] == '|':
+			t.Type, i = ColumnToken, i+1
+		default:
+			i = t.delim(i)
+		}
+	case '+':
+		if startsNumberAt(src, i) {
+			i = t.numeric(i - 1)
+		} else {
+			i = t.delim(i)
+		}
+	case '-':
+		switch {
+		case startsNumberAt(src, i):
+			i = t.numeric(i - 1)
+		case bytes.HasPrefix(src[i:], cdc):
+			t.Type, i = CDCToken, i+len(cdc)
+		case startsIdentAt(src, i-1):
+			i = t.identLike(i - 1)
+		default:
+			i = t.delim(i)
+		}
+	case '.':
+		if i < len(src) && isDigitByte(src[i]) {
+			i = t.numeric(i - 1)
+		} else {
+			i = t.delim(i)
+		}
+	case 'U', 'u':
+		if i+1 < len(src) && src[i] == '+' && (src[i+1] == '?' || isHexByte(src[i+1])) {
+			i = t.unicodeRange(i + 1)
+		} else {
+			i = t.identLike(i - 1)
+		}
+	default:
+		switch {
+		case isDigitByte(c):
+			i = t.numeric(i - 1)
+		case isNameStartByte(c):
+			i = t.identLike(i - 1)
+		default:
+			i = t.delim(i)
+		}
+	}
+	t.End, tk.pos = i, i
+	return t
+}
+
+func (t *ByteToken) delim(i int) int {
+	t.Type, t.valueStart, t.valueEnd = DelimToken, i-1, i
+	return i
+}
+
+func (t *ByteToken) delimOrMatch(i int, matchType TokenType) int {
+	if i < len(t.src) && t.src[i] == '=' {
+		t.Type = matchType
+		return i + 1
+	}
+	return t.delim(i)
+}
+
+// name reads a name starting at i into the token's value, and returns the
+// offset just past it.
+func (t *ByteToken) name(i int) int {
+	end, escaped := scanName(t.src, i)
+	t.valueStart, t.valueEnd = i, end
+	if escaped {
+		t.flags |= flagEscaped
+	}
+	return end
+}
+
+func (t *ByteToken) identLike(i int) int {
+	i = t.name(i)
+	if i >= len(t.src) || t.src[i] != '(' {
+		t.Type = IdentToken
+		return i
+	}
+	if t.ValueEqualFold("url") {
+		return t.url(i + 1)
+	}
+	t.Type = FunctionToken
+	return i + 1
+}
+
+func (t *ByteToken) url(i int) int {
+	src := t.src
+	t.Type, t.flags = UrlToken, 0
+	i = skipSpace(src, i)
+	t.valueStart, t.valueEnd = i, i
+	if i >= len(src) {
+		return i
+	}
+	if c := src[i]; c == '"' || c == '\'' {
+		end, valueEnd, bad, escaped := scanString(src, i+1, c)
+		if bad {
+			return t.badURL(end)
+		}
+		j := skipSpace(src, end)
+		if j < len(src) {
+			if src[j] != ')' {
+				return t.badURL(j)
+			}
+			j++
+		}
+		t.valueStart, t.valueEnd, t.flags = i+1, valueEnd, flagQuoted
+		if escaped {
+			t.flags |= flagEscaped
+		}
+		return j
+	}
+
+	for i < len(src) {
+		switch c := src[i]; {
+		case c == ')':
+			t.valueEnd = i
+			return i + 1
+		case isSpaceByte(c):
+			t.valueEnd = i
+			if i = skipSpace(src, i); i < len(src) && src[i] == ')' {
+				return i + 1
+			}
+			return t.badURL(i)
+		case c == '"' || c == '\'' || c == '(' || isNonPrintableByte(c):
+			return t.badURL(i)
+		case c == '\\':
+			if !startsEscapeAt(src, i) {
+				return t.badURL(i)
+			}
+			t.flags |= flagEscaped
+			i = skipEscape(src, i+1)
+		case c == 0:
+			t.flags |= flagEscaped
+			i++
+		default:
+			i++
+		}
+	}
+	t.valueEnd = i
+	return i
+}
+
+func (t *ByteToken) badURL(i int) int {
+	src := t.src
+	t.Type, t.valueStart, t.valueEnd, t.flags = BadUrlToken, 0, 0, 0
+	for i < len(src) && src[i] != ')' {
+		if startsEscapeAt(src, i) {
+			i = skipEscape(src, i+1)
+		} else {
+			i++
+		}
+	}
+	if i < len(src) {
+		i++
+	}
+	return i
+}
+
+func (t *ByteToken) string(i int, quote byte) int {
+	end, valueEnd, bad, escaped := scanString(t.src, i, quote)
+	t.Type, t.valueStart, t.valueEnd, t.flags = StringToken, i, valueEnd, flagQuoted
+	if bad {
+		t.Type = BadStringToken
+	}
+	if escaped {
+		t.flags |= flagEscaped
+	}
+	return end
+}
+
+func (t *ByteToken) numeric(i int) int {
+	src := t.src
+	t.valueStart = i
+	if src[i] == '+' || src[i] == '-' {
+		i++
+	}
+	i = skipDigits(src, i)
+	if i+1 < len(src) && src[i] == '.' && isDigitByte(src[i+1]) {
+		t.flags |= flagFloat
+		i = skipDigits(src, i+1)
+	}
+	if i+1 < len(src) && (src[i] == 'e' || src[i] == 'E') {
+		if isDigitByte(src[i+1]) {
+			t.flags |= flagFloat
+			i = skipDigits(src, i+1)
+		} else if i+2 < len(src) && (src[i+1] == '+' || src[i+1] == '-') && isDigitByte(src[i+2]) {
+			t.flags |= flagFloat
+			i = skipDigits(src, i+2)
+		}
+	}
+	t.valueEnd, t.unitEnd = i, i
+
+	switch {
+	case startsIdentAt(src, i):
+		end, escaped := scanName(src, i)
+		if escaped {
+			t.flags |= flagUnitEscaped
+		}
+		t.Type, t.unitEnd = DimensionToken, end
+	case i < len(src) && src[i] == '%':
+		t.Type, t.unitEnd = PercentageToken, i+1
+	default:
+		t.Type = NumberToken
+	}
+	return t.unitEnd
+}
+
+func (t *ByteToken) unicodeRange(i int) int {
+	src := t.src
+	t.Type = UnicodeRangeToken
+	code, i, length := scanHex(src, i)
+	qs := 0
+	for ; qs < 6-length && i < len(src) && src[i] == '?'; qs++ {
+		i++
+	}
+	if qs > 0 {
+		t.rangeStart = rune(code << (4 * qs))
+		t.rangeEnd = t.rangeStart | (1<<(4*qs) - 1)
+		return i
+	}
+	t.rangeStart, t.rangeEnd = rune(code), rune(code)
+	if i+1 < len(src) && src[i] == '-' && isHexByte(src[i+1]) {
+		code, i, _ = scanHex(src, i+1)
+		t.rangeEnd = rune(code)
+	}
+	return i
+}
+
+// scanName returns the offset just past the name starting at i, and
+// whether it needs decoding.
+func scanName(src []byte, i int) (end int, escaped bool) {
+	for i < len(src) {
+		switch c := src[i]; {
+		case c == 0:
+			escaped = true
+			i++
+		case isNameByte(c):
+			i++
+		case startsEscapeAt(src, i):
+			escaped = true
+			i = skipEscape(src, i+1)
+		default:
+			return i, escaped
+		}
+	}
+	return i, escaped
+}
+
+// scanString reads the rest of a string opened by quote, returning the
+// offsets just past the string and its contents. A bad string ends before
+// the newline that interrupts it.
+func scanString(src []byte, i int, quote byte) (end, valueEnd int, bad, escaped bool) {
+	for i < len(src) {
+		switch c := src[i]; {
+		case c == quote:
+			return i + 1, i, false, escaped
+		case isNewlineByte(c):
+			return i, i, true, escaped
+		case c == '\\':
+			escaped = true
+			switch {
+			case i+1 >= len(src):
+				i++
+			case isNewlineByte(src[i+1]):
+				i = skipNewline(src, i+1)
+			default:
+				i = skipEscape(src, i+1)
+			}
+		case c == 0:
+			escaped = true
+			i++
+		default:
+			i++
+		}
+	}
+	return i, i, false, escaped
+}
+
+// skipEscape returns the offset just past the escape whose backslash is
+// before i.
+func skipEscape(src []byte, i int) int {
+	if i >= len(src) {
+		return i
+	}
+	if !isHexByte(src[i]) {
+		_, size := utf8.DecodeRune(src[i:])
+		return i + size
+	}
+	_, i, _ = scanHex(src, i)
+	if i < len(src) && isSpaceByte(src[i]) {
+		i = skipNewline(src, i)
+	}
+	return i
+}
+
+// skipNewline skips a single whitespace character, treating CRLF as one.
+func skipNewline(src []byte, i int) int {
+	if src[i] == '\r' && i+1 < len(src) && src[i+1] == '\n' {
+		return i + 2
+	}
+	return i + 1
+}
+
+// scanHex reads up to six hex digits starting at i.
+func scanHex(src []byte, i int) (code, end, length int) {
+	for ; length < 6 && i < len(src) && isHexByte(src[i]); length++ {
+		code = code*16 + parseHexDigit(rune(src[i]))
+		i++
+	}
+	return code, i, length
+}
+
+func skipSpace(src []byte, i int) int {
+	for i < len(src) && isSpaceByte(src[i]) {
+		i++
+	}
+	return i
+}
+
+func skipDigits(src []byte, i int) int {
+	for i < len(src) && isDigitByte(src[i]) {
+		i++
+	}
+	return i
+}
+
+func startsEscapeAt(src []byte, i int) bool {
+	return i < len(src) && src[i] == '\\' && (i+1 >= len(src) || !isNewlineByte(src[i+1]))
+}
+
+func startsIdentAt(src []byte, i int) bool {
+	if i < len(src) && src[i] == '-' {
+		i++
+		if i < len(src) && src[i] == '-' {
+			return true
+		}
+	}
+	return i < len(src) && (isNameStartByte(src[i]) || startsEscapeAt(src, i))
+}
+
+func startsNumberAt(src []byte, i int) bool {
+	return i < len(src) && (isDigitByte(src[i]) || (src[i] == '.' && i+1 < len(src) && isDigitByte(src[i+1])))
+}
+
+// decodeRune decodes the code point at b[i] of a token's value, resolving
+// escapes, and returns it with the offset of the next one. It returns a
+// negative rune for escapes that decode to nothing.
+func decodeRune(b []byte, i int, quoted bool) (rune, int) {
+	c := b[i]
+	switch {
+	case c == 0:
+		return '\ufffd', i + 1
+	case c < utf8.RuneSelf && c != '\\':
+		return rune(c), i + 1
+	case c != '\\':
+		r, size := utf8.DecodeRune(b[i:])
+		return r, i + size
+	}
+	i++
+	if i >= len(b) {
+		if quoted {
+			return -1, i
+		}
+		return '\ufffd', i
+	}
+	if c = b[i]; isNewlineByte(c) {
+		return -1, skipNewline(b, i)
+	}
+	if !isHexByte(c) {
+		if c == 0 {
+			return '\ufffd', i + 1
+		}
+		r, size := utf8.DecodeRune(b[i:])
+		return r, i + size
+	}
+	code, i, _ := scanHex(b, i)
+	if i < len(b) && isSpaceByte(b[i]) {
+		i = skipNewline(b, i)
+	}
+	if code == 0 || (code >= 0xd800 && code <= 0xdfff) || code >= 0x10ffff {
+		code = 0xfffd
+	}
+	return rune(code), i
+}
+
+func appendDecoded(dst, b []byte, quoted bool) []byte {
+	for i := 0; i < len(b); {
+		var r rune
+		if r, i = decodeRune(b, i, quoted); r >= 0 {
+			dst = utf8.AppendRune(dst, r)
+		}
+	}
+	return dst
+}
+
+// appendPreprocessed appends the text of a comment to dst, normalizing
+// newlines and replacing NULs as Tokenizer does.
+func appendPreprocessed(dst, b []byte) []byte {
+	for i := 0; i < len(b); {
+		switch c := b[i]; {
+		case c == '\r' || c == '\f':
+			dst = append(dst, '\n')
+			i = skipNewline(b, i)
+		case c == 0:
+			dst = utf8.AppendRune(dst, '\ufffd')
+			i++
+		case c < utf8.RuneSelf:
+			dst = append(dst, c)
+			i++
+		default:
+			r, size := utf8.DecodeRune(b[i:])
+			dst = utf8.AppendRune(dst, r)
+			i += size
+		}
+	}
+	return dst
+}
+
+const (
+	classSpace byte = 1 << iota
+	classNewline
+	classDigit
+	classHex
+	classNameStart
+	className
+	classNonPrintable
+)
+
+var byteClasses = func() (classes [256]byte) {
+	for c := 0; c < 256; c++ {
+		switch {
+		case c == '\n' || c == '\r' || c == '\f':
+			classes[c] |= classSpace | classNewline
+		case c == ' ' || c == '\t':
+			classes[c] |= classSpace
+		case c >= '0' && c <= '9':
+			classes[c] |= classDigit | classHex | className
+		case c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F':
+			classes[c] |= classHex | classNameStart | className
+		case c == 0 || c == '_' || c >= 0x80 || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			classes[c] |= classNameStart | className
+		case c == '-':
+			classes[c] |= className
+		case c == 0xb || c == 0x7f || c <= 8 || c >= 0xe && c <= 0x1f:
+			classes[c] |= classNonPrintable
+		}
+	}
+	return
+}()
+
+func isSpaceByte(c byte) bool        { return byteClasses[c]&classSpace != 0 }
+func isNewlineByte(c byte) bool      { return byteClasses[c]&classNewline != 0 }
+func isDigitByte(c byte) bool        { return byteClasses[c]&classDigit != 0 }
+func isHexByte(c byte) bool          { return byteClasses[c]&classHex != 0 }
+func isNameStartByte(c byte) bool    { return byteClasses[c]&classNameStart != 0 }
+func isNameByte(c byte) bool         { return byteClasses[c]&className != 0 }
+func isNonPrintableByte(c byte) bool { return byteClasses[c]&classNonPrintable != 0 }
@@ -0,0 +1,175 @@
+package css3
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var byteTokenizerInputs = []string{
+	``, ` `, "a {\n  b: 12px }", `"`, `"test`, `"test"test`, `"\"test\""`, "\"test\n\"",
+	`"\2318"`, `"\002318ff"`, `"\\0022 is \0022"`, `"\0 test`, `"test\`, "\"\\\ntest\\\r\n\"",
+	"#", "#abc", "#123abc", "#\\\n", `#\`, "#=", "#-a", "#--", "$", "$=", "'a'", "(", ")", "*", "*=",
+	"+", "+1", "+.5", "+a", ",", "-", "-1", "-.5e-3", "-a", "--a", "-->", "-\\", ".", ".5", ".a",
+	"/", "/*", "/* a */b", "/**/", "/*/", ":", ";", "<", "<!", "<!--", "@", "@a", "@-a", "@--",
+	"[", "]", "^", "^=", "{", "|", "|=", "||", "}", "~", "~=", "!", "\\", "\\\n", "\\\r\n",
+	"1", "12", "1.5", "1e3", "1E+3", "1e", "1e+", "1.", "010", "09", "1e900", "99999999999999999999",
+	"1px", "1%", "1-a", "1\\70x", "1e3em", "1-", "1--a",
+	"a", "a(", "a-b", "a\\62c", "\\41 b", "\\41\r\nb", "\\110000", "\\d800", "\\0", "a\x00b", "é",
+	"\xff\xfe", "a\xffb", "\x01", "\f\r\r\n",
+	"url(", "url()", "url( a )", "url(a b)", "url(a", "url(a ", "url(\"a\")", "url( 'a' )",
+	"url('a' b)", "url('a\n')", "url(a\"b)", "url(a(b)", "url(a\x01)", "url(a\\)b)", "url(a\\\nb)",
+	"url(\\", "URL(a)", "u\\rl(a)", "url (a)", "url(a\x00)", "url('a\\",
+	"u", "u+", "u+u", "u+0", "u+?", "u+??", "u+00100?", "u+001???", "u+001000?", "u+1000-1011",
+	"u+1000-101?", "u+100?-1011", "U+1234567", "u+a-", "u+a-g",
+}
+
+// tokenizeBoth tokenizes s with a Tokenizer and a ByteTokenizer, describing
+// each token with its offsets. Comparison stops after an ErrorToken, after
+// which the two may resynchronize differently.
+func tokenizeBoth(s string, keepComments bool) (runes, bytez []string) {
+	tk := NewTokenizer(strings.NewReader(s))
+	tk.KeepComments = keepComments
+	for {
+		tok := tk.ConsumeToken()
+		runes = append(runes, fmt.Sprintf("%d-%d %v", tk.TokenStart().Offset, tk.TokenEnd().Offset, tok))
+		if tok.TokenType == EOFToken || tok.TokenType == ErrorToken {
+			break
+		}
+	}
+	btk := NewByteTokenizer([]byte(s))
+	btk.KeepComments = keepComments
+	for {
+		bt := btk.Next()
+		tok := bt.Token()
+		bytez = append(bytez, fmt.Sprintf("%d-%d %v", bt.Start, bt.End, tok))
+		if tok.TokenType == EOFToken || tok.TokenType == ErrorToken {
+			break
+		}
+	}
+	if len(runes) > 0 && strings.Contains(runes[len(runes)-1], "ErrorToken") {
+		// Tokenizer stops before a number's unit, so only the token matters.
+		runes[len(runes)-1] = runes[len(runes)-1][strings.Index(runes[len(runes)-1], " "):]
+		bytez[len(bytez)-1] = bytez[len(bytez)-1][strings.Index(bytez[len(bytez)-1], " "):]
+	}
+	return
+}
+
+func TestByteTokenizer(t *testing.T) {
+	Convey("ByteTokenizer agrees with Tokenizer", t, func() {
+		for _, input := range byteTokenizerInputs {
+			runes, bytez := tokenizeBoth(input, false)
+			So(bytez, ShouldResemble, runes)
+		}
+		for _, input := range []string{"/**/", "/* a */", "/* a", "/*", `a/* *\/ */b`, "/* \r\n\f\x00\xff */"} {
+			runes, bytez := tokenizeBoth(input, true)
+			So(bytez, ShouldResemble, runes)
+		}
+	})
+
+	Convey("ByteTokenizer agrees with Tokenizer on css-parsing-tests", t, func() {
+		for _, path := range []string{
+			"css-parsing-tests/component_value_list.json",
+			"css-parsing-tests/one_component_value.json",
+			"css-parsing-tests/declaration_list.json",
+			"css-parsing-tests/rule_list.json",
+			"css-parsing-tests/stylesheet.json",
+		} {
+			data := readJson(path, t).([]interface{})
+			for i := 0; i < len(data); i += 2 {
+				runes, bytez := tokenizeBoth(data[i].(string), false)
+				So(bytez, ShouldResemble, runes)
+			}
+		}
+	})
+
+	Convey("Tokens refer to the input", t, func() {
+		src := []byte(`a\62 c "x\"y" 1.5em #id url( b.png )`)
+		var toks []ByteToken
+		for tok := range NewByteTokenizer(src).Tokens() {
+			if tok.Type != WhitespaceToken {
+				toks = append(toks, tok)
+			}
+		}
+		So(len(toks), ShouldEqual, 5)
+		So(string(toks[0].Raw()), ShouldEqual, `a\62 c`)
+		So(toks[0].Value(), ShouldEqual, "abc")
+		So(toks[0].ValueEqualFold("ABC"), ShouldBeTrue)
+		So(toks[0].ValueEqualFold("ab"), ShouldBeFalse)
+		So(string(toks[1].AppendValue([]byte("<"))), ShouldEqual, `<x"y`)
+		So(toks[2].Value(), ShouldEqual, "1.5")
+		So(toks[2].Unit(), ShouldEqual, "em")
+		So(toks[3].IsIdentifier(), ShouldBeTrue)
+		So(toks[4].Type, ShouldEqual, UrlToken)
+		So(toks[4].Value(), ShouldEqual, "b.png")
+	})
+
+	Convey("Numbers out of range are reported when parsed", t, func() {
+		tok := NewByteTokenizer([]byte("1e900px")).Next()
+		So(tok.Type, ShouldEqual, DimensionToken)
+		_, err := tok.Numeric()
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Tokenizing doesn't allocate", t, func() {
+		src := benchmarkStylesheet(1)
+		var tk ByteTokenizer
+		allocs := testing.AllocsPerRun(10, func() {
+			tk.Reset(src)
+			for tk.Next().Type != EOFToken {
+			}
+		})
+		So(allocs, ShouldEqual, 0.0)
+	})
+}
+
+// benchmarkStylesheet returns testdata/bench.css repeated n times.
+func benchmarkStylesheet(n int) []byte {
+	b, err := os.ReadFile("testdata/bench.css")
+	if err != nil {
+		panic(err)
+	}
+	return bytes.Repeat(b, n)
+}
+
+func BenchmarkTokenizer(b *testing.B) {
+	src := benchmarkStylesheet(50)
+	b.SetBytes(int64(len(src)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tk := NewTokenizer(bytes.NewReader(src))
+		for tk.ConsumeToken().TokenType != EOFToken {
+		}
+	}
+}
+
+func BenchmarkByteTokenizer(b *testing.B) {
+	src := benchmarkStylesheet(50)
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	var tk ByteTokenizer
+	for i := 0; i < b.N; i++ {
+		tk.Reset(src)
+		for tk.Next().Type != EOFToken {
+		}
+	}
+}
+
+func BenchmarkByteTokenizerValues(b *testing.B) {
+	src := benchmarkStylesheet(50)
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	var tk ByteTokenizer
+	for i := 0; i < b.N; i++ {
+		tk.Reset(src)
+		for tok := tk.Next(); tok.Type != EOFToken; tok = tk.Next() {
+			tok.Token()
+		}
+	}
+}
@@ -0,0 +1,664 @@
+package css3
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+var (
+	CalcSyntaxErr = errors.New("invalid calculation")
+	CalcTypeErr   = errors.New("incompatible types in calculation")
+)
+
+// CalcNode is a node of the calculation tree of a math function such as
+// calc(), as CSS Values 4 defines it: values, sums, products, negations and
+// inversions, nested math functions, and values such as var() that can't be
+// resolved until they're used.
+type CalcNode interface {
+	calcNode()
+}
+
+type CalcValue struct{ Quantity }
+
+type CalcSum struct{ Terms []CalcNode }
+
+type CalcProduct struct{ Factors []CalcNode }
+
+type CalcNegate struct{ Value CalcNode }
+
+type CalcInvert struct{ Value CalcNode }
+
+// CalcFunction is a call of min(), max() or clamp().
+type CalcFunction struct {
+	Name string
+	Args []CalcNode
+}
+
+// CalcRaw is a value kept as written, such as var(--x) or infinity.
+type CalcRaw struct{ Nodes []Node }
+
+func (*CalcValue) calcNode()    {}
+func (*CalcSum) calcNode()      {}
+func (*CalcProduct) calcNode()  {}
+func (*CalcNegate) calcNode()   {}
+func (*CalcInvert) calcNode()   {}
+func (*CalcFunction) calcNode() {}
+func (*CalcRaw) calcNode()      {}
+
+// mathFunctions are the functions whose arguments are calculations.
+var mathFunctions = map[string]bool{"calc": true, "min": true, "max": true, "clamp": true}
+
+// rawCalcFunctions produce values that are only known where they're used.
+var rawCalcFunctions = map[string]bool{"var": true, "env": true, "attr": true}
+
+var euler, _ = new(big.Rat).SetString("2.71828182845904523536028747135266249775724709369995")
+
+// ParseCalc parses a call of calc(), min(), max() or clamp(). A nested calc()
+// is read as the calculation it holds.
+func ParseCalc(fn *FunctionNode) (CalcNode, error) {
+	name := toLower(fn.Name)
+	if !mathFunctions[name] {
+		return nil, fmt.Errorf("%w: %s() isn't a math function", CalcSyntaxErr, fn.Name)
+	}
+	var args []CalcNode
+	start := 0
+	for i := 0; i <= len(fn.Values); i++ {
+		if i < len(fn.Values) && !nodeIsTokenType(fn.Values[i], CommaToken) {
+			continue
+		}
+		arg, err := parseCalcSum(fn.Values[start:i])
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		start = i + 1
+	}
+	switch {
+	case name == "calc" && len(args) != 1:
+		return nil, fmt.Errorf("%w: calc() takes one argument", CalcSyntaxErr)
+	case name == "clamp" && len(args) != 3:
+		return nil, fmt.Errorf("%w: clamp() takes three arguments", CalcSyntaxErr)
+	case name == "calc":
+		return args[0], nil
+	}
+	return &CalcFunction{Name: name, Args: args}, nil
+}
+
+type calcParser struct {
+	nodes []Node
+	i     int
+}
+
+func parseCalcSum(nodes []Node) (CalcNode, error) {
+	cp := &calcParser{nodes: nodes}
+	cp.skipWhitespace()
+	sum, err := cp.sum()
+	if err != nil {
+		return nil, err
+	}
+	cp.skipWhitespace()
+	if cp.i < len(cp.nodes) {
+		return nil, fmt.Errorf("%w: expected an operator", CalcSyntaxErr)
+	}
+	return sum, nil
+}
+
+func (cp *calcParser) skipWhitespace() bool {
+	skipped := false
+	for cp.i < len(cp.nodes) && nodeIsTokenType(cp.nodes[cp.i], WhitespaceToken) {
+		cp.i++
+		skipped = true
+	}
+	return skipped
+}
+
+// operator consumes one of ops, returning it. "+" and "-" must have
+// whitespace on both sides, so as not to be read as signs.
+func (cp *calcParser) operator(ops string) (rune, bool) {
+	save := cp.i
+	before := cp.skipWhitespace()
+	if cp.i < len(cp.nodes) {
+		for _, op := range ops {
+			if !nodeIsDelim(cp.nodes[cp.i], op) {
+				continue
+			}
+			cp.i++
+			after := cp.skipWhitespace()
+			if (op == '+' || op == '-') && !(before && after) {
+				break
+			}
+			return op, true
+		}
+	}
+	cp.i = save
+	return 0, false
+}
+
+func (cp *calcParser) sum() (CalcNode, error) {
+	first, err := cp.product()
+	if err != nil {
+		return nil, err
+	}
+	terms := []CalcNode{first}
+	for {
+		op, ok := cp.operator("+-")
+		if !ok {
+			break
+		}
+		term, err := cp.product()
+		if err != nil {
+			return nil, err
+		}
+		if op == '-' {
+			term = &CalcNegate{term}
+		}
+		terms = append(terms, term)
+	}
+	if len(terms) == 1 {
+		return first, nil
+	}
+	return &CalcSum{terms}, nil
+}
+
+func (cp *calcParser) product() (CalcNode, error) {
+	first, err := cp.value()
+	if err != nil {
+		return nil, err
+	}
+	factors := []CalcNode{first}
+	for {
+		op, ok := cp.operator("*/")
+		if !ok {
+			break
+		}
+		factor, err := cp.value()
+		if err != nil {
+			return nil, err
+		}
+		if op == '/' {
+			factor = &CalcInvert{factor}
+		}
+		factors = append(factors, factor)
+	}
+	if len(factors) == 1 {
+		return first, nil
+	}
+	return &CalcProduct{factors}, nil
+}
+
+func (cp *calcParser) value() (CalcNode, error) {
+	if cp.i >= len(cp.nodes) {
+		return nil, fmt.Errorf("%w: expected a value", CalcSyntaxErr)
+	}
+	node := cp.nodes[cp.i]
+	cp.i++
+	switch n := node.(type) {
+	case *NumberNode:
+		q, err := NodeQuantity(n)
+		if err != nil {
+			return nil, err
+		}
+		return &CalcValue{q}, nil
+	case *BlockNode:
+		if n.EndDelim == RParenToken {
+			return parseCalcSum(n.Values)
+		}
+	case *VarNode:
+		return &CalcRaw{[]Node{n}}, nil
+	case *FunctionNode:
+		switch name := toLower(n.Name); {
+		case mathFunctions[name]:
+			return ParseCalc(n)
+		case rawCalcFunctions[name]:
+			return &CalcRaw{[]Node{n}}, nil
+		}
+		return nil, fmt.Errorf("%w: %s() can't be used in a calculation", CalcSyntaxErr, n.Name)
+	case *TokenNode:
+		if n.TokenType == IdentToken {
+			switch toLower(string(n.Value.(Identifier))) {
+			case "e":
+				return &CalcValue{Quantity{new(big.Rat).Set(euler), ""}}, nil
+			case "pi":
+				return &CalcValue{Quantity{new(big.Rat).Set(pi), ""}}, nil
+			case "infinity", "-infinity", "nan":
+				return &CalcRaw{[]Node{n}}, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: unexpected %s", CalcSyntaxErr, strings.TrimSpace(nodesString([]Node{node})))
+}
+
+// SimplifyCalc folds the parts of a calculation that can be worked out
+// without knowing where it's used. Values of compatible units are combined,
+// in the unit of the first; sums are put in a canonical order, with numbers
+// first, then percentages, then dimensions by unit, then anything else; and
+// multiplying a sum by a number is distributed over its terms.
+func SimplifyCalc(n CalcNode) (CalcNode, error) {
+	switch n := n.(type) {
+	case *CalcNegate:
+		v, err := SimplifyCalc(n.Value)
+		if err != nil {
+			return nil, err
+		}
+		switch v := v.(type) {
+		case *CalcValue:
+			return &CalcValue{Quantity{new(big.Rat).Neg(v.Value), v.Unit}}, nil
+		case *CalcNegate:
+			return v.Value, nil
+		}
+		return &CalcNegate{v}, nil
+	case *CalcInvert:
+		v, err := SimplifyCalc(n.Value)
+		if err != nil {
+			return nil, err
+		}
+		switch v := v.(type) {
+		case *CalcValue:
+			if v.Unit == "" {
+				if v.Value.Sign() == 0 {
+					return infiniteCalc(1, ""), nil
+				}
+				return &CalcValue{Quantity{new(big.Rat).Inv(v.Value), ""}}, nil
+			}
+		case *CalcInvert:
+			return v.Value, nil
+		}
+		return &CalcInvert{v}, nil
+	case *CalcSum:
+		return simplifySum(n)
+	case *CalcProduct:
+		return simplifyProduct(n)
+	case *CalcFunction:
+		return simplifyFunction(n)
+	}
+	return n, nil
+}
+
+func simplifySum(n *CalcSum) (CalcNode, error) {
+	var terms []CalcNode
+	var add func(term CalcNode, negate bool)
+	add = func(term CalcNode, negate bool) {
+		switch t := term.(type) {
+		case *CalcSum:
+			for _, inner := range t.Terms {
+				add(inner, negate)
+			}
+			return
+		case *CalcNegate:
+			if sum, ok := t.Value.(*CalcSum); ok {
+				add(sum, !negate)
+				return
+			}
+			if negate {
+				terms = append(terms, t.Value)
+				return
+			}
+		case *CalcValue:
+			if negate {
+				terms = append(terms, &CalcValue{Quantity{new(big.Rat).Neg(t.Value), t.Unit}})
+				return
+			}
+		}
+		if negate {
+			term = &CalcNegate{term}
+		}
+		terms = append(terms, term)
+	}
+	for _, term := range n.Terms {
+		s, err := SimplifyCalc(term)
+		if err != nil {
+			return nil, err
+		}
+		add(s, false)
+	}
+
+	var combined []CalcNode
+	for _, term := range terms {
+		v, ok := term.(*CalcValue)
+		if !ok {
+			combined = append(combined, term)
+			continue
+		}
+		merged := false
+		for i, other := range combined {
+			o, ok := other.(*CalcValue)
+			if !ok {
+				continue
+			}
+			if err := checkSumTypes(o.Quantity, v.Quantity); err != nil {
+				return nil, err
+			}
+			if c, err := v.ConvertTo(o.Unit); err == nil {
+				combined[i] = &CalcValue{Quantity{new(big.Rat).Add(o.Value, c.Value), o.Unit}}
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			combined = append(combined, &CalcValue{v.Quantity})
+		}
+	}
+	if len(combined) == 1 {
+		return combined[0], nil
+	}
+	sort.SliceStable(combined, func(i, j int) bool { return sumOrder(combined[i]) < sumOrder(combined[j]) })
+	return &CalcSum{combined}, nil
+}
+
+// checkSumTypes returns an error if a and b can never be added, as a number
+// and a length can't. Percentages may resolve to any dimension.
+func checkSumTypes(a, b Quantity) error {
+	da, erra := a.Dimension()
+	db, errb := b.Dimension()
+	switch {
+	case erra != nil:
+		return erra
+	case errb != nil:
+		return errb
+	case da == db:
+		return nil
+	case (da == PercentageDimension || db == PercentageDimension) && da != NumberDimension && db != NumberDimension:
+		return nil
+	}
+	return fmt.Errorf("%w: can't add %s and %s", CalcTypeErr, a, b)
+}
+
+// sumOrder orders the terms of a sum: numbers, percentages, then dimensions
+// alphabetically by unit, then anything else.
+func sumOrder(n CalcNode) string {
+	v, ok := n.(*CalcValue)
+	switch {
+	case !ok:
+		return "3"
+	case v.Unit == "":
+		return "0"
+	case v.Unit == "%":
+		return "1"
+	}
+	return "2" + strings.ToLower(v.Unit)
+}
+
+func simplifyProduct(n *CalcProduct) (CalcNode, error) {
+	var factors []CalcNode
+	for _, factor := range n.Factors {
+		s, err := simplifyFactor(factor)
+		if err != nil {
+			return nil, err
+		}
+		if p, ok := s.(*CalcProduct); ok {
+			factors = append(factors, p.Factors...)
+		} else {
+			factors = append(factors, s)
+		}
+	}
+
+	// Fold the values, keeping track of their units. A division by zero
+	// leaves the coefficient's sign for the infinity it gives.
+	coefficient := big.NewRat(1, 1)
+	divByZero := false
+	var numerators, denominators []string
+	var others []CalcNode
+	for _, factor := range factors {
+		switch f := factor.(type) {
+		case *CalcValue:
+			coefficient.Mul(coefficient, f.Value)
+			if f.Unit != "" {
+				numerators = append(numerators, f.Unit)
+			}
+			continue
+		case *CalcInvert:
+			if v, ok := f.Value.(*CalcValue); ok {
+				if v.Value.Sign() == 0 {
+					divByZero = true
+				} else {
+					coefficient.Quo(coefficient, v.Value)
+				}
+				if v.Unit != "" {
+					denominators = append(denominators, v.Unit)
+				}
+				continue
+			}
+		}
+		others = append(others, factor)
+	}
+	for i := 0; i < len(numerators); i++ {
+		for j, den := range denominators {
+			c, err := (Quantity{big.NewRat(1, 1), numerators[i]}).ConvertTo(den)
+			if err != nil {
+				continue
+			}
+			coefficient.Mul(coefficient, c.Value)
+			numerators = append(numerators[:i], numerators[i+1:]...)
+			denominators = append(denominators[:j], denominators[j+1:]...)
+			i--
+			break
+		}
+	}
+	if len(numerators) > 1 || len(denominators) > 0 {
+		if len(others) > 0 {
+			// The units may yet cancel out with those of the other factors.
+			return &CalcProduct{factors}, nil
+		}
+		return nil, fmt.Errorf("%w: %s", CalcTypeErr, unitProduct(numerators, denominators))
+	}
+	unit := ""
+	if len(numerators) == 1 {
+		unit = numerators[0]
+	}
+	if divByZero {
+		inf := infiniteCalc(coefficient.Sign(), unit)
+		if len(others) == 0 {
+			return inf, nil
+		}
+		return &CalcProduct{append([]CalcNode{inf}, others...)}, nil
+	}
+	value := &CalcValue{Quantity{coefficient, unit}}
+	if len(others) == 0 {
+		return value, nil
+	}
+	if sum, ok := others[0].(*CalcSum); ok && len(others) == 1 && unit == "" {
+		terms := make([]CalcNode, len(sum.Terms))
+		for i, term := range sum.Terms {
+			terms[i] = &CalcProduct{[]CalcNode{value, term}}
+		}
+		return SimplifyCalc(&CalcSum{terms})
+	}
+	if unit == "" && coefficient.Cmp(big.NewRat(1, 1)) == 0 {
+		if len(others) == 1 {
+			return others[0], nil
+		}
+		return &CalcProduct{others}, nil
+	}
+	return &CalcProduct{append([]CalcNode{value}, others...)}, nil
+}
+
+// simplifyFactor simplifies a factor of a product, leaving a division by
+// zero for simplifyProduct to fold with the other factors.
+func simplifyFactor(n CalcNode) (CalcNode, error) {
+	inv, ok := n.(*CalcInvert)
+	if !ok {
+		return SimplifyCalc(n)
+	}
+	v, err := SimplifyCalc(inv.Value)
+	if err != nil {
+		return nil, err
+	}
+	if zero, ok := v.(*CalcValue); ok && zero.Value.Sign() == 0 {
+		return &CalcInvert{zero}, nil
+	}
+	return SimplifyCalc(&CalcInvert{v})
+}
+
+// infiniteCalc returns what dividing a value of unit with the given sign by
+// zero gives: infinity, -infinity or, for zero, NaN, times one unit as in
+// "infinity * 1px".
+func infiniteCalc(sign int, unit string) CalcNode {
+	keyword := "NaN"
+	switch sign {
+	case 1:
+		keyword = "infinity"
+	case -1:
+		keyword = "-infinity"
+	}
+	raw := &CalcRaw{[]Node{NewTokenNode(NewToken(IdentToken, Identifier(keyword)))}}
+	if unit == "" {
+		return raw
+	}
+	return &CalcProduct{[]CalcNode{raw, &CalcValue{Quantity{big.NewRat(1, 1), unit}}}}
+}
+
+func unitProduct(numerators, denominators []string) string {
+	s := strings.Join(numerators, "*")
+	if len(denominators) > 0 {
+		s += "/" + strings.Join(denominators, "*")
+	}
+	return "the units " + s + " aren't a CSS type"
+}
+
+func simplifyFunction(n *CalcFunction) (CalcNode, error) {
+	args := make([]CalcNode, len(n.Args))
+	values := make([]Quantity, 0, len(n.Args))
+	for i, arg := range n.Args {
+		s, err := SimplifyCalc(arg)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = s
+		if v, ok := s.(*CalcValue); ok {
+			values = append(values, v.Quantity)
+		}
+	}
+	if len(values) < len(args) {
+		return &CalcFunction{n.Name, args}, nil
+	}
+	for _, v := range values[1:] {
+		if err := checkSumTypes(values[0], v); err != nil {
+			return nil, err
+		}
+		if _, err := v.ConvertTo(values[0].Unit); err != nil {
+			return &CalcFunction{n.Name, args}, nil
+		}
+	}
+	pick := func(better int) Quantity {
+		best := values[0]
+		for _, v := range values[1:] {
+			if c, _ := v.Cmp(best); c == better {
+				best = v
+			}
+		}
+		return best
+	}
+	switch n.Name {
+	case "min":
+		return &CalcValue{pick(-1)}, nil
+	case "max":
+		return &CalcValue{pick(1)}, nil
+	}
+	result := values[1]
+	if c, _ := result.Cmp(values[2]); c > 0 {
+		result = values[2]
+	}
+	if c, _ := result.Cmp(values[0]); c < 0 {
+		result = values[0]
+	}
+	return &CalcValue{result}, nil
+}
+
+// FormatCalc serializes a calculation. A single value is written as it is,
+// and anything else that isn't a math function is wrapped in calc().
+func FormatCalc(n CalcNode) string {
+	switch n.(type) {
+	case *CalcValue, *CalcFunction:
+		return formatCalc(n)
+	}
+	return "calc(" + formatCalc(n) + ")"
+}
+
+func formatCalc(n CalcNode) string {
+	switch n := n.(type) {
+	case *CalcValue:
+		return n.String()
+	case *CalcRaw:
+		return strings.TrimSpace(nodesString(n.Nodes))
+	case *CalcFunction:
+		args := make([]string, len(n.Args))
+		for i, arg := range n.Args {
+			args[i] = formatCalc(arg)
+		}
+		return n.Name + "(" + strings.Join(args, ", ") + ")"
+	case *CalcSum:
+		var buf strings.Builder
+		for i, term := range n.Terms {
+			negative := false
+			switch t := term.(type) {
+			case *CalcNegate:
+				term, negative = t.Value, true
+			case *CalcValue:
+				if i > 0 && t.Value.Sign() < 0 {
+					term, negative = &CalcValue{Quantity{new(big.Rat).Neg(t.Value), t.Unit}}, true
+				}
+			}
+			switch {
+			case i == 0 && negative:
+				buf.WriteString("-1 * ")
+			case i > 0 && negative:
+				buf.WriteString(" - ")
+			case i > 0:
+				buf.WriteString(" + ")
+			}
+			buf.WriteString(formatCalcOperand(term, negative))
+		}
+		return buf.String()
+	case *CalcProduct:
+		var buf strings.Builder
+		for i, factor := range n.Factors {
+			if inv, ok := factor.(*CalcInvert); ok {
+				if i == 0 {
+					buf.WriteString("1")
+				}
+				buf.WriteString(" / " + formatCalcOperand(inv.Value, true))
+				continue
+			}
+			if i > 0 {
+				buf.WriteString(" * ")
+			}
+			buf.WriteString(formatCalcOperand(factor, false))
+		}
+		return buf.String()
+	case *CalcNegate:
+		return "-1 * " + formatCalcOperand(n.Value, true)
+	case *CalcInvert:
+		return "1 / " + formatCalcOperand(n.Value, true)
+	}
+	return ""
+}
+
+// formatCalcOperand serializes n as an operand, in parentheses if it's a sum
+// or, where the operand is negated or divided by, a product.
+func formatCalcOperand(n CalcNode, strict bool) string {
+	switch n.(type) {
+	case *CalcSum, *CalcNegate:
+		return "(" + formatCalc(n) + ")"
+	case *CalcProduct, *CalcInvert:
+		if strict {
+			return "(" + formatCalc(n) + ")"
+		}
+	}
+	return formatCalc(n)
+}
+
+// SimplifyCalcFunction parses, simplifies and serializes a call of calc(),
+// min(), max() or clamp().
+func SimplifyCalcFunction(fn *FunctionNode) (string, error) {
+	n, err := ParseCalc(fn)
+	if err != nil {
+		return "", err
+	}
+	if n, err = SimplifyCalc(n); err != nil {
+		return "", err
+	}
+	return FormatCalc(n), nil
+}
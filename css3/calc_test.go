@@ -0,0 +1,83 @@
+package css3
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func simplifyCalc(s string) (string, error) {
+	nodes := testParser(s).ParseListOfComponentValues()
+	fn, ok := nodes[0].(*FunctionNode)
+	if !ok {
+		return "", errors.New("not a function")
+	}
+	return SimplifyCalcFunction(fn)
+}
+
+func TestCalc(t *testing.T) {
+	shouldSimplifyTo := func(actual interface{}, expected ...interface{}) string {
+		s, err := simplifyCalc(actual.(string))
+		if err != nil {
+			return err.Error()
+		}
+		return ShouldEqual(s, expected[0])
+	}
+
+	Convey("Constant arithmetic is folded", t, func() {
+		So("calc(10px + 2 * 5px)", shouldSimplifyTo, "20px")
+		So("calc(1in - 2px)", shouldSimplifyTo, "0.9791666667in")
+		So("calc(1px + 1in)", shouldSimplifyTo, "97px")
+		So("calc((1 + 2) * 3 / 4)", shouldSimplifyTo, "2.25")
+		So("calc(10px / 4px)", shouldSimplifyTo, "2.5")
+		So("calc(2 * pi)", shouldSimplifyTo, "6.2831853072")
+		So("calc(1s + 500ms)", shouldSimplifyTo, "1.5s")
+		So("calc(calc(1px + 2px) * 2)", shouldSimplifyTo, "6px")
+	})
+
+	Convey("Unresolved units are kept in canonical form", t, func() {
+		So("calc(100% - 10px)", shouldSimplifyTo, "calc(100% - 10px)")
+		So("calc(10px + 100% - 5px)", shouldSimplifyTo, "calc(100% + 5px)")
+		So("calc(1em + 2px + 3em)", shouldSimplifyTo, "calc(4em + 2px)")
+		So("calc(2 * (100% - 10px))", shouldSimplifyTo, "calc(200% - 20px)")
+		So("calc(100% - (10px - 1em))", shouldSimplifyTo, "calc(100% + 1em - 10px)")
+		So("calc(-1 * (1px + 1em))", shouldSimplifyTo, "calc(-1em - 1px)")
+	})
+
+	Convey("var() is kept as written", t, func() {
+		So("calc(var(--a) + 1px + 2px)", shouldSimplifyTo, "calc(3px + var(--a))")
+		So("calc(2 * var(--a, 1px))", shouldSimplifyTo, "calc(2 * var(--a, 1px))")
+		So("calc(var(--a) / 2px)", shouldSimplifyTo, "calc(var(--a) / 2px)")
+		So("calc(var(--a))", shouldSimplifyTo, "calc(var(--a))")
+	})
+
+	Convey("min(), max() and clamp() are folded when their arguments compare", t, func() {
+		So("min(1rem, 20px)", shouldSimplifyTo, "min(1rem, 20px)")
+		So("min(1in, 20px)", shouldSimplifyTo, "20px")
+		So("max(1px + 2px, 2px)", shouldSimplifyTo, "3px")
+		So("clamp(1px, 5px, 3px)", shouldSimplifyTo, "3px")
+		So("clamp(10px, 50%, 20px)", shouldSimplifyTo, "clamp(10px, 50%, 20px)")
+		So("calc(min(1px, 2px) + 1em)", shouldSimplifyTo, "calc(1em + 1px)")
+		So("max(calc(1px * 2), 10%)", shouldSimplifyTo, "max(2px, 10%)")
+	})
+
+	Convey("Division by zero gives infinity", t, func() {
+		So("calc(1 / 0)", shouldSimplifyTo, "calc(infinity)")
+		So("calc(-1px / 0)", shouldSimplifyTo, "calc(-infinity * 1px)")
+		So("calc(2px / (1 - 1))", shouldSimplifyTo, "calc(infinity * 1px)")
+		So("calc(0 / 0)", shouldSimplifyTo, "calc(NaN)")
+		So("calc(1px / 0px)", shouldSimplifyTo, "calc(infinity)")
+	})
+
+	Convey("Invalid calculations are errors", t, func() {
+		for _, s := range []string{"calc(1px+2px)", "calc(1px -2px)", "calc(foo)", "calc(1px, 2px)", "clamp(1px, 2px)", "calc(rgb(0, 0, 0))"} {
+			_, err := simplifyCalc(s)
+			So(errors.Is(err, CalcSyntaxErr), ShouldBeTrue)
+		}
+		for _, s := range []string{"calc(1px + 1s)", "calc(1 + 1px)", "calc(1px * 1px)", "min(1px, 1s)"} {
+			_, err := simplifyCalc(s)
+			So(errors.Is(err, CalcTypeErr), ShouldBeTrue)
+		}
+	})
+}
@@ -2,6 +2,9 @@ package css3
 
 import (
 	"bytes"
+	"math"
+	"strconv"
+	"strings"
 )
 
 var BasicColorKeywords = map[string]string{
@@ -173,20 +176,79 @@ var ExtendedColorKeywords = map[string]string{
 	"yellowgreen":          "#9acd32",
 }
 
+// ColorSpace is the color space of a Color's channels.
+type ColorSpace int
+
+const (
+	SRGB ColorSpace = iota
+	SRGBLinear
+	DisplayP3
+	A98RGB
+	ProPhotoRGB
+	Rec2020
+	XYZD50
+	XYZD65
+	Lab
+	LCH
+	OKLab
+	OKLCH
+)
+
+var colorSpaceNames = map[ColorSpace]string{
+	SRGB:        "srgb",
+	SRGBLinear:  "srgb-linear",
+	DisplayP3:   "display-p3",
+	A98RGB:      "a98-rgb",
+	ProPhotoRGB: "prophoto-rgb",
+	Rec2020:     "rec2020",
+	XYZD50:      "xyz-d50",
+	XYZD65:      "xyz-d65",
+	Lab:         "lab",
+	LCH:         "lch",
+	OKLab:       "oklab",
+	OKLCH:       "oklch",
+}
+
+func (s ColorSpace) String() string { return colorSpaceNames[s] }
+
+// ColorSpaceFromName returns the color space with the name used by the CSS
+// color() function, and whether there is one.
+func ColorSpaceFromName(name string) (ColorSpace, bool) {
+	name = toLower(name)
+	if name == "xyz" {
+		return XYZD65, true
+	}
+	for space, n := range colorSpaceNames {
+		if n == name && space != Lab && space != LCH && space != OKLab && space != OKLCH {
+			return space, true
+		}
+	}
+	return 0, false
+}
+
+// Color is a color in Space. R, G and B hold its three channels in the order
+// the space's CSS function takes them, so for Lab they're the lightness and
+// the a and b axes; only for the RGB spaces are they red, green and blue.
+// Channels are in the range 0-1 for the RGB and XYZ spaces, and in the units
+// of the CSS functions otherwise, with hues in degrees. Missing records the
+// channels given as "none", with alpha last.
 type Color struct {
 	R            float64
 	G            float64
 	B            float64
 	A            float64
 	CurrentColor bool
+	Space        ColorSpace
+	Missing      [4]bool
+	source       *colorSource
 }
 
 func RGB(r, g, b float64) *Color {
-	return &Color{r, g, b, 1, false}
+	return &Color{R: r, G: g, B: b, A: 1}
 }
 
 func RGBA(r, g, b, a float64) *Color {
-	return &Color{r, g, b, clamp(a), false}
+	return &Color{R: r, G: g, B: b, A: clamp(a)}
 }
 
 func HSL(h, s, v float64) *Color {
@@ -222,8 +284,12 @@ func hue(m1, m2, h float64) float64 {
 	return m1
 }
 
+// normDeg returns an angle in degrees as a fraction of a turn.
 func normDeg(deg float64) float64 {
-	return float64(((int(deg)%360)+360)%360) / 360
+	if deg = math.Mod(deg, 360); deg < 0 {
+		deg += 360
+	}
+	return deg / 360
 }
 
 func HSLA(h, s, l, a float64) *Color {
@@ -246,10 +312,77 @@ func HSLA(h, s, l, a float64) *Color {
 	}
 }
 
+// ToHSL returns the hue, saturation and lightness of c, each in the range
+// 0-1.
+func (c *Color) ToHSL() (h, s, l float64) {
+	r, g, b := clamp(c.R), clamp(c.G), clamp(c.B)
+	max := math.Max(r, math.Max(g, b))
+	min := math.Min(r, math.Min(g, b))
+	l = (max + min) / 2
+	if max == min {
+		return 0, 0, l
+	}
+	d := max - min
+	if l > 0.5 {
+		s = d / (2 - max - min)
+	} else {
+		s = d / (max + min)
+	}
+	switch max {
+	case r:
+		h = (g - b) / d
+		if g < b {
+			h += 6
+		}
+	case g:
+		h = (b-r)/d + 2
+	default:
+		h = (r-g)/d + 4
+	}
+	return h / 6, s, l
+}
+
+// HWBA returns the color with the given hue, whiteness and blackness, each
+// in the range 0-1, and alpha. Whiteness and blackness adding up to more
+// than 1 are scaled down proportionally, giving a shade of gray.
+func HWBA(h, w, b, a float64) *Color {
+	w, b = clamp(w), clamp(b)
+	if w+b >= 1 {
+		gray := w / (w + b)
+		return RGBA(gray, gray, gray, a)
+	}
+	c := HSLA(h, 1, 0.5, a)
+	scale := 1 - w - b
+	c.R = c.R*scale + w
+	c.G = c.G*scale + w
+	c.B = c.B*scale + w
+	return c
+}
+
+// ToHWB returns the hue, whiteness and blackness of c, each in the range
+// 0-1.
+func (c *Color) ToHWB() (h, w, b float64) {
+	h, _, _ = c.ToHSL()
+	r, g, bl := clamp(c.R), clamp(c.G), clamp(c.B)
+	return h, math.Min(r, math.Min(g, bl)), 1 - math.Max(r, math.Max(g, bl))
+}
+
+// ColorFromString parses a color. If s holds nothing else, the color
+// remembers s so that Format writes it back the same way.
 func ColorFromString(s string) *Color {
 	parser := NewParser(bytes.NewReader([]byte(s)))
 	nodes := parser.ParseListOfComponentValues()
-	return ColorFromNodes(nodes)
+	c := ColorFromNodes(nodes)
+	values := 0
+	for _, n := range nodes {
+		if !nodeIsTokenType(n, WhitespaceToken) && !nodeIsTokenType(n, EOFToken) {
+			values++
+		}
+	}
+	if values == 1 {
+		c = c.withSource(strings.TrimSpace(s))
+	}
+	return c
 }
 
 type ColorProvider interface {
@@ -304,26 +437,23 @@ func hexCodeToColorComponent(code string) float64 {
 	return float64(x*16+y) / 255
 }
 
+// ColorFromHexCode parses the digits of a hex color: 3 or 6 digits, or 4 or
+// 8 with alpha.
 func ColorFromHexCode(code string) (color *Color) {
-	if len(code) == 3 {
-		color = &Color{
-			R: hexCodeToColorComponent(code[0:1]),
-			G: hexCodeToColorComponent(code[1:2]),
-			B: hexCodeToColorComponent(code[2:3]),
-			A: 1.0,
-		}
-	} else if len(code) == 6 {
-		color = &Color{
-			R: hexCodeToColorComponent(code[0:2]),
-			G: hexCodeToColorComponent(code[2:4]),
-			B: hexCodeToColorComponent(code[4:6]),
-			A: 1.0,
-		}
-	} else {
+	digits := 1
+	if len(code) == 6 || len(code) == 8 {
+		digits = 2
+	} else if len(code) != 3 && len(code) != 4 {
 		return nil
 	}
-	if color.R < 0 || color.G < 0 || color.B < 0 {
-		return nil
+	color = &Color{A: 1}
+	channels := []*float64{&color.R, &color.G, &color.B, &color.A}
+	for i := 0; i*digits < len(code); i++ {
+		v := hexCodeToColorComponent(code[i*digits : (i+1)*digits])
+		if v < 0 {
+			return nil
+		}
+		*channels[i] = v
 	}
 	return
 }
@@ -337,3 +467,424 @@ func (c *Color) TestRepr() interface{} {
 	}
 	return []float64{c.R, c.G, c.B, c.A}
 }
+
+// channelFormat describes how a channel of a color function is written:
+// numbers are divided by number, percentages are fractions of percent, and
+// hues may also be angles, giving degrees.
+type channelFormat struct {
+	number, percent float64
+	hue             bool
+}
+
+var (
+	rgbChannel     = channelFormat{number: 255, percent: 1}
+	unitChannel    = channelFormat{number: 1, percent: 1}
+	percentChannel = channelFormat{number: 100, percent: 1}
+	hueChannel     = channelFormat{hue: true}
+)
+
+// colorFunctions are the channel formats of the color functions other than
+// color().
+var colorFunctions = map[string][3]channelFormat{
+	"rgb":   {rgbChannel, rgbChannel, rgbChannel},
+	"rgba":  {rgbChannel, rgbChannel, rgbChannel},
+	"hsl":   {hueChannel, percentChannel, percentChannel},
+	"hsla":  {hueChannel, percentChannel, percentChannel},
+	"hwb":   {hueChannel, percentChannel, percentChannel},
+	"lab":   {{number: 1, percent: 100}, {number: 1, percent: 125}, {number: 1, percent: 125}},
+	"lch":   {{number: 1, percent: 100}, {number: 1, percent: 150}, hueChannel},
+	"oklab": {unitChannel, {number: 1, percent: 0.4}, {number: 1, percent: 0.4}},
+	"oklch": {unitChannel, {number: 1, percent: 0.4}, hueChannel},
+}
+
+// colorFunctionSpaces are the color spaces of the colors given by the color
+// functions; hsl() and hwb() give sRGB colors.
+var colorFunctionSpaces = map[string]ColorSpace{
+	"lab":   Lab,
+	"lch":   LCH,
+	"oklab": OKLab,
+	"oklch": OKLCH,
+}
+
+var angleDegrees = map[string]float64{
+	"deg":  1,
+	"grad": 360.0 / 400,
+	"rad":  180 / math.Pi,
+	"turn": 360,
+}
+
+func isNone(n Node) bool {
+	t, ok := n.(*TokenNode)
+	return ok && t.TokenType == IdentToken && toLower(string(t.Value.(Identifier))) == "none"
+}
+
+func nodeFloat(num *NumberNode) float64 {
+	if num.NumberType == Integer {
+		return float64(num.Integer)
+	}
+	return num.Float
+}
+
+// parseChannel returns the value of a channel written in format, and
+// whether it's valid.
+func parseChannel(n Node, format channelFormat) (float64, bool) {
+	num, ok := n.(*NumberNode)
+	if !ok {
+		return 0, false
+	}
+	unit := num.Unit
+	if num.Type == "percentage" {
+		unit = "%"
+	}
+	return channelValue(nodeFloat(num), unit, format)
+}
+
+// channelValue returns the value of a channel given as v in unit, which is
+// "%" for a percentage and empty for a number.
+func channelValue(v float64, unit string, format channelFormat) (float64, bool) {
+	switch {
+	case unit == "" && format.hue:
+		return v, true
+	case unit == "":
+		return v / format.number, true
+	case unit == "%" && !format.hue:
+		return v / 100 * format.percent, true
+	case format.hue:
+		if scale, ok := angleDegrees[toLower(unit)]; ok {
+			return v * scale, true
+		}
+	}
+	return 0, false
+}
+
+// calcChannel evaluates a math function given for a channel, such as
+// "calc(h + 180)", with the keywords of the relative color syntax bound to
+// their values. It returns the value and its unit.
+func calcChannel(fn *FunctionNode, keywords map[string]float64) (float64, string, bool) {
+	calc, err := ParseCalc(bindKeywords(fn, keywords).(*FunctionNode))
+	if err != nil {
+		return 0, "", false
+	}
+	if calc, err = SimplifyCalc(calc); err != nil {
+		return 0, "", false
+	}
+	v, ok := calc.(*CalcValue)
+	if !ok {
+		return 0, "", false
+	}
+	f, _ := v.Value.Float64()
+	return f, v.Unit, true
+}
+
+// bindKeywords returns n with the identifiers in keywords replaced by the
+// numbers they stand for.
+func bindKeywords(n Node, keywords map[string]float64) Node {
+	switch n := n.(type) {
+	case *TokenNode:
+		if n.TokenType != IdentToken {
+			return n
+		}
+		if v, ok := keywords[toLower(string(n.Value.(Identifier)))]; ok {
+			repr := strconv.FormatFloat(v, 'f', -1, 64)
+			return NewNumberNode("number", &Numeric{NumberType: Float, Repr: repr, Float: v})
+		}
+	case *FunctionNode:
+		bound := *n
+		bound.Values = make([]Node, len(n.Values))
+		for i, v := range n.Values {
+			bound.Values[i] = bindKeywords(v, keywords)
+		}
+		return &bound
+	case *BlockNode:
+		bound := *n
+		bound.Values = make([]Node, len(n.Values))
+		for i, v := range n.Values {
+			bound.Values[i] = bindKeywords(v, keywords)
+		}
+		return &bound
+	}
+	return n
+}
+
+// colorArguments splits the arguments of a color function into count
+// channels and an optional alpha. Arguments are either separated by commas,
+// with alpha as a fourth argument, if legacy is true, or by whitespace, with
+// alpha after a slash.
+func colorArguments(values []Node, count int, legacy bool) (channels []Node, alpha Node, commas bool, ok bool) {
+	var args []Node
+	slash := -1
+	for _, v := range values {
+		if t, ok := v.(*TokenNode); ok {
+			switch {
+			case t.TokenType == WhitespaceToken:
+				continue
+			case t.TokenType == CommaToken:
+				commas = true
+			case t.TokenType == DelimToken && t.Value.(rune) == '/':
+				if slash >= 0 {
+					return nil, nil, false, false
+				}
+				slash = len(args)
+				continue
+			}
+		}
+		args = append(args, v)
+	}
+	if commas {
+		if !legacy || slash >= 0 {
+			return nil, nil, false, false
+		}
+		fn := &FunctionNode{Values: values}
+		args = fn.Params()
+		if len(args) != 3 && len(args) != 4 {
+			return nil, nil, false, false
+		}
+		for _, arg := range args {
+			if isNone(arg) {
+				return nil, nil, false, false
+			}
+		}
+		if len(args) == 4 {
+			alpha = args[3]
+		}
+		return args[:3], alpha, true, true
+	}
+	switch {
+	case slash < 0:
+		channels = args
+	case slash == len(args)-1:
+		channels, alpha = args[:slash], args[slash]
+	default:
+		return nil, nil, false, false
+	}
+	return channels, alpha, false, len(channels) == count
+}
+
+// parseChannels parses channels written in formats into c, recording the
+// ones given as "none". Channels may also be the keywords of the relative
+// color syntax, naming channels of the origin color, or math functions of
+// them.
+func (c *Color) parseChannels(channels []Node, alpha Node, formats [3]channelFormat, keywords map[string]float64) bool {
+	targets := [4]*float64{&c.R, &c.G, &c.B, &c.A}
+	c.A = 1
+	if alpha != nil {
+		channels = append(channels[:3:3], alpha)
+	}
+	for i, n := range channels {
+		if isNone(n) {
+			*targets[i], c.Missing[i] = 0, true
+			continue
+		}
+		format := unitChannel
+		if i < 3 {
+			format = formats[i]
+		}
+		var v float64
+		var ok bool
+		switch n := n.(type) {
+		case *TokenNode:
+			if n.TokenType != IdentToken {
+				return false
+			}
+			if v, ok = keywords[toLower(string(n.Value.(Identifier)))]; ok {
+				v, ok = channelValue(v, "", format)
+			}
+		case *FunctionNode:
+			var unit string
+			if v, unit, ok = calcChannel(n, keywords); ok {
+				v, ok = channelValue(v, unit, format)
+			}
+		default:
+			v, ok = parseChannel(n, format)
+		}
+		if !ok {
+			return false
+		}
+		*targets[i] = v
+	}
+	c.A = clamp(c.A)
+	return true
+}
+
+// relativeOrigin splits the origin color off arguments written in the
+// relative color syntax, as in "rgb(from red r g b / 50%)". The origin is
+// nil if it isn't a color known statically, like var(--x).
+func relativeOrigin(values []Node) (origin *Color, rest []Node, relative bool) {
+	var args []Node
+	for i, v := range values {
+		if t, ok := v.(*TokenNode); ok && t.TokenType == WhitespaceToken {
+			continue
+		}
+		args = append(args, v)
+		if len(args) == 2 {
+			rest = values[i+1:]
+			break
+		}
+	}
+	if len(args) == 0 {
+		return nil, values, false
+	}
+	t, ok := args[0].(*TokenNode)
+	if !ok || t.TokenType != IdentToken || toLower(string(t.Value.(Identifier))) != "from" {
+		return nil, values, false
+	}
+	if len(args) < 2 {
+		return nil, nil, true
+	}
+	if origin = ColorFromNodes(args[1:]); origin != nil && origin.CurrentColor {
+		origin = nil
+	}
+	return origin, rest, true
+}
+
+// relativeKeywords returns the values of the channel keywords the color
+// function name may refer to in the relative color syntax, taken from
+// origin converted to the function's color space. They're numbers in the
+// function's own units, such as 0-255 for rgb(). Missing channels are zero.
+func relativeKeywords(name string, space ColorSpace, origin *Color, formats [3]channelFormat) map[string]float64 {
+	c := origin.To(space)
+	for i, v := range []*float64{&c.R, &c.G, &c.B, &c.A} {
+		if c.Missing[i] {
+			*v = 0
+		}
+	}
+	keywords := map[string]float64{"alpha": c.A}
+	var names [3]string
+	var values [3]float64
+	switch name {
+	case "hsl", "hsla":
+		h, s, l := c.ToHSL()
+		names, values = [3]string{"h", "s", "l"}, [3]float64{h * 360, s, l}
+	case "hwb":
+		h, w, b := c.ToHWB()
+		names, values = [3]string{"h", "w", "b"}, [3]float64{h * 360, w, b}
+	default:
+		values = [3]float64{c.R, c.G, c.B}
+		switch space {
+		case Lab, OKLab:
+			names = [3]string{"l", "a", "b"}
+		case LCH, OKLCH:
+			names = [3]string{"l", "c", "h"}
+		case XYZD50, XYZD65:
+			names = [3]string{"x", "y", "z"}
+		default:
+			names = [3]string{"r", "g", "b"}
+		}
+	}
+	for i, n := range names {
+		if formats[i].hue {
+			keywords[n] = values[i]
+		} else {
+			keywords[n] = values[i] * formats[i].number
+		}
+	}
+	return keywords
+}
+
+// ColorFromFunction parses a call to one of the CSS color functions: rgb(),
+// rgba(), hsl(), hsla(), hwb(), lab(), lch(), oklab(), oklch(), color() or
+// color-mix(), including the relative color syntax. Colors given by hsl()
+// and hwb() are converted to sRGB, with "none" channels taken as zero. It
+// returns nil for colors that can't be known statically, such as those
+// relative to var(--x).
+func ColorFromFunction(name string, values []Node) *Color {
+	name = toLower(name)
+	switch name {
+	case "color":
+		return colorFromColorFunction(values)
+	case "color-mix":
+		return colorMix(values)
+	}
+	formats, ok := colorFunctions[name]
+	if !ok {
+		return nil
+	}
+	origin, values, relative := relativeOrigin(values)
+	if relative && origin == nil {
+		return nil
+	}
+	legacy := !relative && (name == "rgb" || name == "rgba" || name == "hsl" || name == "hsla")
+	channels, alpha, commas, ok := colorArguments(values, 3, legacy)
+	if !ok {
+		return nil
+	}
+	if commas && !legacyChannelsValid(name, channels) {
+		return nil
+	}
+	c := &Color{Space: colorFunctionSpaces[name]}
+	var keywords map[string]float64
+	if relative {
+		keywords = relativeKeywords(name, c.Space, origin, formats)
+	}
+	if !c.parseChannels(channels, alpha, formats, keywords) {
+		return nil
+	}
+	switch name {
+	case "hsl", "hsla":
+		hsl := HSLA(normDeg(c.R), c.G, c.B, c.A)
+		hsl.Missing[3] = c.Missing[3]
+		return hsl
+	case "hwb":
+		hwb := HWBA(normDeg(c.R), c.G, c.B, c.A)
+		hwb.Missing[3] = c.Missing[3]
+		return hwb
+	case "lab":
+		c.R = math.Max(0, math.Min(100, c.R))
+	case "lch":
+		c.R = math.Max(0, math.Min(100, c.R))
+		c.G = math.Max(0, c.G)
+	case "oklab":
+		c.R = clamp(c.R)
+	case "oklch":
+		c.R = clamp(c.R)
+		c.G = math.Max(0, c.G)
+	}
+	return c
+}
+
+// legacyChannelsValid reports whether the channels of a comma-separated
+// color function have the types CSS Color 3 requires: rgb() channels all
+// numbers or all percentages, and hsl() saturation and lightness
+// percentages.
+func legacyChannelsValid(name string, channels []Node) bool {
+	types := make([]string, 3)
+	for i, n := range channels {
+		if num, ok := n.(*NumberNode); ok {
+			types[i] = num.Type
+		}
+	}
+	if name == "hsl" || name == "hsla" {
+		return types[1] == "percentage" && types[2] == "percentage"
+	}
+	return types[0] == types[1] && types[1] == types[2]
+}
+
+func colorFromColorFunction(values []Node) *Color {
+	origin, values, relative := relativeOrigin(values)
+	if relative && origin == nil {
+		return nil
+	}
+	// The color space comes before the channels.
+	channels, alpha, _, ok := colorArguments(values, 4, false)
+	if !ok {
+		return nil
+	}
+	t, ok := channels[0].(*TokenNode)
+	if !ok || t.TokenType != IdentToken {
+		return nil
+	}
+	space, ok := ColorSpaceFromName(string(t.Value.(Identifier)))
+	if !ok {
+		return nil
+	}
+	c := &Color{Space: space}
+	formats := [3]channelFormat{unitChannel, unitChannel, unitChannel}
+	var keywords map[string]float64
+	if relative {
+		keywords = relativeKeywords("color", space, origin, formats)
+	}
+	if !c.parseChannels(channels[1:], alpha, formats, keywords) {
+		return nil
+	}
+	return c
+}
@@ -3,11 +3,30 @@ package css3
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
 )
 
+// level4Colors are the test cases of css-parsing-tests that CSS Color 4
+// makes valid, with the colors they parse into.
+var level4Colors = map[string][]interface{}{
+	"#ffff":                      {1.0, 1.0, 1.0, 1.0},
+	"#ffffffff":                  {1.0, 1.0, 1.0, 1.0},
+	"rgb(0, 0, 0, 0)":            {0.0, 0.0, 0.0, 0.0},
+	"rgb(0%, 0%, 0%, 0%)":        {0.0, 0.0, 0.0, 0.0},
+	"rgb(0%, 0%, 0%, 0)":         {0.0, 0.0, 0.0, 0.0},
+	"rgba(255, 255, 255, 0%)":    {1.0, 1.0, 1.0, 0.0},
+	"rgba(0, 0, 0)":              {0.0, 0.0, 0.0, 1.0},
+	"rgba(0%, 0%, 0%)":           {0.0, 0.0, 0.0, 1.0},
+	"rgba(0%, 0%, 0%, 0%)":       {0.0, 0.0, 0.0, 0.0},
+	"hsl(30deg, 100%, 100%)":     {1.0, 1.0, 1.0, 1.0},
+	"hsl(0, 0%, 0%, 0%)":         {0.0, 0.0, 0.0, 0.0},
+	"hsla(30deg, 100%, 100%, 1)": {1.0, 1.0, 1.0, 1.0},
+	"hsla(0, 0%, 0%, 50%)":       {0.0, 0.0, 0.0, 0.5},
+}
+
 func TestColors(t *testing.T) {
 	shouldParseInto := func(actual interface{}, expected ...interface{}) (msg string) {
 		scale := 1.0
@@ -69,7 +88,11 @@ func TestColors(t *testing.T) {
 		testSuite := actual.([]interface{})
 		scale := expected[0].(float64)
 		for i := 0; i < len(testSuite); i += 2 {
-			if msg := shouldParseInto(testSuite[i], testSuite[i+1], scale); msg != "" {
+			expected := testSuite[i+1]
+			if color, ok := level4Colors[testSuite[i].(string)]; ok {
+				expected = color
+			}
+			if msg := shouldParseInto(testSuite[i], expected, scale); msg != "" {
 				return msg
 			}
 		}
@@ -85,6 +108,82 @@ func TestColors(t *testing.T) {
 		})
 	}
 
+	Convey("HSL and HWB", t, func() {
+		h, s, l := ColorFromHexCode("336699").ToHSL()
+		So(h*360, ShouldAlmostEqual, 210)
+		So(s, ShouldAlmostEqual, 0.5)
+		So(l, ShouldAlmostEqual, 0.4)
+
+		h, s, l = ColorFromHexCode("808080").ToHSL()
+		So([]float64{h, s}, ShouldResemble, []float64{0, 0})
+		So(l, ShouldAlmostEqual, 128.0/255)
+
+		h, w, b := ColorFromHexCode("336699").ToHWB()
+		So(h*360, ShouldAlmostEqual, 210)
+		So(w, ShouldAlmostEqual, 0.2)
+		So(b, ShouldAlmostEqual, 0.4)
+
+		c := HWBA(210.0/360, 0.2, 0.4, 1)
+		So(c.R*255, ShouldAlmostEqual, 0x33)
+		So(c.G*255, ShouldAlmostEqual, 0x66)
+		So(c.B*255, ShouldAlmostEqual, 0x99)
+
+		c = HWBA(0, 0.6, 0.6, 0.5)
+		So(c.TestRepr(), ShouldResemble, []float64{0.5, 0.5, 0.5, 0.5})
+	})
+
+	Convey("CSS Color 4 syntax", t, func() {
+		c := ColorFromString("rgb(51 102 153 / 50%)")
+		So(c.TestRepr(), ShouldResemble, []float64{0.2, 0.4, 0.6, 0.5})
+		So(c.String(), ShouldEqual, "rgb(51 102 153 / 0.5)")
+
+		So(ColorFromString("#33669980").A, ShouldAlmostEqual, 128.0/255)
+		So(ColorFromString("#369c").A, ShouldAlmostEqual, 0.8)
+		So(ColorFromString("rgb(1, 2 3)"), ShouldBeNil)
+		So(ColorFromString("rgb(1 2 3 4)"), ShouldBeNil)
+		So(ColorFromString("rgb(1, 2, 3 / 4)"), ShouldBeNil)
+		So(ColorFromString("rgb(1 2 3 /)"), ShouldBeNil)
+
+		c = ColorFromString("hsl(0.5turn 50 40%)")
+		So(c.R*255, ShouldAlmostEqual, 0x33)
+		So(c.G*255, ShouldAlmostEqual, 0x99)
+		So(c.B*255, ShouldAlmostEqual, 0x99)
+
+		c = ColorFromString("hwb(210 20% 40%)")
+		So(c.String(), ShouldEqual, "rgb(51 102 153)")
+
+		c = ColorFromString("rgb(none 20 30 / none)")
+		So(c.Missing, ShouldResemble, [4]bool{true, false, false, true})
+		So(c.String(), ShouldEqual, "rgb(none 20 30 / none)")
+		So(ColorFromString("rgb(none, 20, 30)"), ShouldBeNil)
+	})
+
+	Convey("Color spaces", t, func() {
+		c := ColorFromString("lab(50% 40 -25%)")
+		So(c.Space, ShouldEqual, Lab)
+		So(c.TestRepr(), ShouldResemble, []float64{50, 40, -31.25, 1})
+		So(c.String(), ShouldEqual, "lab(50 40 -31.25)")
+
+		c = ColorFromString("LCH(120 50% 1rad)")
+		So(c.Space, ShouldEqual, LCH)
+		So(c.R, ShouldEqual, 100.0)
+		So(c.G, ShouldEqual, 75.0)
+		So(c.B, ShouldAlmostEqual, 180/math.Pi)
+
+		c = ColorFromString("oklch(60% 0.1 none / 25%)")
+		So(c.String(), ShouldEqual, "oklch(0.6 0.1 none / 0.25)")
+		So(ColorFromString("oklab(50% 100% -0.1)").String(), ShouldEqual, "oklab(0.5 0.4 -0.1)")
+
+		c = ColorFromString("color(display-p3 1 50% none)")
+		So(c.Space, ShouldEqual, DisplayP3)
+		So(c.String(), ShouldEqual, "color(display-p3 1 0.5 none)")
+		So(ColorFromString("color(xyz 0.1 0.2 0.3)").String(), ShouldEqual, "color(xyz-d65 0.1 0.2 0.3)")
+		So(ColorFromString("color(lab 1 2 3)"), ShouldBeNil)
+		So(ColorFromString("color(display-p3 1 2)"), ShouldBeNil)
+		So(ColorFromString("lab(1, 2, 3)"), ShouldBeNil)
+		So(ColorFromString("lch(50 10 10%)"), ShouldBeNil)
+	})
+
 	test("css-parsing-tests/color3.json", 1)
 	test("css-parsing-tests/color3_hsl.json", 1)
 	test("css-parsing-tests/color3_keywords.json", 255)
@@ -0,0 +1,202 @@
+package css3
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// colorSource records the text a color was parsed from and the channels it
+// had then, so that it's written back the same way unless it's modified.
+type colorSource struct {
+	text     string
+	channels [4]float64
+	space    ColorSpace
+	missing  [4]bool
+}
+
+// withSource records text as what c was parsed from.
+func (c *Color) withSource(text string) *Color {
+	if c != nil {
+		c.source = &colorSource{text, [4]float64{c.R, c.G, c.B, c.A}, c.Space, c.Missing}
+	}
+	return c
+}
+
+// modified reports whether c has changed since it was parsed.
+func (c *Color) modified() bool {
+	s := c.source
+	return s == nil || s.channels != [4]float64{c.R, c.G, c.B, c.A} || s.space != c.Space || s.missing != c.Missing
+}
+
+// colorKeywords maps hex codes to the shortest keyword for the color, or
+// the first alphabetically of those as short.
+var colorKeywords = make(map[string]string)
+
+func init() {
+	names := make([]string, 0, len(ExtendedColorKeywords))
+	for name := range ExtendedColorKeywords {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		hex := ExtendedColorKeywords[name]
+		if prev, ok := colorKeywords[hex]; !ok || len(name) < len(prev) {
+			colorKeywords[hex] = name
+		}
+	}
+}
+
+// formatChannel formats v as a CSS number with at most six decimal places,
+// leaving out a leading zero if compressed is true.
+func formatChannel(v float64, compressed bool) string {
+	v = math.Round(v*1e6) / 1e6
+	if v == 0 {
+		v = 0
+	}
+	s := strconv.FormatFloat(v, 'f', -1, 64)
+	if compressed {
+		if strings.HasPrefix(s, "0.") {
+			s = s[1:]
+		} else if strings.HasPrefix(s, "-0.") {
+			s = "-" + s[2:]
+		}
+	}
+	return s
+}
+
+// byteChannel returns v, a channel in the range 0-1, as an integer in the
+// range 0-255, and whether it's exactly representable as one.
+func byteChannel(v float64) (int, bool) {
+	x := v * 255
+	r := math.Round(x)
+	return int(r), math.Abs(x-r) < 1e-6 && 0 <= r && r <= 255
+}
+
+// String returns the CSS for c in its own color space, using the
+// whitespace-separated syntax of CSS Color 4.
+func (c *Color) String() string {
+	return c.modernSyntax(false)
+}
+
+// inRGBRange reports whether the channels of c, an sRGB color, are all in
+// the range rgb() can express.
+func (c *Color) inRGBRange() bool {
+	for i, v := range []float64{c.R, c.G, c.B} {
+		if x := math.Round(v*255*1e6) / 1e6; !c.Missing[i] && (x < 0 || x > 255) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Color) modernSyntax(compressed bool) string {
+	if c.CurrentColor {
+		return "currentcolor"
+	}
+	channels := []float64{c.R, c.G, c.B}
+	var prefix string
+	switch c.Space {
+	case SRGB:
+		if !c.inRGBRange() {
+			// rgb() clamps its channels, so colors out of the sRGB gamut
+			// are written with color() instead.
+			prefix = "color(srgb "
+			break
+		}
+		prefix = "rgb("
+		for i := range channels {
+			channels[i] *= 255
+		}
+	case Lab, LCH, OKLab, OKLCH:
+		prefix = c.Space.String() + "("
+	default:
+		prefix = "color(" + c.Space.String() + " "
+	}
+	parts := make([]string, 3)
+	for i, v := range channels {
+		if c.Missing[i] {
+			parts[i] = "none"
+		} else {
+			parts[i] = formatChannel(v, compressed)
+		}
+	}
+	s := prefix + strings.Join(parts, " ")
+	slash := " / "
+	if compressed {
+		slash = "/"
+	}
+	switch {
+	case c.Missing[3]:
+		s += slash + "none"
+	case c.A != 1:
+		s += slash + formatChannel(c.A, compressed)
+	}
+	return s + ")"
+}
+
+// Format returns the CSS for c. An unmodified color is written the way it
+// was parsed, unless compressed is true, when it's written in its shortest
+// equivalent form. Opaque sRGB colors are otherwise written as keywords or
+// hex codes and translucent ones with rgba(); colors that can't be written
+// that way without losing precision, or in other color spaces, are written
+// with the syntax of CSS Color 4.
+func (c *Color) Format(compressed bool) string {
+	if c.CurrentColor {
+		return "currentcolor"
+	}
+	if !compressed && !c.modified() {
+		return c.source.text
+	}
+	shortest := c.format(compressed)
+	if compressed && !c.modified() && len(c.source.text) < len(shortest) {
+		return c.source.text
+	}
+	return shortest
+}
+
+func (c *Color) format(compressed bool) string {
+	var rgb [4]int
+	exact := c.Space == SRGB && c.Missing == [4]bool{}
+	for i, v := range []float64{c.R, c.G, c.B, c.A} {
+		var ok bool
+		rgb[i], ok = byteChannel(v)
+		exact = exact && (ok || i == 3)
+	}
+	if !exact {
+		return c.modernSyntax(compressed)
+	}
+	hex := fmt.Sprintf("#%02x%02x%02x", rgb[0], rgb[1], rgb[2])
+	if c.A >= 1 {
+		short := hex
+		if compressed {
+			short = shortHex(hex)
+		}
+		if name, ok := colorKeywords[hex]; ok && (!compressed || len(name) < len(short)) {
+			return name
+		}
+		return short
+	}
+	if !compressed {
+		return fmt.Sprintf("rgba(%d, %d, %d, %s)", rgb[0], rgb[1], rgb[2], formatChannel(c.A, false))
+	}
+	if _, ok := byteChannel(c.A); ok {
+		return shortHex(hex + fmt.Sprintf("%02x", rgb[3]))
+	}
+	return fmt.Sprintf("rgba(%d,%d,%d,%s)", rgb[0], rgb[1], rgb[2], formatChannel(c.A, true))
+}
+
+// shortHex returns the three or four digit form of a hex code if it has
+// one.
+func shortHex(hex string) string {
+	short := []byte{'#'}
+	for i := 1; i < len(hex); i += 2 {
+		if hex[i] != hex[i+1] {
+			return hex
+		}
+		short = append(short, hex[i])
+	}
+	return string(short)
+}
@@ -0,0 +1,59 @@
+package css3
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestColorFormat(t *testing.T) {
+	format := func(s string, compressed bool) string {
+		return ColorFromString(s).Format(compressed)
+	}
+
+	Convey("Unmodified colors keep their format", t, func() {
+		So(format("#FFF", false), ShouldEqual, "#FFF")
+		So(format("  White ", false), ShouldEqual, "White")
+		So(format("rgb(255, 0, 0)", false), ShouldEqual, "rgb(255, 0, 0)")
+		So(format("hsl(120deg 100% 25%)", false), ShouldEqual, "hsl(120deg 100% 25%)")
+		So(format("currentColor", false), ShouldEqual, "currentcolor")
+	})
+
+	Convey("Modified colors", t, func() {
+		c := ColorFromString("#336699")
+		c.A = 0.5
+		So(c.Format(false), ShouldEqual, "rgba(51, 102, 153, 0.5)")
+		So(c.Format(true), ShouldEqual, "rgba(51,102,153,.5)")
+		c.A = 0
+		So(c.Format(false), ShouldEqual, "rgba(51, 102, 153, 0)")
+		c.A = 1
+		So(c.Format(false), ShouldEqual, "#336699")
+		So(c.Format(true), ShouldEqual, "#369")
+		c.R, c.G, c.B = 0, 0x80/255.0, 0
+		So(c.Format(false), ShouldEqual, "green")
+		So(RGB(1, 1, 1).Format(false), ShouldEqual, "white")
+		So(RGB(0.1, 0.2, 0.3).Format(false), ShouldEqual, "rgb(25.5 51 76.5)")
+		So(RGB(1.2, 0, 0).Format(true), ShouldEqual, "color(srgb 1.2 0 0)")
+		So(RGB(1, -0.25, 0).String(), ShouldEqual, "color(srgb 1 -0.25 0)")
+	})
+
+	Convey("Shortest forms", t, func() {
+		So(format("#ffffff", true), ShouldEqual, "#fff")
+		So(format("white", true), ShouldEqual, "#fff")
+		So(format("#ff0000", true), ShouldEqual, "red")
+		So(format("rgb(0 0 128)", true), ShouldEqual, "navy")
+		So(format("#f00", true), ShouldEqual, "red")
+		So(format("#c0c0c0", true), ShouldEqual, "silver")
+		So(format("#123456", true), ShouldEqual, "#123456")
+		So(format("rgba(0, 0, 0, 0)", true), ShouldEqual, "#0000")
+		So(format("color(srgb 1.5 0 0)", true), ShouldEqual, "color(srgb 1.5 0 0)")
+		So(format("transparent", false), ShouldEqual, "transparent")
+		So(format("#11223344", true), ShouldEqual, "#1234")
+		So(format("#ff000080", true), ShouldEqual, "#ff000080")
+		So(format("rgb(255 0 0 / 50%)", true), ShouldEqual, "rgba(255,0,0,.5)")
+		So(format("lab(50% 40 -25.5 / 0.5)", true), ShouldEqual, "lab(50 40 -25.5/.5)")
+		So(format("color(display-p3 1 0.5 none)", true), ShouldEqual, "color(display-p3 1 .5 none)")
+		So(format("rgb(none 0 0)", true), ShouldEqual, "rgb(none 0 0)")
+		So(format("currentcolor", true), ShouldEqual, "currentcolor")
+	})
+}
@@ -0,0 +1,230 @@
+package css3
+
+import (
+	"math"
+)
+
+// splitArguments splits function arguments at commas into groups of the
+// nodes between them, leaving out whitespace.
+func splitArguments(values []Node) [][]Node {
+	groups := [][]Node{nil}
+	for _, v := range values {
+		if t, ok := v.(*TokenNode); ok {
+			switch t.TokenType {
+			case WhitespaceToken:
+				continue
+			case CommaToken:
+				groups = append(groups, nil)
+				continue
+			}
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], v)
+	}
+	return groups
+}
+
+func identValue(n Node) (string, bool) {
+	t, ok := n.(*TokenNode)
+	if !ok || t.TokenType != IdentToken {
+		return "", false
+	}
+	return toLower(string(t.Value.(Identifier))), true
+}
+
+// interpolationSpace describes a color space colors may be mixed in. Its
+// channels are those of the space's CSS function, and hue is the index of
+// the hue channel, or -1 if there is none.
+type interpolationSpace struct {
+	space ColorSpace
+	name  string
+	hue   int
+}
+
+func interpolationSpaceFromName(name string) (interpolationSpace, bool) {
+	switch name {
+	case "hsl", "hwb":
+		return interpolationSpace{SRGB, name, 0}, true
+	case "lch", "oklch":
+		space := LCH
+		if name == "oklch" {
+			space = OKLCH
+		}
+		return interpolationSpace{space, name, 2}, true
+	case "lab":
+		return interpolationSpace{Lab, name, -1}, true
+	case "oklab":
+		return interpolationSpace{OKLab, name, -1}, true
+	}
+	space, ok := ColorSpaceFromName(name)
+	return interpolationSpace{space, name, -1}, ok
+}
+
+// channels returns the channels of c in s, with the ones that are missing.
+func (s interpolationSpace) channels(c *Color) (v [4]float64, missing [4]bool) {
+	converted := c.To(s.space)
+	v = [4]float64{converted.R, converted.G, converted.B, converted.A}
+	missing = converted.Missing
+	switch s.name {
+	case "hsl":
+		h, sat, l := converted.ToHSL()
+		v[0], v[1], v[2] = h*360, sat, l
+		missing = [4]bool{sat == 0, false, false, converted.Missing[3]}
+	case "hwb":
+		h, w, b := converted.ToHWB()
+		v[0], v[1], v[2] = h*360, w, b
+		missing = [4]bool{w+b >= 1, false, false, converted.Missing[3]}
+	}
+	return v, missing
+}
+
+// color returns the color with channels v in s.
+func (s interpolationSpace) color(v [4]float64, missing [4]bool) *Color {
+	var c *Color
+	switch s.name {
+	case "hsl":
+		c = HSLA(normDeg(v[0]), v[1], v[2], v[3])
+	case "hwb":
+		c = HWBA(normDeg(v[0]), v[1], v[2], v[3])
+	default:
+		c = &Color{R: v[0], G: v[1], B: v[2], A: v[3], Space: s.space, Missing: missing}
+	}
+	c.Missing[3] = missing[3]
+	return c
+}
+
+// fixupHues adjusts the hues h1 and h2, in degrees, so that interpolating
+// between them goes around the hue circle as method asks.
+func fixupHues(h1, h2 float64, method string) (float64, float64) {
+	h1, h2 = normDeg(h1)*360, normDeg(h2)*360
+	d := h2 - h1
+	switch method {
+	case "shorter":
+		if d > 180 {
+			h1 += 360
+		} else if d < -180 {
+			h2 += 360
+		}
+	case "longer":
+		if 0 < d && d < 180 {
+			h1 += 360
+		} else if -180 < d && d <= 0 {
+			h2 += 360
+		}
+	case "increasing":
+		if d < 0 {
+			h2 += 360
+		}
+	case "decreasing":
+		if d > 0 {
+			h1 += 360
+		}
+	}
+	return h1, h2
+}
+
+// mixComponent parses a color and its optional percentage, in either
+// order, from an argument of color-mix(). The percentage is negative if
+// it's omitted.
+func mixComponent(args []Node) (*Color, float64, bool) {
+	if len(args) == 2 {
+		if _, ok := args[0].(*NumberNode); ok {
+			args[0], args[1] = args[1], args[0]
+		}
+	}
+	if len(args) == 0 || len(args) > 2 {
+		return nil, 0, false
+	}
+	c := ColorFromNodes(args[:1])
+	if c == nil || c.CurrentColor {
+		return nil, 0, false
+	}
+	if len(args) == 1 {
+		return c, -1, true
+	}
+	num, ok := args[1].(*NumberNode)
+	if !ok || num.Type != "percentage" {
+		return nil, 0, false
+	}
+	p := nodeFloat(num)
+	return c, p, 0 <= p && p <= 100
+}
+
+// colorMix evaluates the arguments of color-mix(), as in "color-mix(in
+// oklch, red 40%, blue)", following CSS Color 5: the colors are converted
+// to the interpolation space, and their channels interpolated with alpha
+// premultiplied.
+func colorMix(values []Node) *Color {
+	args := splitArguments(values)
+	if len(args) != 3 || len(args[0]) < 2 {
+		return nil
+	}
+	in, _ := identValue(args[0][0])
+	name, _ := identValue(args[0][1])
+	space, ok := interpolationSpaceFromName(name)
+	if in != "in" || !ok {
+		return nil
+	}
+	method := "shorter"
+	switch len(args[0]) {
+	case 2:
+	case 4:
+		hue, _ := identValue(args[0][3])
+		method, _ = identValue(args[0][2])
+		switch {
+		case space.hue < 0 || hue != "hue":
+			return nil
+		case method != "shorter" && method != "longer" && method != "increasing" && method != "decreasing":
+			return nil
+		}
+	default:
+		return nil
+	}
+	c1, p1, ok1 := mixComponent(args[1])
+	c2, p2, ok2 := mixComponent(args[2])
+	if !ok1 || !ok2 {
+		return nil
+	}
+	switch {
+	case p1 < 0 && p2 < 0:
+		p1, p2 = 50, 50
+	case p1 < 0:
+		p1 = 100 - p2
+	case p2 < 0:
+		p2 = 100 - p1
+	}
+	sum := p1 + p2
+	if sum == 0 {
+		return nil
+	}
+	multiplier := math.Min(sum, 100) / 100
+	p1, p2 = p1/sum, p2/sum
+
+	v1, missing1 := space.channels(c1)
+	v2, missing2 := space.channels(c2)
+	var missing [4]bool
+	for i := range v1 {
+		switch {
+		case missing1[i] && missing2[i]:
+			missing[i] = true
+		case missing1[i]:
+			v1[i] = v2[i]
+		case missing2[i]:
+			v2[i] = v1[i]
+		}
+	}
+	var result [4]float64
+	result[3] = v1[3]*p1 + v2[3]*p2
+	for i := 0; i < 3; i++ {
+		if i == space.hue {
+			h1, h2 := fixupHues(v1[i], v2[i], method)
+			result[i] = normDeg(h1*p1+h2*p2) * 360
+			continue
+		}
+		result[i] = v1[i]*v1[3]*p1 + v2[i]*v2[3]*p2
+		if result[3] != 0 {
+			result[i] /= result[3]
+		}
+	}
+	result[3] *= multiplier
+	return space.color(result, missing)
+}
@@ -0,0 +1,64 @@
+package css3
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestColorMix(t *testing.T) {
+	shouldParseTo := func(actual interface{}, expected ...interface{}) string {
+		c := ColorFromString(actual.(string))
+		if c == nil {
+			return "Expected " + actual.(string) + " to be a color"
+		}
+		for i, v := range []float64{c.R, c.G, c.B, c.A} {
+			if msg := ShouldAlmostEqual(v, expected[i], 0.01); msg != "" {
+				return c.String() + "\n" + msg
+			}
+		}
+		return ""
+	}
+
+	Convey("color-mix()", t, func() {
+		So("color-mix(in srgb, red, blue)", shouldParseTo, 0.5, 0.0, 0.5, 1.0)
+		So("color-mix(in srgb, red 40%, blue)", shouldParseTo, 0.4, 0.0, 0.6, 1.0)
+		So("color-mix(in srgb, 40% red, blue 20%)", shouldParseTo, 2.0/3, 0.0, 1.0/3, 0.6)
+		So("color-mix(in lch, peru 40%, palegoldenrod)", shouldParseTo, 79.7256, 40.448, 84.771, 1.0)
+		So("color-mix(in srgb, rgb(100% 0% 0% / 0.7) 25%, rgb(0% 100% 0% / 0.2))", shouldParseTo, 0.5385, 0.4615, 0.0, 0.325)
+		So("color-mix(in hsl, red, blue)", shouldParseTo, 1.0, 0.0, 1.0, 1.0)
+		So("color-mix(in hsl longer hue, red, blue)", shouldParseTo, 0.0, 1.0, 0.0, 1.0)
+		So("color-mix(in oklch, oklch(0.5 0.1 none), oklch(0.7 0.2 120))", shouldParseTo, 0.6, 0.15, 120.0, 1.0)
+		So(ColorFromString("color-mix(in oklch, red 40%, blue)").Space, ShouldEqual, OKLCH)
+
+		So(ColorFromString("color-mix(in srgb, var(--x), blue)"), ShouldBeNil)
+		So(ColorFromString("color-mix(in srgb, red 0%, blue 0%)"), ShouldBeNil)
+		So(ColorFromString("color-mix(in srgb, red 120%, blue)"), ShouldBeNil)
+		So(ColorFromString("color-mix(in srgb longer hue, red, blue)"), ShouldBeNil)
+		So(ColorFromString("color-mix(in hsv, red, blue)"), ShouldBeNil)
+		So(ColorFromString("color-mix(srgb, red, blue)"), ShouldBeNil)
+	})
+
+	Convey("Relative colors", t, func() {
+		So("rgb(from red r g b / 50%)", shouldParseTo, 1.0, 0.0, 0.0, 0.5)
+		So("rgb(from #336699 b g r)", shouldParseTo, 0.6, 0.4, 0.2, 1.0)
+		So("rgb(from rgb(0 0 0 / 0.5) 255 g b / alpha)", shouldParseTo, 1.0, 0.0, 0.0, 0.5)
+		So("hsl(from #336699 h s 20%)", shouldParseTo, 0.1, 0.2, 0.3, 1.0)
+		So("hwb(from #336699 h 0% b)", shouldParseTo, 0.0, 0.3, 0.6, 1.0)
+		So("lab(from red l 0 0)", shouldParseTo, 54.29054, 0.0, 0.0, 1.0)
+		So("oklch(from color-mix(in srgb, red, blue) l c h)", shouldParseTo, 0.41973, 0.19291, 328.36, 1.0)
+		So(ColorFromString("color(from red display-p3 r g b)").String(), ShouldEqual, "color(display-p3 0.917488 0.200287 0.138561)")
+		So("color(from red xyz x y z)", shouldParseTo, 0.41239, 0.21264, 0.01933, 1.0)
+		So("hsl(from #336699 calc(h + 180) s l)", shouldParseTo, 0.6, 0.4, 0.2, 1.0)
+		So("rgb(from red calc(r / 2) g b)", shouldParseTo, 0.5, 0.0, 0.0, 1.0)
+		So("rgb(from red r g b / calc(alpha / 2))", shouldParseTo, 1.0, 0.0, 0.0, 0.5)
+
+		So(ColorFromString("rgb(from var(--x) r g b)"), ShouldBeNil)
+		So(ColorFromString("rgb(from currentcolor r g b)"), ShouldBeNil)
+		So(ColorFromString("rgb(from red r g x)"), ShouldBeNil)
+		So(ColorFromString("rgb(from red calc(r + 1px) g b)"), ShouldBeNil)
+		So(ColorFromString("rgb(from red l a b)"), ShouldBeNil)
+		So(ColorFromString("rgb(from red, r, g, b)"), ShouldBeNil)
+		So(ColorFromString("rgb(from)"), ShouldBeNil)
+	})
+}
@@ -0,0 +1,422 @@
+package css3
+
+import (
+	"math"
+)
+
+// The conversions here follow the sample code of CSS Color 4. Colors are
+// converted through XYZ with the D65 white point, adapting to D50 for the
+// spaces that use it.
+
+type vector [3]float64
+
+type matrix [3]vector
+
+func (m *matrix) mul(v vector) vector {
+	var result vector
+	for i, row := range m {
+		result[i] = row[0]*v[0] + row[1]*v[1] + row[2]*v[2]
+	}
+	return result
+}
+
+var (
+	linearSRGBToXYZ = matrix{
+		{0.41239079926595934, 0.357584339383878, 0.1804807884018343},
+		{0.21263900587151027, 0.715168678767756, 0.07219231536073371},
+		{0.01933081871559182, 0.11919477979462598, 0.9505321522496607},
+	}
+	xyzToLinearSRGB = matrix{
+		{3.2409699419045226, -1.537383177570094, -0.4986107602930034},
+		{-0.9692436362808796, 1.8759675015077202, 0.04155505740717559},
+		{0.05563007969699366, -0.20397695888897652, 1.0569715142428786},
+	}
+	linearP3ToXYZ = matrix{
+		{608311.0 / 1250200, 189793.0 / 714400, 198249.0 / 1000160},
+		{35783.0 / 156275, 247089.0 / 357200, 198249.0 / 2500400},
+		{0, 32229.0 / 714400, 5220557.0 / 5000800},
+	}
+	xyzToLinearP3 = matrix{
+		{446124.0 / 178915, -333277.0 / 357830, -72051.0 / 178915},
+		{-14852.0 / 17905, 63121.0 / 35810, 423.0 / 17905},
+		{11844.0 / 330415, -50337.0 / 660830, 316169.0 / 330415},
+	}
+	linearA98RGBToXYZ = matrix{
+		{573536.0 / 994567, 263643.0 / 1420810, 187206.0 / 994567},
+		{591459.0 / 1989134, 6239551.0 / 9945670, 374412.0 / 4972835},
+		{53769.0 / 1989134, 351524.0 / 4972835, 4929758.0 / 4972835},
+	}
+	xyzToLinearA98RGB = matrix{
+		{1829569.0 / 896150, -506331.0 / 896150, -308931.0 / 896150},
+		{-851781.0 / 878810, 1648619.0 / 878810, 36519.0 / 878810},
+		{16779.0 / 1248040, -147721.0 / 1248040, 1266979.0 / 1248040},
+	}
+	linearProPhotoToXYZD50 = matrix{
+		{0.7977666449006423, 0.13518129740053308, 0.0313477341283922},
+		{0.2880748288194013, 0.711835234241873, 0.00008993693872564},
+		{0, 0, 0.8251046025104602},
+	}
+	xyzD50ToLinearProPhoto = matrix{
+		{1.3457868816471583, -0.25557208737979464, -0.05110186497554526},
+		{-0.5446307051249019, 1.5082477428451468, 0.02052744743642139},
+		{0, 0, 1.2119675456389452},
+	}
+	linearRec2020ToXYZ = matrix{
+		{63426534.0 / 99577255, 20160776.0 / 139408157, 47086771.0 / 278816314},
+		{26158966.0 / 99577255, 472592308.0 / 697040785, 8267143.0 / 139408157},
+		{0, 19567812.0 / 697040785, 295819943.0 / 278816314},
+	}
+	xyzToLinearRec2020 = matrix{
+		{30757411.0 / 17917100, -6372589.0 / 17917100, -4539589.0 / 17917100},
+		{-19765991.0 / 29648200, 47925759.0 / 29648200, 467509.0 / 29648200},
+		{792561.0 / 44930125, -1921689.0 / 44930125, 42328811.0 / 44930125},
+	}
+	// Bradford chromatic adaptation between the D65 and D50 white points.
+	d65ToD50 = matrix{
+		{1.0479297925449969, 0.022946870601609652, -0.05019226628920524},
+		{0.02962780877005599, 0.9904344267538799, -0.017073799063418826},
+		{-0.009243040646204504, 0.015055191490298152, 0.7518742814281371},
+	}
+	d50ToD65 = matrix{
+		{0.955473421488075, -0.02309845494876471, 0.06325924320057072},
+		{-0.0283697093338637, 1.0099953980813041, 0.021041441191917323},
+		{0.012314014864481998, -0.020507649298898964, 1.330365926242124},
+	}
+	xyzToLMS = matrix{
+		{0.8190224379967030, 0.3619062600528904, -0.1288737815209879},
+		{0.0329836539323885, 0.9292868615863434, 0.0361446663506424},
+		{0.0481771893596242, 0.2642395317527308, 0.6335478284694309},
+	}
+	lmsToXYZ = matrix{
+		{1.2268798758459243, -0.5578149944602171, 0.2813910456659647},
+		{-0.0405757452148008, 1.1122868032803170, -0.0717110580655164},
+		{-0.0763729366746601, -0.4214933324022432, 1.5869240198367816},
+	}
+	lmsToOKLab = matrix{
+		{0.2104542683093140, 0.7936177747023054, -0.0040720430116193},
+		{1.9779985324311684, -2.4285922420485799, 0.4505937096174110},
+		{0.0259040424655478, 0.7827717124575296, -0.8086757549230774},
+	}
+	okLabToLMS = matrix{
+		{1, 0.3963377773761749, 0.2158037573099136},
+		{1, -0.1055613458156586, -0.0638541728258133},
+		{1, -0.0894841775298119, -1.2914855480194092},
+	}
+)
+
+var d50White = vector{0.3457 / 0.3585, 1, (1 - 0.3457 - 0.3585) / 0.3585}
+
+const (
+	labEpsilon = 216.0 / 24389
+	labKappa   = 24389.0 / 27
+)
+
+// eachChannel applies f to each channel of v, preserving its sign so that
+// transfer functions extend to values outside the range 0-1.
+func eachChannel(v vector, f func(float64) float64) vector {
+	for i, c := range v {
+		v[i] = math.Copysign(f(math.Abs(c)), c)
+	}
+	return v
+}
+
+func linearSRGB(v vector) vector {
+	return eachChannel(v, func(c float64) float64 {
+		if c <= 0.04045 {
+			return c / 12.92
+		}
+		return math.Pow((c+0.055)/1.055, 2.4)
+	})
+}
+
+func gammaSRGB(v vector) vector {
+	return eachChannel(v, func(c float64) float64 {
+		if c <= 0.0031308 {
+			return c * 12.92
+		}
+		return 1.055*math.Pow(c, 1/2.4) - 0.055
+	})
+}
+
+func linearA98RGB(v vector) vector {
+	return eachChannel(v, func(c float64) float64 { return math.Pow(c, 563.0/256) })
+}
+
+func gammaA98RGB(v vector) vector {
+	return eachChannel(v, func(c float64) float64 { return math.Pow(c, 256.0/563) })
+}
+
+func linearProPhoto(v vector) vector {
+	return eachChannel(v, func(c float64) float64 {
+		if c <= 16.0/512 {
+			return c / 16
+		}
+		return math.Pow(c, 1.8)
+	})
+}
+
+func gammaProPhoto(v vector) vector {
+	return eachChannel(v, func(c float64) float64 {
+		if c < 1.0/512 {
+			return c * 16
+		}
+		return math.Pow(c, 1/1.8)
+	})
+}
+
+const (
+	rec2020Alpha = 1.09929682680944
+	rec2020Beta  = 0.018053968510807
+)
+
+func linearRec2020(v vector) vector {
+	return eachChannel(v, func(c float64) float64 {
+		if c < rec2020Beta*4.5 {
+			return c / 4.5
+		}
+		return math.Pow((c+rec2020Alpha-1)/rec2020Alpha, 1/0.45)
+	})
+}
+
+func gammaRec2020(v vector) vector {
+	return eachChannel(v, func(c float64) float64 {
+		if c <= rec2020Beta {
+			return c * 4.5
+		}
+		return rec2020Alpha*math.Pow(c, 0.45) - (rec2020Alpha - 1)
+	})
+}
+
+func xyzD50ToLab(xyz vector) vector {
+	var f vector
+	for i, c := range xyz {
+		if c /= d50White[i]; c > labEpsilon {
+			f[i] = math.Cbrt(c)
+		} else {
+			f[i] = (labKappa*c + 16) / 116
+		}
+	}
+	return vector{116*f[1] - 16, 500 * (f[0] - f[1]), 200 * (f[1] - f[2])}
+}
+
+func labToXYZD50(lab vector) vector {
+	f1 := (lab[0] + 16) / 116
+	f0 := lab[1]/500 + f1
+	f2 := f1 - lab[2]/200
+	xyz := vector{(116*f0 - 16) / labKappa, lab[0] / labKappa, (116*f2 - 16) / labKappa}
+	if math.Pow(f0, 3) > labEpsilon {
+		xyz[0] = math.Pow(f0, 3)
+	}
+	if lab[0] > labKappa*labEpsilon {
+		xyz[1] = math.Pow(f1, 3)
+	}
+	if math.Pow(f2, 3) > labEpsilon {
+		xyz[2] = math.Pow(f2, 3)
+	}
+	for i := range xyz {
+		xyz[i] *= d50White[i]
+	}
+	return xyz
+}
+
+func xyzToOKLab(xyz vector) vector {
+	lms := xyzToLMS.mul(xyz)
+	for i, c := range lms {
+		lms[i] = math.Cbrt(c)
+	}
+	return lmsToOKLab.mul(lms)
+}
+
+func okLabToXYZ(lab vector) vector {
+	lms := okLabToLMS.mul(lab)
+	for i, c := range lms {
+		lms[i] = c * c * c
+	}
+	return lmsToXYZ.mul(lms)
+}
+
+// toPolar converts the a and b axes of lab into chroma and a hue in
+// degrees. The hue is powerless, and reported as missing, if the chroma is
+// at most epsilon.
+func toPolar(lab vector, epsilon float64) (vector, bool) {
+	hue := math.Atan2(lab[2], lab[1]) * 180 / math.Pi
+	if hue < 0 {
+		hue += 360
+	}
+	chroma := math.Hypot(lab[1], lab[2])
+	if chroma <= epsilon {
+		return vector{lab[0], chroma, 0}, true
+	}
+	return vector{lab[0], chroma, hue}, false
+}
+
+func fromPolar(lch vector) vector {
+	hue := lch[2] * math.Pi / 180
+	return vector{lch[0], lch[1] * math.Cos(hue), lch[1] * math.Sin(hue)}
+}
+
+// toXYZ returns the coordinates of channels in space in XYZ with the D65
+// white point.
+func toXYZ(space ColorSpace, v vector) vector {
+	switch space {
+	case SRGB:
+		return linearSRGBToXYZ.mul(linearSRGB(v))
+	case SRGBLinear:
+		return linearSRGBToXYZ.mul(v)
+	case DisplayP3:
+		return linearP3ToXYZ.mul(linearSRGB(v))
+	case A98RGB:
+		return linearA98RGBToXYZ.mul(linearA98RGB(v))
+	case ProPhotoRGB:
+		return d50ToD65.mul(linearProPhotoToXYZD50.mul(linearProPhoto(v)))
+	case Rec2020:
+		return linearRec2020ToXYZ.mul(linearRec2020(v))
+	case XYZD50:
+		return d50ToD65.mul(v)
+	case Lab:
+		return d50ToD65.mul(labToXYZD50(v))
+	case LCH:
+		return d50ToD65.mul(labToXYZD50(fromPolar(v)))
+	case OKLab:
+		return okLabToXYZ(v)
+	case OKLCH:
+		return okLabToXYZ(fromPolar(v))
+	}
+	return v
+}
+
+// fromXYZ returns the channels in space of the XYZ D65 coordinates xyz, and
+// whether the hue of a polar space is powerless.
+func fromXYZ(space ColorSpace, xyz vector) (vector, bool) {
+	switch space {
+	case SRGB:
+		return gammaSRGB(xyzToLinearSRGB.mul(xyz)), false
+	case SRGBLinear:
+		return xyzToLinearSRGB.mul(xyz), false
+	case DisplayP3:
+		return gammaSRGB(xyzToLinearP3.mul(xyz)), false
+	case A98RGB:
+		return gammaA98RGB(xyzToLinearA98RGB.mul(xyz)), false
+	case ProPhotoRGB:
+		return gammaProPhoto(xyzD50ToLinearProPhoto.mul(d65ToD50.mul(xyz))), false
+	case Rec2020:
+		return gammaRec2020(xyzToLinearRec2020.mul(xyz)), false
+	case XYZD50:
+		return d65ToD50.mul(xyz), false
+	case Lab:
+		return xyzD50ToLab(d65ToD50.mul(xyz)), false
+	case LCH:
+		return toPolar(xyzD50ToLab(d65ToD50.mul(xyz)), 0.0015)
+	case OKLab:
+		return xyzToOKLab(xyz), false
+	case OKLCH:
+		return toPolar(xyzToOKLab(xyz), 0.000004)
+	}
+	return xyz, false
+}
+
+// To returns c converted to space. Missing channels are taken as zero,
+// except for alpha, which stays missing; a hue that's powerless in the new
+// space is missing.
+func (c *Color) To(space ColorSpace) *Color {
+	if c.CurrentColor {
+		return c
+	}
+	if space == c.Space {
+		result := *c
+		return &result
+	}
+	result := &Color{A: c.A, Space: space}
+	result.Missing[3] = c.Missing[3]
+	v := vector{c.R, c.G, c.B}
+	for i := range v {
+		if c.Missing[i] {
+			v[i] = 0
+		}
+	}
+	v, powerless := fromXYZ(space, toXYZ(c.Space, v))
+	result.R, result.G, result.B = v[0], v[1], v[2]
+	result.Missing[2] = powerless
+	return result
+}
+
+// hasGamut reports whether space is bounded, so that colors may fall
+// outside of it. Only the RGB spaces are.
+func (s ColorSpace) hasGamut() bool {
+	return s <= Rec2020
+}
+
+// InGamut reports whether c lies within the gamut of space.
+func (c *Color) InGamut(space ColorSpace) bool {
+	if !space.hasGamut() || c.CurrentColor {
+		return true
+	}
+	const epsilon = 0.000075
+	converted := c.To(space)
+	for _, v := range []float64{converted.R, converted.G, converted.B} {
+		if v < -epsilon || v > 1+epsilon {
+			return false
+		}
+	}
+	return true
+}
+
+// clip returns c in space with its channels clamped to the gamut.
+func (c *Color) clip(space ColorSpace) *Color {
+	result := c.To(space)
+	result.R, result.G, result.B = clamp(result.R), clamp(result.G), clamp(result.B)
+	return result
+}
+
+// deltaEOK returns the distance between c and other in OKLab.
+func (c *Color) deltaEOK(other *Color) float64 {
+	a, b := c.To(OKLab), other.To(OKLab)
+	return math.Sqrt(math.Pow(a.R-b.R, 2) + math.Pow(a.G-b.G, 2) + math.Pow(a.B-b.B, 2))
+}
+
+// ToGamut returns c converted to space, mapped into its gamut with the
+// algorithm of CSS Color 4: chroma in OKLCH is reduced until clipping the
+// color changes it by less than a just noticeable difference.
+func (c *Color) ToGamut(space ColorSpace) *Color {
+	if !space.hasGamut() || c.CurrentColor {
+		return c.To(space)
+	}
+	const (
+		jnd     = 0.02
+		epsilon = 0.0001
+	)
+	current := c.To(OKLCH)
+	switch {
+	case current.R >= 1:
+		return (&Color{R: 1, G: 1, B: 1, A: c.A, Missing: [4]bool{3: c.Missing[3]}}).To(space)
+	case current.R <= 0:
+		return (&Color{A: c.A, Missing: [4]bool{3: c.Missing[3]}}).To(space)
+	case c.InGamut(space):
+		return c.To(space)
+	}
+	clipped := current.clip(space)
+	if clipped.deltaEOK(current) < jnd {
+		return clipped
+	}
+	min, max := 0.0, current.G
+	minInGamut := true
+	for max-min > epsilon {
+		current.G = (min + max) / 2
+		if minInGamut && current.InGamut(space) {
+			min = current.G
+			continue
+		}
+		clipped = current.clip(space)
+		e := clipped.deltaEOK(current)
+		if e >= jnd {
+			max = current.G
+			continue
+		}
+		if jnd-e < epsilon {
+			break
+		}
+		minInGamut = false
+		min = current.G
+	}
+	return clipped
+}
@@ -0,0 +1,80 @@
+package css3
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestColorSpaces(t *testing.T) {
+	shouldHaveChannels := func(actual interface{}, expected ...interface{}) string {
+		c := actual.(*Color)
+		for i, v := range []float64{c.R, c.G, c.B} {
+			if msg := ShouldAlmostEqual(v, expected[i], 0.0001); msg != "" {
+				return c.String() + "\n" + msg
+			}
+		}
+		return ""
+	}
+
+	red := RGB(1, 0, 0)
+
+	Convey("Conversion from sRGB", t, func() {
+		So(red.To(SRGBLinear), shouldHaveChannels, 1.0, 0.0, 0.0)
+		So(red.To(DisplayP3), shouldHaveChannels, 0.91749, 0.20029, 0.13856)
+		So(red.To(Rec2020), shouldHaveChannels, 0.79198, 0.23098, 0.07376)
+		So(red.To(XYZD65), shouldHaveChannels, 0.41239, 0.21264, 0.01933)
+		So(red.To(XYZD50), shouldHaveChannels, 0.43607, 0.22249, 0.01392)
+		So(red.To(Lab), shouldHaveChannels, 54.29054, 80.80492, 69.89098)
+		So(red.To(LCH), shouldHaveChannels, 54.29054, 106.83719, 40.85766)
+		So(red.To(OKLab), shouldHaveChannels, 0.62796, 0.22486, 0.12585)
+		So(red.To(OKLCH), shouldHaveChannels, 0.62796, 0.25768, 29.23389)
+		So(red.To(OKLCH).Space, ShouldEqual, OKLCH)
+	})
+
+	Convey("Round trips", t, func() {
+		c := RGBA(0.2, 0.4, 0.6, 0.5)
+		for space := SRGB; space <= OKLCH; space++ {
+			back := c.To(space).To(SRGB)
+			So(back, shouldHaveChannels, 0.2, 0.4, 0.6)
+			So(back.A, ShouldEqual, 0.5)
+		}
+	})
+
+	Convey("Powerless hues", t, func() {
+		gray := RGB(0.5, 0.5, 0.5)
+		So(gray.To(LCH).Missing, ShouldResemble, [4]bool{false, false, true, false})
+		So(gray.To(OKLCH).Missing, ShouldResemble, [4]bool{false, false, true, false})
+		So(ColorFromString("oklch(50% none 120)").To(SRGB).Missing, ShouldResemble, [4]bool{})
+		So(ColorFromString("rgb(0 0 0 / none)").To(Lab).Missing[3], ShouldBeTrue)
+	})
+
+	Convey("Gamut", t, func() {
+		So(red.InGamut(SRGB), ShouldBeTrue)
+		So(red.InGamut(DisplayP3), ShouldBeTrue)
+		p3 := ColorFromString("color(display-p3 1 0 0)")
+		So(p3.InGamut(SRGB), ShouldBeFalse)
+		So(p3.To(SRGB).String(), ShouldStartWith, "color(srgb 1.09")
+		So(p3.InGamut(DisplayP3), ShouldBeTrue)
+		So(ColorFromString("color(display-p3 0.9 0.2 0.1)").InGamut(Rec2020), ShouldBeTrue)
+		So(ColorFromString("lab(50 500 0)").InGamut(Lab), ShouldBeTrue)
+	})
+
+	Convey("Gamut mapping", t, func() {
+		So(red.ToGamut(SRGB), shouldHaveChannels, 1.0, 0.0, 0.0)
+		So(ColorFromString("oklch(110% 0.2 30)").ToGamut(SRGB), shouldHaveChannels, 1.0, 1.0, 1.0)
+		So(ColorFromString("lab(-10 20 30)").ToGamut(SRGB), shouldHaveChannels, 0.0, 0.0, 0.0)
+
+		for _, s := range []string{"color(display-p3 1 0 0)", "oklch(70% 0.4 150)", "lch(50% 150 270 / 0.5)"} {
+			c := ColorFromString(s)
+			mapped := c.ToGamut(SRGB)
+			So(mapped.Space, ShouldEqual, SRGB)
+			So(mapped.InGamut(SRGB), ShouldBeTrue)
+			So(mapped.A, ShouldEqual, c.A)
+			original, result := c.To(OKLCH), mapped.To(OKLCH)
+			So(result.R, ShouldAlmostEqual, original.R, 0.02)
+			So(result.B, ShouldAlmostEqual, original.B, 3)
+			So(result.G, ShouldBeLessThan, original.G)
+		}
+	})
+}
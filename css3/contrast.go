@@ -0,0 +1,27 @@
+package css3
+
+import (
+	"math"
+)
+
+// WCAG 2 minimum contrast ratios for normal text.
+const (
+	ContrastAA  = 4.5
+	ContrastAAA = 7.0
+)
+
+// RelativeLuminance returns the relative luminance of c as WCAG 2 defines
+// it, from 0 for black to 1 for white. c is converted to sRGB, clipping it
+// to the gamut, and its alpha is ignored.
+func (c *Color) RelativeLuminance() float64 {
+	srgb := c.clip(SRGB)
+	v := linearSRGB(vector{srgb.R, srgb.G, srgb.B})
+	return 0.2126*v[0] + 0.7152*v[1] + 0.0722*v[2]
+}
+
+// ContrastRatio returns the WCAG 2 contrast ratio between c and other,
+// from 1 for colors of the same luminance to 21 for black and white.
+func (c *Color) ContrastRatio(other *Color) float64 {
+	l1, l2 := c.RelativeLuminance(), other.RelativeLuminance()
+	return (math.Max(l1, l2) + 0.05) / (math.Min(l1, l2) + 0.05)
+}
@@ -0,0 +1,28 @@
+package css3
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestContrast(t *testing.T) {
+	Convey("Relative luminance", t, func() {
+		So(RGB(0, 0, 0).RelativeLuminance(), ShouldEqual, 0.0)
+		So(RGB(1, 1, 1).RelativeLuminance(), ShouldAlmostEqual, 1)
+		So(RGB(1, 0, 0).RelativeLuminance(), ShouldAlmostEqual, 0.2126)
+		So(ColorFromString("#808080").RelativeLuminance(), ShouldAlmostEqual, 0.2158605, 0.000001)
+		So(ColorFromString("color(display-p3 0 1 0)").RelativeLuminance(), ShouldAlmostEqual, 0.7152)
+	})
+
+	Convey("Contrast ratio", t, func() {
+		black, white := RGB(0, 0, 0), RGB(1, 1, 1)
+		So(black.ContrastRatio(white), ShouldAlmostEqual, 21)
+		So(white.ContrastRatio(black), ShouldAlmostEqual, 21)
+		So(white.ContrastRatio(white), ShouldEqual, 1.0)
+		So(ColorFromString("#777").ContrastRatio(white), ShouldAlmostEqual, 4.478, 0.001)
+		So(ColorFromString("#767676").ContrastRatio(white), ShouldBeGreaterThan, ContrastAA)
+		So(ColorFromString("#595959").ContrastRatio(white), ShouldBeGreaterThan, ContrastAAA)
+		So(ColorFromString("lab(50 0 0)").ContrastRatio(black), ShouldAlmostEqual, 4.68, 0.01)
+	})
+}
@@ -0,0 +1,406 @@
+package css3
+
+import (
+	"bytes"
+	"iter"
+	"strings"
+)
+
+// Trivia is input that doesn't affect the meaning of a stylesheet:
+// whitespace and comments. Type is WhitespaceToken or CommentToken.
+type Trivia struct {
+	Type TokenType
+	Text string
+}
+
+// IsLoud reports whether the trivia is a comment starting with "/*!", which
+// is kept even in compressed output.
+func (t Trivia) IsLoud() bool {
+	return t.Type == CommentToken && strings.HasPrefix(t.Text, "/*!")
+}
+
+// CSTToken is a token in a concrete syntax tree, with Text exactly as it
+// appears in the input. Trivia after a token up to the end of its line is
+// trailing; the rest leads the following token.
+type CSTToken struct {
+	Type     TokenType
+	Text     string
+	Leading  []Trivia
+	Trailing []Trivia
+}
+
+// CSTNode is a node in a concrete syntax tree. Unlike the nodes returned by
+// Parser, it keeps all of its input, so that FormatCST can reproduce it.
+type CSTNode interface {
+	// Tokens returns an iterator over the node's tokens in source order.
+	Tokens() iter.Seq[*CSTToken]
+	children() []CSTNode
+}
+
+// CSTBlock is a simple block or a function. Open is the "{", "[", "(" or
+// function token, and Close is nil if the input ended first. The values of
+// a rule's block are declarations and rules; otherwise they're component
+// values.
+type CSTBlock struct {
+	Open   *CSTToken
+	Values []CSTNode
+	Close  *CSTToken
+}
+
+// CSTDeclaration is a declaration. Semicolon is nil if the declaration
+// ended at the end of its block.
+type CSTDeclaration struct {
+	Name      *CSTToken
+	Colon     *CSTToken
+	Value     []CSTNode
+	Semicolon *CSTToken
+}
+
+// CSTRule is an at-rule, whose prelude starts with its at-keyword, or a
+// qualified rule. A rule without a block is either an at-rule ended by
+// Semicolon or invalid.
+type CSTRule struct {
+	Prelude   []CSTNode
+	Block     *CSTBlock
+	Semicolon *CSTToken
+}
+
+// CSTStylesheet is the root of a concrete syntax tree. Its rules may
+// include stray CDO and CDC tokens. EOF is an empty token whose leading
+// trivia ends the input.
+type CSTStylesheet struct {
+	Rules []CSTNode
+	EOF   *CSTToken
+}
+
+func (t *CSTToken) Tokens() iter.Seq[*CSTToken]       { return cstTokens(t) }
+func (n *CSTBlock) Tokens() iter.Seq[*CSTToken]       { return cstTokens(n) }
+func (n *CSTDeclaration) Tokens() iter.Seq[*CSTToken] { return cstTokens(n) }
+func (n *CSTRule) Tokens() iter.Seq[*CSTToken]        { return cstTokens(n) }
+func (n *CSTStylesheet) Tokens() iter.Seq[*CSTToken]  { return cstTokens(n) }
+
+func (t *CSTToken) children() []CSTNode { return nil }
+
+func (n *CSTBlock) children() []CSTNode {
+	return cstNodes(append(cstNodes(nil, n.Open), n.Values...), n.Close)
+}
+
+func (n *CSTDeclaration) children() []CSTNode {
+	return cstNodes(append(cstNodes(nil, n.Name, n.Colon), n.Value...), n.Semicolon)
+}
+
+func (n *CSTRule) children() []CSTNode {
+	return cstNodes(n.Prelude[:len(n.Prelude):len(n.Prelude)], n.Block, n.Semicolon)
+}
+
+func (n *CSTStylesheet) children() []CSTNode {
+	return cstNodes(n.Rules[:len(n.Rules):len(n.Rules)], n.EOF)
+}
+
+// cstNodes appends nodes to list, skipping missing tokens and blocks.
+func cstNodes(list []CSTNode, nodes ...CSTNode) []CSTNode {
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *CSTToken:
+			if n == nil {
+				continue
+			}
+		case *CSTBlock:
+			if n == nil {
+				continue
+			}
+		}
+		list = append(list, n)
+	}
+	return list
+}
+
+// cstTokens returns an iterator over the tokens of n.
+func cstTokens(n CSTNode) iter.Seq[*CSTToken] {
+	return func(yield func(*CSTToken) bool) {
+		var walk func(n CSTNode) bool
+		walk = func(n CSTNode) bool {
+			if t, ok := n.(*CSTToken); ok {
+				return yield(t)
+			}
+			for _, child := range n.children() {
+				if !walk(child) {
+					return false
+				}
+			}
+			return true
+		}
+		walk(n)
+	}
+}
+
+// String returns the stylesheet's source text.
+func (n *CSTStylesheet) String() string { return FormatCST(n, false) }
+
+// ParseCST parses a stylesheet into a concrete syntax tree, keeping its
+// comments and whitespace as trivia. Parsing never fails: input that isn't
+// valid CSS is kept in invalid rules.
+func ParseCST(src []byte) *CSTStylesheet {
+	p := &cstParser{tokens: cstTokenize(src)}
+	sheet := &CSTStylesheet{}
+	for p.peek().Type != EOFToken {
+		sheet.Rules = append(sheet.Rules, p.item(EOFToken))
+	}
+	sheet.EOF = p.next()
+	return sheet
+}
+
+// cstTokenize reads the tokens of src, attaching whitespace and comments
+// to them as trivia. The last token is an EOFToken.
+func cstTokenize(src []byte) []*CSTToken {
+	tk := NewByteTokenizer(src)
+	tk.KeepComments = true
+	var tokens []*CSTToken
+	var leading []Trivia
+	var last *CSTToken // the token taking trailing trivia, until a newline
+	for {
+		t := tk.Next()
+		switch t.Type {
+		case WhitespaceToken, CommentToken:
+			text := string(t.Raw())
+			if last == nil {
+				leading = append(leading, Trivia{t.Type, text})
+				continue
+			}
+			if i := bytes.IndexAny(t.Raw(), "\n\r\f"); t.Type == WhitespaceToken && i >= 0 {
+				i = skipNewline(t.Raw(), i)
+				last.Trailing = append(last.Trailing, Trivia{t.Type, text[:i]})
+				if i < len(text) {
+					leading = append(leading, Trivia{t.Type, text[i:]})
+				}
+				last = nil
+				continue
+			}
+			last.Trailing = append(last.Trailing, Trivia{t.Type, text})
+		default:
+			tok := &CSTToken{Type: t.Type, Text: string(t.Raw()), Leading: leading}
+			tokens = append(tokens, tok)
+			if t.Type == EOFToken {
+				return tokens
+			}
+			leading, last = nil, tok
+		}
+	}
+}
+
+type cstParser struct {
+	tokens []*CSTToken
+	pos    int
+}
+
+func (p *cstParser) peek() *CSTToken { return p.tokens[p.pos] }
+
+func (p *cstParser) next() *CSTToken {
+	t := p.tokens[p.pos]
+	if t.Type != EOFToken {
+		p.pos++
+	}
+	return t
+}
+
+// item consumes a rule, or a declaration or stray semicolon in a block
+// ended by end, or a stray CDO or CDC token at the top level.
+func (p *cstParser) item(end TokenType) CSTNode {
+	switch t := p.peek(); {
+	case t.Type == AtKeywordToken:
+		return p.rule(end, true)
+	case end == EOFToken && (t.Type == CDOToken || t.Type == CDCToken):
+		return p.next()
+	case end == RCurlyToken && t.Type == SemicolonToken:
+		return p.next()
+	case end == RCurlyToken && t.Type == IdentToken:
+		if decl := p.declaration(); decl != nil {
+			return decl
+		}
+	}
+	return p.rule(end, false)
+}
+
+func (p *cstParser) rule(end TokenType, at bool) *CSTRule {
+	rule := &CSTRule{}
+	for {
+		switch t := p.peek(); {
+		case t.Type == EOFToken || t.Type == end:
+			return rule
+		case t.Type == LCurlyToken:
+			rule.Block = p.ruleBlock()
+			return rule
+		case t.Type == SemicolonToken && (at || end != EOFToken):
+			rule.Semicolon = p.next()
+			return rule
+		}
+		rule.Prelude = append(rule.Prelude, p.componentValue())
+	}
+}
+
+func (p *cstParser) ruleBlock() *CSTBlock {
+	block := &CSTBlock{Open: p.next()}
+	for t := p.peek(); t.Type != RCurlyToken && t.Type != EOFToken; t = p.peek() {
+		block.Values = append(block.Values, p.item(RCurlyToken))
+	}
+	if p.peek().Type == RCurlyToken {
+		block.Close = p.next()
+	}
+	return block
+}
+
+// declaration consumes a declaration, or returns nil without consuming
+// anything if the input is a nested rule instead.
+func (p *cstParser) declaration() CSTNode {
+	start := p.pos
+	decl := &CSTDeclaration{Name: p.next()}
+	if p.peek().Type != ColonToken {
+		p.pos = start
+		return nil
+	}
+	decl.Colon = p.next()
+	custom := strings.HasPrefix(decl.Name.Text, "--")
+	for {
+		switch p.peek().Type {
+		case SemicolonToken:
+			decl.Semicolon = p.next()
+			return decl
+		case RCurlyToken, EOFToken:
+			return decl
+		case LCurlyToken:
+			if !custom {
+				p.pos = start
+				return nil
+			}
+		}
+		decl.Value = append(decl.Value, p.componentValue())
+	}
+}
+
+func (p *cstParser) componentValue() CSTNode {
+	t := p.next()
+	var end TokenType
+	switch t.Type {
+	case LCurlyToken:
+		end = RCurlyToken
+	case LSquareToken:
+		end = RSquareToken
+	case LParenToken, FunctionToken:
+		end = RParenToken
+	default:
+		return t
+	}
+	block := &CSTBlock{Open: t}
+	for t := p.peek(); t.Type != end && t.Type != EOFToken; t = p.peek() {
+		block.Values = append(block.Values, p.componentValue())
+	}
+	if p.peek().Type == end {
+		block.Close = p.next()
+	}
+	return block
+}
+
+// FormatCST prints a concrete syntax tree. Unless compressed, it reproduces
+// the input exactly, along with any edits made to the tree. Compressed
+// output keeps only loud comments, and only the whitespace that's needed.
+func FormatCST(n CSTNode, compressed bool) string {
+	w := &cstWriter{compressed: compressed}
+	w.node(n)
+	if w.prev != nil {
+		w.gap(nil, false)
+	}
+	return w.String()
+}
+
+type cstWriter struct {
+	strings.Builder
+	compressed bool
+	prev       *CSTToken
+	// Whether whitespace after prev can be dropped.
+	tight bool
+}
+
+func (w *cstWriter) node(n CSTNode) {
+	switch n := n.(type) {
+	case *CSTToken:
+		w.token(n, false)
+	case *CSTDeclaration:
+		w.token(n.Name, false)
+		w.token(n.Colon, true)
+		for _, v := range n.Value {
+			w.node(v)
+		}
+		w.token(n.Semicolon, false)
+	default:
+		for _, child := range n.children() {
+			w.node(child)
+		}
+	}
+}
+
+// token writes t with the trivia before it. If tight is set, whitespace
+// around t isn't needed.
+func (w *cstWriter) token(t *CSTToken, tight bool) {
+	if t == nil {
+		return
+	}
+	w.gap(t, tight)
+	w.WriteString(t.Text)
+	w.prev, w.tight = t, tight
+}
+
+// gap writes the trivia between the previous token and next, which is nil
+// at the end of the output.
+func (w *cstWriter) gap(next *CSTToken, tight bool) {
+	var trivia [2][]Trivia
+	if w.prev != nil {
+		trivia[0] = w.prev.Trailing
+	}
+	if next != nil {
+		trivia[1] = next.Leading
+	}
+	var space, comment bool
+	for _, list := range trivia {
+		for _, t := range list {
+			switch {
+			case !w.compressed || t.IsLoud():
+				w.WriteString(t.Text)
+				comment = w.compressed
+			case t.Type == WhitespaceToken:
+				space = true
+			}
+		}
+	}
+	if !w.compressed || comment || w.prev == nil || next == nil || next.Type == EOFToken {
+		return
+	}
+	if cstTokensMerge(w.prev, next) {
+		if space {
+			w.WriteByte(' ')
+		} else {
+			w.WriteString("/**/")
+		}
+	} else if space && !w.tight && !tight && !cstSpaceDroppable(w.prev, next) {
+		w.WriteByte(' ')
+	}
+}
+
+// cstSpaceDroppable reports whether whitespace between a and b is
+// insignificant because of the punctuation around it.
+func cstSpaceDroppable(a, b *CSTToken) bool {
+	switch a.Type {
+	case LCurlyToken, RCurlyToken, SemicolonToken, CommaToken, LParenToken, LSquareToken, FunctionToken:
+		return true
+	}
+	switch b.Type {
+	case LCurlyToken, RCurlyToken, SemicolonToken, CommaToken, RParenToken, RSquareToken:
+		return true
+	}
+	return false
+}
+
+// cstTokensMerge reports whether a and b would be read differently if
+// nothing separated them.
+func cstTokensMerge(a, b *CSTToken) bool {
+	return NewByteTokenizer([]byte(a.Text+b.Text)).Next().End != len(a.Text)
+}
@@ -0,0 +1,83 @@
+package css3
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCST(t *testing.T) {
+	Convey("Printing a CST reproduces its input", t, func() {
+		inputs := []string{"", " ", "/**/", "/* a", "a{", "a{b:c", "a{b:(c}", "@a", "@a;b", "}", "<!-- a{} -->", "a/**/b", "\r\n\f\x00"}
+		for _, path := range []string{
+			"css-parsing-tests/component_value_list.json",
+			"css-parsing-tests/one_component_value.json",
+			"css-parsing-tests/declaration_list.json",
+			"css-parsing-tests/one_declaration.json",
+			"css-parsing-tests/one_rule.json",
+			"css-parsing-tests/rule_list.json",
+			"css-parsing-tests/stylesheet.json",
+		} {
+			data := readJson(path, t).([]interface{})
+			for i := 0; i < len(data); i += 2 {
+				inputs = append(inputs, data[i].(string))
+			}
+		}
+		bench, err := os.ReadFile("testdata/bench.css")
+		So(err, ShouldBeNil)
+		inputs = append(inputs, string(bench))
+
+		for _, input := range inputs {
+			So(ParseCST([]byte(input)).String(), ShouldEqual, input)
+		}
+	})
+
+	Convey("Comments and whitespace are attached as trivia", t, func() {
+		sheet := ParseCST([]byte("/* head */\na /* x */ {\n  /* y */\n  b : c;\n}\n"))
+		So(len(sheet.Rules), ShouldEqual, 1)
+		rule := sheet.Rules[0].(*CSTRule)
+		a := rule.Prelude[0].(*CSTToken)
+		So(a.Leading, ShouldResemble, []Trivia{{CommentToken, "/* head */"}, {WhitespaceToken, "\n"}})
+		So(a.Trailing, ShouldResemble, []Trivia{{WhitespaceToken, " "}, {CommentToken, "/* x */"}, {WhitespaceToken, " "}})
+		So(rule.Block.Open.Trailing, ShouldResemble, []Trivia{{WhitespaceToken, "\n"}})
+
+		decl := rule.Block.Values[0].(*CSTDeclaration)
+		So(decl.Name.Text, ShouldEqual, "b")
+		So(decl.Name.Leading, ShouldResemble, []Trivia{{WhitespaceToken, "  "}, {CommentToken, "/* y */"}, {WhitespaceToken, "\n  "}})
+		So(decl.Value[0].(*CSTToken).Text, ShouldEqual, "c")
+		So(decl.Semicolon, ShouldNotBeNil)
+		So(rule.Block.Close.Trailing, ShouldResemble, []Trivia{{WhitespaceToken, "\n"}})
+		So(sheet.EOF.Leading, ShouldBeNil)
+	})
+
+	Convey("Blocks hold declarations and nested rules", t, func() {
+		sheet := ParseCST([]byte("@media print { a:hover { color: red } b { x: [y] } }"))
+		media := sheet.Rules[0].(*CSTRule)
+		So(media.Prelude[0].(*CSTToken).Type, ShouldEqual, AtKeywordToken)
+		So(len(media.Block.Values), ShouldEqual, 2)
+		hover := media.Block.Values[0].(*CSTRule)
+		So(len(hover.Prelude), ShouldEqual, 3)
+		So(hover.Block.Values[0].(*CSTDeclaration).Name.Text, ShouldEqual, "color")
+		decl := media.Block.Values[1].(*CSTRule).Block.Values[0].(*CSTDeclaration)
+		So(decl.Value[0].(*CSTBlock).Open.Type, ShouldEqual, LSquareToken)
+	})
+
+	Convey("Edits are printed with the original formatting", t, func() {
+		sheet := ParseCST([]byte("a {\n  color: red; /* brand */\n}\n"))
+		decl := sheet.Rules[0].(*CSTRule).Block.Values[0].(*CSTDeclaration)
+		decl.Value[0].(*CSTToken).Text = "#c00"
+		So(sheet.String(), ShouldEqual, "a {\n  color: #c00; /* brand */\n}\n")
+		So(FormatCST(decl, false), ShouldEqual, "  color: #c00; /* brand */\n")
+	})
+
+	Convey("Compressed output keeps loud comments", t, func() {
+		compress := func(s string) string { return FormatCST(ParseCST([]byte(s)), true) }
+		So(compress("/*! License */\nbody , p {\n  color : red; /* drop */\n  margin: 0  auto ;\n}\n"),
+			ShouldEqual, "/*! License */body,p{color:red;margin:0 auto;}")
+		So(compress("a :hover > b { x: calc( 1px + 2px ) }"), ShouldEqual, "a :hover > b{x:calc(1px + 2px)}")
+		So(compress("a/**/b /*! keep */ c"), ShouldEqual, "a/**/b/*! keep */c")
+		So(compress("a{b:c}\n/*! end */\n"), ShouldEqual, "a{b:c}/*! end */")
+		So(compress("@media screen and (min-width: 1px) { }"), ShouldEqual, "@media screen and (min-width: 1px){}")
+	})
+}
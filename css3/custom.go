@@ -0,0 +1,291 @@
+package css3
+
+import "strings"
+
+// VarNode is a reference to a custom property, as in "var(--x, 1px)".
+// Fallback is nil if there is none, and empty if it's given as nothing, as
+// in "var(--x,)". Function holds the call as written, which is how it's
+// serialized.
+type VarNode struct {
+	Function *FunctionNode
+	Name     string
+	Fallback []Node
+}
+
+func (n *VarNode) TestRepr() interface{} {
+	var fallback interface{}
+	if n.Fallback != nil {
+		fallback = nodeListTestRepr(n.Fallback)
+	}
+	return []interface{}{"var", n.Name, fallback}
+}
+
+// newVarNode returns the reference fn makes to a custom property, or nil
+// if its arguments aren't one.
+func newVarNode(fn *FunctionNode) *VarNode {
+	values := trimWhitespace(fn.Values)
+	if len(values) == 0 || !nodeIsTokenType(values[0], IdentToken) {
+		return nil
+	}
+	name := string(values[0].(*TokenNode).Value.(Identifier))
+	if !strings.HasPrefix(name, "--") {
+		return nil
+	}
+	rest := trimWhitespace(values[1:])
+	if len(rest) == 0 {
+		return &VarNode{Function: fn, Name: name}
+	}
+	if !nodeIsTokenType(rest[0], CommaToken) {
+		return nil
+	}
+	return &VarNode{Function: fn, Name: name, Fallback: append([]Node{}, trimWhitespace(rest[1:])...)}
+}
+
+func trimWhitespace(nodes []Node) []Node {
+	for len(nodes) > 0 && nodeIsTokenType(nodes[0], WhitespaceToken) {
+		nodes = nodes[1:]
+	}
+	for len(nodes) > 0 && nodeIsTokenType(nodes[len(nodes)-1], WhitespaceToken) {
+		nodes = nodes[:len(nodes)-1]
+	}
+	return nodes
+}
+
+// IsCustomProperty reports whether d declares a custom property, such as
+// "--x". Its value is kept as written rather than checked against any
+// grammar.
+func (d *DeclarationNode) IsCustomProperty() bool {
+	return strings.HasPrefix(d.Name, "--")
+}
+
+// ValueText returns d's value as written, less any comments.
+func (d *DeclarationNode) ValueText() string {
+	return nodesString(d.Values)
+}
+
+func containsVar(nodes []Node) bool {
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *VarNode:
+			return true
+		case *FunctionNode:
+			if containsVar(n.Values) {
+				return true
+			}
+		case *BlockNode:
+			if containsVar(n.Values) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// InlineCustomProperties resolves the var() references in rules parsed in
+// nesting mode against the custom properties declared in top-level ":root"
+// rules, for browsers that don't support custom properties. A declaration
+// whose references all resolve, directly or through their fallbacks, is
+// preceded by a copy with them substituted; if preserve is false it's
+// replaced by the copy instead, and the ":root" custom properties that are
+// no longer referred to are removed.
+//
+// Custom properties declared anywhere else, even if ":root" declares them
+// too, can't be known statically and aren't used; references to them are
+// left alone unless they have a fallback.
+func InlineCustomProperties(rules []Node, preserve bool) []Node {
+	props := &customProperties{
+		values:    make(map[string][]Node),
+		unknown:   make(map[string]bool),
+		kept:      make(map[string]bool),
+		resolved:  make(map[string][]Node),
+		resolving: make(map[string]bool),
+	}
+	for _, rule := range rules {
+		r, ok := rule.(*StyleRuleNode)
+		if !ok || !isRootRule(r) {
+			declaredElsewhere([]Node{rule}, props.unknown)
+			continue
+		}
+		for _, n := range r.Body {
+			if d, ok := n.(*DeclarationNode); ok && d.IsCustomProperty() {
+				props.values[d.Name] = d.Values
+			} else {
+				declaredElsewhere([]Node{n}, props.unknown)
+			}
+		}
+	}
+	out := make([]Node, len(rules))
+	for i, rule := range rules {
+		if r, ok := rule.(*StyleRuleNode); ok && isRootRule(r) {
+			out[i] = NewStyleRuleNode(r.Selector, props.inlineBody(r.Body, preserve, true))
+			continue
+		}
+		out[i] = props.inlineRule(rule, preserve)
+	}
+	if preserve {
+		return out
+	}
+	// Only now is it known which ":root" custom properties are still used.
+	rootless := out[:0]
+	for _, rule := range out {
+		if r, ok := rule.(*StyleRuleNode); ok && isRootRule(r) {
+			var body []Node
+			for _, n := range r.Body {
+				if d, ok := n.(*DeclarationNode); !ok || !d.IsCustomProperty() || props.kept[d.Name] {
+					body = append(body, n)
+				}
+			}
+			if len(body) == 0 {
+				continue
+			}
+			rule = NewStyleRuleNode(r.Selector, body)
+		}
+		rootless = append(rootless, rule)
+	}
+	return rootless
+}
+
+func isRootRule(r *StyleRuleNode) bool {
+	return len(r.Selector) == 1 && r.Selector.String() == ":root"
+}
+
+// declaredElsewhere adds the custom properties declared in nodes, which
+// aren't part of a top-level ":root" rule, to names.
+func declaredElsewhere(nodes []Node, names map[string]bool) {
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *DeclarationNode:
+			if n.IsCustomProperty() {
+				names[n.Name] = true
+			}
+		case *StyleRuleNode:
+			declaredElsewhere(n.Body, names)
+		case *GroupRuleNode:
+			declaredElsewhere(n.Body, names)
+		}
+	}
+}
+
+type customProperties struct {
+	values    map[string][]Node
+	unknown   map[string]bool
+	kept      map[string]bool
+	resolved  map[string][]Node
+	resolving map[string]bool
+}
+
+func (c *customProperties) inlineRule(rule Node, preserve bool) Node {
+	switch r := rule.(type) {
+	case *StyleRuleNode:
+		return NewStyleRuleNode(r.Selector, c.inlineBody(r.Body, preserve, false))
+	case *GroupRuleNode:
+		return NewGroupRuleNode(r.Name, r.Prelude, c.inlineBody(r.Body, preserve, false))
+	}
+	return rule
+}
+
+// inlineBody substitutes custom properties into the declarations of body,
+// which belongs to a ":root" rule if root is set. Custom properties
+// declared elsewhere keep their own references if preserve is set, and
+// otherwise have them substituted too.
+func (c *customProperties) inlineBody(body []Node, preserve, root bool) []Node {
+	var out []Node
+	for _, n := range body {
+		d, ok := n.(*DeclarationNode)
+		switch {
+		case !ok:
+			out = append(out, c.inlineRule(n, preserve))
+			continue
+		case !containsVar(d.Values):
+			out = append(out, d)
+			continue
+		case d.IsCustomProperty() && (root || preserve):
+			out = append(out, d)
+			continue
+		}
+		values, ok := c.substitute(d.Values)
+		if !ok {
+			c.keep(d.Values)
+			out = append(out, d)
+			continue
+		}
+		out = append(out, NewDeclarationNode(d.Name, values, d.Important))
+		if preserve && !d.IsCustomProperty() {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// keep records that the custom properties nodes refer to are still needed,
+// along with those their ":root" values refer to.
+func (c *customProperties) keep(nodes []Node) {
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *VarNode:
+			if !c.kept[n.Name] {
+				c.kept[n.Name] = true
+				c.keep(c.values[n.Name])
+			}
+			c.keep(n.Fallback)
+		case *FunctionNode:
+			c.keep(n.Values)
+		case *BlockNode:
+			c.keep(n.Values)
+		}
+	}
+}
+
+// substitute returns nodes with their var() references replaced by the
+// values they refer to, or false if any can't be resolved.
+func (c *customProperties) substitute(nodes []Node) ([]Node, bool) {
+	out := make([]Node, 0, len(nodes))
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *VarNode:
+			values, ok := c.lookup(n.Name)
+			if !ok && n.Fallback != nil {
+				values, ok = c.substitute(n.Fallback)
+			}
+			if !ok {
+				return nil, false
+			}
+			out = append(out, values...)
+		case *FunctionNode:
+			values, ok := c.substitute(n.Values)
+			if !ok {
+				return nil, false
+			}
+			out = append(out, NewFunctionNode(n.Name, values...))
+		case *BlockNode:
+			values, ok := c.substitute(n.Values)
+			if !ok {
+				return nil, false
+			}
+			out = append(out, NewBlockNode(n.EndDelim, values...))
+		default:
+			out = append(out, n)
+		}
+	}
+	return out, true
+}
+
+// lookup returns the value of the custom property name, with its own
+// references resolved. Properties declared outside ":root", and those that
+// refer to themselves, directly or not, have no value.
+func (c *customProperties) lookup(name string) ([]Node, bool) {
+	if values, ok := c.resolved[name]; ok {
+		return values, true
+	}
+	values, ok := c.values[name]
+	if !ok || c.unknown[name] || c.resolving[name] {
+		return nil, false
+	}
+	c.resolving[name] = true
+	defer delete(c.resolving, name)
+	if values, ok = c.substitute(values); !ok {
+		return nil, false
+	}
+	c.resolved[name] = values
+	return values, true
+}
@@ -0,0 +1,74 @@
+package css3
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCustomProperties(t *testing.T) {
+	inline := func(s string, preserve bool) string {
+		return FormatRules(InlineCustomProperties(nestingParser(s).ParseStylesheet(), preserve))
+	}
+
+	Convey("Custom property names are identifiers", t, func() {
+		d := testParser("--main-color :  #06c  ").ParseDeclaration().(*DeclarationNode)
+		So(d.Name, ShouldEqual, "--main-color")
+		So(d.IsCustomProperty(), ShouldBeTrue)
+		So(d.ValueText(), ShouldEqual, "#06c")
+	})
+
+	Convey("Custom property values are kept as written", t, func() {
+		d := testParser("--x: { a: b } [c] 1px !important").ParseDeclaration().(*DeclarationNode)
+		So(d.ValueText(), ShouldEqual, "{ a: b } [c] 1px")
+		So(d.Important, ShouldBeTrue)
+		So(testParser("--x:;").ParseDeclaration().(*DeclarationNode).ValueText(), ShouldEqual, "")
+	})
+
+	Convey("var() references are parsed into VarNodes", t, func() {
+		nodes := simplify(testParser("var(--a) var( --b , 1px 2px ) var(--c,) var(a) var(--d 1px)").ParseListOfComponentValues())
+		So(nodes, ShouldResemble, []interface{}{
+			[]interface{}{"var", "--a", nil}, " ",
+			[]interface{}{"var", "--b", []interface{}{[]interface{}{"dimension", "1", 1.0, "integer", "px"}, " ", []interface{}{"dimension", "2", 2.0, "integer", "px"}}}, " ",
+			[]interface{}{"var", "--c", []interface{}{}}, " ",
+			[]interface{}{"function", "var", []interface{}{"ident", "a"}}, " ",
+			[]interface{}{"function", "var", []interface{}{"ident", "--d"}, " ", []interface{}{"dimension", "1", 1.0, "integer", "px"}},
+		})
+		d := testParser("a: var( --b , 1px)").ParseDeclaration().(*DeclarationNode)
+		So(d.ValueText(), ShouldEqual, "var( --b , 1px)")
+		_, ok := trimWhitespace(compatParser("a: var(--b)").ParseDeclaration().(*DeclarationNode).Values)[0].(*FunctionNode)
+		So(ok, ShouldBeTrue)
+	})
+
+	Convey(":root custom properties can be inlined", t, func() {
+		const css = ":root { --a: 1px; --b: var(--a) solid; --c: var(--c); } " +
+			"p { border: var(--b) red; margin: calc(var(--a) * 2); color: var(--c, blue); width: var(--d); --e: var(--a) }"
+		So(inline(css, true), ShouldEqual, ":root {\n"+
+			"  --a: 1px;\n  --b: var(--a) solid;\n  --c: var(--c);\n}\n"+
+			"p {\n"+
+			"  border: 1px solid red;\n  border: var(--b) red;\n"+
+			"  margin: calc(1px * 2);\n  margin: calc(var(--a) * 2);\n"+
+			"  color: blue;\n  color: var(--c, blue);\n"+
+			"  width: var(--d);\n"+
+			"  --e: var(--a);\n"+
+			"}\n")
+		So(inline(css, false), ShouldEqual, "p {\n"+
+			"  border: 1px solid red;\n  margin: calc(1px * 2);\n  color: blue;\n  width: var(--d);\n  --e: 1px;\n"+
+			"}\n")
+	})
+
+	Convey("Custom properties declared outside :root aren't inlined", t, func() {
+		So(inline(":root { --a: 1px } p { --e: var(--a); width: var(--e) }", false), ShouldEqual,
+			"p {\n  --e: 1px;\n  width: var(--e);\n}\n")
+		So(inline(":root { --a: 1px; --b: 2px } .dark { --a: 2px } p { width: var(--a); height: var(--b) }", false), ShouldEqual,
+			":root {\n  --a: 1px;\n}\n.dark {\n  --a: 2px;\n}\np {\n  width: var(--a);\n  height: 2px;\n}\n")
+		So(inline(":root { --a: var(--b); --b: 1px } .dark { --a: 2px } p { width: var(--a) }", false), ShouldEqual,
+			":root {\n  --a: var(--b);\n  --b: 1px;\n}\n.dark {\n  --a: 2px;\n}\np {\n  width: var(--a);\n}\n")
+	})
+
+	Convey("Custom properties set in group rules aren't inlined", t, func() {
+		So(inline("@media print { :root { --a: 1px } } a { @media screen { b: var(--a, 2px) } }", false), ShouldEqual,
+			"@media print {\n  :root {\n    --a: 1px;\n  }\n}\n"+
+				"a {\n  @media screen {\n    b: 2px;\n  }\n}\n")
+	})
+}
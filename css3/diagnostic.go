@@ -0,0 +1,196 @@
+package css3
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	if s == SeverityWarning {
+		return "warning"
+	}
+	return "error"
+}
+
+// Error codes identify the kind of problem a Diagnostic reports.
+const (
+	CodeSyntax          = "invalid-syntax"
+	CodeEmpty           = "empty"
+	CodeExtraInput      = "extra-input"
+	CodeUnmatchedCurly  = "unmatched-curly"
+	CodeUnmatchedSquare = "unmatched-square"
+	CodeUnmatchedParen  = "unmatched-paren"
+	CodeBadString       = "bad-string"
+	CodeBadURL          = "bad-url"
+	CodeSelector        = "invalid-selector"
+)
+
+var errorCodes = map[error]string{
+	SyntaxErr:          CodeSyntax,
+	EmptyErr:           CodeEmpty,
+	ExtraInputErr:      CodeExtraInput,
+	UnmatchedCurlyErr:  CodeUnmatchedCurly,
+	UnmatchedSquareErr: CodeUnmatchedSquare,
+	UnmatchedParenErr:  CodeUnmatchedParen,
+
+	ExpectedSelectorErr:   CodeSelector,
+	ExpectedIdentifierErr: CodeSelector,
+	BadAttributeErr:       CodeSelector,
+	BadCombinatorErr:      CodeSelector,
+	NestingSuffixErr:      CodeSelector,
+}
+
+// Span is the part of a file between two positions. End is the position
+// just past the last code point.
+type Span struct {
+	File       string
+	Start, End Position
+}
+
+func (s Span) String() string {
+	if s.File == "" {
+		return s.Start.String()
+	}
+	return s.File + ":" + s.Start.String()
+}
+
+// Diagnostic is a problem found in a stylesheet. Err is the sentinel error
+// the problem is an instance of, if any, so that errors.Is can classify it.
+type Diagnostic struct {
+	Severity Severity
+	Code     string
+	Message  string
+	Span     Span
+	Err      error
+}
+
+func NewDiagnostic(err error, span Span, format string, args ...interface{}) *Diagnostic {
+	return &Diagnostic{
+		Severity: SeverityError,
+		Code:     errorCodes[err],
+		Message:  fmt.Sprintf(format, args...),
+		Span:     span,
+		Err:      err,
+	}
+}
+
+func (d *Diagnostic) Error() string {
+	return fmt.Sprintf("%s: %s[%s]: %s", d.Span, d.Severity, d.Code, d.Message)
+}
+
+func (d *Diagnostic) Unwrap() error { return d.Err }
+
+// Format returns the diagnostic followed by a code frame showing where it
+// occurs in src, the text of the file it refers to.
+func (d *Diagnostic) Format(src []byte) string {
+	return d.Error() + "\n" + d.CodeFrame(src)
+}
+
+// CodeFrame renders the line of src on which the diagnostic starts, and the
+// one before it, with carets underlining the span:
+//
+//	1 | a {
+//	2 |   color: red;}}
+//	  |              ^
+//
+// A span covering several lines is underlined to the end of its first line.
+func (d *Diagnostic) CodeFrame(src []byte) string {
+	lines := strings.Split(string(src), "\n")
+	start, end := d.Span.Start, d.Span.End
+	if start.Line < 1 || start.Line > len(lines) {
+		return ""
+	}
+	width := len(fmt.Sprint(start.Line))
+	var buf bytes.Buffer
+	for n := start.Line - 1; n <= start.Line; n++ {
+		if n >= 1 {
+			fmt.Fprintf(&buf, "%*d | %s\n", width, n, strings.TrimSuffix(lines[n-1], "\r"))
+		}
+	}
+	line := []rune(strings.TrimSuffix(lines[start.Line-1], "\r"))
+	from := start.Column - 1
+	to := len(line)
+	if end.Line == start.Line && end.Column-1 < to {
+		to = end.Column - 1
+	}
+	if from > len(line) {
+		from = len(line)
+	}
+	if to <= from {
+		to = from + 1
+	}
+	fmt.Fprintf(&buf, "%*s | ", width, "")
+	for _, ch := range line[:from] {
+		if ch == '\t' {
+			buf.WriteByte('\t')
+		} else {
+			buf.WriteByte(' ')
+		}
+	}
+	buf.WriteString(strings.Repeat("^", to-from))
+	buf.WriteByte('\n')
+	return buf.String()
+}
+
+// Diagnostics collects the problems found while parsing, so that a parser
+// that recovers from errors can report all of them.
+type Diagnostics []*Diagnostic
+
+func (ds *Diagnostics) Add(d *Diagnostic) { *ds = append(*ds, d) }
+
+// HasErrors reports whether any of the diagnostics has error severity.
+func (ds Diagnostics) HasErrors() bool {
+	for _, d := range ds {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns the first error, or nil if there are none.
+func (ds Diagnostics) Err() error {
+	for _, d := range ds {
+		if d.Severity == SeverityError {
+			return d
+		}
+	}
+	return nil
+}
+
+// Format renders every diagnostic with its code frame.
+func (ds Diagnostics) Format(src []byte) string {
+	parts := make([]string, len(ds))
+	for i, d := range ds {
+		parts[i] = d.Format(src)
+	}
+	return strings.Join(parts, "\n")
+}
+
+// errorRepr returns the name the css-parsing-tests give an error.
+func errorRepr(err error) string {
+	switch {
+	case errors.Is(err, SyntaxErr):
+		return "invalid"
+	case errors.Is(err, EmptyErr):
+		return "empty"
+	case errors.Is(err, ExtraInputErr):
+		return "extra-input"
+	case errors.Is(err, UnmatchedCurlyErr):
+		return "}"
+	case errors.Is(err, UnmatchedSquareErr):
+		return "]"
+	case errors.Is(err, UnmatchedParenErr):
+		return ")"
+	}
+	return "unknown"
+}
@@ -0,0 +1,60 @@
+package css3
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDiagnostics(t *testing.T) {
+	Convey("errors are collected with spans", t, func() {
+		src := "color: red;\n4px: x;\nmargin;\n"
+		p := compatParser(src)
+		p.SetFile("a.css")
+		decls := p.ParseDeclarationList()
+		So(len(decls), ShouldEqual, 3)
+
+		ds := p.Diagnostics()
+		So(len(ds), ShouldEqual, 2)
+		So(ds.HasErrors(), ShouldBeTrue)
+		So(ds[0].Code, ShouldEqual, CodeSyntax)
+		So(ds[0].Span.Start, ShouldResemble, Position{12, 2, 1})
+		So(ds[0].Error(), ShouldEqual, "a.css:2:1: error[invalid-syntax]: expected a declaration or at-rule")
+		So(ds[1].Span.Start.Line, ShouldEqual, 3)
+		So(ds[1].Message, ShouldEqual, `expected ":" after property name "margin"`)
+		So(ds.Err(), ShouldEqual, ds[0])
+
+		So(decls[1].(*ErrorNode).Diagnostic(), ShouldEqual, ds[0])
+		So(errors.Is(ds[0], SyntaxErr), ShouldBeTrue)
+		So(decls[1].TestRepr(), ShouldResemble, []interface{}{"error", "invalid"})
+	})
+
+	Convey("code frames", t, func() {
+		src := []byte("a {\n  color: red;}}\n")
+		p := testParser(string(src))
+		p.ParseListOfComponentValues()
+		ds := p.Diagnostics()
+		So(len(ds), ShouldEqual, 1)
+		So(ds[0].Code, ShouldEqual, CodeUnmatchedCurly)
+		So(ds[0].CodeFrame(src), ShouldEqual, "1 | a {\n2 |   color: red;}}\n  |               ^\n")
+
+		d := &Diagnostic{Span: Span{Start: Position{0, 1, 3}, End: Position{6, 2, 1}}}
+		So(d.CodeFrame([]byte("\tab cd\nx")), ShouldEqual, "1 | \tab cd\n  | \t ^^^^\n")
+	})
+
+	Convey("bad tokens", t, func() {
+		p := testParser("a: \"x\n")
+		p.ParseDeclarationList()
+		ds := p.Diagnostics()
+		So(len(ds), ShouldEqual, 1)
+		So(ds[0].Code, ShouldEqual, CodeBadString)
+	})
+
+	Convey("no errors", t, func() {
+		p := testParser("a { b: c }")
+		p.ParseStylesheet()
+		So(p.Diagnostics().HasErrors(), ShouldBeFalse)
+		So(p.Diagnostics().Err(), ShouldBeNil)
+	})
+}
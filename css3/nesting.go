@@ -0,0 +1,324 @@
+package css3
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+)
+
+var NestingSuffixErr = errors.New("nesting selector with a suffix")
+
+// groupRules are the conditional group rules, and the other at-rules whose
+// blocks CSS Nesting allows inside style rules.
+var groupRules = map[string]bool{
+	"media":          true,
+	"supports":       true,
+	"container":      true,
+	"layer":          true,
+	"scope":          true,
+	"starting-style": true,
+	"document":       true,
+	"-moz-document":  true,
+}
+
+// StyleRuleNode is a style rule parsed in nesting mode. Body holds its
+// declarations and nested rules in order. The selector of a nested rule is
+// relative to its parent's, and has yet to be resolved against it.
+type StyleRuleNode struct {
+	Selector SelectorList
+	Body     []Node
+}
+
+func NewStyleRuleNode(selector SelectorList, body []Node) *StyleRuleNode {
+	return &StyleRuleNode{selector, body}
+}
+
+func (n *StyleRuleNode) TestRepr() interface{} {
+	return []interface{}{"style rule", n.Selector.String(), nodeListTestRepr(n.Body)}
+}
+
+// GroupRuleNode is a conditional group rule such as @media, parsed in
+// nesting mode. Inside a style rule, Body may hold declarations, which apply
+// to the style rule's selector.
+type GroupRuleNode struct {
+	Name    string
+	Prelude []Node
+	Body    []Node
+}
+
+func NewGroupRuleNode(name string, prelude []Node, body []Node) *GroupRuleNode {
+	return &GroupRuleNode{name, prelude, body}
+}
+
+func (n *GroupRuleNode) TestRepr() interface{} {
+	return []interface{}{"group rule", n.Name, nodeListTestRepr(n.Prelude), nodeListTestRepr(n.Body)}
+}
+
+// consumeStyleRule consumes the block of a qualified rule whose prelude has
+// been read, leaving the current token on the block's closing "}".
+func (p *Parser) consumeStyleRule(prelude []Node, start Position) Node {
+	sel, err := ParseSelectorNodes(prelude)
+	if err == nil && hasNestingSuffix(sel) {
+		err = NestingSuffixErr
+	}
+	p.Consume1()
+	body := p.consumeBlockContents(true, true)
+	if err != nil {
+		return p.errorNode(err, start, "invalid selector: %v", err)
+	}
+	return NewStyleRuleNode(sel, body)
+}
+
+func hasNestingSuffix(list SelectorList) bool {
+	for _, complex := range list {
+		for _, comp := range complex.Components {
+			for _, s := range comp.Compound {
+				if (s.Type == NestingSelector && s.Suffix != "") || (s.Selector != nil && hasNestingSuffix(s.Selector)) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func hasNesting(list SelectorList) bool {
+	for _, complex := range list {
+		for _, comp := range complex.Components {
+			if compoundHasNesting(comp.Compound) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func compoundHasNesting(compound CompoundSelector) bool {
+	for _, s := range compound {
+		if s.Type == NestingSelector || (s.Selector != nil && hasNesting(s.Selector)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Denest flattens the nested rules parsed in nesting mode into rules that
+// browsers without CSS Nesting understand. Nested selectors are resolved
+// against their parents, conditional group rules are moved out of style
+// rules, and declarations that follow a nested rule get a rule of their own
+// so that they still come after it.
+//
+// "&" is replaced by the parent selector where that means the same thing,
+// and by ":is()" of it otherwise, as in "c &" with a parent of "a b".
+// Selectors may match with a different specificity than nested ones, which
+// take that of ":is()" of the parent selector.
+func Denest(rules []Node) []Node {
+	var out []Node
+	for _, rule := range rules {
+		out = denestRule(rule, nil, out)
+	}
+	return out
+}
+
+// denestRule appends the flattened form of node, inside a style rule with
+// the selector parent if there is one, to out.
+func denestRule(node Node, parent SelectorList, out []Node) []Node {
+	switch n := node.(type) {
+	case *StyleRuleNode:
+		sel := n.Selector
+		if parent != nil {
+			sel = resolveNesting(sel, parent)
+		}
+		return denestBody(n.Body, sel, out)
+	case *GroupRuleNode:
+		var body []Node
+		if parent != nil {
+			body = denestBody(n.Body, parent, nil)
+		} else {
+			for _, rule := range n.Body {
+				body = denestRule(rule, nil, body)
+			}
+		}
+		return append(out, NewGroupRuleNode(n.Name, n.Prelude, body))
+	}
+	return append(out, node)
+}
+
+// denestBody appends the flattened form of the body of a style rule with
+// the selector sel to out.
+func denestBody(body []Node, sel SelectorList, out []Node) []Node {
+	var decls []Node
+	flush := func() {
+		if len(decls) > 0 {
+			out = append(out, NewStyleRuleNode(sel, decls))
+			decls = nil
+		}
+	}
+	for _, n := range body {
+		if _, ok := n.(*DeclarationNode); ok {
+			decls = append(decls, n)
+			continue
+		}
+		flush()
+		out = denestRule(n, sel, out)
+	}
+	flush()
+	return out
+}
+
+// resolveNesting returns the selector a nested rule's selector means inside
+// a rule with the selector parent. One without "&" is relative to it, so "a"
+// means "& a" and "> a" means "& > a".
+func resolveNesting(sel, parent SelectorList) SelectorList {
+	var result SelectorList
+	for _, complex := range sel {
+		if !hasNesting(SelectorList{complex}) {
+			components := []ComplexComponent{{NoCombinator, CompoundSelector{{Type: NestingSelector}}}}
+			components = append(components, complex.Components...)
+			if components[1].Combinator == NoCombinator {
+				components[1].Combinator = DescendantCombinator
+			}
+			complex = &ComplexSelector{Components: components}
+		}
+		result = append(result, substituteNesting(complex, parent)...)
+	}
+	return result
+}
+
+// substituteNesting replaces each "&" in complex by each of the selectors
+// in parent in turn, returning every combination.
+func substituteNesting(complex *ComplexSelector, parent SelectorList) []*ComplexSelector {
+	partials := []*ComplexSelector{{}}
+	for i, comp := range complex.Components {
+		compound := substituteNestingArguments(comp.Compound, parent)
+		j := nestingIndex(compound)
+		if j < 0 {
+			for _, partial := range partials {
+				partial.Components = append(partial.Components, ComplexComponent{comp.Combinator, compound})
+			}
+			continue
+		}
+		var next []*ComplexSelector
+		for _, partial := range partials {
+			for _, p := range parent {
+				components := append([]ComplexComponent(nil), partial.Components...)
+				components = append(components, insertParent(comp.Combinator, compound, j, p, i == 0)...)
+				next = append(next, &ComplexSelector{Components: components})
+			}
+		}
+		partials = next
+	}
+	return partials
+}
+
+// insertParent returns the components that replace a compound selector
+// whose jth simple selector is "&", preceded by combinator, with the "&"
+// replaced by p. The compound may only be merged into p's last compound if
+// p has no others, or if the "&" starts the nested selector.
+func insertParent(combinator Combinator, compound CompoundSelector, j int, p *ComplexSelector, first bool) []ComplexComponent {
+	last := p.Components[len(p.Components)-1].Compound
+	typed := last[0].Type == TypeSelector || last[0].Type == UniversalSelector
+	if (len(p.Components) > 1 && !(first && j == 0)) || (j > 0 && typed) {
+		is := &SimpleSelector{Type: PseudoClassSelector, Name: "is", Selector: SelectorList{p}}
+		merged := append(append(append(CompoundSelector(nil), compound[:j]...), is), compound[j+1:]...)
+		return []ComplexComponent{{combinator, merged}}
+	}
+	components := append([]ComplexComponent(nil), p.Components...)
+	merged := append(append(append(CompoundSelector(nil), compound[:j]...), last...), compound[j+1:]...)
+	components[len(components)-1].Compound = merged
+	if !first {
+		components[0].Combinator = combinator
+	}
+	return components
+}
+
+func nestingIndex(compound CompoundSelector) int {
+	for i, s := range compound {
+		if s.Type == NestingSelector {
+			return i
+		}
+	}
+	return -1
+}
+
+// substituteNestingArguments resolves "&" in the selector arguments of
+// pseudo-classes such as ":not(&)".
+func substituteNestingArguments(compound CompoundSelector, parent SelectorList) CompoundSelector {
+	var result CompoundSelector
+	for i, s := range compound {
+		if s.Selector == nil || !hasNesting(s.Selector) {
+			if result != nil {
+				result = append(result, s)
+			}
+			continue
+		}
+		if result == nil {
+			result = append(result, compound[:i]...)
+		}
+		var list SelectorList
+		for _, complex := range s.Selector {
+			if hasNesting(SelectorList{complex}) {
+				list = append(list, substituteNesting(complex, parent)...)
+			} else {
+				list = append(list, complex)
+			}
+		}
+		copied := *s
+		copied.Selector = list
+		result = append(result, &copied)
+	}
+	if result == nil {
+		return compound
+	}
+	return result
+}
+
+// FormatRules serializes rules parsed in nesting mode, or flattened by
+// Denest, as CSS with each declaration on its own line.
+func FormatRules(rules []Node) string {
+	var buf bytes.Buffer
+	for _, rule := range rules {
+		formatRule(&buf, rule, "")
+	}
+	return buf.String()
+}
+
+func formatRule(buf *bytes.Buffer, node Node, indent string) {
+	switch n := node.(type) {
+	case *StyleRuleNode:
+		buf.WriteString(indent + n.Selector.String())
+		formatBody(buf, n.Body, indent)
+	case *GroupRuleNode:
+		buf.WriteString(indent + "@" + escapeIdent(n.Name))
+		if prelude := strings.TrimSpace(nodesString(n.Prelude)); prelude != "" {
+			buf.WriteString(" " + prelude)
+		}
+		formatBody(buf, n.Body, indent)
+	case *DeclarationNode:
+		buf.WriteString(indent + escapeIdent(n.Name) + ": " + nodesString(n.Values))
+		if n.Important {
+			buf.WriteString(" !important")
+		}
+		buf.WriteString(";\n")
+	case *AtRuleNode:
+		buf.WriteString(indent + "@" + escapeIdent(n.Name))
+		if prelude := strings.TrimSpace(nodesString(n.Prelude)); prelude != "" {
+			buf.WriteString(" " + prelude)
+		}
+		if n.Body == nil {
+			buf.WriteString(";\n")
+		} else {
+			buf.WriteString(" {" + nodesString(n.Body) + "}\n")
+		}
+	case *QualifiedRuleNode:
+		buf.WriteString(indent + strings.TrimSpace(nodesString(n.Prelude)) + " {" + nodesString(n.Body) + "}\n")
+	}
+}
+
+func formatBody(buf *bytes.Buffer, body []Node, indent string) {
+	buf.WriteString(" {\n")
+	for _, n := range body {
+		formatRule(buf, n, indent+"  ")
+	}
+	buf.WriteString(indent + "}\n")
+}
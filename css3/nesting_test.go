@@ -0,0 +1,75 @@
+package css3
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func nestingParser(s string) *Parser {
+	p := testParser(s)
+	p.SetNesting(true)
+	return p
+}
+
+func TestNesting(t *testing.T) {
+	denest := func(s string) string { return FormatRules(Denest(nestingParser(s).ParseStylesheet())) }
+
+	Convey("Style rules hold declarations and nested rules", t, func() {
+		p := nestingParser(".a { color: red; &:hover { color: blue } > b { x: y } @media print { z: w } }")
+		rules := p.ParseStylesheet()
+		So(len(p.Diagnostics()), ShouldEqual, 0)
+		So(len(rules), ShouldEqual, 1)
+		rule := rules[0].(*StyleRuleNode)
+		So(rule.Selector.String(), ShouldEqual, ".a")
+		So(len(rule.Body), ShouldEqual, 4)
+		So(rule.Body[0].(*DeclarationNode).Name, ShouldEqual, "color")
+		So(rule.Body[1].(*StyleRuleNode).Selector.String(), ShouldEqual, "&:hover")
+		So(rule.Body[2].(*StyleRuleNode).Selector.String(), ShouldEqual, "> b")
+		media := rule.Body[3].(*GroupRuleNode)
+		So(media.Name, ShouldEqual, "media")
+		So(media.Body[0].(*DeclarationNode).Name, ShouldEqual, "z")
+	})
+
+	Convey("Invalid rules are reported and skipped", t, func() {
+		p := nestingParser("a { b { } .. { x: y } c: d } @media x { e: f } &-x {}")
+		p.SetFile("a.css")
+		rules := p.ParseStylesheet()
+		So(len(rules), ShouldEqual, 3)
+		So(len(rules[0].(*StyleRuleNode).Body), ShouldEqual, 2)
+		ds := p.Diagnostics()
+		So(len(ds), ShouldEqual, 3)
+		So(ds[0].Code, ShouldEqual, CodeSelector)
+		So(ds[0].Span.Start.Column, ShouldEqual, 11)
+		So(ds[1].Message, ShouldEqual, "declarations are only allowed in a style rule")
+		So(ds[2].Code, ShouldEqual, CodeSelector)
+	})
+
+	Convey("Denesting resolves selectors against their parents", t, func() {
+		So(denest("a { b { x: y } }"), ShouldEqual, "a b {\n  x: y;\n}\n")
+		So(denest("a { > b, + c { x: y } }"), ShouldEqual, "a > b, a + c {\n  x: y;\n}\n")
+		So(denest(".a, .b { &:hover, .c & { x: y } }"), ShouldEqual,
+			".a:hover, .b:hover, .c .a, .c .b {\n  x: y;\n}\n")
+		So(denest("a b { &.c d { x: y } }"), ShouldEqual, "a b.c d {\n  x: y;\n}\n")
+		So(denest("a b { c & { x: y } }"), ShouldEqual, "c :is(a b) {\n  x: y;\n}\n")
+		So(denest("div { .a& { x: y } }"), ShouldEqual, ".a:is(div) {\n  x: y;\n}\n")
+		So(denest(".a { div& { x: y } }"), ShouldEqual, "div.a {\n  x: y;\n}\n")
+		So(denest(".a { :not(&) { x: y } }"), ShouldEqual, ":not(.a) {\n  x: y;\n}\n")
+		So(denest(".a { & + & { x: y } }"), ShouldEqual, ".a + .a {\n  x: y;\n}\n")
+		So(denest("a { b { c { x: y } } }"), ShouldEqual, "a b c {\n  x: y;\n}\n")
+	})
+
+	Convey("Denesting keeps declarations in order", t, func() {
+		So(denest("a { x: 1; b { y: 2 } z: 3 !important }"), ShouldEqual,
+			"a {\n  x: 1;\n}\na b {\n  y: 2;\n}\na {\n  z: 3 !important;\n}\n")
+	})
+
+	Convey("Denesting moves group rules out of style rules", t, func() {
+		So(denest(".a { color: red; @media (min-width: 1px) { color: blue; .b { x: y } } }"), ShouldEqual,
+			".a {\n  color: red;\n}\n@media (min-width: 1px) {\n  .a {\n    color: blue;\n  }\n  .a .b {\n    x: y;\n  }\n}\n")
+		So(denest("@supports (display: grid) { .a { .b { x: y } } }"), ShouldEqual,
+			"@supports (display: grid) {\n  .a .b {\n    x: y;\n  }\n}\n")
+		So(denest("@import url(a.css); a { @layer base { x: y } }"), ShouldEqual,
+			"@import url(a.css);\n@layer base {\n  a {\n    x: y;\n  }\n}\n")
+	})
+}
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"iter"
+	"strings"
 )
 
 var (
@@ -32,15 +34,6 @@ func nodeIsTokenType(node Node, tokenType TokenType) bool {
 	}
 }
 
-func nodeIsEOFOrError(node Node) bool {
-	switch node.(type) {
-	case ErrorNode, EOFNode:
-		return true
-	default:
-		return false
-	}
-}
-
 func nodeListTestRepr(nl []Node) []interface{} {
 	if nl == nil {
 		return []interface{}{}
@@ -73,6 +66,25 @@ func nodeListLastTwoNonwhitespace(nl []Node) (chopped []Node, penultimate Node,
 	return nl, nil, nil
 }
 
+// nonWhitespaceIndexes returns the indexes of up to n nodes in nl that aren't
+// whitespace, from the end backwards if fromEnd is set.
+func nonWhitespaceIndexes(nl []Node, n int, fromEnd bool) []int {
+	var indexes []int
+	for j := range nl {
+		i := j
+		if fromEnd {
+			i = len(nl) - 1 - j
+		}
+		if len(indexes) == n {
+			break
+		}
+		if !nodeIsTokenType(nl[i], WhitespaceToken) {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
 type EOFNode int
 
 func NewEOFNode() EOFNode { return EOFNode(0) }
@@ -84,24 +96,14 @@ type ErrorNode struct{ error }
 func NewErrorNode(err error) *ErrorNode { return &ErrorNode{err} }
 
 func (n ErrorNode) TestRepr() interface{} {
-	var repr string
-	switch n.error {
-	case SyntaxErr:
-		repr = "invalid"
-	case EmptyErr:
-		repr = "empty"
-	case ExtraInputErr:
-		repr = "extra-input"
-	case UnmatchedCurlyErr:
-		repr = "}"
-	case UnmatchedSquareErr:
-		repr = "]"
-	case UnmatchedParenErr:
-		repr = ")"
-	default:
-		repr = "unknown"
-	}
-	return []interface{}{"error", repr}
+	return []interface{}{"error", errorRepr(n.error)}
+}
+
+// Diagnostic returns the diagnostic describing the error, if the parser
+// that produced the node recorded one.
+func (n ErrorNode) Diagnostic() *Diagnostic {
+	d, _ := n.error.(*Diagnostic)
+	return d
 }
 
 type QualifiedRuleNode struct {
@@ -197,55 +199,7 @@ func (n *FunctionNode) Params() []Node {
 }
 
 func (n *FunctionNode) Color() *Color {
-	name := toLower(n.Name)
-	switch name {
-	case "rgb":
-		if cs, ok := n.requireFloats("", "", ""); ok {
-			return RGB(cs[0]/255, cs[1]/255, cs[2]/255)
-		}
-		if cs, ok := n.requireFloats("%", "%", "%"); ok {
-			return RGB(cs[0]/100, cs[1]/100, cs[2]/100)
-		}
-	case "rgba":
-		if cs, ok := n.requireFloats("", "", "", ""); ok {
-			return RGBA(cs[0]/255, cs[1]/255, cs[2]/255, cs[3])
-		}
-		if cs, ok := n.requireFloats("%", "%", "%", ""); ok {
-			return RGBA(cs[0]/100, cs[1]/100, cs[2]/100, cs[3])
-		}
-	case "hsl":
-		if cs, ok := n.requireFloats("", "%", "%"); ok {
-			return HSL(normDeg(cs[0]), cs[1]/100, cs[2]/100)
-		}
-		return nil
-	case "hsla":
-		if cs, ok := n.requireFloats("", "%", "%", ""); ok {
-			return HSLA(normDeg(cs[0]), cs[1]/100, cs[2]/100, cs[3])
-		}
-	}
-	return nil
-}
-
-func (n *FunctionNode) requireFloats(units ...string) ([]float64, bool) {
-	params := n.Params()
-	if len(params) != len(units) {
-		return nil, false
-	}
-	for i, unit := range units {
-		if num, ok := params[i].(*NumberNode); !ok || num.Unit != unit {
-			return nil, false
-		}
-	}
-	result := make([]float64, len(params))
-	for i, param := range params {
-		num := param.(*NumberNode)
-		if num.NumberType == Integer {
-			result[i] = float64(num.Integer)
-		} else {
-			result[i] = num.Float
-		}
-	}
-	return result, true
+	return ColorFromFunction(n.Name, n.Values)
 }
 
 type HashNode struct {
@@ -266,7 +220,7 @@ func (n *HashNode) TestRepr() interface{} {
 }
 
 func (n *HashNode) Color() *Color {
-	return ColorFromHexCode(n.Hash)
+	return ColorFromHexCode(n.Hash).withSource("#" + n.Hash)
 }
 
 type NumberNode struct {
@@ -348,7 +302,8 @@ func (n *TokenNode) TestRepr() interface{} {
 
 func (n *TokenNode) Color() *Color {
 	if n.TokenType == IdentToken {
-		return ColorFromName(string(n.Value.(Identifier)))
+		name := string(n.Value.(Identifier))
+		return ColorFromName(name).withSource(name)
 	}
 	return nil
 }
@@ -394,6 +349,18 @@ type Parser struct {
 	next      *Token
 	reconsume bool
 	debugOn   bool
+	compat    bool
+	nesting   bool
+
+	// Tokens read since mark, and tokens to read again after a restore.
+	marked   *parserMark
+	recorded []spannedToken
+	replay   []spannedToken
+
+	file        string
+	currentSpan Span
+	nextSpan    Span
+	diagnostics Diagnostics
 }
 
 func (p *Parser) debug(s ...interface{}) {
@@ -402,16 +369,138 @@ func (p *Parser) debug(s ...interface{}) {
 	}
 }
 
+type spannedToken struct {
+	token *Token
+	span  Span
+}
+
+type parserMark struct {
+	current, next spannedToken
+	diagnostics   int
+}
+
 func newParser(runeScanner io.RuneScanner, debugOn bool) *Parser {
 	p := &Parser{tokenizer: NewTokenizer(runeScanner), debugOn: debugOn}
-	p.current = p.tokenizer.ConsumeToken()
+	p.current, p.currentSpan = p.fetch()
 	p.debug("Consume:", p.current.String())
-	p.next = p.tokenizer.ConsumeToken()
+	p.next, p.nextSpan = p.fetch()
 	return p
 }
 
+// fetch reads the token after next, from the replay buffer if a restore
+// left any there.
+func (p *Parser) fetch() (*Token, Span) {
+	var t spannedToken
+	if len(p.replay) > 0 {
+		t, p.replay = p.replay[0], p.replay[1:]
+	} else {
+		t = spannedToken{p.tokenizer.ConsumeToken(), p.tokenSpan()}
+	}
+	if p.marked != nil {
+		p.recorded = append(p.recorded, t)
+	}
+	return t.token, t.span
+}
+
+// mark remembers the current position, so that restore can return to it.
+func (p *Parser) mark() {
+	p.marked = &parserMark{spannedToken{p.current, p.currentSpan}, spannedToken{p.next, p.nextSpan}, len(p.diagnostics)}
+	p.recorded = p.recorded[:0]
+}
+
+// restore returns to the position of the last mark.
+func (p *Parser) restore() {
+	m := p.marked
+	p.replay = append(append([]spannedToken(nil), p.recorded...), p.replay...)
+	p.current, p.currentSpan = m.current.token, m.current.span
+	p.next, p.nextSpan = m.next.token, m.next.span
+	p.diagnostics = p.diagnostics[:m.diagnostics]
+	p.marked = nil
+}
+
+// unmark forgets the last mark.
+func (p *Parser) unmark() { p.marked = nil }
+
+func (p *Parser) tokenSpan() Span {
+	return Span{Start: p.tokenizer.TokenStart(), End: p.tokenizer.TokenEnd()}
+}
+
+// SetFile names the file being parsed in the spans of diagnostics.
+func (p *Parser) SetFile(name string) { p.file = name }
+
+// SetCompat selects the older declaration parsing that css-parsing-tests
+// expects: declaration lists hold no nested rules, values run to the next
+// ";" keeping trailing whitespace, a "!" other than "!important" makes a
+// declaration invalid, and invalid input is kept as error nodes.
+func (p *Parser) SetCompat(on bool) { p.compat = on }
+
+// SetNesting parses style rules as CSS Nesting does: qualified rules become
+// StyleRuleNodes holding their declarations and nested rules, and
+// conditional group rules such as @media become GroupRuleNodes.
+func (p *Parser) SetNesting(on bool) { p.nesting = on }
+
+// Diagnostics returns the problems found so far. Parsing recovers from
+// errors, so there may be several.
+func (p *Parser) Diagnostics() Diagnostics { return p.diagnostics }
+
+// errorNode records a diagnostic for err spanning from start to the end of
+// the current token, and returns an error node holding it.
+func (p *Parser) errorNode(err error, start Position, format string, args ...interface{}) *ErrorNode {
+	d := NewDiagnostic(err, Span{p.file, start, p.currentSpan.End}, format, args...)
+	p.diagnostics.Add(d)
+	return NewErrorNode(d)
+}
+
 func NewParser(runeScanner io.RuneScanner) *Parser { return newParser(runeScanner, false) }
 
+// nodeParser returns a parser that reads nodes already parsed, such as the
+// body of an at-rule, as the tokens they were parsed from, so that they can
+// be parsed again as something more specific without losing anything to
+// serialization.
+func nodeParser(nodes []Node) *Parser {
+	p := &Parser{tokenizer: NewTokenizer(strings.NewReader("")), replay: appendNodeTokens(nil, nodes)}
+	p.current, p.currentSpan = p.fetch()
+	p.next, p.nextSpan = p.fetch()
+	return p
+}
+
+var blockOpenTokens = map[TokenType]TokenType{RCurlyToken: LCurlyToken, RSquareToken: LSquareToken, RParenToken: LParenToken}
+
+func appendNodeTokens(toks []spannedToken, nodes []Node) []spannedToken {
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *TokenNode:
+			toks = append(toks, spannedToken{token: n.Token})
+		case *NumberNode:
+			tt := NumberToken
+			switch n.Type {
+			case "dimension":
+				tt = DimensionToken
+			case "percentage":
+				tt = PercentageToken
+			}
+			toks = append(toks, spannedToken{token: NewToken(tt, n.Numeric)})
+		case *HashNode:
+			var value interface{} = Identifier(n.Hash)
+			if n.Unrestricted {
+				value = n.Hash
+			}
+			toks = append(toks, spannedToken{token: NewToken(HashToken, value)})
+		case *VarNode:
+			toks = appendNodeTokens(toks, []Node{n.Function})
+		case *FunctionNode:
+			toks = append(toks, spannedToken{token: NewToken(FunctionToken, n.Name)})
+			toks = appendNodeTokens(toks, n.Values)
+			toks = append(toks, spannedToken{token: NewToken(RParenToken, nil)})
+		case *BlockNode:
+			toks = append(toks, spannedToken{token: NewToken(blockOpenTokens[n.EndDelim], nil)})
+			toks = appendNodeTokens(toks, n.Values)
+			toks = append(toks, spannedToken{token: NewToken(n.EndDelim, nil)})
+		}
+	}
+	return toks
+}
+
 func NewDebugParser(runeScanner io.RuneScanner) *Parser { return newParser(runeScanner, true) }
 
 func (p *Parser) consume1() {
@@ -419,8 +508,8 @@ func (p *Parser) consume1() {
 		p.reconsume = false
 		return
 	}
-	p.current = p.next
-	p.next = p.tokenizer.ConsumeToken()
+	p.current, p.currentSpan = p.next, p.nextSpan
+	p.next, p.nextSpan = p.fetch()
 	p.debug("Consume:", p.current.String())
 }
 
@@ -429,12 +518,32 @@ func (p *Parser) Consume1() *Token {
 	return p.current
 }
 
+// ComponentValues returns an iterator over the remaining component values,
+// parsing each as it's reached rather than the whole input at once. It ends
+// at the end of the input, or after yielding an ErrorNode.
+func (p *Parser) ComponentValues() iter.Seq[Node] {
+	return func(yield func(Node) bool) {
+		for {
+			next := p.consumeComponentValue()
+			if _, eof := next.(EOFNode); eof {
+				return
+			}
+			if _, bad := next.(*ErrorNode); !yield(next) || bad {
+				return
+			}
+			p.Consume1()
+		}
+	}
+}
+
 func (p *Parser) ParseListOfComponentValues() []Node {
 	nodes := make([]Node, 0)
 	for {
 		next := p.consumeComponentValue()
 		nodes = append(nodes, next)
-		if nodeIsEOFOrError(next) {
+		// Unmatched closing brackets are kept as error nodes, and the
+		// list goes on after them.
+		if _, eof := next.(EOFNode); eof {
 			break
 		}
 		p.Consume1()
@@ -456,14 +565,25 @@ func (p *Parser) consumeComponentValue() Node {
 	case LParenToken:
 		return p.consumeSimpleBlock(RParenToken)
 	case RCurlyToken:
-		return NewErrorNode(UnmatchedCurlyErr)
+		return p.errorNode(UnmatchedCurlyErr, p.currentSpan.Start, "unmatched \"}\"")
 	case RSquareToken:
-		return NewErrorNode(UnmatchedSquareErr)
+		return p.errorNode(UnmatchedSquareErr, p.currentSpan.Start, "unmatched \"]\"")
 	case RParenToken:
-		return NewErrorNode(UnmatchedParenErr)
-	default:
-		return NewTokenNode(p.current)
+		return p.errorNode(UnmatchedParenErr, p.currentSpan.Start, "unmatched \")\"")
+	case BadStringToken:
+		p.badToken(CodeBadString, "unterminated string")
+	case BadUrlToken:
+		p.badToken(CodeBadURL, "invalid url()")
 	}
+	return NewTokenNode(p.current)
+}
+
+// badToken records a diagnostic for a token the tokenizer could only
+// partially read. The token itself is kept in the parsed values.
+func (p *Parser) badToken(code, message string) {
+	span := p.currentSpan
+	span.File = p.file
+	p.diagnostics.Add(&Diagnostic{Severity: SeverityError, Code: code, Message: message, Span: span})
 }
 
 func (p *Parser) consumeSimpleBlock(delim TokenType) *BlockNode {
@@ -484,28 +604,40 @@ func (p *Parser) consumeFunction() Node {
 		values = append(values, p.consumeComponentValue())
 		tt = p.Consume1().TokenType
 	}
-	return NewFunctionNode(name, values...)
+	fn := NewFunctionNode(name, values...)
+	if !p.compat && toLower(name) == "var" {
+		if v := newVarNode(fn); v != nil {
+			return v
+		}
+	}
+	return fn
 }
 
+// ParseDeclarationList parses the contents of a block such as a style rule's.
+// Besides declarations and at-rules it may hold nested qualified rules; input
+// that is none of these is reported in the diagnostics and skipped.
 func (p *Parser) ParseDeclarationList() []Node {
+	if !p.compat {
+		return p.consumeBlockContents(false, true)
+	}
 	decls := make([]Node, 0)
 	for p.current.TokenType != EOFToken {
 		switch p.current.TokenType {
 		case WhitespaceToken, SemicolonToken:
 			p.Consume1()
 		case AtKeywordToken:
-			decls = append(decls, p.consumeAtRule())
+			decls = append(decls, p.consumeAtRule(false, true))
 		case IdentToken:
 			decls = append(decls, p.consumeDeclaration())
 		default:
-			// FIXME: compliance with css3 tests, but not with standard
-			// should just be consuming tokens, not component values
+			start := p.currentSpan.Start
 			for p.current.TokenType != EOFToken && p.current.TokenType != SemicolonToken {
 				p.consumeComponentValue()
 				p.Consume1()
 			}
+			err := p.errorNode(SyntaxErr, start, "expected a declaration or at-rule")
 			p.Consume1()
-			decls = append(decls, NewErrorNode(SyntaxErr))
+			decls = append(decls, err)
 		}
 	}
 	return decls
@@ -516,38 +648,57 @@ func (p *Parser) ParseDeclaration() Node {
 		p.Consume1()
 	}
 	if p.current.TokenType == EOFToken {
-		return NewErrorNode(EmptyErr)
+		return p.errorNode(EmptyErr, p.currentSpan.Start, "expected a declaration")
 	}
 	if p.current.TokenType != IdentToken {
-		return NewErrorNode(SyntaxErr)
+		return p.errorNode(SyntaxErr, p.currentSpan.Start, "expected a property name")
+	}
+	if !p.compat {
+		start := p.currentSpan.Start
+		result := p.consumeSpecDeclaration()
+		if result == nil {
+			return p.errorNode(SyntaxErr, start, "invalid declaration")
+		}
+		if p.current.TokenType == SemicolonToken {
+			p.Consume1()
+		}
+		for p.current.TokenType == WhitespaceToken {
+			p.Consume1()
+		}
+		if p.current.TokenType != EOFToken {
+			return p.errorNode(ExtraInputErr, p.currentSpan.Start, "unexpected input after declaration")
+		}
+		return result
 	}
 	result := p.consumeDeclaration()
 	if _, ok := result.(*DeclarationNode); ok && p.current.TokenType != EOFToken {
-		return NewErrorNode(ExtraInputErr)
+		return p.errorNode(ExtraInputErr, p.currentSpan.Start, "unexpected input after declaration")
 	}
 	return result
 }
 
+// consumeDeclaration consumes a declaration in compat mode, up to and
+// including the ";" that ends it.
 func (p *Parser) consumeDeclaration() Node {
 	name := string(p.current.Value.(Identifier))
+	start := p.currentSpan.Start
 	p.Consume1()
 	for p.current.TokenType == WhitespaceToken {
 		p.Consume1()
 	}
 	if p.current.TokenType != ColonToken {
-		return NewErrorNode(SyntaxErr)
+		return p.errorNode(SyntaxErr, p.currentSpan.Start, "expected \":\" after property name %q", name)
 	}
 	p.Consume1()
 	values := make([]Node, 0)
 	for p.current.TokenType != EOFToken && p.current.TokenType != SemicolonToken {
-		// FIXME: compliance with css3 tests, but not with standard
-		// should just be consuming tokens, not component values
 		values = append(values, p.consumeComponentValue())
 		p.Consume1()
 	}
 	if p.current.TokenType != EOFToken && p.current.TokenType != SemicolonToken {
-		return NewErrorNode(ExtraInputErr)
+		return p.errorNode(ExtraInputErr, p.currentSpan.Start, "unexpected input after declaration")
 	}
+	end := p.currentSpan.Start
 	p.Consume1()
 
 	// Check if consumed values list ends with "!important"
@@ -563,27 +714,192 @@ func (p *Parser) consumeDeclaration() Node {
 		}
 	}
 
-	// FIXME: compliance with css3 tests, but not with standard?
 	for _, n := range values {
 		if nodeIsTokenType(n, DelimToken) && n.(*TokenNode).Value.(rune) == '!' {
-			return NewErrorNode(SyntaxErr)
+			d := NewDiagnostic(SyntaxErr, Span{p.file, start, end}, "unexpected \"!\" in value of %q", name)
+			p.diagnostics.Add(d)
+			return NewErrorNode(d)
+		}
+	}
+
+	return NewDeclarationNode(name, values, important)
+}
+
+// consumeBlockContents parses declarations, at-rules and nested rules as the
+// CSS Syntax Level 3 "consume a block's contents" algorithm does: anything
+// that doesn't start with an at-keyword is tried as a declaration first, and
+// then again as a qualified rule ending at the next ";". Inside a block
+// (nested), it stops at the closing "}". Declarations are reported and
+// skipped unless inStyle is set.
+func (p *Parser) consumeBlockContents(nested, inStyle bool) []Node {
+	decls := make([]Node, 0)
+	for p.current.TokenType != EOFToken {
+		switch p.current.TokenType {
+		case WhitespaceToken, SemicolonToken:
+			p.Consume1()
+		case RCurlyToken:
+			if nested {
+				return decls
+			}
+			p.errorNode(UnmatchedCurlyErr, p.currentSpan.Start, "unmatched \"}\"")
+			p.Consume1()
+		case AtKeywordToken:
+			decls = append(decls, p.consumeAtRule(true, inStyle))
+		default:
+			start := p.currentSpan.Start
+			p.mark()
+			if decl := p.consumeSpecDeclaration(); decl != nil {
+				p.unmark()
+				if inStyle {
+					decls = append(decls, decl)
+				} else {
+					p.errorNode(SyntaxErr, start, "declarations are only allowed in a style rule")
+				}
+				continue
+			}
+			p.restore()
+			if rule := p.consumeNestedRule(); rule != nil {
+				if _, bad := rule.(*ErrorNode); !bad {
+					decls = append(decls, rule)
+				}
+				p.Consume1()
+				continue
+			}
+			p.errorNode(SyntaxErr, start, "expected a declaration, at-rule or nested rule")
+		}
+	}
+	return decls
+}
+
+// consumeSpecDeclaration consumes a declaration as CSS Syntax Level 3 does,
+// stopping before the ";" or "}" that ends it. If the input isn't a valid
+// declaration it returns nil as soon as that's clear, so callers restore a
+// mark to read it again as something else.
+func (p *Parser) consumeSpecDeclaration() Node {
+	if p.current.TokenType != IdentToken {
+		return nil
+	}
+	name := string(p.current.Value.(Identifier))
+	p.Consume1()
+	for p.current.TokenType == WhitespaceToken {
+		p.Consume1()
+	}
+	if p.current.TokenType != ColonToken {
+		return nil
+	}
+	p.Consume1()
+	for p.current.TokenType == WhitespaceToken {
+		p.Consume1()
+	}
+
+	// Outside custom properties, a {} block must be a value on its own, so
+	// that "a:hover { ... }" is left to be read as a nested rule.
+	custom := strings.HasPrefix(name, "--")
+	var block, other bool
+	values := make([]Node, 0)
+	for !p.atDeclarationEnd() {
+		value := p.consumeComponentValue()
+		if b, ok := value.(*BlockNode); ok && b.EndDelim == RCurlyToken {
+			block = true
+		} else if !nodeIsTokenType(value, WhitespaceToken) {
+			other = true
 		}
+		if block && other && !custom {
+			return nil
+		}
+		values = append(values, value)
+		p.Consume1()
 	}
 
+	var important bool
+	if i := nonWhitespaceIndexes(values, 2, true); len(i) == 2 && nodeIsTokenType(values[i[1]], DelimToken) &&
+		values[i[1]].(*TokenNode).Value.(rune) == '!' && nodeIsTokenType(values[i[0]], IdentToken) &&
+		caseInsensitiveCompare(string(values[i[0]].(*TokenNode).Value.(Identifier)), "important") {
+		values = values[:i[1]]
+		important = true
+	}
+	for len(values) > 0 && nodeIsTokenType(values[len(values)-1], WhitespaceToken) {
+		values = values[:len(values)-1]
+	}
 	return NewDeclarationNode(name, values, important)
 }
 
-func (p *Parser) consumeAtRule() Node {
+// atDeclarationEnd reports whether the current token ends a declaration.
+func (p *Parser) atDeclarationEnd() bool {
+	switch p.current.TokenType {
+	case EOFToken, SemicolonToken, RCurlyToken:
+		return true
+	}
+	return false
+}
+
+// consumeBadDeclaration skips the rest of an invalid declaration.
+func (p *Parser) consumeBadDeclaration() {
+	for !p.atDeclarationEnd() {
+		p.consumeComponentValue()
+		p.Consume1()
+	}
+}
+
+// consumeNestedRule consumes a qualified rule inside a block, which ends at
+// a ";" or "}" if no "{" is found first. It returns nil, having consumed the
+// prelude, if there is no rule, leaving the current token on the block's
+// closing "}" otherwise.
+func (p *Parser) consumeNestedRule() Node {
+	start := p.currentSpan.Start
+	prelude := make([]Node, 0)
+	for p.current.TokenType != LCurlyToken {
+		if p.atDeclarationEnd() {
+			return nil
+		}
+		prelude = append(prelude, p.consumeComponentValue())
+		p.Consume1()
+	}
+	if i := nonWhitespaceIndexes(prelude, 2, false); len(i) == 2 && nodeIsTokenType(prelude[i[0]], IdentToken) &&
+		strings.HasPrefix(string(prelude[i[0]].(*TokenNode).Value.(Identifier)), "--") &&
+		nodeIsTokenType(prelude[i[1]], ColonToken) {
+		p.consumeBadDeclaration()
+		return nil
+	}
+	if p.nesting {
+		return p.consumeStyleRule(prelude, start)
+	}
+	var body []Node
+	block := p.consumeSimpleBlock(RCurlyToken)
+	if len(block.Values) > 0 {
+		body = block.Values
+	}
+	return NewQualifiedRuleNode(prelude, body)
+}
+
+// consumeAtRule consumes an at-rule and whatever ends it. Inside a block
+// (nested), a "}" ends the rule but is left to be consumed by the block. In
+// nesting mode, conditional group rules are parsed as GroupRuleNodes, whose
+// blocks may hold declarations if they're inside a style rule (inStyle).
+func (p *Parser) consumeAtRule(nested, inStyle bool) Node {
 	name := p.current.Value.(string)
+	start := p.currentSpan.Start
 	prelude := make([]Node, 0)
 	p.Consume1()
 	for p.current.TokenType != EOFToken && p.current.TokenType != SemicolonToken {
-		if p.current.TokenType == LCurlyToken {
+		if p.current.TokenType == LCurlyToken || (nested && p.current.TokenType == RCurlyToken) {
 			break
 		}
 		prelude = append(prelude, p.consumeComponentValue())
 		p.Consume1()
 	}
+	if nested && p.current.TokenType == RCurlyToken {
+		return NewAtRuleNode(name, prelude, nil)
+	}
+	if p.nesting && p.current.TokenType == LCurlyToken && groupRules[toLower(name)] {
+		p.Consume1()
+		body := p.consumeBlockContents(true, inStyle)
+		if p.current.TokenType != RCurlyToken {
+			p.errorNode(SyntaxErr, start, "expected \"}\" to end @%s", name)
+		}
+		p.Consume1()
+		return NewGroupRuleNode(name, prelude, body)
+	}
 	var body []Node
 	if p.current.TokenType == LCurlyToken {
 		block := p.consumeSimpleBlock(RCurlyToken)
@@ -600,11 +916,11 @@ func (p *Parser) ParseRule() Node {
 		p.Consume1()
 	}
 	if p.current.TokenType == EOFToken {
-		return NewErrorNode(EmptyErr)
+		return p.errorNode(EmptyErr, p.currentSpan.Start, "expected a rule")
 	}
 	var result Node
 	if p.current.TokenType == AtKeywordToken {
-		result = p.consumeAtRule()
+		result = p.consumeAtRule(false, false)
 	} else {
 		result = p.consumeQualifiedRule()
 		// if nothing was returned, return a syntax error
@@ -614,46 +930,64 @@ func (p *Parser) ParseRule() Node {
 		p.Consume1()
 	}
 	if p.current.TokenType != EOFToken {
-		return NewErrorNode(ExtraInputErr)
+		return p.errorNode(ExtraInputErr, p.currentSpan.Start, "unexpected input after rule")
 	}
 	return result
 }
 
 func (p *Parser) ParseRuleList() []Node {
-	return p.consumeRuleList(false)
+	return collectNodes(p.rules(false))
 }
 
-func (p *Parser) consumeRuleList(toplevel bool) []Node {
-	rules := make([]Node, 0)
-	for p.current.TokenType != EOFToken {
-		if p.current.TokenType == WhitespaceToken {
+// rules returns an iterator over the rules of a rule list, parsing each as
+// it's reached.
+func (p *Parser) rules(toplevel bool) iter.Seq[Node] {
+	return func(yield func(Node) bool) {
+		for p.current.TokenType != EOFToken {
+			if p.current.TokenType == WhitespaceToken {
+				p.Consume1()
+				continue
+			}
+			var rule Node
+			if p.current.TokenType == CDOToken || p.current.TokenType == CDCToken {
+				if !toplevel {
+					rule = p.consumeQualifiedRule()
+				}
+			} else if p.current.TokenType == AtKeywordToken {
+				rule = p.consumeAtRule(false, false)
+			} else {
+				rule = p.consumeQualifiedRule()
+			}
 			p.Consume1()
-			continue
-		}
-		if p.current.TokenType == CDOToken || p.current.TokenType == CDCToken {
-			if !toplevel {
-				rules = append(rules, p.consumeQualifiedRule())
+			if rule != nil && !yield(rule) {
+				return
 			}
-		} else if p.current.TokenType == AtKeywordToken {
-			rules = append(rules, p.consumeAtRule())
-		} else {
-			rules = append(rules, p.consumeQualifiedRule())
 		}
-		p.Consume1()
 	}
-	return rules
+}
+
+func collectNodes(seq iter.Seq[Node]) []Node {
+	nodes := make([]Node, 0)
+	for n := range seq {
+		nodes = append(nodes, n)
+	}
+	return nodes
 }
 
 func (p *Parser) consumeQualifiedRule() Node {
 	var body []Node
 	prelude := make([]Node, 0)
+	start := p.currentSpan.Start
 	for p.current.TokenType != LCurlyToken {
 		if p.current.TokenType == EOFToken {
-			return NewErrorNode(SyntaxErr)
+			return p.errorNode(SyntaxErr, start, "expected \"{\" after selector")
 		}
 		prelude = append(prelude, p.consumeComponentValue())
 		p.Consume1()
 	}
+	if p.nesting {
+		return p.consumeStyleRule(prelude, start)
+	}
 	block := p.consumeSimpleBlock(RCurlyToken)
 	if len(block.Values) > 0 {
 		body = block.Values
@@ -662,7 +996,13 @@ func (p *Parser) consumeQualifiedRule() Node {
 }
 
 func (p *Parser) ParseStylesheet() []Node {
-	return p.consumeRuleList(true)
+	return collectNodes(p.Stylesheet())
+}
+
+// Stylesheet returns an iterator over the rules of a stylesheet, parsing
+// each as it's reached, so that only one rule is held in memory at a time.
+func (p *Parser) Stylesheet() iter.Seq[Node] {
+	return p.rules(true)
 }
 
 func caseInsensitiveCompare(s1, s2 string) bool {
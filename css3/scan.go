@@ -1,6 +1,7 @@
 package css3
 
 import (
+	"fmt"
 	"io"
 )
 
@@ -12,6 +13,32 @@ type codePoint struct {
 	error
 }
 
+// Position locates a code point in the scanned input. Offset counts bytes
+// from the start of the input; Line and Column count from 1, with columns
+// measured in code points.
+type Position struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+var StartPosition = Position{0, 1, 1}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+func (p Position) advance(ch rune, size int) Position {
+	p.Offset += size
+	if ch == '\n' {
+		p.Line++
+		p.Column = 1
+	} else {
+		p.Column++
+	}
+	return p
+}
+
 type Scanner struct {
 	error
 	preprocessor
@@ -19,24 +46,35 @@ type Scanner struct {
 	current   rune
 	next      []rune
 	reconsume bool
+
+	pos     Position
+	nextPos []Position
+	readPos Position
 }
 
 func NewScanner(runeScanner io.RuneScanner) *Scanner {
-	return &Scanner{preprocessor: preprocessor{RuneScanner: runeScanner}}
+	return &Scanner{preprocessor: preprocessor{RuneScanner: runeScanner}, readPos: StartPosition}
 }
 
-func (s *Scanner) nextRune() (rune, error) {
-	return s.preprocessor.nextRune()
+func (s *Scanner) nextRune() (rune, Position, error) {
+	pos := s.readPos
+	ch, size, err := s.preprocessor.nextRune()
+	if ch >= 0 {
+		s.readPos = s.readPos.advance(ch, size)
+	}
+	return ch, pos, err
 }
 
 func (s *Scanner) fill() {
 	if s.next == nil {
-		s.current, s.error = s.nextRune()
+		s.current, s.pos, s.error = s.nextRune()
 		s.next = make([]rune, 0, 3)
+		s.nextPos = make([]Position, 0, 3)
 	}
 	for len(s.next) < 3 {
-		ch, err := s.nextRune()
+		ch, pos, err := s.nextRune()
 		s.next = append(s.next, ch)
+		s.nextPos = append(s.nextPos, pos)
 		s.error = err
 	}
 }
@@ -49,10 +87,10 @@ func (s *Scanner) consume1() {
 	if s.next == nil {
 		s.fill()
 	} else {
-		s.current = s.next[0]
-		s.next[0] = s.next[1]
-		s.next[1] = s.next[2]
-		s.next[2], s.error = s.nextRune()
+		s.current, s.pos = s.next[0], s.nextPos[0]
+		s.next[0], s.nextPos[0] = s.next[1], s.nextPos[1]
+		s.next[1], s.nextPos[1] = s.next[2], s.nextPos[2]
+		s.next[2], s.nextPos[2], s.error = s.nextRune()
 	}
 }
 
@@ -62,6 +100,17 @@ func (s *Scanner) Current() rune { return s.current }
 func (s *Scanner) Next() rune    { return s.next[0] }
 func (s *Scanner) Peek3() []rune { return s.next }
 
+// Pos returns the position of the current code point.
+func (s *Scanner) Pos() Position { return s.pos }
+
+// NextPos returns the position of the code point following the current one.
+func (s *Scanner) NextPos() Position {
+	if s.nextPos == nil {
+		return s.readPos
+	}
+	return s.nextPos[0]
+}
+
 func (s *Scanner) Consume1() rune {
 	s.consume1()
 	return s.current
@@ -88,41 +137,43 @@ type preprocessor struct {
 	eof bool
 }
 
-func (p *preprocessor) nextRune() (rune, error) {
+func (p *preprocessor) nextRune() (rune, int, error) {
 	if p.error != nil {
-		return ErrorRune, p.error
+		return ErrorRune, 0, p.error
 	}
 	if p.eof {
-		return EOFRune, nil
+		return EOFRune, 0, nil
 	}
 
-	next, _, err := p.RuneScanner.ReadRune()
+	next, size, err := p.RuneScanner.ReadRune()
 	if err != nil {
 		if err == io.EOF {
 			p.eof = true
-			return EOFRune, nil
+			return EOFRune, 0, nil
 		}
 		p.error = err
-		return ErrorRune, err
+		return ErrorRune, 0, err
 	}
 	if next == '\r' {
-		next, _, err = p.RuneScanner.ReadRune()
+		var size2 int
+		next, size2, err = p.RuneScanner.ReadRune()
 		if err != nil {
 			if err == io.EOF {
 				p.eof = true
 			} else {
 				p.error = err
 			}
-			return '\n', nil
+			return '\n', size, nil
 		} else if next != '\n' {
 			p.error = p.RuneScanner.UnreadRune()
-			return '\n', nil
+			return '\n', size, nil
 		}
+		size += size2
 		next = '\n'
 	} else if next == '\f' {
 		next = '\n'
 	} else if next == 0 {
 		next = '\ufffd'
 	}
-	return next, nil
+	return next, size, nil
 }
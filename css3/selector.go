@@ -0,0 +1,680 @@
+package css3
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ExpectedSelectorErr   = errors.New("expected selector")
+	ExpectedIdentifierErr = errors.New("expected identifier")
+	BadAttributeErr       = errors.New("invalid attribute selector")
+	BadCombinatorErr      = errors.New("unexpected combinator")
+)
+
+type Combinator int
+
+const (
+	NoCombinator Combinator = iota
+	DescendantCombinator
+	ChildCombinator
+	NextSiblingCombinator
+	SubsequentSiblingCombinator
+	ColumnCombinator
+)
+
+func (c Combinator) String() string {
+	switch c {
+	case DescendantCombinator:
+		return " "
+	case ChildCombinator:
+		return ">"
+	case NextSiblingCombinator:
+		return "+"
+	case SubsequentSiblingCombinator:
+		return "~"
+	case ColumnCombinator:
+		return "||"
+	default:
+		return ""
+	}
+}
+
+type SimpleSelectorType int
+
+const (
+	UniversalSelector SimpleSelectorType = iota
+	TypeSelector
+	IDSelector
+	ClassSelector
+	AttributeSelector
+	PseudoClassSelector
+	PseudoElementSelector
+	NestingSelector
+	PlaceholderSelector
+)
+
+// SimpleSelector is one simple selector of a compound selector. Which fields
+// are meaningful depends on Type: Namespace applies to type, universal and
+// attribute selectors and includes the trailing "|"; Matcher, Value, Quoted
+// and Modifier describe attribute selectors; Argument and Selector hold the
+// arguments of functional pseudo-classes; Suffix is the text glued onto a
+// nesting selector, as in "&-item".
+type SimpleSelector struct {
+	Type      SimpleSelectorType
+	Namespace string
+	Name      string
+	Matcher   string
+	Value     string
+	Quoted    bool
+	Modifier  string
+	Argument  string
+	Selector  SelectorList
+	Suffix    string
+}
+
+type CompoundSelector []*SimpleSelector
+
+type ComplexComponent struct {
+	Combinator Combinator
+	Compound   CompoundSelector
+}
+
+// ComplexSelector is a sequence of compound selectors joined by combinators.
+// The first component's combinator is NoCombinator unless the selector is
+// relative, as in "> a".
+type ComplexSelector struct {
+	Components []ComplexComponent
+}
+
+type SelectorList []*ComplexSelector
+
+var selectorPseudoClasses = map[string]bool{
+	"not":          true,
+	"is":           true,
+	"matches":      true,
+	"where":        true,
+	"any":          true,
+	"-webkit-any":  true,
+	"-moz-any":     true,
+	"has":          true,
+	"host":         true,
+	"host-context": true,
+	"current":      true,
+	"slotted":      true,
+}
+
+var legacyPseudoElements = map[string]bool{
+	"before":       true,
+	"after":        true,
+	"first-line":   true,
+	"first-letter": true,
+}
+
+// ParseSelector parses a selector list from source text.
+func ParseSelector(s string) (SelectorList, error) {
+	return ParseSelectorNodes(NewParser(strings.NewReader(s)).ParseListOfComponentValues())
+}
+
+// ParseSelectorNodes parses a selector list from component values, such as
+// the prelude of a QualifiedRuleNode. Besides standard CSS it accepts the
+// nesting selector "&" and Sass placeholder selectors ("%name").
+func ParseSelectorNodes(nodes []Node) (SelectorList, error) {
+	var list SelectorList
+	start := 0
+	for i := 0; i <= len(nodes); i++ {
+		end := i == len(nodes)
+		if !end {
+			switch n := nodes[i].(type) {
+			case EOFNode:
+				end = true
+			case *ErrorNode:
+				return nil, n.error
+			}
+		}
+		if end || nodeIsTokenType(nodes[i], CommaToken) {
+			complex, err := parseComplexSelector(nodes[start:i])
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, complex)
+			start = i + 1
+		}
+		if end {
+			break
+		}
+	}
+	return list, nil
+}
+
+type selectorParser struct {
+	nodes []Node
+	i     int
+}
+
+func (sp *selectorParser) done() bool { return sp.i >= len(sp.nodes) }
+
+func (sp *selectorParser) peek() Node {
+	if sp.done() {
+		return nil
+	}
+	return sp.nodes[sp.i]
+}
+
+func (sp *selectorParser) peekDelim(ch rune) bool {
+	return nodeIsDelim(sp.peek(), ch)
+}
+
+func (sp *selectorParser) skipWhitespace() bool {
+	skipped := false
+	for nodeIsTokenType(sp.peek(), WhitespaceToken) {
+		sp.i++
+		skipped = true
+	}
+	return skipped
+}
+
+func nodeIsDelim(node Node, ch rune) bool {
+	if tn, ok := node.(*TokenNode); ok && tn.TokenType == DelimToken {
+		return tn.Value.(rune) == ch
+	}
+	return false
+}
+
+func (sp *selectorParser) combinator() (Combinator, bool) {
+	node := sp.peek()
+	switch {
+	case nodeIsDelim(node, '>'):
+		return ChildCombinator, true
+	case nodeIsDelim(node, '+'):
+		return NextSiblingCombinator, true
+	case nodeIsDelim(node, '~'):
+		return SubsequentSiblingCombinator, true
+	case nodeIsTokenType(node, ColumnToken):
+		return ColumnCombinator, true
+	}
+	return NoCombinator, false
+}
+
+func parseComplexSelector(nodes []Node) (*ComplexSelector, error) {
+	sp := &selectorParser{nodes: nodes}
+	complex := &ComplexSelector{}
+	combinator := NoCombinator
+	sp.skipWhitespace()
+	for !sp.done() {
+		if c, ok := sp.combinator(); ok {
+			if combinator != NoCombinator && combinator != DescendantCombinator {
+				return nil, BadCombinatorErr
+			}
+			combinator = c
+			sp.i++
+			sp.skipWhitespace()
+			continue
+		}
+		if sp.skipWhitespace() {
+			if combinator == NoCombinator {
+				combinator = DescendantCombinator
+			}
+			continue
+		}
+		compound, err := sp.parseCompound()
+		if err != nil {
+			return nil, err
+		}
+		if len(complex.Components) == 0 && combinator == DescendantCombinator {
+			combinator = NoCombinator
+		}
+		complex.Components = append(complex.Components, ComplexComponent{combinator, compound})
+		combinator = NoCombinator
+	}
+	if len(complex.Components) == 0 || (combinator != NoCombinator && combinator != DescendantCombinator) {
+		return nil, ExpectedSelectorErr
+	}
+	return complex, nil
+}
+
+func (sp *selectorParser) parseCompound() (CompoundSelector, error) {
+	var compound CompoundSelector
+	for !sp.done() {
+		if _, ok := sp.combinator(); ok || nodeIsTokenType(sp.peek(), WhitespaceToken) {
+			break
+		}
+		simple, err := sp.parseSimple(len(compound) == 0)
+		if err != nil {
+			return nil, err
+		}
+		compound = append(compound, simple)
+	}
+	if len(compound) == 0 {
+		return nil, ExpectedSelectorErr
+	}
+	return compound, nil
+}
+
+func (sp *selectorParser) ident() (string, bool) {
+	if tn, ok := sp.peek().(*TokenNode); ok && tn.TokenType == IdentToken {
+		sp.i++
+		return string(tn.Value.(Identifier)), true
+	}
+	return "", false
+}
+
+// namespacedName parses "name", "*", "ns|name", "*|name" or "|name".
+func (sp *selectorParser) namespacedName() (ns string, name string, ok bool) {
+	first := ""
+	if sp.peekDelim('*') {
+		sp.i++
+		first = "*"
+	} else if id, ok := sp.ident(); ok {
+		first = id
+	} else if !sp.peekDelim('|') {
+		return "", "", false
+	}
+	if !sp.peekDelim('|') {
+		return "", first, true
+	}
+	sp.i++
+	if sp.peekDelim('*') {
+		sp.i++
+		return first + "|", "*", true
+	}
+	if id, ok := sp.ident(); ok {
+		return first + "|", id, true
+	}
+	return "", "", false
+}
+
+func (sp *selectorParser) parseSimple(first bool) (*SimpleSelector, error) {
+	switch n := sp.peek().(type) {
+	case *HashNode:
+		sp.i++
+		return &SimpleSelector{Type: IDSelector, Name: n.Hash}, nil
+	case *BlockNode:
+		if n.EndDelim != RSquareToken {
+			return nil, ExpectedSelectorErr
+		}
+		sp.i++
+		return parseAttributeSelector(n.Values)
+	case *TokenNode:
+		switch {
+		case n.TokenType == ColonToken:
+			return sp.parsePseudo()
+		case nodeIsDelim(n, '.'), nodeIsDelim(n, '%'):
+			sp.i++
+			name, ok := sp.ident()
+			if !ok {
+				return nil, ExpectedIdentifierErr
+			}
+			if nodeIsDelim(n, '.') {
+				return &SimpleSelector{Type: ClassSelector, Name: name}, nil
+			}
+			return &SimpleSelector{Type: PlaceholderSelector, Name: name}, nil
+		case nodeIsDelim(n, '&'):
+			sp.i++
+			suffix, _ := sp.ident()
+			return &SimpleSelector{Type: NestingSelector, Suffix: suffix}, nil
+		case first && (n.TokenType == IdentToken || nodeIsDelim(n, '*') || nodeIsDelim(n, '|')):
+			ns, name, ok := sp.namespacedName()
+			if !ok {
+				return nil, ExpectedIdentifierErr
+			}
+			if name == "*" {
+				return &SimpleSelector{Type: UniversalSelector, Namespace: ns}, nil
+			}
+			return &SimpleSelector{Type: TypeSelector, Namespace: ns, Name: name}, nil
+		}
+	}
+	return nil, ExpectedSelectorErr
+}
+
+func (sp *selectorParser) parsePseudo() (*SimpleSelector, error) {
+	sp.i++
+	t := PseudoClassSelector
+	if nodeIsTokenType(sp.peek(), ColonToken) {
+		sp.i++
+		t = PseudoElementSelector
+	}
+	switch n := sp.peek().(type) {
+	case *TokenNode:
+		if n.TokenType == IdentToken {
+			sp.i++
+			return &SimpleSelector{Type: t, Name: string(n.Value.(Identifier))}, nil
+		}
+	case *FunctionNode:
+		sp.i++
+		s := &SimpleSelector{Type: t, Name: n.Name}
+		if selectorPseudoClasses[toLower(n.Name)] {
+			list, err := ParseSelectorNodes(n.Values)
+			if err != nil {
+				return nil, err
+			}
+			s.Selector = list
+		} else {
+			s.Argument = strings.TrimSpace(nodesString(n.Values))
+		}
+		return s, nil
+	}
+	return nil, ExpectedIdentifierErr
+}
+
+func parseAttributeSelector(nodes []Node) (*SimpleSelector, error) {
+	sp := &selectorParser{nodes: nodes}
+	sp.skipWhitespace()
+	ns, name, ok := sp.namespacedName()
+	if !ok || name == "*" {
+		return nil, BadAttributeErr
+	}
+	s := &SimpleSelector{Type: AttributeSelector, Namespace: ns, Name: name}
+	sp.skipWhitespace()
+	if sp.done() {
+		return s, nil
+	}
+	switch n := sp.peek().(type) {
+	case *TokenNode:
+		switch n.TokenType {
+		case DelimToken:
+			if n.Value.(rune) != '=' {
+				return nil, BadAttributeErr
+			}
+			s.Matcher = "="
+		case IncludeMatchToken, DashMatchToken, PrefixMatchToken, SuffixMatchToken, SubstringMatchToken:
+			s.Matcher = nodesString([]Node{n})
+		default:
+			return nil, BadAttributeErr
+		}
+	default:
+		return nil, BadAttributeErr
+	}
+	sp.i++
+	sp.skipWhitespace()
+	switch n := sp.peek().(type) {
+	case *TokenNode:
+		switch n.TokenType {
+		case IdentToken:
+			s.Value = string(n.Value.(Identifier))
+		case StringToken:
+			s.Value = n.Value.(string)
+			s.Quoted = true
+		default:
+			return nil, BadAttributeErr
+		}
+	default:
+		return nil, BadAttributeErr
+	}
+	sp.i++
+	sp.skipWhitespace()
+	if id, ok := sp.ident(); ok {
+		s.Modifier = id
+		sp.skipWhitespace()
+	}
+	if !sp.done() {
+		return nil, BadAttributeErr
+	}
+	return s, nil
+}
+
+// IsPseudoElement reports whether s selects a pseudo-element, including the
+// legacy single-colon forms such as ":before".
+func (s *SimpleSelector) IsPseudoElement() bool {
+	if s.Type == PseudoElementSelector {
+		return true
+	}
+	return s.Type == PseudoClassSelector && legacyPseudoElements[toLower(s.Name)]
+}
+
+func (s *SimpleSelector) Equal(other *SimpleSelector) bool {
+	return s.String() == other.String()
+}
+
+func (s *SimpleSelector) String() string {
+	var buf bytes.Buffer
+	s.format(&buf, false)
+	return buf.String()
+}
+
+func (s *SimpleSelector) format(buf *bytes.Buffer, compact bool) {
+	switch s.Type {
+	case UniversalSelector:
+		buf.WriteString(s.Namespace)
+		buf.WriteByte('*')
+	case TypeSelector:
+		buf.WriteString(s.Namespace)
+		buf.WriteString(escapeIdent(s.Name))
+	case IDSelector:
+		buf.WriteByte('#')
+		buf.WriteString(escapeIdent(s.Name))
+	case ClassSelector:
+		buf.WriteByte('.')
+		buf.WriteString(escapeIdent(s.Name))
+	case PlaceholderSelector:
+		buf.WriteByte('%')
+		buf.WriteString(escapeIdent(s.Name))
+	case NestingSelector:
+		buf.WriteByte('&')
+		buf.WriteString(s.Suffix)
+	case AttributeSelector:
+		buf.WriteByte('[')
+		buf.WriteString(s.Namespace)
+		buf.WriteString(escapeIdent(s.Name))
+		if s.Matcher != "" {
+			buf.WriteString(s.Matcher)
+			if s.Quoted {
+				buf.WriteString(quoteString(s.Value))
+			} else {
+				buf.WriteString(escapeIdent(s.Value))
+			}
+			if s.Modifier != "" {
+				buf.WriteByte(' ')
+				buf.WriteString(s.Modifier)
+			}
+		}
+		buf.WriteByte(']')
+	case PseudoClassSelector, PseudoElementSelector:
+		buf.WriteByte(':')
+		if s.Type == PseudoElementSelector {
+			buf.WriteByte(':')
+		}
+		buf.WriteString(escapeIdent(s.Name))
+		if s.Selector != nil {
+			buf.WriteByte('(')
+			s.Selector.format(buf, compact)
+			buf.WriteByte(')')
+		} else if s.Argument != "" {
+			buf.WriteByte('(')
+			buf.WriteString(s.Argument)
+			buf.WriteByte(')')
+		}
+	}
+}
+
+func (c CompoundSelector) String() string {
+	var buf bytes.Buffer
+	c.format(&buf, false)
+	return buf.String()
+}
+
+func (c CompoundSelector) format(buf *bytes.Buffer, compact bool) {
+	for _, s := range c {
+		s.format(buf, compact)
+	}
+}
+
+func (c *ComplexSelector) String() string {
+	var buf bytes.Buffer
+	c.format(&buf, false)
+	return buf.String()
+}
+
+func (c *ComplexSelector) format(buf *bytes.Buffer, compact bool) {
+	for i, comp := range c.Components {
+		switch comp.Combinator {
+		case NoCombinator:
+		case DescendantCombinator:
+			buf.WriteByte(' ')
+		default:
+			if i > 0 && !compact {
+				buf.WriteByte(' ')
+			}
+			buf.WriteString(comp.Combinator.String())
+			if !compact {
+				buf.WriteByte(' ')
+			}
+		}
+		comp.Compound.format(buf, compact)
+	}
+}
+
+func (l SelectorList) String() string {
+	return l.Format(false)
+}
+
+// Format serializes the selector list; compact output omits the optional
+// whitespace around combinators and after commas.
+func (l SelectorList) Format(compact bool) string {
+	var buf bytes.Buffer
+	l.format(&buf, compact)
+	return buf.String()
+}
+
+func (l SelectorList) format(buf *bytes.Buffer, compact bool) {
+	for i, c := range l {
+		if i > 0 {
+			buf.WriteByte(',')
+			if !compact {
+				buf.WriteByte(' ')
+			}
+		}
+		c.format(buf, compact)
+	}
+}
+
+func escapeIdent(s string) string {
+	var buf bytes.Buffer
+	for i, ch := range s {
+		switch {
+		case ch == 0:
+			buf.WriteRune('�')
+		case (ch >= 1 && ch <= 0x1f) || ch == 0x7f ||
+			(i == 0 && isDigit(ch)) || (i == 1 && isDigit(ch) && s[0] == '-'):
+			fmt.Fprintf(&buf, "\\%x ", ch)
+		case i == 0 && ch == '-' && len(s) == 1:
+			buf.WriteString("\\-")
+		case isName(ch):
+			buf.WriteRune(ch)
+		default:
+			buf.WriteByte('\\')
+			buf.WriteRune(ch)
+		}
+	}
+	return buf.String()
+}
+
+// escapeName escapes s as the name of a hash such as "#06c", which unlike an
+// identifier may start with a digit.
+func escapeName(s string) string {
+	var buf bytes.Buffer
+	for _, ch := range s {
+		switch {
+		case ch == 0:
+			buf.WriteRune('�')
+		case (ch >= 1 && ch <= 0x1f) || ch == 0x7f:
+			fmt.Fprintf(&buf, "\\%x ", ch)
+		case isName(ch):
+			buf.WriteRune(ch)
+		default:
+			buf.WriteByte('\\')
+			buf.WriteRune(ch)
+		}
+	}
+	return buf.String()
+}
+
+func quoteString(s string) string {
+	var buf bytes.Buffer
+	buf.WriteByte('"')
+	for _, ch := range s {
+		switch {
+		case ch == '"' || ch == '\\':
+			buf.WriteByte('\\')
+			buf.WriteRune(ch)
+		case (ch >= 1 && ch <= 0x1f) || ch == 0x7f:
+			fmt.Fprintf(&buf, "\\%x ", ch)
+		default:
+			buf.WriteRune(ch)
+		}
+	}
+	buf.WriteByte('"')
+	return buf.String()
+}
+
+func nodesString(nodes []Node) string {
+	var buf bytes.Buffer
+	for _, node := range nodes {
+		writeNode(&buf, node)
+	}
+	return buf.String()
+}
+
+func writeNode(buf *bytes.Buffer, node Node) {
+	switch n := node.(type) {
+	case *HashNode:
+		buf.WriteByte('#')
+		if n.Unrestricted {
+			buf.WriteString(escapeName(n.Hash))
+		} else {
+			buf.WriteString(escapeIdent(n.Hash))
+		}
+	case *NumberNode:
+		buf.WriteString(n.Repr)
+		buf.WriteString(n.Unit)
+	case *VarNode:
+		writeNode(buf, n.Function)
+	case *FunctionNode:
+		buf.WriteString(escapeIdent(n.Name))
+		buf.WriteByte('(')
+		buf.WriteString(nodesString(n.Values))
+		buf.WriteByte(')')
+	case *BlockNode:
+		switch n.EndDelim {
+		case RParenToken:
+			buf.WriteByte('(')
+		case RSquareToken:
+			buf.WriteByte('[')
+		case RCurlyToken:
+			buf.WriteByte('{')
+		}
+		buf.WriteString(nodesString(n.Values))
+		buf.WriteString(map[TokenType]string{RParenToken: ")", RSquareToken: "]", RCurlyToken: "}"}[n.EndDelim])
+	case *TokenNode:
+		switch n.TokenType {
+		case WhitespaceToken:
+			buf.WriteByte(' ')
+		case DelimToken:
+			buf.WriteRune(n.Value.(rune))
+		case IdentToken:
+			buf.WriteString(escapeIdent(string(n.Value.(Identifier))))
+		case AtKeywordToken:
+			buf.WriteByte('@')
+			buf.WriteString(escapeIdent(n.Value.(string)))
+		case StringToken:
+			buf.WriteString(quoteString(n.Value.(string)))
+		case UrlToken:
+			buf.WriteString("url(")
+			buf.WriteString(n.Value.(string))
+			buf.WriteByte(')')
+		case UnicodeRangeToken:
+			ur := n.Value.(UnicodeRange)
+			if ur.Start == ur.End {
+				fmt.Fprintf(buf, "U+%X", ur.Start)
+			} else {
+				fmt.Fprintf(buf, "U+%X-%X", ur.Start, ur.End)
+			}
+		default:
+			if s, ok := n.TestRepr().(string); ok {
+				buf.WriteString(s)
+			}
+		}
+	}
+}
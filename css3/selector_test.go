@@ -0,0 +1,85 @@
+package css3
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSelector(t *testing.T) {
+	shouldRoundTrip := func(actual interface{}, expected ...interface{}) string {
+		list, err := ParseSelector(actual.(string))
+		if msg := ShouldBeNil(err); msg != "" {
+			return msg
+		}
+		if msg := ShouldEqual(list.String(), expected[0]); msg != "" {
+			return msg
+		}
+		if len(expected) > 1 {
+			return ShouldEqual(list.Format(true), expected[1])
+		}
+		return ""
+	}
+
+	Convey("simple selectors", t, func() {
+		So("a", shouldRoundTrip, "a")
+		So("*", shouldRoundTrip, "*")
+		So("svg|rect", shouldRoundTrip, "svg|rect")
+		So("*|*", shouldRoundTrip, "*|*")
+		So("|a", shouldRoundTrip, "|a")
+		So("#main", shouldRoundTrip, "#main")
+		So(".item", shouldRoundTrip, ".item")
+		So("%placeholder", shouldRoundTrip, "%placeholder")
+		So("&", shouldRoundTrip, "&")
+		So("&-suffix", shouldRoundTrip, "&-suffix")
+		So("&__elem", shouldRoundTrip, "&__elem")
+		So("a:hover", shouldRoundTrip, "a:hover")
+		So("p::first-line", shouldRoundTrip, "p::first-line")
+		So("li:nth-child( 2n+1 )", shouldRoundTrip, "li:nth-child(2n+1)")
+		So(`.\31 0`, shouldRoundTrip, `.\31 0`)
+	})
+
+	Convey("attribute selectors", t, func() {
+		So("[href]", shouldRoundTrip, "[href]")
+		So("[ href = x ]", shouldRoundTrip, "[href=x]")
+		So(`[lang|="en"]`, shouldRoundTrip, `[lang|="en"]`)
+		So(`[href^='http' i]`, shouldRoundTrip, `[href^="http" i]`)
+		So("[xlink|href$=png]", shouldRoundTrip, "[xlink|href$=png]")
+	})
+
+	Convey("combinators and lists", t, func() {
+		So("a  b", shouldRoundTrip, "a b", "a b")
+		So("a>b", shouldRoundTrip, "a > b", "a>b")
+		So("a +b~ c", shouldRoundTrip, "a + b ~ c", "a+b~c")
+		So("> a", shouldRoundTrip, "> a", ">a")
+		So("a,b ,  c", shouldRoundTrip, "a, b, c", "a,b,c")
+		So("a:not(.b, .c > d)", shouldRoundTrip, "a:not(.b, .c > d)", "a:not(.b,.c>d)")
+		So("col || td", shouldRoundTrip, "col || td")
+	})
+
+	Convey("structure", t, func() {
+		list, err := ParseSelector("ul > li.item:first-child a")
+		So(err, ShouldBeNil)
+		So(len(list), ShouldEqual, 1)
+		comps := list[0].Components
+		So(len(comps), ShouldEqual, 3)
+		So(comps[0].Combinator, ShouldEqual, NoCombinator)
+		So(comps[1].Combinator, ShouldEqual, ChildCombinator)
+		So(comps[2].Combinator, ShouldEqual, DescendantCombinator)
+		So(len(comps[1].Compound), ShouldEqual, 3)
+		So(comps[1].Compound[1].Type, ShouldEqual, ClassSelector)
+		So(comps[1].Compound[2].Type, ShouldEqual, PseudoClassSelector)
+
+		list, _ = ParseSelector("a:before, a::after, a:hover")
+		So(list[0].Components[0].Compound[1].IsPseudoElement(), ShouldBeTrue)
+		So(list[1].Components[0].Compound[1].IsPseudoElement(), ShouldBeTrue)
+		So(list[2].Components[0].Compound[1].IsPseudoElement(), ShouldBeFalse)
+	})
+
+	Convey("errors", t, func() {
+		for _, s := range []string{"", "a,", "a >", "a > > b", ".", "#", ".a*", "[=x]", "[a=]", "a:", "a)"} {
+			_, err := ParseSelector(s)
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
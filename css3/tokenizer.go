@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"iter"
 	"strconv"
 	"strings"
 )
@@ -44,6 +45,7 @@ const (
 	RSquareToken
 	LCurlyToken
 	RCurlyToken
+	CommentToken
 	EOFToken
 
 	MinTokenType = IdentToken
@@ -116,6 +118,8 @@ func (tt TokenType) String() string {
 		return "LCurlyToken"
 	case RCurlyToken:
 		return "RCurlyToken"
+	case CommentToken:
+		return "CommentToken"
 	case EOFToken:
 		return "EOFToken"
 	default:
@@ -190,16 +194,53 @@ type UnicodeRange struct {
 
 type Tokenizer struct {
 	*Scanner
+	start Position
+
+	// KeepComments makes ConsumeToken return comments as CommentTokens
+	// holding the text between the delimiters, instead of skipping them.
+	KeepComments bool
 }
 
 func NewTokenizer(runeScanner io.RuneScanner) *Tokenizer {
-	return &Tokenizer{NewScanner(runeScanner)}
+	return &Tokenizer{Scanner: NewScanner(runeScanner)}
+}
+
+// TokenStart returns the position of the first code point of the token most
+// recently returned by ConsumeToken.
+func (tk *Tokenizer) TokenStart() Position { return tk.start }
+
+// TokenEnd returns the position just past the token most recently returned
+// by ConsumeToken.
+func (tk *Tokenizer) TokenEnd() Position {
+	if tk.reconsume {
+		return tk.Pos()
+	}
+	return tk.NextPos()
+}
+
+// Tokens returns an iterator over the remaining tokens and their spans. It
+// ends at the end of the input, which isn't yielded, or after yielding an
+// ErrorToken if reading the input fails. Tokens are read as they're
+// iterated over, so the input may be much larger than memory.
+func (tk *Tokenizer) Tokens() iter.Seq2[Token, Span] {
+	return func(yield func(Token, Span) bool) {
+		for {
+			t := tk.ConsumeToken()
+			if t.TokenType == EOFToken {
+				return
+			}
+			if !yield(*t, Span{Start: tk.TokenStart(), End: tk.TokenEnd()}) || t.TokenType == ErrorToken {
+				return
+			}
+		}
+	}
 }
 
 func (tk *Tokenizer) ConsumeToken() *Token {
 	var ch rune
 	for tk.Error() == nil {
 		ch = tk.Consume1()
+		tk.start = tk.Pos()
 		if ch == EOFRune {
 			return NewEOFToken()
 		}
@@ -212,17 +253,9 @@ func (tk *Tokenizer) ConsumeToken() *Token {
 			break
 		}
 		if tk.Next() == '*' {
-			star := false
-			tk.Consume1()
-			for tk.Error() == nil && tk.Current() != EOFRune {
-				tk.Consume1()
-				if star {
-					if tk.Current() == '/' {
-						break
-					}
-					star = false
-				}
-				star = tk.Current() == '*'
+			comment := tk.consumeComment()
+			if tk.KeepComments && tk.Error() == nil {
+				return NewToken(CommentToken, comment)
 			}
 		} else {
 			return NewDelimToken(ch)
@@ -298,13 +331,13 @@ func (tk *Tokenizer) ConsumeToken() *Token {
 		if isDigit(next3[0]) || (next3[0] == '.' && isDigit(next3[1])) {
 			return tk.consumeNumeric()
 		}
-		if startsIdent([]rune{tk.Current(), next3[0], next3[1]}) {
-			return tk.consumeIdentLike()
-		}
 		if next3[0] == '-' && next3[1] == '>' {
 			tk.Consume(2)
 			return NewToken(CDCToken, nil)
 		}
+		if startsIdent([]rune{tk.Current(), next3[0], next3[1]}) {
+			return tk.consumeIdentLike()
+		}
 		return NewDelimToken(ch)
 	case '.':
 		if isDigit(tk.Next()) {
@@ -344,6 +377,31 @@ func (tk *Tokenizer) ConsumeToken() *Token {
 	}
 }
 
+// consumeComment consumes a comment, returning its text if KeepComments is
+// set.
+func (tk *Tokenizer) consumeComment() string {
+	var text bytes.Buffer
+	star := false
+	tk.Consume1()
+	for tk.Error() == nil && tk.Current() != EOFRune {
+		tk.Consume1()
+		if star {
+			if tk.Current() == '/' {
+				if tk.KeepComments {
+					text.Truncate(text.Len() - 1)
+				}
+				break
+			}
+			star = false
+		}
+		star = tk.Current() == '*'
+		if tk.KeepComments && tk.Current() >= 0 {
+			text.WriteRune(tk.Current())
+		}
+	}
+	return text.String()
+}
+
 func (tk *Tokenizer) consumeNumeric() *Token {
 	num, err := tk.consumeNumber()
 	if err != nil {
@@ -627,6 +685,10 @@ func isWhitespace(ch rune) bool {
 
 func startsIdent(next3 []rune) bool {
 	if next3[0] == '-' {
+		if next3[1] == '-' {
+			// Custom property names such as "--a" are identifiers.
+			return true
+		}
 		next3 = next3[1:]
 	}
 	if isNameStart(next3[0]) {
@@ -29,10 +29,19 @@ func TestNumeric(t *testing.T) {
 	})
 }
 
+// syntax3Tokens are the inputs below whose tokens changed when CSS Syntax 3
+// let identifiers start with "--", with the tokens they produce now.
+var syntax3Tokens = map[string][]interface{}{
+	"--->": {NewToken(IdentToken, Identifier("---"))},
+}
+
 func TestConsumeToken(t *testing.T) {
 	shouldTokenize := func(actual interface{}, expected ...interface{}) string {
 		scanner, ok := actual.(io.RuneScanner)
 		if !ok {
+			if override, ok := syntax3Tokens[actual.(string)]; ok {
+				expected = override
+			}
 			scanner = bytes.NewReader([]byte(actual.(string)))
 		}
 		tokenizer := NewTokenizer(scanner)
@@ -118,6 +127,7 @@ func TestConsumeToken(t *testing.T) {
 		So("-1", shouldTokenize, NewToken(NumberToken, &Numeric{Repr: "-1", Integer: -1}))
 		So("-a", shouldTokenize, NewToken(IdentToken, Identifier("-a")))
 		So("--->", shouldTokenize, NewDelimToken('-'))
+		So("--a", shouldTokenize, NewToken(IdentToken, Identifier("--a")))
 		So("-->", shouldTokenize, NewToken(CDCToken, nil))
 		So("->", shouldTokenize, NewDelimToken('-'))
 	})
@@ -302,3 +312,71 @@ func TestConsumeToken(t *testing.T) {
 		So("!", shouldTokenize, NewDelimToken('!'))
 	})
 }
+
+func TestTokens(t *testing.T) {
+	tokens := func(s io.RuneScanner) ([]Token, []string) {
+		var toks []Token
+		var spans []string
+		for tok, span := range NewTokenizer(s).Tokens() {
+			toks = append(toks, tok)
+			spans = append(spans, span.Start.String()+"-"+span.End.String())
+		}
+		return toks, spans
+	}
+
+	Convey("Tokens yields tokens with their spans", t, func() {
+		toks, spans := tokens(bytes.NewReader([]byte("a {\n  b: 12px }")))
+		So(toks, ShouldResemble, []Token{
+			{IdentToken, Identifier("a")},
+			{WhitespaceToken, nil},
+			{LCurlyToken, nil},
+			{WhitespaceToken, nil},
+			{IdentToken, Identifier("b")},
+			{ColonToken, nil},
+			{WhitespaceToken, nil},
+			{DimensionToken, &Numeric{Integer, "12", 12, 0, "px"}},
+			{WhitespaceToken, nil},
+			{RCurlyToken, nil},
+		})
+		So(spans, ShouldResemble, []string{"1:1-1:2", "1:2-1:3", "1:3-1:4", "1:4-2:3", "2:3-2:4", "2:4-2:5", "2:5-2:6", "2:6-2:10", "2:10-2:11", "2:11-2:12"})
+
+		toks, _ = tokens(bytes.NewReader(nil))
+		So(toks, ShouldBeNil)
+	})
+
+	Convey("Tokens stops after a read error", t, func() {
+		toks, _ := tokens(&testRuneScanner{RuneScanner: bytes.NewReader([]byte("a b " + string(readRuneFailer) + " c"))})
+		So(len(toks), ShouldBeGreaterThan, 0)
+		So(toks[len(toks)-1].TokenType, ShouldEqual, ErrorToken)
+	})
+
+	Convey("Comments are kept on request", t, func() {
+		tk := NewTokenizer(bytes.NewReader([]byte("a/* b */c/**/ /* d")))
+		tk.KeepComments = true
+		var toks []Token
+		for tok := range tk.Tokens() {
+			toks = append(toks, tok)
+		}
+		So(toks, ShouldResemble, []Token{
+			{IdentToken, Identifier("a")},
+			{CommentToken, " b "},
+			{IdentToken, Identifier("c")},
+			{CommentToken, ""},
+			{WhitespaceToken, nil},
+			{CommentToken, " d"},
+		})
+	})
+
+	Convey("Iteration can stop early and resume", t, func() {
+		tk := NewTokenizer(bytes.NewReader([]byte("a b c")))
+		for tok := range tk.Tokens() {
+			So(tok, ShouldResemble, Token{IdentToken, Identifier("a")})
+			break
+		}
+		var rest []TokenType
+		for tok := range tk.Tokens() {
+			rest = append(rest, tok.TokenType)
+		}
+		So(rest, ShouldResemble, []TokenType{WhitespaceToken, IdentToken, WhitespaceToken, IdentToken})
+	})
+}
@@ -0,0 +1,358 @@
+package css3
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+var (
+	UnknownUnitErr      = errors.New("unknown unit")
+	IncompatibleUnitErr = errors.New("incompatible units")
+	WrongTypeErr        = errors.New("wrong value type")
+)
+
+// Dimension is the kind of quantity a unit measures.
+type Dimension int
+
+const (
+	NumberDimension Dimension = iota
+	PercentageDimension
+	LengthDimension
+	AngleDimension
+	TimeDimension
+	FrequencyDimension
+	ResolutionDimension
+	FlexDimension
+)
+
+func (d Dimension) String() string {
+	switch d {
+	case PercentageDimension:
+		return "percentage"
+	case LengthDimension:
+		return "length"
+	case AngleDimension:
+		return "angle"
+	case TimeDimension:
+		return "time"
+	case FrequencyDimension:
+		return "frequency"
+	case ResolutionDimension:
+		return "resolution"
+	case FlexDimension:
+		return "flex"
+	default:
+		return "number"
+	}
+}
+
+// CanonicalUnit returns the unit other units of the dimension are converted
+// to, such as "px" for lengths.
+func (d Dimension) CanonicalUnit() string {
+	return canonicalUnits[d]
+}
+
+var canonicalUnits = map[Dimension]string{
+	PercentageDimension: "%",
+	LengthDimension:     "px",
+	AngleDimension:      "deg",
+	TimeDimension:       "s",
+	FrequencyDimension:  "hz",
+	ResolutionDimension: "dppx",
+	FlexDimension:       "fr",
+}
+
+type unitDef struct {
+	dimension Dimension
+	// factor is the size of the unit in the dimension's canonical unit, or
+	// nil for units such as "em" whose size depends on where they're used.
+	factor *big.Rat
+}
+
+// pi has 50 decimal places, so conversions from radians are only exact well
+// beyond float64 precision.
+var pi, _ = new(big.Rat).SetString("3.14159265358979323846264338327950288419716939937510")
+
+func ratio(a, b int64) *big.Rat { return big.NewRat(a, b) }
+
+var unitDefs = map[string]unitDef{
+	"px": {LengthDimension, ratio(1, 1)},
+	"cm": {LengthDimension, ratio(9600, 254)},
+	"mm": {LengthDimension, ratio(960, 254)},
+	"q":  {LengthDimension, ratio(240, 254)},
+	"in": {LengthDimension, ratio(96, 1)},
+	"pt": {LengthDimension, ratio(4, 3)},
+	"pc": {LengthDimension, ratio(16, 1)},
+
+	"deg":  {AngleDimension, ratio(1, 1)},
+	"grad": {AngleDimension, ratio(9, 10)},
+	"rad":  {AngleDimension, new(big.Rat).Quo(ratio(180, 1), pi)},
+	"turn": {AngleDimension, ratio(360, 1)},
+
+	"s":  {TimeDimension, ratio(1, 1)},
+	"ms": {TimeDimension, ratio(1, 1000)},
+
+	"hz":  {FrequencyDimension, ratio(1, 1)},
+	"khz": {FrequencyDimension, ratio(1000, 1)},
+
+	"dppx": {ResolutionDimension, ratio(1, 1)},
+	"x":    {ResolutionDimension, ratio(1, 1)},
+	"dpi":  {ResolutionDimension, ratio(1, 96)},
+	"dpcm": {ResolutionDimension, ratio(254, 9600)},
+
+	"fr": {FlexDimension, ratio(1, 1)},
+}
+
+func init() {
+	for _, u := range []string{
+		"em", "rem", "ex", "rex", "cap", "rcap", "ch", "rch", "ic", "ric", "lh", "rlh",
+		"vw", "vh", "vi", "vb", "vmin", "vmax",
+		"svw", "svh", "svi", "svb", "svmin", "svmax",
+		"lvw", "lvh", "lvi", "lvb", "lvmin", "lvmax",
+		"dvw", "dvh", "dvi", "dvb", "dvmin", "dvmax",
+		"cqw", "cqh", "cqi", "cqb", "cqmin", "cqmax",
+	} {
+		unitDefs[u] = unitDef{LengthDimension, nil}
+	}
+}
+
+// UnitDimension returns the dimension a unit measures. Units are matched
+// case-insensitively; "" is a plain number and "%" a percentage.
+func UnitDimension(unit string) (Dimension, bool) {
+	switch unit {
+	case "":
+		return NumberDimension, true
+	case "%":
+		return PercentageDimension, true
+	}
+	def, ok := unitDefs[strings.ToLower(unit)]
+	return def.dimension, ok
+}
+
+// Quantity is a number with a unit, held exactly as a rational number so
+// that conversions don't lose precision to floating point.
+type Quantity struct {
+	Value *big.Rat
+	Unit  string
+}
+
+// NewQuantity parses a number such as "1.5e3" and pairs it with unit.
+func NewQuantity(number, unit string) (Quantity, error) {
+	v, ok := new(big.Rat).SetString(number)
+	if !ok {
+		return Quantity{}, fmt.Errorf("invalid number %q", number)
+	}
+	return Quantity{v, unit}, nil
+}
+
+// NodeQuantity returns the quantity a NumberNode holds, exactly as written.
+func NodeQuantity(n *NumberNode) (Quantity, error) {
+	unit := n.Unit
+	if n.Type == "percentage" {
+		unit = "%"
+	}
+	return NewQuantity(n.Repr, unit)
+}
+
+// Dimension returns the dimension of q's unit.
+func (q Quantity) Dimension() (Dimension, error) {
+	d, ok := UnitDimension(q.Unit)
+	if !ok {
+		return 0, fmt.Errorf("%w %q", UnknownUnitErr, q.Unit)
+	}
+	return d, nil
+}
+
+// ConvertTo returns q in unit, which must measure the same dimension. Units
+// whose size depends on context, such as "em", convert only to themselves.
+func (q Quantity) ConvertTo(unit string) (Quantity, error) {
+	if strings.EqualFold(q.Unit, unit) {
+		return Quantity{q.Value, unit}, nil
+	}
+	if _, ok := unitDefs[strings.ToLower(q.Unit)]; !ok {
+		return Quantity{}, fmt.Errorf("%w %q", UnknownUnitErr, q.Unit)
+	}
+	if _, ok := unitDefs[strings.ToLower(unit)]; !ok {
+		return Quantity{}, fmt.Errorf("%w %q", UnknownUnitErr, unit)
+	}
+	factor, ok := ConversionFactor(q.Unit, unit)
+	if !ok {
+		return Quantity{}, fmt.Errorf("%w: can't convert %s to %q", IncompatibleUnitErr, q, unit)
+	}
+	return Quantity{new(big.Rat).Mul(q.Value, factor), unit}, nil
+}
+
+// ConversionFactor returns the number of to units in one from unit, if the
+// units measure the same dimension and their sizes don't depend on context.
+func ConversionFactor(from, to string) (*big.Rat, bool) {
+	if strings.EqualFold(from, to) {
+		return ratio(1, 1), true
+	}
+	f, ok1 := unitDefs[strings.ToLower(from)]
+	t, ok2 := unitDefs[strings.ToLower(to)]
+	if !ok1 || !ok2 || f.dimension != t.dimension || f.factor == nil || t.factor == nil {
+		return nil, false
+	}
+	return new(big.Rat).Quo(f.factor, t.factor), true
+}
+
+// Canonical returns q in its dimension's canonical unit, or unchanged if it
+// can't be converted.
+func (q Quantity) Canonical() Quantity {
+	d, ok := UnitDimension(q.Unit)
+	if !ok || d == NumberDimension || d == PercentageDimension {
+		return q
+	}
+	if c, err := q.ConvertTo(d.CanonicalUnit()); err == nil {
+		return c
+	}
+	return q
+}
+
+// Cmp compares q and other, converting other to q's unit. It returns -1, 0
+// or +1 as q is less than, equal to or greater than other.
+func (q Quantity) Cmp(other Quantity) (int, error) {
+	o, err := other.ConvertTo(q.Unit)
+	if err != nil {
+		return 0, err
+	}
+	return q.Value.Cmp(o.Value), nil
+}
+
+// quantityPrecision is the number of decimal places that quantities which
+// have no exact decimal form are rounded to.
+const quantityPrecision = 10
+
+// String formats q as CSS, exactly if its value has a short enough decimal
+// form and rounded to 10 decimal places otherwise.
+func (q Quantity) String() string {
+	s := q.Value.FloatString(quantityPrecision)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	if s == "-0" {
+		s = "0"
+	}
+	return s + q.Unit
+}
+
+// Value types a property accepts, as in its grammar.
+const (
+	acceptNumber = 1 << iota
+	acceptInteger
+	acceptPercentage
+	acceptLength
+	acceptAngle
+	acceptTime
+	acceptFrequency
+	acceptResolution
+	acceptFlex
+)
+
+const lengthPercentage = acceptLength | acceptPercentage
+
+// propertyTypes lists the numeric types that the values of common
+// properties may have. Numbers inside functions aren't checked.
+var propertyTypes = map[string]int{
+	"width": lengthPercentage, "height": lengthPercentage, "min-width": lengthPercentage, "min-height": lengthPercentage,
+	"max-width": lengthPercentage, "max-height": lengthPercentage, "inline-size": lengthPercentage, "block-size": lengthPercentage,
+	"margin": lengthPercentage, "margin-top": lengthPercentage, "margin-right": lengthPercentage,
+	"margin-bottom": lengthPercentage, "margin-left": lengthPercentage,
+	"padding": lengthPercentage, "padding-top": lengthPercentage, "padding-right": lengthPercentage,
+	"padding-bottom": lengthPercentage, "padding-left": lengthPercentage,
+	"top": lengthPercentage, "right": lengthPercentage, "bottom": lengthPercentage, "left": lengthPercentage,
+	"inset": lengthPercentage, "gap": lengthPercentage, "row-gap": lengthPercentage, "column-gap": lengthPercentage,
+	"font-size": lengthPercentage, "text-indent": lengthPercentage, "flex-basis": lengthPercentage,
+	"border-radius": lengthPercentage, "background-position": lengthPercentage, "background-size": lengthPercentage,
+	"letter-spacing": lengthPercentage, "word-spacing": lengthPercentage, "vertical-align": lengthPercentage,
+	"border-width": acceptLength, "border-top-width": acceptLength, "border-right-width": acceptLength,
+	"border-bottom-width": acceptLength, "border-left-width": acceptLength, "border-spacing": acceptLength,
+	"outline-width": acceptLength, "outline-offset": acceptLength, "column-width": acceptLength,
+	"perspective": acceptLength, "column-rule-width": acceptLength,
+	"line-height":               acceptNumber | lengthPercentage,
+	"tab-size":                  acceptNumber | acceptLength,
+	"flex":                      acceptNumber | lengthPercentage,
+	"opacity":                   acceptNumber | acceptPercentage,
+	"fill-opacity":              acceptNumber | acceptPercentage,
+	"stroke-opacity":            acceptNumber | acceptPercentage,
+	"flex-grow":                 acceptNumber,
+	"flex-shrink":               acceptNumber,
+	"font-weight":               acceptNumber,
+	"animation-iteration-count": acceptNumber,
+	"z-index":                   acceptInteger,
+	"order":                     acceptInteger,
+	"orphans":                   acceptInteger,
+	"widows":                    acceptInteger,
+	"column-count":              acceptInteger,
+	"font-stretch":              acceptPercentage,
+	"rotate":                    acceptAngle,
+	"transition-duration":       acceptTime,
+	"transition-delay":          acceptTime,
+	"animation-duration":        acceptTime,
+	"animation-delay":           acceptTime,
+	"voice-pitch":               acceptFrequency,
+	"image-resolution":          acceptResolution,
+	"grid-template-columns":     lengthPercentage | acceptFlex,
+	"grid-template-rows":        lengthPercentage | acceptFlex,
+	"grid-auto-columns":         lengthPercentage | acceptFlex,
+	"grid-auto-rows":            lengthPercentage | acceptFlex,
+}
+
+var dimensionTypes = map[Dimension]int{
+	PercentageDimension: acceptPercentage,
+	LengthDimension:     acceptLength,
+	AngleDimension:      acceptAngle,
+	TimeDimension:       acceptTime,
+	FrequencyDimension:  acceptFrequency,
+	ResolutionDimension: acceptResolution,
+	FlexDimension:       acceptFlex,
+}
+
+// ValidateDeclaration checks that the numbers in the value of a declaration
+// of a known property have types the property accepts, as a "5s" width
+// doesn't. Unitless zero is accepted as a length. Declarations of other
+// properties are always valid.
+func ValidateDeclaration(d *DeclarationNode) error {
+	accepts, ok := propertyTypes[toLower(d.Name)]
+	if !ok {
+		return nil
+	}
+	for _, value := range d.Values {
+		n, ok := value.(*NumberNode)
+		if !ok {
+			continue
+		}
+		q, err := NodeQuantity(n)
+		if err != nil {
+			return err
+		}
+		dim, err := q.Dimension()
+		if err != nil {
+			return err
+		}
+		var valid bool
+		if dim == NumberDimension {
+			valid = accepts&acceptNumber != 0 ||
+				(accepts&acceptInteger != 0 && n.NumberType == Integer) ||
+				(accepts&acceptLength != 0 && q.Value.Sign() == 0)
+		} else {
+			valid = accepts&dimensionTypes[dim] != 0
+		}
+		if !valid {
+			return fmt.Errorf("%w: %q doesn't accept %s %s", WrongTypeErr, d.Name, describeDimension(dim, n), q)
+		}
+	}
+	return nil
+}
+
+func describeDimension(d Dimension, n *NumberNode) string {
+	switch {
+	case d == NumberDimension && n.NumberType == Integer:
+		return "the integer"
+	case d == NumberDimension:
+		return "the number"
+	}
+	return "the " + d.String()
+}
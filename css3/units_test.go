@@ -0,0 +1,96 @@
+package css3
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUnits(t *testing.T) {
+	quantity := func(s string) Quantity {
+		n := testParser(s).ParseListOfComponentValues()[0].(*NumberNode)
+		q, err := NodeQuantity(n)
+		So(err, ShouldBeNil)
+		return q
+	}
+	convert := func(s, unit string) string {
+		q, err := quantity(s).ConvertTo(unit)
+		So(err, ShouldBeNil)
+		return q.String()
+	}
+
+	Convey("Units are classified into dimensions", t, func() {
+		for unit, dim := range map[string]Dimension{
+			"": NumberDimension, "%": PercentageDimension, "px": LengthDimension, "EM": LengthDimension,
+			"cqmin": LengthDimension, "turn": AngleDimension, "ms": TimeDimension, "kHz": FrequencyDimension,
+			"dpcm": ResolutionDimension, "x": ResolutionDimension, "fr": FlexDimension,
+		} {
+			d, ok := UnitDimension(unit)
+			So(ok, ShouldBeTrue)
+			So(d, ShouldEqual, dim)
+		}
+		_, ok := UnitDimension("furlongs")
+		So(ok, ShouldBeFalse)
+		So(AngleDimension.CanonicalUnit(), ShouldEqual, "deg")
+		So(quantity("50%").Unit, ShouldEqual, "%")
+	})
+
+	Convey("Conversions are exact", t, func() {
+		So(convert("1in", "cm"), ShouldEqual, "2.54cm")
+		So(convert("2.54cm", "in"), ShouldEqual, "1in")
+		So(convert("1cm", "mm"), ShouldEqual, "10mm")
+		So(convert("40Q", "mm"), ShouldEqual, "10mm")
+		So(convert("72pt", "px"), ShouldEqual, "96px")
+		So(convert("0.1s", "ms"), ShouldEqual, "100ms")
+		So(convert("1turn", "grad"), ShouldEqual, "400grad")
+		So(convert("96dpi", "dppx"), ShouldEqual, "1dppx")
+		So(convert("1PX", "px"), ShouldEqual, "1px")
+		So(convert("1cm", "px"), ShouldEqual, "37.7952755906px")
+		So(convert("3.14159265358979323846rad", "deg"), ShouldEqual, "180deg")
+		So(quantity("1e3ms").Canonical().String(), ShouldEqual, "1s")
+		So(quantity("2em").Canonical().String(), ShouldEqual, "2em")
+		f, ok := ConversionFactor("IN", "pt")
+		So(ok, ShouldBeTrue)
+		So(f.String(), ShouldEqual, "72/1")
+		_, ok = ConversionFactor("em", "px")
+		So(ok, ShouldBeFalse)
+	})
+
+	Convey("Incompatible units don't convert", t, func() {
+		_, err := quantity("1px").ConvertTo("s")
+		So(errors.Is(err, IncompatibleUnitErr), ShouldBeTrue)
+		_, err = quantity("1em").ConvertTo("px")
+		So(errors.Is(err, IncompatibleUnitErr), ShouldBeTrue)
+		_, err = quantity("1px").ConvertTo("foo")
+		So(errors.Is(err, UnknownUnitErr), ShouldBeTrue)
+		c, err := quantity("1in").Cmp(quantity("95px"))
+		So(err, ShouldBeNil)
+		So(c, ShouldEqual, 1)
+	})
+
+	Convey("Declarations are checked against the types their property accepts", t, func() {
+		validate := func(s string) error {
+			return ValidateDeclaration(testParser(s).ParseDeclaration().(*DeclarationNode))
+		}
+		for _, s := range []string{
+			"width: 10px", "width: 50%", "margin: 0 auto", "line-height: 1.5", "z-index: 3",
+			"transition-duration: 200ms", "grid-template-columns: 1fr 200px", "width: calc(1s)",
+			"rotate: 45deg", "unknown: 5s", "opacity: 50%",
+		} {
+			So(validate(s), ShouldBeNil)
+		}
+		for s, msg := range map[string]string{
+			"width: 5s":            `wrong value type: "width" doesn't accept the time 5s`,
+			"z-index: 1.5":         `wrong value type: "z-index" doesn't accept the number 1.5`,
+			"border-width: 10%":    `wrong value type: "border-width" doesn't accept the percentage 10%`,
+			"height: 10":           `wrong value type: "height" doesn't accept the integer 10`,
+			"animation-delay: 1px": `wrong value type: "animation-delay" doesn't accept the length 1px`,
+			"font-size: 12furlong": `unknown unit "furlong"`,
+		} {
+			err := validate(s)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, msg)
+		}
+	})
+}
@@ -0,0 +1,63 @@
+package scss
+
+import (
+	"fmt"
+
+	"github.com/logan/scss/css3"
+)
+
+type Location struct {
+	File string
+	css3.Position
+}
+
+func (l Location) String() string {
+	if l.Line == 0 {
+		return l.File
+	}
+	return fmt.Sprintf("%s:%d:%d", l.File, l.Line, l.Column)
+}
+
+// Error is returned for any problem that stops compilation.
+type Error struct {
+	Message string
+	Location
+}
+
+func (e *Error) Error() string {
+	return e.Location.String() + ": " + e.Message
+}
+
+type Warning struct {
+	Message string
+	Location
+
+	// Trace is the stack of mixins and functions the warning was issued
+	// from, innermost first.
+	Trace []Frame
+
+	// Deprecation is set for warnings about deprecated features, rather
+	// than those of @warn rules.
+	Deprecation bool
+}
+
+func (w *Warning) String() string {
+	return w.Location.String() + ": " + w.Message
+}
+
+func errorf(loc Location, format string, args ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, args...), Location: loc}
+}
+
+// recoverError converts an *Error panic into a returned error. The parser
+// and evaluator report errors by panicking so that deeply recursive code
+// doesn't have to thread errors through every call.
+func recoverError(err *error) {
+	if r := recover(); r != nil {
+		if e, ok := r.(*Error); ok {
+			*err = e
+			return
+		}
+		panic(r)
+	}
+}
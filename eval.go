@@ -0,0 +1,745 @@
+package scss
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/logan/scss/css3"
+)
+
+// evalState is the context a statement is evaluated in. Statements that
+// change it restore it when they finish.
+type evalState struct {
+	env *environment
+
+	// parent is the node that style rules and at-rules are added to, and
+	// styleRule is the node declarations are added to. Since nested rules
+	// are output as siblings, parent is never a style rule.
+	parent    *cssNode
+	styleRule *cssNode
+
+	// selector is the selector "&" refers to. Under @at-root it remains set
+	// while atRoot suppresses implicit nesting.
+	selector css3.SelectorList
+	atRoot   bool
+
+	media       []string
+	inKeyframes bool
+	declPrefix  string
+	content     *callable
+	inFunction  bool
+	inMixin     bool
+	stack       []stackFrame
+}
+
+type evaluator struct {
+	evalState
+
+	opts       *Options
+	fs         http.FileSystem
+	compressed bool
+	functions  map[string]*callable
+	logger     Logger
+
+	root    *cssNode
+	imports []*cssNode
+	extends []*extension
+
+	sheets       map[string]*stylesheet
+	modules      map[string]*module
+	loading      map[string]bool
+	importing    map[string]bool
+	fromImporter map[string]bool
+	loadedFiles  []string
+	warnings     []*Warning
+}
+
+func newEvaluator(fs http.FileSystem, opts *Options) *evaluator {
+	e := &evaluator{
+		opts:         opts,
+		fs:           fs,
+		compressed:   opts.OutputStyle == Compressed,
+		functions:    make(map[string]*callable),
+		root:         &cssNode{kind: rootNode},
+		sheets:       make(map[string]*stylesheet),
+		modules:      make(map[string]*module),
+		loading:      make(map[string]bool),
+		importing:    make(map[string]bool),
+		fromImporter: make(map[string]bool),
+		logger:       opts.Logger,
+	}
+	if e.logger == nil {
+		e.logger = defaultLogger
+	}
+	for sig, fn := range opts.Functions {
+		c := newFunction(sig, fn)
+		e.functions[c.name] = c
+	}
+	return e
+}
+
+// run evaluates the entry stylesheet and resolves @extend.
+func (e *evaluator) run(f *loadedFile) {
+	sheet := e.parseFile(f)
+	m := newModule(f.name)
+	e.modules[f.name] = m
+	e.loading[f.name] = true
+	e.evalState = evalState{env: newEnvironment(m, nil), parent: e.root}
+	e.execStmts(sheet.stmts)
+	e.applyExtends()
+}
+
+// warn issues a warning about a deprecated feature used at loc.
+func (e *evaluator) warn(loc Location, format string, args ...interface{}) {
+	e.logWarning(&Warning{Message: fmt.Sprintf(format, args...), Location: loc, Deprecation: true})
+}
+
+func (e *evaluator) logWarning(w *Warning) {
+	w.Trace = e.trace(w.Location)
+	e.warnings = append(e.warnings, w)
+	e.logger.Warn(w)
+}
+
+// message returns the text a @debug or @warn rule logs for v: the text of a
+// string, or the inspected form of any other value.
+func message(v Value) string {
+	if s, ok := v.(*String); ok {
+		return s.Text
+	}
+	return v.String()
+}
+
+// serialize returns the CSS form of v, failing at loc if v can't be
+// represented in CSS.
+func (e *evaluator) serialize(v Value, loc Location) string {
+	s, err := toCSS(v, e.compressed)
+	if err != nil {
+		panic(errorf(loc, "%s", err.Error()))
+	}
+	return s
+}
+
+// endGroup marks the end of the output of a statement outside of any style
+// rule, which the serializer separates from what follows.
+func (e *evaluator) endGroup() {
+	if e.styleRule == nil && len(e.parent.children) > 0 {
+		e.parent.children[len(e.parent.children)-1].groupEnd = true
+	}
+}
+
+func (e *evaluator) execStmts(stmts []stmt) Value {
+	for _, s := range stmts {
+		if v := e.exec(s); v != nil {
+			return v
+		}
+	}
+	return nil
+}
+
+// execBlock executes stmts in a new scope.
+func (e *evaluator) execBlock(stmts []stmt) Value {
+	env := e.env
+	e.env = env.withScope(newScope(env.scope))
+	v := e.execStmts(stmts)
+	e.env = env
+	return v
+}
+
+// execFlow executes the body of a control flow rule.
+func (e *evaluator) execFlow(stmts []stmt, vars map[string]Value) Value {
+	env := e.env
+	e.env = env.withScope(newFlowScope(env.scope))
+	for name, v := range vars {
+		e.env.scope.vars[name] = v
+	}
+	v := e.execStmts(stmts)
+	e.env = env
+	return v
+}
+
+func (e *evaluator) exec(s stmt) Value {
+	if e.inFunction {
+		switch s.(type) {
+		case *varDecl, *ifStmt, *eachStmt, *forStmt, *whileStmt, *returnStmt, *functionDecl, *mixinDecl,
+			*debugStmt, *warnStmt, *errorStmt:
+		case *commentStmt:
+			return nil
+		default:
+			panic(errorf(s.location(), "This at-rule is not allowed here."))
+		}
+	}
+	switch s := s.(type) {
+	case *styleRule:
+		e.execStyleRule(s)
+	case *declaration:
+		e.execDeclaration(s)
+	case *varDecl:
+		e.setVariable(s, e.eval(s.value))
+	case *atRule:
+		e.execAtRule(s)
+	case *commentStmt:
+		e.execComment(s)
+	case *mediaRule:
+		e.execMedia(s)
+	case *supportsRule:
+		e.execBubblingRule(&cssNode{kind: atRuleNode, name: "supports", value: e.evalInterp(s.condition), hasBody: true, loc: s.loc}, s.children)
+	case *mixinDecl:
+		e.env.scope.mixins[s.name] = &callable{name: s.name, params: s.params, body: s.body, env: e.env}
+	case *functionDecl:
+		e.env.scope.funcs[s.name] = &callable{name: s.name, params: s.params, body: s.body, env: e.env}
+	case *includeStmt:
+		c := e.lookupMixin(s.namespace, s.name, s.loc)
+		args := e.evalArgs(s.args)
+		var content *callable
+		if s.content != nil {
+			content = &callable{name: s.content.name, params: s.content.params, body: s.content.body, env: e.env, content: e.content}
+		}
+		e.includeMixin(c, args, content, s.loc)
+	case *contentStmt:
+		e.execContent(s)
+	case *returnStmt:
+		if !e.inFunction {
+			panic(errorf(s.loc, "This at-rule is not allowed here."))
+		}
+		return e.eval(s.value)
+	case *debugStmt:
+		e.logger.Debug(s.loc, message(e.eval(s.value)))
+	case *warnStmt:
+		e.logWarning(&Warning{Message: message(e.eval(s.value)), Location: s.loc})
+	case *errorStmt:
+		panic(errorf(s.loc, "%s", e.eval(s.value)))
+	case *ifStmt:
+		for _, clause := range s.clauses {
+			if e.eval(clause.cond).Truthy() {
+				return e.execFlow(clause.body, nil)
+			}
+		}
+		if s.hasElse {
+			return e.execFlow(s.elseBody, nil)
+		}
+	case *eachStmt:
+		return e.execEach(s)
+	case *forStmt:
+		return e.execFor(s)
+	case *whileStmt:
+		for e.eval(s.cond).Truthy() {
+			if v := e.execFlow(s.body, nil); v != nil {
+				return v
+			}
+		}
+	case *extendStmt:
+		e.execExtend(s)
+	case *importStmt:
+		e.execImport(s)
+	case *useStmt:
+		e.execUse(s)
+	case *forwardStmt:
+		e.execForward(s)
+	case *atRootStmt:
+		e.execAtRoot(s)
+	default:
+		panic(errorf(s.location(), "unsupported statement %T", s))
+	}
+	return nil
+}
+
+func (e *evaluator) execStyleRule(s *styleRule) {
+	text := e.evalInterp(s.selector)
+	saved := e.evalState
+	defer func() {
+		e.evalState = saved
+		e.endGroup()
+	}()
+	if e.inKeyframes {
+		e.styleRule = e.parent.add(&cssNode{kind: ruleNode, value: text, loc: s.loc})
+		e.execBlock(s.children)
+		return
+	}
+	sel, err := css3.ParseSelector(text)
+	if err != nil {
+		panic(errorf(s.loc, "Invalid selector \"%s\": %s.", text, err))
+	}
+	sel = e.resolveParent(sel, s.loc)
+	e.styleRule = e.parent.add(&cssNode{kind: ruleNode, selector: sel, media: e.media, loc: s.loc})
+	e.selector = sel
+	e.atRoot = false
+	e.declPrefix = ""
+	e.execBlock(s.children)
+}
+
+func (e *evaluator) execDeclaration(s *declaration) {
+	if e.styleRule == nil {
+		panic(errorf(s.loc, "Declarations may only be used within style rules."))
+	}
+	name := e.declPrefix + e.evalInterp(s.name)
+	if s.custom {
+		value := e.evalInterp(s.value.(*stringExpr).text)
+		e.styleRule.add(&cssNode{kind: declNode, name: name, value: value, loc: s.loc})
+		return
+	}
+	if s.value != nil {
+		if v := e.eval(s.value); !isBlank(v) {
+			e.styleRule.add(&cssNode{kind: declNode, name: name, value: e.serialize(v, s.loc), loc: s.loc})
+		}
+	}
+	if s.children != nil {
+		prefix := e.declPrefix
+		e.declPrefix = name + "-"
+		e.execBlock(s.children)
+		e.declPrefix = prefix
+	}
+}
+
+// isBlank reports whether a declaration with value v should be omitted.
+func isBlank(v Value) bool {
+	switch v := v.(type) {
+	case nullValue:
+		return true
+	case *String:
+		return !v.Quoted && v.Text == ""
+	case *List:
+		if v.Bracketed {
+			return false
+		}
+		for _, item := range v.Items {
+			if !isBlank(item) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// execComment copies a comment to the output. Compressed output keeps only
+// comments starting with "/*!".
+func (e *evaluator) execComment(s *commentStmt) {
+	text := e.evalInterp(s.text)
+	if e.compressed && !strings.HasPrefix(text, "/*!") {
+		return
+	}
+	node := &cssNode{kind: commentNode, value: text, loc: s.loc}
+	if e.styleRule != nil {
+		e.styleRule.add(node)
+	} else {
+		e.parent.add(node)
+	}
+}
+
+func (e *evaluator) execAtRule(s *atRule) {
+	node := &cssNode{kind: atRuleNode, name: s.name, value: e.evalInterp(s.prelude), hasBody: s.hasBody, loc: s.loc}
+	if !s.hasBody {
+		if e.styleRule != nil {
+			e.styleRule.add(node)
+		} else {
+			e.parent.add(node)
+		}
+		return
+	}
+	if strings.HasSuffix(s.name, "keyframes") {
+		saved := e.evalState
+		defer func() {
+			e.evalState = saved
+			e.endGroup()
+		}()
+		e.parent = e.parent.add(node)
+		e.styleRule = nil
+		e.inKeyframes = true
+		e.execBlock(s.children)
+		return
+	}
+	e.execBubblingRule(node, s.children)
+}
+
+// execBubblingRule adds an at-rule with a body. Within a style rule the
+// at-rule is moved out of it, with a copy of the rule inside the at-rule to
+// hold any declarations.
+func (e *evaluator) execBubblingRule(node *cssNode, children []stmt) {
+	saved := e.evalState
+	defer func() {
+		e.evalState = saved
+		e.endGroup()
+	}()
+	e.parent = e.parent.add(node)
+	if e.styleRule != nil && e.styleRule.kind == ruleNode {
+		e.styleRule = node.add(e.styleRule.copyRule())
+	} else {
+		e.styleRule = node
+	}
+	e.execBlock(children)
+}
+
+func (e *evaluator) execMedia(s *mediaRule) {
+	queries := splitQueries(e.evalInterp(s.query))
+	if e.media != nil {
+		queries = mergeMediaQueries(e.media, queries)
+		if len(queries) == 0 {
+			return
+		}
+	}
+	saved := e.evalState
+	defer func() {
+		e.evalState = saved
+		e.endGroup()
+	}()
+	// Nested media rules are merged with the enclosing ones, so they're
+	// output alongside them rather than inside them.
+	container := e.parent
+	for container.kind == mediaNode {
+		container = container.parent
+	}
+	node := container.add(&cssNode{kind: mediaNode, value: strings.Join(queries, ", "), loc: s.loc})
+	e.parent = node
+	e.media = queries
+	if e.styleRule != nil && e.styleRule.kind == ruleNode {
+		rule := e.styleRule.copyRule()
+		rule.media = queries
+		e.styleRule = node.add(rule)
+	} else {
+		e.styleRule = nil
+	}
+	e.execBlock(s.children)
+}
+
+// splitQueries splits a media query list at its top-level commas.
+func splitQueries(list string) []string {
+	var queries []string
+	depth, start := 0, 0
+	for i := 0; i <= len(list); i++ {
+		if i < len(list) {
+			switch list[i] {
+			case '(':
+				depth++
+			case ')':
+				depth--
+			}
+			if list[i] != ',' || depth > 0 {
+				continue
+			}
+		}
+		if q := strings.TrimSpace(list[start:i]); q != "" {
+			queries = append(queries, q)
+		}
+		start = i + 1
+	}
+	return queries
+}
+
+// mergeMediaQueries returns the queries matching both an outer and an inner
+// query. Pairs of queries that can't both match are dropped.
+func mergeMediaQueries(outer, inner []string) []string {
+	var merged []string
+	for _, o := range outer {
+		for _, i := range inner {
+			if q, ok := mergeMediaQuery(o, i); ok {
+				merged = append(merged, q)
+			}
+		}
+	}
+	return merged
+}
+
+func mergeMediaQuery(outer, inner string) (string, bool) {
+	otype, ofeatures := splitMediaQuery(outer)
+	itype, ifeatures := splitMediaQuery(inner)
+	mediaType := otype
+	switch {
+	case otype == "" || strings.EqualFold(otype, "all"):
+		mediaType = itype
+	case itype == "" || strings.EqualFold(itype, "all") || strings.EqualFold(otype, itype):
+	default:
+		return "", false
+	}
+	parts := append(append([]string(nil), ofeatures...), ifeatures...)
+	if mediaType != "" {
+		parts = append([]string{mediaType}, parts...)
+	}
+	return strings.Join(parts, " and "), true
+}
+
+func splitMediaQuery(q string) (string, []string) {
+	parts := strings.Split(q, " and ")
+	if strings.HasPrefix(parts[0], "(") {
+		return "", parts
+	}
+	return parts[0], parts[1:]
+}
+
+func (e *evaluator) execEach(s *eachStmt) Value {
+	for _, item := range listItems(e.eval(s.list)) {
+		vars := make(map[string]Value)
+		if len(s.vars) == 1 {
+			vars[s.vars[0]] = item
+		} else {
+			values := listItems(item)
+			for i, name := range s.vars {
+				vars[name] = Null
+				if i < len(values) {
+					vars[name] = values[i]
+				}
+			}
+		}
+		if v := e.execFlow(s.body, vars); v != nil {
+			return v
+		}
+	}
+	return nil
+}
+
+func (e *evaluator) execFor(s *forStmt) Value {
+	from := e.evalNumber(s.from)
+	to := e.evalNumber(s.to)
+	to, err := to.ConvertTo(from.Numerators, from.Denominators)
+	if err != nil {
+		panic(errorf(s.to.location(), "%s", err.Error()))
+	}
+	start, ok := from.Int()
+	if !ok {
+		panic(errorf(s.from.location(), "%s is not an int.", from))
+	}
+	end, ok := to.Int()
+	if !ok {
+		panic(errorf(s.to.location(), "%s is not an int.", to))
+	}
+	step := 1
+	if start > end {
+		step = -1
+	}
+	if !s.inclusive {
+		end -= step
+	}
+	for i := start; i*step <= end*step; i += step {
+		n := &Number{Value: float64(i), Numerators: from.Numerators, Denominators: from.Denominators}
+		if v := e.execFlow(s.body, map[string]Value{s.variable: n}); v != nil {
+			return v
+		}
+	}
+	return nil
+}
+
+func (e *evaluator) evalNumber(x expr) *Number {
+	v := e.eval(x)
+	n, ok := v.(*Number)
+	if !ok {
+		panic(errorf(x.location(), "%s is not a number.", v))
+	}
+	return n
+}
+
+func (e *evaluator) execImport(s *importStmt) {
+	for _, arg := range s.imports {
+		if arg.isPlain {
+			text := e.evalInterp(arg.plain)
+			if mods := e.evalInterp(arg.modifiers); mods != "" {
+				text += " " + mods
+			}
+			node := &cssNode{kind: importNode, value: text, loc: arg.loc}
+			if e.parent == e.root {
+				e.imports = append(e.imports, node)
+			} else {
+				e.parent.add(node)
+			}
+			continue
+		}
+		f := e.resolve(arg.url, arg.loc.File, arg.loc)
+		if e.importing[f.name] || e.loading[f.name] {
+			panic(errorf(arg.loc, "This file is already being loaded."))
+		}
+		sheet := e.parseFile(f)
+		e.importing[f.name] = true
+		e.execStmts(sheet.stmts)
+		delete(e.importing, f.name)
+	}
+}
+
+func (e *evaluator) execAtRoot(s *atRootStmt) {
+	saved := e.evalState
+	defer func() {
+		e.evalState = saved
+		e.endGroup()
+	}()
+	excludes := func(name string) bool {
+		switch {
+		case s.with != nil:
+			return !s.with[name] && !s.with["all"]
+		case s.without != nil:
+			return s.without[name] || s.without["all"]
+		}
+		return name == "rule"
+	}
+	if excludes("media") {
+		for e.parent.kind == mediaNode {
+			e.parent = e.parent.parent
+		}
+		e.media = nil
+	}
+	if excludes("rule") {
+		e.styleRule = nil
+		e.atRoot = true
+	} else if e.styleRule != nil && e.styleRule.parent != e.parent {
+		e.styleRule = e.parent.add(e.styleRule.copyRule())
+	}
+	if s.selector != nil {
+		e.execStyleRule(&styleRule{node: s.node, selector: *s.selector, children: s.children})
+		return
+	}
+	e.execBlock(s.children)
+}
+
+func (e *evaluator) eval(x expr) Value {
+	switch x := x.(type) {
+	case *literalExpr:
+		return x.value
+	case *stringExpr:
+		return &String{Text: e.evalInterp(x.text), Quoted: x.quoted}
+	case *variableExpr:
+		return e.lookupVariable(x.namespace, x.name, x.loc)
+	case *funcCallExpr:
+		c := e.lookupFunction(x.namespace, x.name, x.loc)
+		if c == nil {
+			return e.plainFunction(x)
+		}
+		return e.callFunction(c, e.evalArgs(x.args), x.loc)
+	case *ifExpr:
+		return e.evalIf(x)
+	case *binaryExpr:
+		return e.evalBinary(x)
+	case *unaryExpr:
+		v, err := unaryOperation(x.op, e.eval(x.operand))
+		if err != nil {
+			panic(errorf(x.loc, "%s", err.Error()))
+		}
+		return v
+	case *listExpr:
+		items := make([]Value, len(x.items))
+		for i, item := range x.items {
+			items[i] = e.eval(item)
+		}
+		return &List{Items: items, Separator: x.separator, Bracketed: x.bracketed}
+	case *mapExpr:
+		m := NewMap()
+		for i, key := range x.keys {
+			k := e.eval(key)
+			if _, dup := m.Get(k); dup {
+				panic(errorf(key.location(), "Duplicate key."))
+			}
+			m.Set(k, e.eval(x.values[i]))
+		}
+		return m
+	case *parenExpr:
+		v := e.eval(x.inner)
+		if n, ok := v.(*Number); ok {
+			return n.withoutSlash()
+		}
+		return v
+	case *selectorExpr:
+		return selectorValue(e.selector)
+	case *calcExpr:
+		return e.evalCalculation(x)
+	}
+	panic(errorf(x.location(), "unsupported expression %T", x))
+}
+
+func (e *evaluator) evalIf(x *ifExpr) Value {
+	args := x.args
+	params := []string{"condition", "if-true", "if-false"}
+	exprs := make([]expr, len(params))
+	for i, name := range params {
+		if i < len(args.positional) {
+			exprs[i] = args.positional[i]
+		} else if named, ok := args.named[name]; ok {
+			exprs[i] = named
+		} else {
+			panic(errorf(x.loc, "Missing argument $%s.", name))
+		}
+	}
+	if len(args.positional) > len(params) {
+		panic(errorf(x.loc, "Only 3 arguments allowed, but %d were passed.", len(args.positional)))
+	}
+	if e.eval(exprs[0]).Truthy() {
+		return e.eval(exprs[1])
+	}
+	return e.eval(exprs[2])
+}
+
+func (e *evaluator) evalBinary(x *binaryExpr) Value {
+	switch x.op {
+	case "and":
+		if left := e.eval(x.left); !left.Truthy() {
+			return left
+		}
+		return e.eval(x.right)
+	case "or":
+		if left := e.eval(x.left); left.Truthy() {
+			return left
+		}
+		return e.eval(x.right)
+	}
+	left, right := e.eval(x.left), e.eval(x.right)
+	ln, lok := left.(*Number)
+	rn, rok := right.(*Number)
+	if x.op == "/" && lok && rok {
+		v, err := numberOperation("/", ln.withoutSlash(), rn.withoutSlash())
+		if err != nil {
+			panic(errorf(x.loc, "%s", err.Error()))
+		}
+		if x.allowsSlash {
+			n := v.(*Number)
+			n.slash = &[2]*Number{ln, rn}
+			return n
+		}
+		e.warn(x.loc, "Using / for division is deprecated.\n\nRecommendation: math.div(%s, %s)", left, right)
+		return v
+	}
+	v, err := binaryOperation(x.op, left, right)
+	if err != nil {
+		panic(errorf(x.loc, "%s", err.Error()))
+	}
+	return v
+}
+
+// evalInterp evaluates interpolated text. Interpolated quoted strings lose
+// their quotes.
+func (e *evaluator) evalInterp(it interp) string {
+	var buf strings.Builder
+	for _, part := range it.parts {
+		switch part := part.(type) {
+		case string:
+			buf.WriteString(part)
+		case expr:
+			switch v := e.eval(part).(type) {
+			case nullValue:
+			case *String:
+				buf.WriteString(v.Text)
+			default:
+				s, err := toCSS(v, false)
+				if err != nil {
+					panic(errorf(part.location(), "%s", err.Error()))
+				}
+				buf.WriteString(s)
+			}
+		}
+	}
+	return buf.String()
+}
+
+// selectorValue returns a selector as a comma-separated list of
+// space-separated lists of compound selectors and combinators.
+func selectorValue(sel css3.SelectorList) Value {
+	if sel == nil {
+		return Null
+	}
+	complexes := make([]Value, len(sel))
+	for i, complex := range sel {
+		var parts []Value
+		for _, comp := range complex.Components {
+			if comp.Combinator != css3.NoCombinator && comp.Combinator != css3.DescendantCombinator {
+				parts = append(parts, &String{Text: comp.Combinator.String()})
+			}
+			parts = append(parts, &String{Text: comp.Compound.String()})
+		}
+		complexes[i] = NewList(parts, SpaceSeparator)
+	}
+	return NewList(complexes, CommaSeparator)
+}
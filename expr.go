@@ -0,0 +1,762 @@
+package scss
+
+import (
+	"math"
+	"strings"
+
+	"github.com/logan/scss/css3"
+)
+
+// Expressions are parsed by precedence climbing, from comma-separated lists
+// down to primary expressions:
+//
+//	comma list, space list, or, and, == !=, < > <= >=, + -, * / %, unary
+
+func (p *parser) parseExpression() expr {
+	start := p.peek()
+	first := p.parseSpaceList()
+	p.skipWS()
+	if !p.at(css3.CommaToken) {
+		return first
+	}
+	list := &listExpr{node: node{p.loc(start)}, items: []expr{first}, separator: CommaSeparator}
+	for p.at(css3.CommaToken) {
+		p.advance()
+		p.skipWS()
+		if p.atExpressionEnd() {
+			break
+		}
+		list.items = append(list.items, p.parseSpaceList())
+		p.skipWS()
+	}
+	return list
+}
+
+// atExpressionEnd reports whether the next token can't start another item of
+// a list.
+func (p *parser) atExpressionEnd() bool {
+	t := p.peek()
+	switch t.TokenType {
+	case css3.EOFToken, css3.SemicolonToken, css3.CommaToken, css3.ColonToken,
+		css3.RParenToken, css3.RSquareToken, css3.LCurlyToken, css3.RCurlyToken:
+		return true
+	case css3.DelimToken:
+		switch {
+		case p.atDelim('!'):
+			return !p.peekAt(1).isIdent("important")
+		case p.atDelim('.'):
+			return p.peekAt(1).isDelim('.')
+		}
+	case css3.IdentToken:
+		for _, word := range p.stopWords {
+			if t.isIdent(word) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p *parser) parseSpaceList() expr {
+	start := p.peek()
+	if p.atExpressionEnd() {
+		p.errorf(start, "Expected expression.")
+	}
+	first := p.parseOr()
+	var items []expr
+	for {
+		save := p.i
+		p.skipWS()
+		if p.atExpressionEnd() {
+			p.i = save
+			break
+		}
+		if items == nil {
+			items = []expr{first}
+		}
+		items = append(items, p.parseOr())
+	}
+	if items == nil {
+		return first
+	}
+	return &listExpr{node: node{p.loc(start)}, items: items, separator: SpaceSeparator}
+}
+
+// binaryOp looks past whitespace for one of the given operators. If found,
+// it consumes the operator and any following whitespace.
+func (p *parser) binaryOp(ops ...string) (string, bool) {
+	save := p.i
+	wsBefore := p.skipWS()
+	for _, op := range ops {
+		if p.matchOp(op, wsBefore) {
+			p.skipWS()
+			return op, true
+		}
+	}
+	p.i = save
+	return "", false
+}
+
+func (p *parser) matchOp(op string, wsBefore bool) bool {
+	switch op {
+	case "or", "and":
+		if p.atIdent(op) {
+			p.advance()
+			return true
+		}
+		return false
+	case "+", "-":
+		t := p.peek()
+		if t.isNumeric() && adjacent(p.prev(), t) && !wsBefore {
+			// "1-2" is tokenized as "1" followed by "-2".
+			num := t.Value.(*css3.Numeric)
+			if strings.HasPrefix(num.Repr, op) {
+				p.toks[p.i] = unsign(t)
+				return true
+			}
+			return false
+		}
+		if !t.isDelim(rune(op[0])) {
+			return false
+		}
+		// In "a -b" the minus is a unary operator starting another list item.
+		next := p.peekAt(1)
+		if wsBefore && next.TokenType != css3.WhitespaceToken {
+			return false
+		}
+		p.advance()
+		return true
+	}
+	// Multi-character operators are made of adjacent delimiters.
+	for i, ch := range op {
+		tk := p.peekAt(i)
+		if !tk.isDelim(ch) || (i > 0 && !adjacent(p.peekAt(i-1), tk)) {
+			return false
+		}
+	}
+	for range op {
+		p.advance()
+	}
+	return true
+}
+
+// unsign strips the sign from a numeric token that turned out to follow a
+// binary operator.
+func unsign(t token) token {
+	num := *t.Value.(*css3.Numeric)
+	num.Repr = num.Repr[1:]
+	num.Integer = int64(math.Abs(float64(num.Integer)))
+	num.Float = math.Abs(num.Float)
+	t.Token = css3.NewToken(t.TokenType, &num)
+	t.start.Offset++
+	t.start.Column++
+	return t
+}
+
+func (p *parser) parseOr() expr {
+	left := p.parseAnd()
+	for {
+		start := p.peek()
+		if _, ok := p.binaryOp("or"); !ok {
+			return left
+		}
+		left = &binaryExpr{node: node{p.loc(start)}, op: "or", left: left, right: p.parseAnd()}
+	}
+}
+
+func (p *parser) parseAnd() expr {
+	left := p.parseEquality()
+	for {
+		start := p.peek()
+		if _, ok := p.binaryOp("and"); !ok {
+			return left
+		}
+		left = &binaryExpr{node: node{p.loc(start)}, op: "and", left: left, right: p.parseEquality()}
+	}
+}
+
+func (p *parser) parseEquality() expr {
+	left := p.parseRelational()
+	for {
+		start := p.peek()
+		op, ok := p.binaryOp("==", "!=")
+		if !ok {
+			return left
+		}
+		left = &binaryExpr{node: node{p.loc(start)}, op: op, left: left, right: p.parseRelational()}
+	}
+}
+
+func (p *parser) parseRelational() expr {
+	left := p.parseAdditive()
+	for {
+		start := p.peek()
+		op, ok := p.binaryOp("<=", ">=", "<", ">")
+		if !ok {
+			return left
+		}
+		left = &binaryExpr{node: node{p.loc(start)}, op: op, left: left, right: p.parseAdditive()}
+	}
+}
+
+func (p *parser) parseAdditive() expr {
+	left := p.parseMultiplicative()
+	for {
+		start := p.peek()
+		op, ok := p.binaryOp("+", "-")
+		if !ok {
+			return left
+		}
+		left = &binaryExpr{node: node{p.loc(start)}, op: op, left: left, right: p.parseMultiplicative()}
+	}
+}
+
+func (p *parser) parseMultiplicative() expr {
+	left := p.parseUnary()
+	for {
+		start := p.peek()
+		op, ok := p.binaryOp("*", "/", "%")
+		if !ok {
+			return left
+		}
+		right := p.parseUnary()
+		left = &binaryExpr{
+			node:        node{p.loc(start)},
+			op:          op,
+			left:        left,
+			right:       right,
+			allowsSlash: op == "/" && isSlashOperand(left) && isSlashOperand(right),
+		}
+	}
+}
+
+// isSlashOperand reports whether e may be one side of a "/" that is kept as
+// a separator, as in "font: 12px/1.5".
+func isSlashOperand(e expr) bool {
+	switch e := e.(type) {
+	case *literalExpr:
+		_, ok := e.value.(*Number)
+		return ok
+	case *binaryExpr:
+		return e.allowsSlash
+	}
+	return false
+}
+
+func (p *parser) parseUnary() expr {
+	t := p.peek()
+	switch {
+	case t.isDelim('+'), t.isDelim('-'):
+		p.advance()
+		p.skipWS()
+		return &unaryExpr{node: node{p.loc(t)}, op: string(t.Value.(rune)), operand: p.parseUnary()}
+	case t.isDelim('/'):
+		p.advance()
+		p.skipWS()
+		return &unaryExpr{node: node{p.loc(t)}, op: "/", operand: p.parseUnary()}
+	case t.isIdent("not"):
+		p.advance()
+		p.skipWS()
+		return &unaryExpr{node: node{p.loc(t)}, op: "not", operand: p.parseUnary()}
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() expr {
+	t := p.peek()
+	loc := p.loc(t)
+	switch t.TokenType {
+	case css3.LParenToken:
+		return p.parseParens()
+	case css3.LSquareToken:
+		p.advance()
+		p.skipWS()
+		list := &listExpr{node: node{loc}, bracketed: true, separator: UndecidedSeparator}
+		if !p.at(css3.RSquareToken) {
+			switch e := p.parseExpression().(type) {
+			case *listExpr:
+				if !e.bracketed {
+					list.items, list.separator = e.items, e.separator
+					break
+				}
+				list.items = []expr{e}
+			default:
+				list.items = []expr{e}
+			}
+			p.skipWS()
+		}
+		p.expect(css3.RSquareToken, "\"]\"")
+		return list
+	case css3.StringToken:
+		return p.parseQuotedString()
+	case css3.NumberToken, css3.PercentageToken, css3.DimensionToken:
+		p.advance()
+		return &literalExpr{node: node{loc}, value: numberFromToken(t)}
+	case css3.HashToken:
+		p.advance()
+		if c := css3.ColorFromString(p.text(t)); c != nil {
+			return &literalExpr{node: node{loc}, value: &Color{Color: *c}}
+		}
+		return &literalExpr{node: node{loc}, value: &String{Text: p.text(t)}}
+	case css3.UrlToken, css3.BadUrlToken:
+		return p.parseURL()
+	case css3.UnicodeRangeToken:
+		p.advance()
+		return &literalExpr{node: node{loc}, value: &String{Text: p.text(t)}}
+	case css3.FunctionToken:
+		p.advance()
+		return p.parseFunctionCall("", t.ident(), t)
+	case css3.DelimToken:
+		switch {
+		case p.atVariable():
+			name := p.expectVariable()
+			return &variableExpr{node: node{loc}, name: name}
+		case p.atInterp():
+			return p.parseIdentifier()
+		case t.isDelim('&'):
+			p.advance()
+			return &selectorExpr{node{loc}}
+		case t.isDelim('!') && p.peekAt(1).isIdent("important"):
+			p.advance()
+			p.advance()
+			return &literalExpr{node: node{loc}, value: &String{Text: "!important"}}
+		case t.isDelim('-') && p.peekAt(1).TokenType != css3.WhitespaceToken:
+			return p.parseUnary()
+		}
+	case css3.IdentToken:
+		if p.atNamespaced() {
+			ns := p.advance()
+			p.advance()
+			if p.atVariable() {
+				return &variableExpr{node: node{loc}, namespace: ns.ident(), name: p.expectVariable()}
+			}
+			return p.parseFunctionCall(ns.ident(), p.advance().ident(), ns)
+		}
+		return p.parseIdentifier()
+	}
+	p.errorf(t, "Expected expression.")
+	return nil
+}
+
+func (p *parser) parseParens() expr {
+	start := p.advance()
+	loc := p.loc(start)
+	p.skipWS()
+	if p.at(css3.RParenToken) {
+		p.advance()
+		return &listExpr{node: node{loc}, separator: UndecidedSeparator}
+	}
+	first := p.parseSpaceList()
+	p.skipWS()
+	if p.at(css3.ColonToken) {
+		return p.parseMap(loc, first)
+	}
+	if !p.at(css3.CommaToken) {
+		p.expect(css3.RParenToken, "\")\"")
+		return &parenExpr{node: node{loc}, inner: first}
+	}
+	list := &listExpr{node: node{loc}, items: []expr{first}, separator: CommaSeparator}
+	for p.at(css3.CommaToken) {
+		p.advance()
+		p.skipWS()
+		if p.at(css3.RParenToken) {
+			break
+		}
+		list.items = append(list.items, p.parseSpaceList())
+		p.skipWS()
+	}
+	p.expect(css3.RParenToken, "\")\"")
+	return &parenExpr{node: node{loc}, inner: list}
+}
+
+func (p *parser) parseMap(loc Location, firstKey expr) expr {
+	m := &mapExpr{node: node{loc}}
+	key := firstKey
+	for {
+		p.expect(css3.ColonToken, "\":\"")
+		p.skipWS()
+		m.keys = append(m.keys, key)
+		m.values = append(m.values, p.parseSpaceList())
+		p.skipWS()
+		if !p.at(css3.CommaToken) {
+			break
+		}
+		p.advance()
+		p.skipWS()
+		if p.at(css3.RParenToken) {
+			break
+		}
+		key = p.parseSpaceList()
+		p.skipWS()
+	}
+	p.expect(css3.RParenToken, "\")\"")
+	return m
+}
+
+// parseIdentifier parses an unquoted string, which may be made of several
+// adjacent identifiers and interpolations, as in "foo-#{$x}-bar".
+func (p *parser) parseIdentifier() expr {
+	start := p.peek()
+	loc := p.loc(start)
+	var it interp
+	for first := true; ; first = false {
+		if !first && !adjacent(p.prev(), p.peek()) {
+			break
+		}
+		t := p.peek()
+		switch {
+		case p.atInterp():
+			it.addExpr(p.parseInterpolation())
+		case t.TokenType == css3.IdentToken:
+			p.advance()
+			it.addText(p.text(t))
+		case !first && (t.isDelim('-') || t.TokenType == css3.NumberToken || t.TokenType == css3.DimensionToken):
+			p.advance()
+			it.addText(p.text(t))
+		default:
+			goto done
+		}
+	}
+done:
+	if s, ok := it.plain(); ok {
+		switch s {
+		case "true":
+			return &literalExpr{node: node{loc}, value: True}
+		case "false":
+			return &literalExpr{node: node{loc}, value: False}
+		case "null":
+			return &literalExpr{node: node{loc}, value: Null}
+		}
+		if c := css3.ColorFromName(s); c != nil && !c.CurrentColor {
+			return &literalExpr{node: node{loc}, value: &Color{Color: *css3.ColorFromString(s)}}
+		}
+	}
+	return &stringExpr{node: node{loc}, text: it}
+}
+
+// parseURL parses the url() the tokenizer has read as an unquoted URL. As
+// in dart-sass, it's kept as a URL if it's one apart from any interpolation,
+// as in "url(#{$base}/x.png)", and is otherwise a call to the CSS url()
+// function, whose argument is an expression, as in "url($base + "/x.png")".
+func (p *parser) parseURL() expr {
+	t := p.advance()
+	loc := p.loc(t)
+	text := p.text(t)
+	name := text[:strings.IndexByte(text, '(')]
+	start := advancePos(t.start, text[:len(name)+1])
+	it, end, ok := p.scanURL(name, start)
+	if !ok {
+		p.retokenize(start)
+		return &funcCallExpr{node: node{loc}, name: name, args: p.parseArgInvocation()}
+	}
+	if end.Offset != t.end.Offset {
+		p.retokenize(end)
+	}
+	if s, ok := it.plain(); ok {
+		return &literalExpr{node: node{loc}, value: &String{Text: s}}
+	}
+	return &stringExpr{node: node{loc}, text: it}
+}
+
+// scanURL reads the unquoted URL that starts at start up to its closing
+// parenthesis, returning it with surrounding whitespace trimmed and the
+// position just past it. It fails if the URL contains anything other than
+// URL characters, escapes and interpolation.
+func (p *parser) scanURL(name string, start css3.Position) (it interp, end css3.Position, ok bool) {
+	src := p.src[:p.toks[len(p.toks)-1].start.Offset]
+	i := start.Offset
+	for i < len(src) && isCSSSpace(src[i]) {
+		i++
+	}
+	it.addText(name + "(")
+	lit := i
+	for i < len(src) {
+		switch c := src[i]; {
+		case c == '\\':
+			i += 2
+			if i <= len(src) && isHexDigit(src[i-1]) {
+				for n := 1; n < 6 && i < len(src) && isHexDigit(src[i]); n++ {
+					i++
+				}
+				if i < len(src) && isCSSSpace(src[i]) {
+					i++
+				}
+			}
+		case c == '#' && i+1 < len(src) && src[i+1] == '{':
+			it.addText(string(src[lit:i]))
+			sub := p.subParser(advancePos(start, string(src[start.Offset:i+2])), len(src))
+			sub.skipWS()
+			it.addExpr(sub.parseExpression())
+			sub.skipWS()
+			i = sub.expect(css3.RCurlyToken, "\"}\"").end.Offset
+			lit = i
+		case c == '!' || c == '%' || c == '&' || c >= '*' && c <= '~' || c >= 0x80:
+			i++
+		case c == ')' || isCSSSpace(c):
+			j := i
+			for j < len(src) && isCSSSpace(src[j]) {
+				j++
+			}
+			if j == len(src) || src[j] != ')' {
+				return interp{}, end, false
+			}
+			it.addText(string(src[lit:i]) + ")")
+			return it, advancePos(start, string(src[start.Offset:j+1])), true
+		default:
+			return interp{}, end, false
+		}
+	}
+	return interp{}, end, false
+}
+
+// retokenize replaces the tokens from the current one on with those read
+// from pos, for when the tokenizer has read the text there differently
+// from how Sass does.
+func (p *parser) retokenize(pos css3.Position) {
+	toks, _ := tokenize(p.file, p.src[pos.Offset:p.toks[len(p.toks)-1].start.Offset], pos)
+	p.toks = append(p.toks[:p.i:p.i], toks...)
+}
+
+func isCSSSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+// parseQuotedString parses a quoted string, evaluating any interpolation it
+// contains.
+func (p *parser) parseQuotedString() expr {
+	t := p.advance()
+	loc := p.loc(t)
+	raw := p.text(t)
+	if !strings.Contains(raw, "#{") {
+		return &stringExpr{node: node{loc}, text: interp{[]interface{}{t.Value.(string)}}, quoted: true}
+	}
+	var it interp
+	quote, body := raw[:1], raw[1:]
+	if strings.HasSuffix(body, quote) {
+		body = body[:len(body)-1]
+	}
+	bodyStart := t.start.Offset + 1
+	lit := 0
+	for i := 0; i < len(body); i++ {
+		switch {
+		case body[i] == '\\':
+			i++
+		case strings.HasPrefix(body[i:], "#{"):
+			it.addText(unquote(quote + body[lit:i] + quote))
+			sub := p.subParser(advancePos(t.start, raw[:1+i+2]), bodyStart+len(body))
+			sub.skipWS()
+			it.addExpr(sub.parseExpression())
+			sub.skipWS()
+			end := sub.expect(css3.RCurlyToken, "\"}\"")
+			lit = end.end.Offset - bodyStart
+			i = lit - 1
+		}
+	}
+	it.addText(unquote(quote + body[lit:] + quote))
+	return &stringExpr{node: node{loc}, text: it, quoted: true}
+}
+
+func unquote(quoted string) string {
+	tk := css3.NewTokenizer(strings.NewReader(quoted))
+	if t := tk.ConsumeToken(); t.TokenType == css3.StringToken {
+		return t.Value.(string)
+	}
+	return quoted[1 : len(quoted)-1]
+}
+
+// specialFunctions take arguments that aren't Sass expressions, so they're
+// passed through to the output with only interpolation evaluated.
+var specialFunctions = map[string]bool{
+	"calc":       true,
+	"element":    true,
+	"expression": true,
+	"env":        true,
+	"var":        true,
+}
+
+func (p *parser) parseFunctionCall(namespace, name string, start token) expr {
+	loc := p.loc(start)
+	lower := strings.ToLower(name)
+	if namespace == "" {
+		switch lower {
+		case "calc", "clamp":
+			return p.parseCalculation(lower, start)
+		case "min", "max":
+			// These are Sass functions if their arguments aren't a valid
+			// calculation, as in "min($list...)".
+			if x := p.tryCalculation(lower, start); x != nil {
+				return x
+			}
+		}
+	}
+	if namespace == "" && (specialFunctions[lower] || strings.HasPrefix(lower, "-") && specialFunctions[lower[strings.LastIndex(lower, "-")+1:]]) {
+		return &stringExpr{node: node{loc}, text: p.parseSpecialFunction(name)}
+	}
+	args := p.parseArgInvocation()
+	if namespace == "" && name == "if" {
+		return &ifExpr{node: node{loc}, args: args}
+	}
+	return &funcCallExpr{node: node{loc}, namespace: namespace, name: name, args: args}
+}
+
+// parseSpecialFunction reads the arguments of a special function verbatim,
+// evaluating only interpolation and variables.
+func (p *parser) parseSpecialFunction(name string) interp {
+	var it interp
+	it.addText(name + "(")
+	depth := 0
+	textStart := p.peek().start.Offset
+	for !p.atEOF() && (depth > 0 || !p.at(css3.RParenToken)) {
+		if p.atInterp() || p.atVariable() {
+			it.addText(string(p.src[textStart:p.peek().start.Offset]))
+			if p.atInterp() {
+				it.addExpr(p.parseInterpolation())
+			} else {
+				it.addExpr(p.parsePrimary())
+			}
+			textStart = p.prev().end.Offset
+			continue
+		}
+		switch p.advance().TokenType {
+		case css3.FunctionToken, css3.LParenToken:
+			depth++
+		case css3.RParenToken:
+			depth--
+		}
+	}
+	it.addText(string(p.src[textStart:p.peek().start.Offset]))
+	p.expect(css3.RParenToken, "\")\"")
+	it.addText(")")
+	return it
+}
+
+// parseCalculation parses the arguments of a calculation, after the
+// function token that starts it.
+func (p *parser) parseCalculation(name string, start token) *calcExpr {
+	x := &calcExpr{node: node{p.loc(start)}, name: name}
+	p.skipWS()
+	for {
+		x.args = append(x.args, p.parseCalcSum())
+		p.skipWS()
+		if name == "calc" || !p.at(css3.CommaToken) {
+			break
+		}
+		p.advance()
+		p.skipWS()
+	}
+	p.expect(css3.RParenToken, "\")\"")
+	if name == "clamp" && len(x.args) != 3 {
+		p.errorf(start, "clamp() requires exactly 3 arguments.")
+	}
+	return x
+}
+
+// tryCalculation parses a calculation, returning nil and leaving the parser
+// where it was if the arguments aren't one.
+func (p *parser) tryCalculation(name string, start token) (x expr) {
+	save, toks := p.i, append([]token(nil), p.toks...)
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(*Error); !ok {
+				panic(r)
+			}
+			p.i, p.toks = save, toks
+			x = nil
+		}
+	}()
+	return p.parseCalculation(name, start)
+}
+
+func (p *parser) parseCalcSum() expr {
+	left := p.parseCalcProduct()
+	for {
+		save := p.i
+		wsBefore := p.skipWS()
+		t := p.peek()
+		switch {
+		case t.isDelim('+') || t.isDelim('-'):
+			if !wsBefore || p.peekAt(1).TokenType != css3.WhitespaceToken {
+				p.errorf(t, "\"+\" and \"-\" must be surrounded by whitespace in calculations.")
+			}
+			p.advance()
+			p.skipWS()
+			left = &calcOpExpr{node: node{p.loc(t)}, op: string(t.Value.(rune)), left: left, right: p.parseCalcProduct()}
+		case t.isNumeric() && strings.ContainsAny(t.Value.(*css3.Numeric).Repr[:1], "+-"):
+			// "1px -2px" and "1px+2px" are tokenized with a signed number.
+			p.errorf(t, "\"+\" and \"-\" must be surrounded by whitespace in calculations.")
+		default:
+			p.i = save
+			return left
+		}
+	}
+}
+
+func (p *parser) parseCalcProduct() expr {
+	left := p.parseCalcValue()
+	for {
+		save := p.i
+		p.skipWS()
+		t := p.peek()
+		if !t.isDelim('*') && !t.isDelim('/') {
+			p.i = save
+			return left
+		}
+		p.advance()
+		p.skipWS()
+		left = &calcOpExpr{node: node{p.loc(t)}, op: string(t.Value.(rune)), left: left, right: p.parseCalcValue()}
+	}
+}
+
+func (p *parser) parseCalcValue() expr {
+	t := p.peek()
+	loc := p.loc(t)
+	switch {
+	case t.isNumeric():
+		p.advance()
+		return &literalExpr{node: node{loc}, value: numberFromToken(t)}
+	case t.TokenType == css3.LParenToken:
+		p.advance()
+		p.skipWS()
+		inner := p.parseCalcSum()
+		p.skipWS()
+		p.expect(css3.RParenToken, "\")\"")
+		return &parenExpr{node: node{loc}, inner: inner}
+	case t.TokenType == css3.FunctionToken:
+		p.advance()
+		switch lower := strings.ToLower(t.ident()); lower {
+		case "calc", "min", "max", "clamp":
+			return p.parseCalculation(lower, t)
+		}
+		return p.parseFunctionCall("", t.ident(), t)
+	case t.TokenType == css3.IdentToken && !p.atNamespaced():
+		switch strings.ToLower(t.ident()) {
+		case "pi":
+			p.advance()
+			return &literalExpr{node: node{loc}, value: NewNumber(math.Pi, "")}
+		case "e":
+			p.advance()
+			return &literalExpr{node: node{loc}, value: NewNumber(math.E, "")}
+		}
+		return p.parseIdentifier()
+	case t.isDelim('-') && adjacent(t, p.peekAt(1)):
+		p.advance()
+		return &unaryExpr{node: node{loc}, op: "-", operand: p.parseCalcValue()}
+	case t.TokenType == css3.IdentToken, p.atVariable(), p.atInterp():
+		return p.parsePrimary()
+	}
+	p.errorf(t, "Expected number, variable, function, or calculation.")
+	return nil
+}
+
+func numberFromToken(t token) *Number {
+	num := t.Value.(*css3.Numeric)
+	n := &Number{Value: num.Float64()}
+	if num.Unit != "" {
+		n.Numerators = []string{num.Unit}
+	}
+	return n
+}
@@ -0,0 +1,399 @@
+package scss
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/logan/scss/css3"
+)
+
+// resolveParent resolves a style rule's selector against the selector of the
+// enclosing rule, replacing "&" or, if it has none, nesting it as a
+// descendant.
+func (e *evaluator) resolveParent(sel css3.SelectorList, loc Location) css3.SelectorList {
+	if e.selector == nil {
+		if hasNesting(sel) {
+			panic(errorf(loc, "Top-level selectors may not contain the parent selector \"&\"."))
+		}
+		return sel
+	}
+	result, err := nestSelector(e.selector, sel, !e.atRoot)
+	if err != nil {
+		panic(errorf(loc, "%s", err))
+	}
+	return result
+}
+
+// nestSelector resolves sel within parent. Selectors without "&" are nested
+// as descendants of parent if implicit is true, and left alone otherwise.
+func nestSelector(parent, sel css3.SelectorList, implicit bool) (css3.SelectorList, error) {
+	var groups []css3.SelectorList
+	for _, complex := range sel {
+		if !hasNesting(css3.SelectorList{complex}) {
+			if !implicit {
+				groups = append(groups, css3.SelectorList{complex})
+				continue
+			}
+			var group css3.SelectorList
+			for _, p := range parent {
+				group = append(group, joinComplex(p.Components, complex.Components))
+			}
+			groups = append(groups, group)
+			continue
+		}
+		partials := []*css3.ComplexSelector{{}}
+		for _, comp := range complex.Components {
+			if !hasNestingCompound(comp.Compound) {
+				for _, partial := range partials {
+					partial.Components = append(partial.Components, comp)
+				}
+				continue
+			}
+			if comp.Compound[0].Type != css3.NestingSelector {
+				return nil, fmt.Errorf("\"&\" may only be used at the beginning of a compound selector.")
+			}
+			var next []*css3.ComplexSelector
+			for _, partial := range partials {
+				for _, p := range parent {
+					components := append([]css3.ComplexComponent(nil), partial.Components...)
+					inserted := append([]css3.ComplexComponent(nil), p.Components...)
+					last := &inserted[len(inserted)-1]
+					nested, err := nestCompound(last.Compound, comp.Compound)
+					if err != nil {
+						return nil, err
+					}
+					last.Compound = nested
+					if len(components) > 0 {
+						inserted[0].Combinator = comp.Combinator
+					}
+					next = append(next, &css3.ComplexSelector{Components: append(components, inserted...)})
+				}
+			}
+			partials = next
+		}
+		groups = append(groups, partials)
+	}
+	return flattenVertically(groups), nil
+}
+
+// flattenVertically interleaves groups, taking the first selector of each
+// group, then the second, and so on, so that "a, b { c, d {} }" resolves to
+// "a c, a d, b c, b d".
+func flattenVertically(groups []css3.SelectorList) css3.SelectorList {
+	var result css3.SelectorList
+	for i := 0; ; i++ {
+		added := false
+		for _, group := range groups {
+			if i < len(group) {
+				result = append(result, group[i])
+				added = true
+			}
+		}
+		if !added {
+			return result
+		}
+	}
+}
+
+// nestCompound replaces the "&" at the start of compound with parent.
+func nestCompound(parent, compound css3.CompoundSelector) (css3.CompoundSelector, error) {
+	result := append(css3.CompoundSelector(nil), parent...)
+	if suffix := compound[0].Suffix; suffix != "" {
+		last := *result[len(result)-1]
+		switch last.Type {
+		case css3.TypeSelector, css3.ClassSelector, css3.IDSelector, css3.PlaceholderSelector:
+		default:
+			return nil, fmt.Errorf("Invalid parent selector \"%s\".", parent)
+		}
+		last.Name += suffix
+		result[len(result)-1] = &last
+	}
+	return append(result, compound[1:]...), nil
+}
+
+// joinComplex nests child within parent as a descendant, or with its
+// leading combinator if it has one.
+func joinComplex(parent, child []css3.ComplexComponent) *css3.ComplexSelector {
+	components := append(append([]css3.ComplexComponent(nil), parent...), child...)
+	if c := &components[len(parent)]; c.Combinator == css3.NoCombinator {
+		c.Combinator = css3.DescendantCombinator
+	}
+	return &css3.ComplexSelector{Components: components}
+}
+
+func hasNesting(sel css3.SelectorList) bool {
+	for _, complex := range sel {
+		for _, comp := range complex.Components {
+			if hasNestingCompound(comp.Compound) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasNestingCompound(compound css3.CompoundSelector) bool {
+	for _, simple := range compound {
+		if simple.Type == css3.NestingSelector {
+			return true
+		}
+	}
+	return false
+}
+
+type extension struct {
+	extender css3.SelectorList
+	target   css3.CompoundSelector
+	media    []string
+	optional bool
+	matched  bool
+	loc      Location
+}
+
+// maxExtendedSelectors bounds the selectors @extend may generate for one
+// rule.
+const maxExtendedSelectors = 1000
+
+func (e *evaluator) execExtend(s *extendStmt) {
+	if e.styleRule == nil || e.selector == nil {
+		panic(errorf(s.loc, "@extend may only be used within style rules."))
+	}
+	text := e.evalInterp(s.selector)
+	targets, err := css3.ParseSelector(text)
+	if err != nil {
+		panic(errorf(s.loc, "Invalid selector \"%s\": %s.", text, err))
+	}
+	for _, target := range targets {
+		if len(target.Components) != 1 {
+			panic(errorf(s.loc, "complex selectors may not be extended."))
+		}
+		e.extends = append(e.extends, &extension{
+			extender: e.selector,
+			target:   target.Components[0].Compound,
+			media:    e.media,
+			optional: s.optional,
+			loc:      s.loc,
+		})
+	}
+}
+
+// applyExtends adds the selectors generated by @extend to every style rule,
+// then removes selectors containing placeholders.
+func (e *evaluator) applyExtends() {
+	e.walkRules(e.root, func(n *cssNode) {
+		var exts []*extension
+		for _, ext := range e.extends {
+			if len(ext.media) == 0 || strings.Join(ext.media, ", ") == strings.Join(n.media, ", ") {
+				exts = append(exts, ext)
+			}
+		}
+		if len(exts) > 0 {
+			n.selector = extendList(n.selector, exts)
+		}
+	})
+	for _, ext := range e.extends {
+		if !ext.matched && !ext.optional {
+			panic(errorf(ext.loc, "The target selector was not found.\nUse \"@extend %s !optional\" to avoid this error.", ext.target))
+		}
+	}
+	e.walkRules(e.root, func(n *cssNode) {
+		visible := css3.SelectorList{}
+		for _, complex := range n.selector {
+			if !hasPlaceholder(complex) {
+				visible = append(visible, complex)
+			}
+		}
+		n.selector = visible
+	})
+}
+
+func (e *evaluator) walkRules(n *cssNode, f func(*cssNode)) {
+	for _, child := range n.children {
+		if child.kind == ruleNode && child.selector != nil {
+			f(child)
+		}
+		e.walkRules(child, f)
+	}
+}
+
+func hasPlaceholder(complex *css3.ComplexSelector) bool {
+	for _, comp := range complex.Components {
+		for _, simple := range comp.Compound {
+			if simple.Type == css3.PlaceholderSelector {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// extendList applies extensions to a selector list until no new selectors
+// are generated, so that extenders can themselves be extended.
+func extendList(sel css3.SelectorList, exts []*extension) css3.SelectorList {
+	seen := make(map[string]bool)
+	result := append(css3.SelectorList(nil), sel...)
+	for _, complex := range result {
+		seen[complex.String()] = true
+	}
+	for i := 0; i < len(result) && len(result) < maxExtendedSelectors; i++ {
+		for _, ext := range exts {
+			for _, extended := range extendComplex(result[i], ext) {
+				if key := extended.String(); !seen[key] {
+					seen[key] = true
+					result = append(result, extended)
+				}
+			}
+		}
+	}
+	return result
+}
+
+// extendComplex returns the selectors generated by replacing the target of
+// ext in each compound of complex with each of its extenders.
+func extendComplex(complex *css3.ComplexSelector, ext *extension) []*css3.ComplexSelector {
+	var result []*css3.ComplexSelector
+	for j, comp := range complex.Components {
+		rest, ok := removeTarget(comp.Compound, ext.target)
+		if !ok {
+			continue
+		}
+		ext.matched = true
+		prefix := complex.Components[:j]
+		suffix := complex.Components[j+1:]
+		for _, extender := range ext.extender {
+			last := extender.Components[len(extender.Components)-1]
+			unified := unifyCompounds(rest, last.Compound)
+			if unified == nil {
+				continue
+			}
+			for _, woven := range weave(prefix, extender.Components[:len(extender.Components)-1], comp.Combinator, last.Combinator) {
+				components := append(woven.components, css3.ComplexComponent{Combinator: woven.combinator, Compound: unified})
+				components = append(components, suffix...)
+				result = append(result, &css3.ComplexSelector{Components: components})
+			}
+		}
+	}
+	return result
+}
+
+// removeTarget returns compound without the simple selectors of target, if
+// compound contains all of them.
+func removeTarget(compound, target css3.CompoundSelector) (css3.CompoundSelector, bool) {
+	var rest css3.CompoundSelector
+	matched := 0
+	for _, simple := range compound {
+		if compoundContains(target, simple) {
+			matched++
+		} else {
+			rest = append(rest, simple)
+		}
+	}
+	return rest, matched == len(target)
+}
+
+func compoundContains(compound css3.CompoundSelector, simple *css3.SimpleSelector) bool {
+	for _, s := range compound {
+		if s.Equal(simple) {
+			return true
+		}
+	}
+	return false
+}
+
+type wovenPrefix struct {
+	components []css3.ComplexComponent
+	combinator css3.Combinator
+}
+
+// weave combines the components preceding an extended compound with those
+// preceding the extender's compound. When both are descendants, either may
+// come first in the document, so both orders are generated.
+func weave(prefix, extPrefix []css3.ComplexComponent, comb, extComb css3.Combinator) []wovenPrefix {
+	join := func(a, b []css3.ComplexComponent) []css3.ComplexComponent {
+		if len(a) == 0 || len(b) == 0 {
+			return append(append([]css3.ComplexComponent(nil), a...), b...)
+		}
+		return joinComplex(a, b).Components
+	}
+	descendant := func(c css3.Combinator) bool {
+		return c == css3.DescendantCombinator || c == css3.NoCombinator
+	}
+	switch {
+	case len(extPrefix) == 0:
+		return []wovenPrefix{{join(prefix, nil), comb}}
+	case len(prefix) == 0:
+		return []wovenPrefix{{join(extPrefix, nil), extComb}}
+	case descendant(comb) && descendant(extComb):
+		return []wovenPrefix{
+			{join(prefix, extPrefix), extComb},
+			{join(extPrefix, prefix), comb},
+		}
+	case descendant(comb):
+		return []wovenPrefix{{join(prefix, extPrefix), extComb}}
+	case descendant(extComb):
+		return []wovenPrefix{{join(extPrefix, prefix), comb}}
+	}
+	return nil
+}
+
+// unifyCompounds returns a compound selector matching elements matched by
+// both a and b, or nil if there can be none.
+func unifyCompounds(a, b css3.CompoundSelector) css3.CompoundSelector {
+	result := append(css3.CompoundSelector(nil), a...)
+	for _, simple := range b {
+		if result = unifySimple(simple, result); result == nil {
+			return nil
+		}
+	}
+	return result
+}
+
+func unifySimple(simple *css3.SimpleSelector, compound css3.CompoundSelector) css3.CompoundSelector {
+	if compoundContains(compound, simple) {
+		return compound
+	}
+	var first *css3.SimpleSelector
+	if len(compound) > 0 {
+		first = compound[0]
+	}
+	switch {
+	case simple.Type == css3.UniversalSelector:
+		if len(compound) == 0 {
+			return css3.CompoundSelector{simple}
+		}
+		return compound
+	case simple.Type == css3.TypeSelector:
+		if first != nil && first.Type == css3.TypeSelector {
+			return nil
+		}
+		if first != nil && first.Type == css3.UniversalSelector {
+			compound = compound[1:]
+		}
+		return append(css3.CompoundSelector{simple}, compound...)
+	case simple.Type == css3.IDSelector:
+		for _, s := range compound {
+			if s.Type == css3.IDSelector {
+				return nil
+			}
+		}
+	case simple.IsPseudoElement():
+		for _, s := range compound {
+			if s.IsPseudoElement() {
+				return nil
+			}
+		}
+		return append(append(css3.CompoundSelector(nil), compound...), simple)
+	}
+	// Pseudo-classes go before any pseudo-element, and other simple
+	// selectors before any pseudo-selector.
+	i := 0
+	for ; i < len(compound); i++ {
+		s := compound[i]
+		if s.IsPseudoElement() || simple.Type != css3.PseudoClassSelector && s.Type == css3.PseudoClassSelector {
+			break
+		}
+	}
+	result := append(css3.CompoundSelector(nil), compound[:i]...)
+	result = append(result, simple)
+	return append(result, compound[i:]...)
+}
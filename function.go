@@ -0,0 +1,149 @@
+package scss
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/logan/scss/css3"
+)
+
+// globalFunctions are the built-in functions available without @use, by
+// name. Functions given in Options override them.
+var globalFunctions = make(map[string]*callable)
+
+// parseSignature parses a function signature like "name($a, $b: null)" into
+// the function's name and parameters. A bare name declares a function taking
+// any number of positional arguments, which gets a nil parameter list.
+func parseSignature(sig string) (string, *paramList) {
+	toks, _ := tokenize("signature", []byte(sig), css3.StartPosition)
+	p := &parser{file: "signature", src: []byte(sig), toks: toks}
+	p.skipWS()
+	var name string
+	var params *paramList
+	switch {
+	case p.at(css3.FunctionToken):
+		name = normName(p.advance().ident())
+		params = p.parseParamList()
+	case p.at(css3.IdentToken):
+		name = normName(p.advance().ident())
+	default:
+		p.errorf(p.peek(), "Expected identifier.")
+	}
+	p.skipWS()
+	if !p.atEOF() {
+		p.errorf(p.peek(), "expected end of signature.")
+	}
+	return name, params
+}
+
+// newFunction returns a function implemented in Go with the given
+// signature. Default values in the signature are evaluated in an empty
+// module of their own.
+func newFunction(sig string, fn Function) *callable {
+	name, params := parseSignature(sig)
+	return &callable{name: name, params: params, fn: fn, env: newEnvironment(newModule(""), nil)}
+}
+
+func newBuiltin(sig string, fn builtinFunction) *callable {
+	c := newFunction(sig, nil)
+	c.builtin = fn
+	return c
+}
+
+// callGoFunction calls a function or mixin implemented in Go. If it was
+// declared with parameters, it receives one argument for each of them in
+// order, followed by an *ArgList for its rest parameter if it has one.
+func (e *evaluator) callGoFunction(c *callable, args *evaluatedArgs, loc Location) Value {
+	values := args.positional
+	if c.params != nil {
+		values = e.bindGoArgs(c, args, loc)
+	} else if len(args.named) > 0 {
+		panic(errorf(loc, "No argument named $%s.", args.names[0]))
+	}
+	var v Value
+	var err error
+	if c.builtin != nil {
+		v, err = c.builtin(e, values, loc)
+	} else {
+		v, err = c.fn(values)
+	}
+	if err != nil {
+		panic(errorf(loc, "%s", err.Error()))
+	}
+	if v == nil {
+		return Null
+	}
+	return v
+}
+
+func (e *evaluator) bindGoArgs(c *callable, args *evaluatedArgs, loc Location) []Value {
+	saved := e.evalState
+	defer func() { e.evalState = saved }()
+	e.enter(c, loc)
+	e.bindArgs(c, args, loc)
+	values := make([]Value, 0, len(c.params.params)+1)
+	for _, p := range c.params.params {
+		values = append(values, e.env.scope.vars[p.name])
+	}
+	if c.params.rest != "" {
+		values = append(values, e.env.scope.vars[c.params.rest])
+	}
+	return values
+}
+
+// argumentError returns the error a function reports when its argument
+// named name has the wrong type.
+func argumentError(name string, v Value, want string) error {
+	if name == "" {
+		return fmt.Errorf("%s is not %s.", v, want)
+	}
+	return fmt.Errorf("$%s: %s is not %s.", strings.TrimPrefix(name, "$"), v, want)
+}
+
+// ExpectNumber returns v as a number, or an error naming the argument it was
+// passed as if it's any other type.
+func ExpectNumber(v Value, name string) (*Number, error) {
+	if n, ok := v.(*Number); ok {
+		return n, nil
+	}
+	return nil, argumentError(name, v, "a number")
+}
+
+func ExpectString(v Value, name string) (*String, error) {
+	if s, ok := v.(*String); ok {
+		return s, nil
+	}
+	return nil, argumentError(name, v, "a string")
+}
+
+func ExpectColor(v Value, name string) (*Color, error) {
+	if c, ok := v.(*Color); ok {
+		return c, nil
+	}
+	return nil, argumentError(name, v, "a color")
+}
+
+func ExpectMap(v Value, name string) (*Map, error) {
+	switch v := v.(type) {
+	case *Map:
+		return v, nil
+	case *List:
+		if len(v.Items) == 0 {
+			return NewMap(), nil
+		}
+	}
+	return nil, argumentError(name, v, "a map")
+}
+
+// ExpectInt returns v as an int if it's a number without a fractional part.
+func ExpectInt(v Value, name string) (int, error) {
+	n, err := ExpectNumber(v, name)
+	if err != nil {
+		return 0, err
+	}
+	i, ok := n.Int()
+	if !ok {
+		return 0, argumentError(name, v, "an int")
+	}
+	return i, nil
+}
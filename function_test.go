@@ -0,0 +1,101 @@
+package scss
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFunctions(t *testing.T) {
+	assetURL := func(args []Value) (Value, error) {
+		path, err := ExpectString(args[0], "path")
+		if err != nil {
+			return nil, err
+		}
+		url := "/assets/" + path.Text
+		if args[1] != Null {
+			version, err := ExpectInt(args[1], "version")
+			if err != nil {
+				return nil, err
+			}
+			url += "?v=" + NewNumber(float64(version), "").String()
+		}
+		return &String{Text: "url(" + url + ")"}, nil
+	}
+	join := func(args []Value) (Value, error) {
+		sep, err := ExpectString(args[0], "separator")
+		if err != nil {
+			return nil, err
+		}
+		rest := args[1].(*ArgList)
+		parts := make([]string, len(rest.Items))
+		for i, item := range rest.Items {
+			parts[i] = item.String()
+		}
+		return NewString(strings.Join(parts, sep.Text)), nil
+	}
+	opts := Options{Functions: map[string]Function{
+		"asset-url($path, $version: null)": assetURL,
+		"join-all($separator, $items...)":  join,
+	}}
+	compile := func(src string) (string, error) {
+		result, err := CompileString(src, opts)
+		if err != nil {
+			return "", err
+		}
+		return result.CSS, nil
+	}
+
+	Convey("arguments are bound to the signature", t, func() {
+		css, err := compile(`a { b: asset-url("x.png"); c: asset_url($version: 2, $path: "y.png"); }`)
+		So(err, ShouldBeNil)
+		So(css, ShouldEqual, "a {\n  b: url(/assets/x.png);\n  c: url(/assets/y.png?v=2);\n}")
+
+		css, err = compile(`a { b: join-all("-", 1, 2, 3); }`)
+		So(err, ShouldBeNil)
+		So(css, ShouldEqual, "a {\n  b: \"1-2-3\";\n}")
+	})
+
+	Convey("errors are reported at the call", t, func() {
+		_, err := compile("a {\n  b: asset-url(1);\n}")
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "stdin:2:6: $path: 1 is not a string.")
+
+		_, err = compile("a { b: asset-url(\"x\", 1.5); }")
+		So(err.Error(), ShouldEqual, "stdin:1:8: $version: 1.5 is not an int.")
+
+		_, err = compile("a { b: asset-url(); }")
+		So(err.Error(), ShouldEqual, "stdin:1:8: Missing argument $path.")
+
+		_, err = compile("a { b: asset-url(\"x\", $size: 1); }")
+		So(err.Error(), ShouldEqual, "stdin:1:8: No argument named $size.")
+	})
+
+	Convey("invalid signatures", t, func() {
+		_, err := CompileString("", Options{Functions: map[string]Function{"f($a": assetURL}})
+		So(err, ShouldNotBeNil)
+		_, err = CompileString("", Options{Functions: map[string]Function{"f() g": assetURL}})
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("custom functions override built-ins", t, func() {
+		globalFunctions["builtin-test"] = newFunction("builtin-test()", func([]Value) (Value, error) {
+			return NewString("built-in"), nil
+		})
+		defer delete(globalFunctions, "builtin-test")
+
+		css, err := compile("a { b: builtin-test(); }")
+		So(err, ShouldBeNil)
+		So(css, ShouldEqual, "a {\n  b: \"built-in\";\n}")
+
+		override := func([]Value) (Value, error) { return nil, errors.New("overridden") }
+		_, err = CompileString("a { b: builtin-test(); }", Options{Functions: map[string]Function{"builtin-test()": override}})
+		So(err.Error(), ShouldEqual, "stdin:1:8: overridden")
+
+		css, err = compile("@function builtin-test() { @return 1; } a { b: builtin-test(); }")
+		So(err, ShouldBeNil)
+		So(css, ShouldEqual, "a {\n  b: 1;\n}")
+	})
+}
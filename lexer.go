@@ -0,0 +1,145 @@
+package scss
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/logan/scss/css3"
+)
+
+type token struct {
+	*css3.Token
+	start css3.Position
+	end   css3.Position
+}
+
+func (t token) isDelim(ch rune) bool {
+	return t.TokenType == css3.DelimToken && t.Value.(rune) == ch
+}
+
+func (t token) isIdent(name string) bool {
+	return t.TokenType == css3.IdentToken && string(t.Value.(css3.Identifier)) == name
+}
+
+func (t token) ident() string {
+	switch v := t.Value.(type) {
+	case css3.Identifier:
+		return string(v)
+	case string:
+		return v
+	}
+	return ""
+}
+
+func (t token) isNumeric() bool {
+	switch t.TokenType {
+	case css3.NumberToken, css3.PercentageToken, css3.DimensionToken:
+		return true
+	}
+	return false
+}
+
+// shift translates a position within a fragment of the source that begins
+// at base into a position within the whole source.
+func shift(pos, base css3.Position) css3.Position {
+	if pos.Line == 1 {
+		pos.Column += base.Column - 1
+	}
+	pos.Line += base.Line - 1
+	pos.Offset += base.Offset
+	return pos
+}
+
+// tokenize runs the CSS tokenizer over src, a fragment of a file starting at
+// base. The result always ends with an EOF token. Silent "//" comments are
+// dropped, and loud "/* */" comments are returned separately.
+func tokenize(file string, src []byte, base css3.Position) (toks, comments []token) {
+	tk, origin := newTokenizer(src), base
+	for {
+		t := token{tk.ConsumeToken(), shift(tk.TokenStart(), origin), shift(tk.TokenEnd(), origin)}
+		switch t.TokenType {
+		case css3.ErrorToken:
+			panic(errorf(Location{file, t.start}, "%s", t.Value.(error).Error()))
+		case css3.CommentToken:
+			comments = append(comments, t)
+			continue
+		case css3.DelimToken:
+			if i := t.end.Offset - base.Offset; t.isDelim('/') && i < len(src) && src[i] == '/' {
+				// A silent comment runs to the end of the line. Tokenizing
+				// starts again from there.
+				eol := len(src)
+				if j := bytes.IndexAny(src[i:], "\n\r\f"); j >= 0 {
+					eol = i + j
+				}
+				origin = advancePos(t.start, string(src[t.start.Offset-base.Offset:eol]))
+				tk = newTokenizer(src[eol:])
+				continue
+			}
+		case css3.DimensionToken:
+			if split := splitDimension(file, src, base, t); split != nil {
+				toks = append(toks, split...)
+				continue
+			}
+		case css3.WhitespaceToken:
+			// Whitespace on both sides of a comment is read as one run.
+			if n := len(toks); n > 0 && toks[n-1].TokenType == css3.WhitespaceToken {
+				toks[n-1].end = t.end
+				continue
+			}
+		}
+		toks = append(toks, t)
+		if t.TokenType == css3.EOFToken {
+			return toks, comments
+		}
+	}
+}
+
+func newTokenizer(src []byte) *css3.Tokenizer {
+	tk := css3.NewTokenizer(bytes.NewReader(src))
+	tk.KeepComments = true
+	return tk
+}
+
+// splitDimension separates a dimension like "10px-2px", which CSS reads as a
+// single number with the unit "px-2px", into "10px" and "-2px" so that the
+// expression parser sees a subtraction.
+func splitDimension(file string, src []byte, base css3.Position, t token) []token {
+	num := t.Value.(*css3.Numeric)
+	unit := num.Unit
+	i := 1
+	for ; i < len(unit); i++ {
+		if unit[i] == '-' && i+1 < len(unit) && (isDigit(unit[i+1]) ||
+			(unit[i+1] == '.' && i+2 < len(unit) && isDigit(unit[i+2]))) {
+			break
+		}
+	}
+	raw := string(src[t.start.Offset-base.Offset : t.end.Offset-base.Offset])
+	if i >= len(unit) || strings.ContainsRune(raw, '\\') {
+		return nil
+	}
+	first := *num
+	first.Unit = unit[:i]
+	n := len(num.Repr) + i
+	mid := t.start
+	mid.Offset += n
+	mid.Column += n
+	rest, _ := tokenize(file, src[mid.Offset-base.Offset:t.end.Offset-base.Offset], mid)
+	return append([]token{{css3.NewToken(css3.DimensionToken, &first), t.start, mid}}, rest[:len(rest)-1]...)
+}
+
+func isDigit(ch byte) bool { return ch >= '0' && ch <= '9' }
+
+// advancePos returns the position just past s, which starts at pos.
+func advancePos(pos css3.Position, s string) css3.Position {
+	for _, ch := range s {
+		pos.Offset += utf8.RuneLen(ch)
+		if ch == '\n' {
+			pos.Line++
+			pos.Column = 1
+		} else {
+			pos.Column++
+		}
+	}
+	return pos
+}
@@ -0,0 +1,152 @@
+package scss
+
+import (
+	"fmt"
+)
+
+// listIndex returns the zero-based index of the list item that the Sass
+// index n refers to. Negative indices count from the end.
+func listIndex(list Value, n Value, name string) (int, error) {
+	i, err := ExpectInt(n, name)
+	if err != nil {
+		return 0, err
+	}
+	length := len(listItems(list))
+	switch {
+	case i == 0:
+		return 0, fmt.Errorf("$%s: List index may not be 0.", name)
+	case i > length || -i > length:
+		return 0, fmt.Errorf("$%s: Invalid index %d for a list with %d %s.", name, i, length, pluralize(length, "element", "elements"))
+	case i < 0:
+		return length + i, nil
+	}
+	return i - 1, nil
+}
+
+func isBracketed(v Value) bool {
+	l, ok := v.(*List)
+	return ok && l.Bracketed
+}
+
+// expectSeparator returns the separator named by v, or auto if v is "auto".
+func expectSeparator(v Value, name string, auto Separator) (Separator, error) {
+	s, err := ExpectString(v, name)
+	if err != nil {
+		return 0, err
+	}
+	switch s.Text {
+	case "auto":
+		return auto, nil
+	case "space":
+		return SpaceSeparator, nil
+	case "comma":
+		return CommaSeparator, nil
+	case "slash":
+		return SlashSeparator, nil
+	}
+	return 0, fmt.Errorf("$%s: Must be \"space\", \"comma\", \"slash\", or \"auto\".", name)
+}
+
+func init() {
+	m := builtinModule("list")
+	m.define("length($list)", func(args []Value) (Value, error) {
+		return NewNumber(float64(len(listItems(args[0]))), ""), nil
+	}, "length")
+
+	m.define("nth($list, $n)", func(args []Value) (Value, error) {
+		i, err := listIndex(args[0], args[1], "n")
+		if err != nil {
+			return nil, err
+		}
+		return listItems(args[0])[i], nil
+	}, "nth")
+
+	m.define("set-nth($list, $n, $value)", func(args []Value) (Value, error) {
+		i, err := listIndex(args[0], args[1], "n")
+		if err != nil {
+			return nil, err
+		}
+		items := append([]Value(nil), listItems(args[0])...)
+		items[i] = args[2]
+		sep := listSeparator(args[0])
+		if sep == UndecidedSeparator {
+			sep = SpaceSeparator
+		}
+		return &List{Items: items, Separator: sep, Bracketed: isBracketed(args[0])}, nil
+	}, "set-nth")
+
+	m.define("join($list1, $list2, $separator: auto, $bracketed: auto)", func(args []Value) (Value, error) {
+		auto := listSeparator(args[0])
+		if auto == UndecidedSeparator {
+			auto = listSeparator(args[1])
+		}
+		if auto == UndecidedSeparator {
+			auto = SpaceSeparator
+		}
+		sep, err := expectSeparator(args[2], "separator", auto)
+		if err != nil {
+			return nil, err
+		}
+		bracketed := isBracketed(args[0])
+		if s, ok := args[3].(*String); !ok || s.Text != "auto" {
+			bracketed = args[3].Truthy()
+		}
+		items := append(append([]Value(nil), listItems(args[0])...), listItems(args[1])...)
+		return &List{Items: items, Separator: sep, Bracketed: bracketed}, nil
+	}, "join")
+
+	m.define("append($list, $val, $separator: auto)", func(args []Value) (Value, error) {
+		auto := listSeparator(args[0])
+		if auto == UndecidedSeparator {
+			auto = SpaceSeparator
+		}
+		sep, err := expectSeparator(args[2], "separator", auto)
+		if err != nil {
+			return nil, err
+		}
+		items := append(append([]Value(nil), listItems(args[0])...), args[1])
+		return &List{Items: items, Separator: sep, Bracketed: isBracketed(args[0])}, nil
+	}, "append")
+
+	m.define("zip($lists...)", func(args []Value) (Value, error) {
+		lists := args[0].(*ArgList).Items
+		var result []Value
+		for i := 0; len(lists) > 0; i++ {
+			tuple := make([]Value, len(lists))
+			for j, list := range lists {
+				items := listItems(list)
+				if i >= len(items) {
+					return NewList(result, CommaSeparator), nil
+				}
+				tuple[j] = items[i]
+			}
+			result = append(result, NewList(tuple, SpaceSeparator))
+		}
+		return NewList(result, CommaSeparator), nil
+	}, "zip")
+
+	m.define("index($list, $value)", func(args []Value) (Value, error) {
+		for i, item := range listItems(args[0]) {
+			if item.Equal(args[1]) {
+				return NewNumber(float64(i+1), ""), nil
+			}
+		}
+		return Null, nil
+	}, "index")
+
+	m.define("separator($list)", func(args []Value) (Value, error) {
+		return &String{Text: listSeparator(args[0]).String()}, nil
+	}, "list-separator")
+
+	m.define("is-bracketed($list)", func(args []Value) (Value, error) {
+		return Bool(isBracketed(args[0])), nil
+	}, "is-bracketed")
+
+	m.define("slash($elements...)", func(args []Value) (Value, error) {
+		elements := args[0].(*ArgList).Items
+		if len(elements) < 2 {
+			return nil, fmt.Errorf("At least two elements are required.")
+		}
+		return NewList(elements, SlashSeparator), nil
+	})
+}
@@ -0,0 +1,47 @@
+package scss
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestListFunctions(t *testing.T) {
+	eval := moduleEval("list", "")
+
+	Convey("reading lists", t, func() {
+		So(eval("length(1px 2px 3px)"), ShouldEqual, "3")
+		So(eval("list.length((a: 1, b: 2))"), ShouldEqual, "2")
+		So(eval("nth(a b c, 2)"), ShouldEqual, "b")
+		So(eval("list.nth(a b c, -1)"), ShouldEqual, "c")
+		So(eval("nth((a: 1), 1)"), ShouldEqual, "a 1")
+		So(eval("index(a b c, c)"), ShouldEqual, "3")
+		So(eval("index(a b c, d)"), ShouldEqual, "null")
+		So(eval("list.separator((a, b))"), ShouldEqual, "comma")
+		So(eval("list-separator(a)"), ShouldEqual, "space")
+		So(eval("is-bracketed([a b])"), ShouldEqual, "true")
+
+		So(eval("nth(a b c, 0)"), ShouldEqual, "stdin:2:8: $n: List index may not be 0.")
+		So(eval("nth(a b c, 4)"), ShouldEqual, "stdin:2:8: $n: Invalid index 4 for a list with 3 elements.")
+	})
+
+	Convey("building lists", t, func() {
+		So(eval("set-nth(a b c, 2, x)"), ShouldEqual, "a x c")
+		So(eval("join(a b, c d)"), ShouldEqual, "a b c d")
+		So(eval("join(a, (b, c))"), ShouldEqual, "a, b, c")
+		So(eval("join([a], b, $separator: comma)"), ShouldEqual, "[a, b]")
+		So(eval("join(a, b, $bracketed: true)"), ShouldEqual, "[a b]")
+		So(eval("append((a, b), c)"), ShouldEqual, "a, b, c")
+		So(eval("append(a, b, $separator: slash)"), ShouldEqual, "a/b")
+		So(eval("zip(1px 2px 3px, solid dashed)"), ShouldEqual, "1px solid, 2px dashed")
+		So(eval("list.slash(1px, 2px)"), ShouldEqual, "1px/2px")
+		So(eval("append(a, b, $separator: x)"), ShouldEqual,
+			"stdin:2:8: $separator: Must be \"space\", \"comma\", \"slash\", or \"auto\".")
+	})
+
+	Convey("lists are values", t, func() {
+		css, err := CompileString("$l: a b; $m: set-nth($l, 1, x); a { b: $l; c: $m; }", Options{})
+		So(err, ShouldBeNil)
+		So(css.CSS, ShouldEqual, "a {\n  b: a b;\n  c: x b;\n}")
+	})
+}
@@ -0,0 +1,94 @@
+package scss
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Logger receives the messages of @debug and @warn rules, and warnings about
+// deprecated features.
+type Logger interface {
+	Debug(loc Location, message string)
+	Warn(w *Warning)
+}
+
+// Frame is an entry of a stack trace: a location, and the member it's in,
+// such as "mixin-name()", "@content" or "root stylesheet".
+type Frame struct {
+	Location
+	Member string
+}
+
+// stackFrame is a mixin, function or content block being executed, and the
+// location it was called from.
+type stackFrame struct {
+	member string
+	call   Location
+}
+
+// trace returns the stack trace at loc, innermost frame first.
+func (e *evaluator) trace(loc Location) []Frame {
+	frames := make([]Frame, 0, len(e.stack)+1)
+	for i := len(e.stack) - 1; i >= 0; i-- {
+		frames = append(frames, Frame{loc, e.stack[i].member})
+		loc = e.stack[i].call
+	}
+	return append(frames, Frame{loc, "root stylesheet"})
+}
+
+// formatTrace formats frames one per line, with their members aligned.
+func formatTrace(frames []Frame) string {
+	locs := make([]string, len(frames))
+	width := 0
+	for i, f := range frames {
+		locs[i] = fmt.Sprintf("%s %d:%d", f.File, f.Line, f.Column)
+		if len(locs[i]) > width {
+			width = len(locs[i])
+		}
+	}
+	var buf strings.Builder
+	for i, f := range frames {
+		fmt.Fprintf(&buf, "%-*s  %s\n", width, locs[i], f.Member)
+	}
+	return buf.String()
+}
+
+type writerLogger struct {
+	w io.Writer
+}
+
+// NewLogger returns a Logger that writes messages to w in the format used by
+// dart-sass. The default logger writes to standard error.
+func NewLogger(w io.Writer) Logger {
+	return &writerLogger{w}
+}
+
+func (l *writerLogger) Debug(loc Location, message string) {
+	fmt.Fprintf(l.w, "%s:%d DEBUG: %s\n", loc.File, loc.Line, message)
+}
+
+func (l *writerLogger) Warn(w *Warning) {
+	if w.Deprecation {
+		io.WriteString(l.w, "DEPRECATION ")
+	}
+	fmt.Fprintf(l.w, "WARNING: %s\n", w.Message)
+	for _, line := range strings.SplitAfter(formatTrace(w.Trace), "\n") {
+		if line != "" {
+			io.WriteString(l.w, "    "+line)
+		}
+	}
+	io.WriteString(l.w, "\n")
+}
+
+type silentLogger struct{}
+
+func (silentLogger) Debug(Location, string) {}
+func (silentLogger) Warn(*Warning)          {}
+
+// SilentLogger discards all messages. Warnings are still collected in the
+// Result.
+var SilentLogger Logger = silentLogger{}
+
+var defaultLogger = NewLogger(os.Stderr)
@@ -0,0 +1,100 @@
+package scss
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type recordingLogger struct {
+	debugs   []string
+	warnings []*Warning
+}
+
+func (l *recordingLogger) Debug(loc Location, message string) {
+	l.debugs = append(l.debugs, loc.String()+" "+message)
+}
+
+func (l *recordingLogger) Warn(w *Warning) { l.warnings = append(l.warnings, w) }
+
+// debugOutput compiles src with the built-in module loaded, returning the
+// messages it logs with @debug, one per line, or the error it fails with.
+func debugOutput(module, src string) string {
+	l := &recordingLogger{}
+	_, err := CompileString("@use \"sass:"+module+"\";\n"+src, Options{Logger: l})
+	if err != nil {
+		return err.Error()
+	}
+	var messages []string
+	for _, d := range l.debugs {
+		messages = append(messages, d[strings.Index(d, " ")+1:])
+	}
+	return strings.Join(messages, "\n")
+}
+
+// moduleEval returns a function that evaluates an expression with the
+// built-in module loaded, after the statements in prelude, returning the
+// result as @debug shows it.
+func moduleEval(module, prelude string) func(expr string) string {
+	return func(expr string) string {
+		return debugOutput(module, prelude+"@debug "+expr+";")
+	}
+}
+
+func TestLogger(t *testing.T) {
+	src := `@mixin check($x) {
+  @if $x < 0 { @warn "negative: #{$x}"; }
+  @debug $x * 2;
+}
+@function f($x) {
+  @if $x == 0 { @error "zero isn't allowed"; }
+  @return $x;
+}
+a {
+  @include check(-1px);
+  b: f(1);
+}
+`
+
+	Convey("@debug and @warn", t, func() {
+		l := &recordingLogger{}
+		result, err := CompileString(src, Options{Logger: l})
+		So(err, ShouldBeNil)
+		So(result.CSS, ShouldEqual, "a {\n  b: 1;\n}")
+		So(l.debugs, ShouldResemble, []string{"stdin:3:3 -2px"})
+		So(len(l.warnings), ShouldEqual, 1)
+		w := l.warnings[0]
+		So(w.Message, ShouldEqual, "negative: -1px")
+		So(w.Deprecation, ShouldBeFalse)
+		So(w.Trace, ShouldResemble, []Frame{
+			{Location{"stdin", w.Position}, "check()"},
+			{Location{"stdin", w.Trace[1].Position}, "root stylesheet"},
+		})
+		So(w.Trace[1].Line, ShouldEqual, 10)
+		So(result.Warnings, ShouldResemble, l.warnings)
+	})
+
+	Convey("@error", t, func() {
+		_, err := CompileString(src+"c { d: f(0); }", Options{Logger: SilentLogger})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "stdin:6:17: \"zero isn't allowed\"")
+	})
+
+	Convey("default format", t, func() {
+		var buf bytes.Buffer
+		_, err := CompileString(src, Options{Logger: NewLogger(&buf)})
+		So(err, ShouldBeNil)
+		So(buf.String(), ShouldEqual, "WARNING: negative: -1px\n"+
+			"    stdin 2:16  check()\n"+
+			"    stdin 10:3  root stylesheet\n"+
+			"\n"+
+			"stdin:3 DEBUG: -2px\n")
+
+		buf.Reset()
+		_, err = CompileString("$x: 1; a { b: $x / 2; }", Options{Logger: NewLogger(&buf)})
+		So(err, ShouldBeNil)
+		So(buf.String(), ShouldStartWith, "DEPRECATION WARNING: Using / for division is deprecated.")
+	})
+}
@@ -0,0 +1,235 @@
+package scss
+
+import (
+	"fmt"
+)
+
+// copy returns a copy of m that can be changed without affecting m, so that
+// map functions leave their arguments as they were.
+func (m *Map) copy() *Map {
+	return &Map{Keys: append([]Value(nil), m.Keys...), Values: append([]Value(nil), m.Values...)}
+}
+
+// remove returns m without key.
+func (m *Map) remove(key Value) *Map {
+	i := m.index(key)
+	if i < 0 {
+		return m
+	}
+	result := m.copy()
+	result.Keys = append(result.Keys[:i], result.Keys[i+1:]...)
+	result.Values = append(result.Values[:i], result.Values[i+1:]...)
+	return result
+}
+
+// nestedValue returns the value in m found by following keys through
+// nested maps.
+func nestedValue(m *Map, keys []Value) (Value, bool) {
+	var v Value = m
+	for _, key := range keys {
+		nested, ok := v.(*Map)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = nested.Get(key); !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// modifyNested returns a copy of m with the value found by following keys
+// through nested maps replaced by the result of modify. If nest is true,
+// missing or non-map values along the way become empty maps; otherwise m is
+// returned unchanged.
+func modifyNested(m *Map, keys []Value, nest bool, modify func(Value) (Value, error)) (Value, error) {
+	if len(keys) == 0 {
+		return modify(m)
+	}
+	v, ok := m.Get(keys[0])
+	if len(keys) == 1 {
+		if !ok {
+			v = Null
+		}
+		v, err := modify(v)
+		if err != nil {
+			return nil, err
+		}
+		result := m.copy()
+		result.Set(keys[0], v)
+		return result, nil
+	}
+	nested, isMap := v.(*Map)
+	if !isMap {
+		if !nest {
+			return m, nil
+		}
+		nested = NewMap()
+	}
+	v, err := modifyNested(nested, keys[1:], nest, modify)
+	if err != nil {
+		return nil, err
+	}
+	result := m.copy()
+	result.Set(keys[0], v)
+	return result, nil
+}
+
+func mergeMaps(m1, m2 *Map) *Map {
+	result := m1.copy()
+	for i, key := range m2.Keys {
+		result.Set(key, m2.Values[i])
+	}
+	return result
+}
+
+// deepMergeMaps merges m2 into m1, merging the values of keys that are maps
+// in both rather than replacing them.
+func deepMergeMaps(m1, m2 *Map) *Map {
+	result := m1.copy()
+	for i, key := range m2.Keys {
+		v := m2.Values[i]
+		if old, ok := result.Get(key); ok {
+			oldMap, ok1 := old.(*Map)
+			newMap, ok2 := v.(*Map)
+			if ok1 && ok2 {
+				v = deepMergeMaps(oldMap, newMap)
+			}
+		}
+		result.Set(key, v)
+	}
+	return result
+}
+
+// keysAndValue splits the rest arguments of map.merge() and map.set() into
+// the keys leading to a nested map and the value at the end.
+func keysAndValue(args []Value, want string) ([]Value, Value, error) {
+	rest := args[0].(*ArgList).Items
+	if len(rest) == 0 {
+		return nil, nil, fmt.Errorf("Expected $args to contain a key.")
+	}
+	if len(rest) == 1 {
+		return nil, nil, fmt.Errorf("Expected $args to contain a %s.", want)
+	}
+	return rest[:len(rest)-1], rest[len(rest)-1], nil
+}
+
+func init() {
+	m := builtinModule("map")
+	m.define("get($map, $key, $keys...)", func(args []Value) (Value, error) {
+		mp, err := ExpectMap(args[0], "map")
+		if err != nil {
+			return nil, err
+		}
+		keys := append([]Value{args[1]}, args[2].(*ArgList).Items...)
+		if v, ok := nestedValue(mp, keys); ok {
+			return v, nil
+		}
+		return Null, nil
+	}, "map-get")
+
+	m.define("has-key($map, $key, $keys...)", func(args []Value) (Value, error) {
+		mp, err := ExpectMap(args[0], "map")
+		if err != nil {
+			return nil, err
+		}
+		keys := append([]Value{args[1]}, args[2].(*ArgList).Items...)
+		_, ok := nestedValue(mp, keys)
+		return Bool(ok), nil
+	}, "map-has-key")
+
+	m.define("merge($map1, $args...)", func(args []Value) (Value, error) {
+		m1, err := ExpectMap(args[0], "map1")
+		if err != nil {
+			return nil, err
+		}
+		rest := args[1].(*ArgList).Items
+		if len(rest) == 1 {
+			m2, err := ExpectMap(rest[0], "map2")
+			if err != nil {
+				return nil, err
+			}
+			return mergeMaps(m1, m2), nil
+		}
+		keys, last, err := keysAndValue(args[1:], "map")
+		if err != nil {
+			return nil, err
+		}
+		m2, err := ExpectMap(last, "map2")
+		if err != nil {
+			return nil, err
+		}
+		return modifyNested(m1, keys, true, func(v Value) (Value, error) {
+			if nested, ok := v.(*Map); ok {
+				return mergeMaps(nested, m2), nil
+			}
+			return m2, nil
+		})
+	}, "map-merge")
+
+	m.define("deep-merge($map1, $map2)", func(args []Value) (Value, error) {
+		m1, err := ExpectMap(args[0], "map1")
+		if err != nil {
+			return nil, err
+		}
+		m2, err := ExpectMap(args[1], "map2")
+		if err != nil {
+			return nil, err
+		}
+		return deepMergeMaps(m1, m2), nil
+	})
+
+	m.define("remove($map, $keys...)", func(args []Value) (Value, error) {
+		mp, err := ExpectMap(args[0], "map")
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range args[1].(*ArgList).Items {
+			mp = mp.remove(key)
+		}
+		return mp, nil
+	}, "map-remove")
+
+	m.define("deep-remove($map, $key, $keys...)", func(args []Value) (Value, error) {
+		mp, err := ExpectMap(args[0], "map")
+		if err != nil {
+			return nil, err
+		}
+		keys := append([]Value{args[1]}, args[2].(*ArgList).Items...)
+		last := keys[len(keys)-1]
+		return modifyNested(mp, keys[:len(keys)-1], false, func(v Value) (Value, error) {
+			if nested, ok := v.(*Map); ok {
+				return nested.remove(last), nil
+			}
+			return v, nil
+		})
+	})
+
+	m.define("set($map, $args...)", func(args []Value) (Value, error) {
+		mp, err := ExpectMap(args[0], "map")
+		if err != nil {
+			return nil, err
+		}
+		keys, value, err := keysAndValue(args[1:], "value")
+		if err != nil {
+			return nil, err
+		}
+		return modifyNested(mp, keys, true, func(Value) (Value, error) { return value, nil })
+	})
+
+	m.define("keys($map)", func(args []Value) (Value, error) {
+		mp, err := ExpectMap(args[0], "map")
+		if err != nil {
+			return nil, err
+		}
+		return NewList(append([]Value(nil), mp.Keys...), CommaSeparator), nil
+	}, "map-keys")
+
+	m.define("values($map)", func(args []Value) (Value, error) {
+		mp, err := ExpectMap(args[0], "map")
+		if err != nil {
+			return nil, err
+		}
+		return NewList(append([]Value(nil), mp.Values...), CommaSeparator), nil
+	}, "map-values")
+}
@@ -0,0 +1,46 @@
+package scss
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMapFunctions(t *testing.T) {
+	eval := moduleEval("map", "$theme: (colors: (primary: blue, secondary: green), size: 1px);\n")
+
+	Convey("reading maps", t, func() {
+		So(eval("map-get($theme, size)"), ShouldEqual, "1px")
+		So(eval("map.get($theme, colors, primary)"), ShouldEqual, "blue")
+		So(eval("map.get($theme, colors, tertiary)"), ShouldEqual, "null")
+		So(eval("map.get($theme, size, x)"), ShouldEqual, "null")
+		So(eval("map.has-key($theme, colors, secondary)"), ShouldEqual, "true")
+		So(eval("map-has-key($theme, color)"), ShouldEqual, "false")
+		So(eval("map-keys($theme)"), ShouldEqual, "colors, size")
+		So(eval("map.values((a: 1, b: 2))"), ShouldEqual, "1, 2")
+		So(eval("map.get(1px, a)"), ShouldEqual, "stdin:3:8: $map: 1px is not a map.")
+	})
+
+	Convey("modifying maps", t, func() {
+		So(eval("map-merge((a: 1, b: 2), (b: 3, c: 4))"), ShouldEqual, "(a: 1, b: 3, c: 4)")
+		So(eval("map.merge($theme, colors, (primary: red))"), ShouldEqual,
+			"(colors: (primary: red, secondary: green), size: 1px)")
+		So(eval("map.merge((a: 1), b, c, (d: 2))"), ShouldEqual, "(a: 1, b: (c: (d: 2)))")
+		So(eval("map.deep-merge($theme, (colors: (secondary: red), size: 2px))"), ShouldEqual,
+			"(colors: (primary: blue, secondary: red), size: 2px)")
+		So(eval("map-remove((a: 1, b: 2, c: 3), a, c, d)"), ShouldEqual, "(b: 2)")
+		So(eval("map.deep-remove($theme, colors, primary)"), ShouldEqual, "(colors: (secondary: green), size: 1px)")
+		So(eval("map.deep-remove($theme, size, x)"), ShouldEqual, "(colors: (primary: blue, secondary: green), size: 1px)")
+		So(eval("map.set($theme, colors, primary, red)"), ShouldEqual,
+			"(colors: (primary: red, secondary: green), size: 1px)")
+		So(eval("map.set((), a, b, 1)"), ShouldEqual, "(a: (b: 1))")
+		So(eval("map.set((), a)"), ShouldEqual, "stdin:3:8: Expected $args to contain a value.")
+		So(eval("map.merge(())"), ShouldEqual, "stdin:3:8: Expected $args to contain a key.")
+	})
+
+	Convey("maps are values", t, func() {
+		css, err := CompileString("@use \"sass:map\"; $m: (a: 1); $n: map.set($m, a, 2); a { b: map.get($m, a); c: map.get($n, a); }", Options{})
+		So(err, ShouldBeNil)
+		So(css.CSS, ShouldEqual, "a {\n  b: 1;\n  c: 2;\n}")
+	})
+}
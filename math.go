@@ -0,0 +1,308 @@
+package scss
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// fuzzyRound rounds v to the nearest integer, rounding halves up even when
+// they're off by less than epsilon.
+func fuzzyRound(v float64) float64 {
+	if v > 0 {
+		return math.Floor(v + 0.5 + epsilon)
+	}
+	return math.Ceil(v - 0.5 - epsilon)
+}
+
+// withValue returns a number with n's units and the value v.
+func (n *Number) withValue(v float64) *Number {
+	return &Number{Value: v, Numerators: n.Numerators, Denominators: n.Denominators}
+}
+
+// expectUnitless returns the value of the number v, which can't have units.
+func expectUnitless(v Value, name string) (float64, error) {
+	n, err := ExpectNumber(v, name)
+	if err != nil {
+		return 0, err
+	}
+	if !n.Unitless() {
+		return 0, fmt.Errorf("$%s: Expected %s to have no units.", name, n)
+	}
+	return n.Value, nil
+}
+
+// expectAngle returns the number v in radians. Unitless numbers are radians.
+func expectAngle(v Value, name string) (float64, error) {
+	n, err := ExpectNumber(v, name)
+	if err != nil {
+		return 0, err
+	}
+	if n.Unitless() {
+		return n.Value, nil
+	}
+	rad, err := n.ConvertTo([]string{"rad"}, nil)
+	if err != nil {
+		return 0, fmt.Errorf("$%s: Expected %s to be an angle.", name, n)
+	}
+	return rad.Value, nil
+}
+
+// compatibleNumbers returns the values of numbers, all converted to the
+// units of the first. Either all or none of them must have units.
+func compatibleNumbers(numbers []Value) ([]float64, error) {
+	values := make([]float64, len(numbers))
+	var first *Number
+	for i, v := range numbers {
+		n, err := ExpectNumber(v, "")
+		if err != nil {
+			return nil, err
+		}
+		if first == nil {
+			first = n
+		} else if n.Unitless() != first.Unitless() {
+			if n.Unitless() {
+				return nil, fmt.Errorf("Argument %d is unitless but argument 1 has unit %s. Arguments must all have units or all be unitless.", i+1, first.Unit())
+			}
+			return nil, fmt.Errorf("Argument 1 is unitless but argument %d has unit %s. Arguments must all have units or all be unitless.", i+1, n.Unit())
+		}
+		if values[i], err = n.convert(first.Numerators, first.Denominators); err != nil {
+			return nil, fmt.Errorf("Incompatible units %s and %s.", first.Unit(), n.Unit())
+		}
+	}
+	return values, nil
+}
+
+// rounding returns a function that applies round to its argument, keeping
+// its units.
+func rounding(round func(float64) float64) Function {
+	return func(args []Value) (Value, error) {
+		n, err := ExpectNumber(args[0], "number")
+		if err != nil {
+			return nil, err
+		}
+		return n.withValue(round(n.Value)), nil
+	}
+}
+
+// extremum returns min() or max(): the first of its arguments for which
+// better reports true against every other.
+func extremum(better func(a, b float64) bool) Function {
+	return func(args []Value) (Value, error) {
+		numbers := args[0].(*ArgList).Items
+		if len(numbers) == 0 {
+			return nil, fmt.Errorf("At least one argument must be passed.")
+		}
+		var result *Number
+		for _, v := range numbers {
+			n, err := ExpectNumber(v, "")
+			if err != nil {
+				return nil, err
+			}
+			if result == nil {
+				result = n
+				continue
+			}
+			value := n.Value
+			if !n.Unitless() && !result.Unitless() {
+				if value, err = n.convert(result.Numerators, result.Denominators); err != nil {
+					return nil, fmt.Errorf("Incompatible units %s and %s.", result.Unit(), n.Unit())
+				}
+			}
+			if better(value, result.Value) {
+				result = n
+			}
+		}
+		return result.withoutSlash(), nil
+	}
+}
+
+// cssExtremum returns the global min() or max(), which are output as the
+// CSS functions of the same name when their arguments can't be compared
+// until the browser resolves them, as in "min(100%, 500px)".
+func cssExtremum(name string, fn Function) Function {
+	return func(args []Value) (Value, error) {
+		v, err := fn(args)
+		if err != nil {
+			for _, arg := range args[0].(*ArgList).Items {
+				if _, ok := arg.(*Number); !ok && !isSpecialArg(arg) {
+					return nil, err
+				}
+			}
+			return plainCall(name, args[0].(*ArgList).Items), nil
+		}
+		return v, nil
+	}
+}
+
+// unitlessMath returns a function that applies f to a unitless number.
+func unitlessMath(f func(float64) float64) Function {
+	return func(args []Value) (Value, error) {
+		v, err := expectUnitless(args[0], "number")
+		if err != nil {
+			return nil, err
+		}
+		return NewNumber(f(v), ""), nil
+	}
+}
+
+func trig(f func(float64) float64) Function {
+	return func(args []Value) (Value, error) {
+		v, err := expectAngle(args[0], "number")
+		if err != nil {
+			return nil, err
+		}
+		return NewNumber(f(v), ""), nil
+	}
+}
+
+func inverseTrig(f func(float64) float64) Function {
+	return func(args []Value) (Value, error) {
+		v, err := expectUnitless(args[0], "number")
+		if err != nil {
+			return nil, err
+		}
+		return NewNumber(f(v)*180/math.Pi, "deg"), nil
+	}
+}
+
+func init() {
+	m := builtinModule("math")
+	m.global.vars["pi"] = NewNumber(math.Pi, "")
+	m.global.vars["e"] = NewNumber(math.E, "")
+
+	m.define("div($number1, $number2)", func(args []Value) (Value, error) {
+		return binaryOperation("/", args[0], args[1])
+	})
+	m.define("percentage($number)", func(args []Value) (Value, error) {
+		v, err := expectUnitless(args[0], "number")
+		if err != nil {
+			return nil, err
+		}
+		return NewNumber(v*100, "%"), nil
+	}, "percentage")
+	m.define("round($number)", rounding(fuzzyRound), "round")
+	m.define("ceil($number)", rounding(math.Ceil), "ceil")
+	m.define("floor($number)", rounding(math.Floor), "floor")
+	m.define("abs($number)", rounding(math.Abs), "abs")
+	min := extremum(func(a, b float64) bool { return a < b && !fuzzyEqual(a, b) })
+	max := extremum(func(a, b float64) bool { return a > b && !fuzzyEqual(a, b) })
+	m.define("min($numbers...)", min)
+	m.define("max($numbers...)", max)
+	defineGlobal("min($numbers...)", cssExtremum("min", min))
+	defineGlobal("max($numbers...)", cssExtremum("max", max))
+
+	m.define("clamp($min, $number, $max)", func(args []Value) (Value, error) {
+		values, err := compatibleNumbers(args)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case values[1] < values[0]:
+			return args[0].(*Number).withoutSlash(), nil
+		case values[1] > values[2]:
+			return args[2].(*Number).withoutSlash(), nil
+		}
+		return args[1].(*Number).withoutSlash(), nil
+	})
+	m.define("hypot($numbers...)", func(args []Value) (Value, error) {
+		numbers := args[0].(*ArgList).Items
+		if len(numbers) == 0 {
+			return nil, fmt.Errorf("At least one argument must be passed.")
+		}
+		values, err := compatibleNumbers(numbers)
+		if err != nil {
+			return nil, err
+		}
+		sum := 0.0
+		for _, v := range values {
+			sum += v * v
+		}
+		return numbers[0].(*Number).withValue(math.Sqrt(sum)), nil
+	})
+
+	m.define("sqrt($number)", unitlessMath(math.Sqrt))
+	m.define("pow($base, $exponent)", func(args []Value) (Value, error) {
+		base, err := expectUnitless(args[0], "base")
+		if err != nil {
+			return nil, err
+		}
+		exponent, err := expectUnitless(args[1], "exponent")
+		if err != nil {
+			return nil, err
+		}
+		return NewNumber(math.Pow(base, exponent), ""), nil
+	})
+	m.define("log($number, $base: null)", func(args []Value) (Value, error) {
+		v, err := expectUnitless(args[0], "number")
+		if err != nil {
+			return nil, err
+		}
+		if args[1] == Null {
+			return NewNumber(math.Log(v), ""), nil
+		}
+		base, err := expectUnitless(args[1], "base")
+		if err != nil {
+			return nil, err
+		}
+		return NewNumber(math.Log(v)/math.Log(base), ""), nil
+	})
+
+	m.define("sin($number)", trig(math.Sin))
+	m.define("cos($number)", trig(math.Cos))
+	m.define("tan($number)", trig(math.Tan))
+	m.define("asin($number)", inverseTrig(math.Asin))
+	m.define("acos($number)", inverseTrig(math.Acos))
+	m.define("atan($number)", inverseTrig(math.Atan))
+	m.define("atan2($y, $x)", func(args []Value) (Value, error) {
+		values, err := compatibleNumbers(args)
+		if err != nil {
+			return nil, err
+		}
+		return NewNumber(math.Atan2(values[0], values[1])*180/math.Pi, "deg"), nil
+	})
+
+	m.define("random($limit: null)", func(args []Value) (Value, error) {
+		if args[0] == Null {
+			return NewNumber(rand.Float64(), ""), nil
+		}
+		limit, err := ExpectInt(args[0], "limit")
+		if err != nil {
+			return nil, err
+		}
+		if limit < 1 {
+			return nil, fmt.Errorf("$limit: Must be greater than 0, was %d.", limit)
+		}
+		return NewNumber(float64(rand.Intn(limit)+1), ""), nil
+	}, "random")
+
+	m.define("unit($number)", func(args []Value) (Value, error) {
+		n, err := ExpectNumber(args[0], "number")
+		if err != nil {
+			return nil, err
+		}
+		return NewString(n.Unit()), nil
+	}, "unit")
+	m.define("is-unitless($number)", func(args []Value) (Value, error) {
+		n, err := ExpectNumber(args[0], "number")
+		if err != nil {
+			return nil, err
+		}
+		return Bool(n.Unitless()), nil
+	}, "unitless")
+	m.define("compatible($number1, $number2)", func(args []Value) (Value, error) {
+		n1, err := ExpectNumber(args[0], "number1")
+		if err != nil {
+			return nil, err
+		}
+		n2, err := ExpectNumber(args[1], "number2")
+		if err != nil {
+			return nil, err
+		}
+		if n1.Unitless() || n2.Unitless() {
+			return Bool(true), nil
+		}
+		_, err = n2.convert(n1.Numerators, n1.Denominators)
+		return Bool(err == nil), nil
+	}, "comparable")
+}
@@ -0,0 +1,66 @@
+package scss
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMathFunctions(t *testing.T) {
+	eval := func(expr string) string {
+		result, err := CompileString("@use \"sass:math\";\na { b: "+expr+"; }", Options{})
+		if err != nil {
+			return err.Error()
+		}
+		return result.CSS[len("a {\n  b: ") : len(result.CSS)-len(";\n}")]
+	}
+
+	Convey("arithmetic", t, func() {
+		So(eval("math.div(10px, 4)"), ShouldEqual, "2.5px")
+		So(eval("math.div(1in, 2px)"), ShouldEqual, "48")
+		So(eval("percentage(0.25)"), ShouldEqual, "25%")
+		So(eval("math.round(2.5px)"), ShouldEqual, "3px")
+		So(eval("round(-2.5)"), ShouldEqual, "-3")
+		So(eval("ceil(1.2em)"), ShouldEqual, "2em")
+		So(eval("math.floor(1.8)"), ShouldEqual, "1")
+		So(eval("abs(-3px)"), ShouldEqual, "3px")
+		So(eval("math.sqrt(16)"), ShouldEqual, "4")
+		So(eval("math.pow(2, 10)"), ShouldEqual, "1024")
+		So(eval("math.log(100, 10)"), ShouldEqual, "2")
+		So(eval("math.hypot(3px, 4px)"), ShouldEqual, "5px")
+		So(eval("math.hypot(1in, 48px)"), ShouldEqual, "1.1180339887in")
+	})
+
+	Convey("comparison", t, func() {
+		So(eval("math.min(1in, 50px, 2in)"), ShouldEqual, "50px")
+		So(eval("max(1, 5, 3)"), ShouldEqual, "5")
+		So(eval("max(100%, 500px)"), ShouldEqual, "max(100%, 500px)")
+		So(eval("math.max(100%, 500px)"), ShouldEqual, "stdin:2:8: Incompatible units % and px.")
+		So(eval("math.clamp(1px, 5px, 3px)"), ShouldEqual, "3px")
+		So(eval("math.clamp(1, 5px, 3px)"), ShouldEqual,
+			"stdin:2:8: Argument 1 is unitless but argument 2 has unit px. Arguments must all have units or all be unitless.")
+	})
+
+	Convey("trigonometry", t, func() {
+		So(eval("math.sin(90deg)"), ShouldEqual, "1")
+		So(eval("math.cos(math.$pi)"), ShouldEqual, "-1")
+		So(eval("math.tan(0.5turn)"), ShouldEqual, "0")
+		So(eval("math.asin(1)"), ShouldEqual, "90deg")
+		So(eval("math.atan2(1px, -1px)"), ShouldEqual, "135deg")
+		So(eval("math.sin(1px)"), ShouldEqual, "stdin:2:8: $number: Expected 1px to be an angle.")
+	})
+
+	Convey("units", t, func() {
+		So(eval("unit(math.div(1px * 1px, 1s))"), ShouldEqual, "\"px*px/s\"")
+		So(eval("unitless(1)"), ShouldEqual, "true")
+		So(eval("math.compatible(1in, 1cm)"), ShouldEqual, "true")
+		So(eval("comparable(1px, 1s)"), ShouldEqual, "false")
+		So(eval("math.percentage(1px)"), ShouldEqual, "stdin:2:8: $number: Expected 1px to have no units.")
+		So(eval("math.$e"), ShouldEqual, "2.7182818285")
+	})
+
+	Convey("random", t, func() {
+		So(eval("math.random(1)"), ShouldEqual, "1")
+		So(eval("math.random(0)"), ShouldEqual, "stdin:2:8: $limit: Must be greater than 0, was 0.")
+	})
+}
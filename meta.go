@@ -0,0 +1,259 @@
+package scss
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// features are the language features feature-exists() reports.
+var features = map[string]bool{
+	"global-variable-shadowing":   true,
+	"extend-selector-pseudoclass": true,
+	"units-level-3":               true,
+	"at-error":                    true,
+	"custom-property":             true,
+}
+
+// memberArgs returns the name and the optional module namespace passed to
+// the functions that check whether a member exists.
+func memberArgs(args []Value) (name, namespace string, err error) {
+	s, err := ExpectString(args[0], "name")
+	if err != nil {
+		return "", "", err
+	}
+	if args[1] != Null {
+		ns, err := ExpectString(args[1], "module")
+		if err != nil {
+			return "", "", err
+		}
+		namespace = ns.Text
+	}
+	return normName(s.Text), namespace, nil
+}
+
+// loadCSS evaluates the stylesheet at url as a new module configured by
+// with, adding its CSS where meta.load-css() was included. The module is
+// evaluated each time, so its CSS appears at every inclusion.
+func (e *evaluator) loadCSS(url string, with *Map, loc Location) error {
+	config := make(map[string]*configValue)
+	for i, key := range with.Keys {
+		name, err := ExpectString(key, "with")
+		if err != nil {
+			return err
+		}
+		config[normName(strings.TrimPrefix(name.Text, "$"))] = &configValue{value: with.Values[i], loc: loc}
+	}
+	if strings.HasPrefix(url, "sass:") {
+		e.loadModule(url, config, loc)
+		return nil
+	}
+	f := e.resolve(url, loc.File, loc)
+	if e.loading[f.name] {
+		return fmt.Errorf("Module loop: this module is already being loaded.")
+	}
+	sheet := e.parseFile(f)
+	e.loading[f.name] = true
+	defer delete(e.loading, f.name)
+
+	saved := e.evalState
+	e.env = newEnvironment(newModule(f.name), config)
+	e.content = nil
+	e.inFunction = false
+	e.inMixin = false
+	e.execStmts(sheet.stmts)
+	e.evalState = saved
+	for name, v := range config {
+		if !v.used {
+			return fmt.Errorf("$%s was not declared with !default in the @used module.", name)
+		}
+	}
+	return nil
+}
+
+// callFunctionValue calls f with the arguments in args, for meta.call().
+func (e *evaluator) callFunctionValue(f *FunctionValue, args *ArgList, loc Location) (Value, error) {
+	evaluated := &evaluatedArgs{positional: args.Items, named: make(map[string]Value), separator: args.Separator}
+	for i, key := range args.Keywords.Keys {
+		name := key.(*String).Text
+		evaluated.names = append(evaluated.names, name)
+		evaluated.named[name] = args.Keywords.Values[i]
+	}
+	if f.callable != nil {
+		return e.callFunction(f.callable, evaluated, loc), nil
+	}
+	if len(evaluated.named) > 0 {
+		return nil, fmt.Errorf("Plain CSS functions don't support keyword arguments.")
+	}
+	parts := make([]string, len(args.Items))
+	for i, arg := range args.Items {
+		parts[i] = e.serialize(arg, loc)
+	}
+	return &String{Text: f.Name + "(" + strings.Join(parts, separatorText(CommaSeparator, e.compressed)) + ")"}, nil
+}
+
+func sortedNames[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	m := builtinModule("meta")
+	m.defineMixin("load-css($url, $with: null)", func(e *evaluator, args []Value, loc Location) (Value, error) {
+		url, err := ExpectString(args[0], "url")
+		if err != nil {
+			return nil, err
+		}
+		with := NewMap()
+		if args[1] != Null {
+			if with, err = ExpectMap(args[1], "with"); err != nil {
+				return nil, err
+			}
+		}
+		return nil, e.loadCSS(url.Text, with, loc)
+	})
+
+	m.define("type-of($value)", func(args []Value) (Value, error) {
+		return &String{Text: args[0].TypeName()}, nil
+	}, "type-of")
+	m.define("inspect($value)", func(args []Value) (Value, error) {
+		return &String{Text: args[0].String()}, nil
+	}, "inspect")
+	m.define("calc-name($calc)", func(args []Value) (Value, error) {
+		c, ok := args[0].(*Calculation)
+		if !ok {
+			return nil, argumentError("calc", args[0], "a calculation")
+		}
+		return &String{Text: c.Name, Quoted: true}, nil
+	})
+	m.define("calc-args($calc)", func(args []Value) (Value, error) {
+		c, ok := args[0].(*Calculation)
+		if !ok {
+			return nil, argumentError("calc", args[0], "a calculation")
+		}
+		return calcArgs(c), nil
+	})
+	m.define("feature-exists($feature)", func(args []Value) (Value, error) {
+		s, err := ExpectString(args[0], "feature")
+		if err != nil {
+			return nil, err
+		}
+		return Bool(features[s.Text]), nil
+	}, "feature-exists")
+	m.define("keywords($args)", func(args []Value) (Value, error) {
+		list, ok := args[0].(*ArgList)
+		if !ok {
+			return nil, argumentError("args", args[0], "an argument list")
+		}
+		return list.Keywords.copy(), nil
+	}, "keywords")
+
+	m.defineBuiltin("variable-exists($name)", func(e *evaluator, args []Value, loc Location) (Value, error) {
+		name, err := ExpectString(args[0], "name")
+		if err != nil {
+			return nil, err
+		}
+		_, ok := e.findVariable(normName(name.Text), e.env.scope)
+		return Bool(ok), nil
+	}, "variable-exists")
+	m.defineBuiltin("global-variable-exists($name, $module: null)", func(e *evaluator, args []Value, loc Location) (Value, error) {
+		name, namespace, err := memberArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		if namespace != "" {
+			_, ok := e.namespace(namespace, loc).variable(name)
+			return Bool(ok), nil
+		}
+		_, ok := e.findVariable(name, e.env.module.global)
+		return Bool(ok), nil
+	}, "global-variable-exists")
+	m.defineBuiltin("function-exists($name, $module: null)", func(e *evaluator, args []Value, loc Location) (Value, error) {
+		name, namespace, err := memberArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		if namespace != "" {
+			_, ok := e.namespace(namespace, loc).function(name)
+			return Bool(ok), nil
+		}
+		return Bool(e.lookupFunction("", name, loc) != nil), nil
+	}, "function-exists")
+	m.defineBuiltin("mixin-exists($name, $module: null)", func(e *evaluator, args []Value, loc Location) (Value, error) {
+		name, namespace, err := memberArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		return Bool(e.findMixin(namespace, name, loc) != nil), nil
+	}, "mixin-exists")
+	m.defineBuiltin("content-exists()", func(e *evaluator, args []Value, loc Location) (Value, error) {
+		if !e.inMixin {
+			return nil, fmt.Errorf("content-exists() may only be called within a mixin.")
+		}
+		return Bool(e.content != nil), nil
+	}, "content-exists")
+
+	m.defineBuiltin("module-variables($module)", func(e *evaluator, args []Value, loc Location) (Value, error) {
+		namespace, err := ExpectString(args[0], "module")
+		if err != nil {
+			return nil, err
+		}
+		vars := e.namespace(namespace.Text, loc).variables()
+		result := NewMap()
+		for _, name := range sortedNames(vars) {
+			result.Set(NewString(name), vars[name])
+		}
+		return result, nil
+	})
+	m.defineBuiltin("module-functions($module)", func(e *evaluator, args []Value, loc Location) (Value, error) {
+		namespace, err := ExpectString(args[0], "module")
+		if err != nil {
+			return nil, err
+		}
+		funcs := e.namespace(namespace.Text, loc).functions()
+		result := NewMap()
+		for _, name := range sortedNames(funcs) {
+			result.Set(NewString(name), &FunctionValue{Name: name, callable: funcs[name]})
+		}
+		return result, nil
+	})
+
+	m.defineBuiltin("get-function($name, $css: false, $module: null)", func(e *evaluator, args []Value, loc Location) (Value, error) {
+		name, namespace, err := memberArgs([]Value{args[0], args[2]})
+		if err != nil {
+			return nil, err
+		}
+		if args[1].Truthy() {
+			if namespace != "" {
+				return nil, fmt.Errorf("$css and $module may not both be passed at once.")
+			}
+			return &FunctionValue{Name: name}, nil
+		}
+		var c *callable
+		if namespace != "" {
+			c, _ = e.namespace(namespace, loc).function(name)
+		} else {
+			c = e.lookupFunction("", name, loc)
+		}
+		if c == nil {
+			return nil, fmt.Errorf("Function not found: %s", name)
+		}
+		return &FunctionValue{Name: name, callable: c}, nil
+	}, "get-function")
+	m.defineBuiltin("call($function, $args...)", func(e *evaluator, args []Value, loc Location) (Value, error) {
+		f, ok := args[0].(*FunctionValue)
+		if !ok {
+			s, isString := args[0].(*String)
+			if !isString {
+				return nil, argumentError("function", args[0], "a function reference")
+			}
+			e.warn(loc, "Passing a string to call() is deprecated and will be illegal in Dart Sass 2.0.0.\n\nRecommendation: call(get-function(%s))", s)
+			f = &FunctionValue{Name: s.Text, callable: e.lookupFunction("", s.Text, loc)}
+		}
+		return e.callFunctionValue(f, args[1].(*ArgList), loc)
+	}, "call")
+}
@@ -0,0 +1,70 @@
+package scss
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMetaFunctions(t *testing.T) {
+	eval := func(src string) string { return debugOutput("meta", src) }
+
+	Convey("introspecting values", t, func() {
+		So(eval("@debug type-of(1px) type-of(a) type-of(red) type-of(a b) type-of((a: b)) type-of(true) type-of(null);"),
+			ShouldEqual, "number string color list map bool null")
+		So(eval("@debug meta.inspect(\"a\");"), ShouldEqual, `"a"`)
+		So(eval("@debug inspect((a: (1, 2)));"), ShouldEqual, "(a: (1, 2))")
+		So(eval("@debug feature-exists(at-error) feature-exists(nope);"), ShouldEqual, "true false")
+		So(eval("@mixin m($args...) { @debug keywords($args); } @include m(1, $a: 2, $b-c: 3);"), ShouldEqual, "(a: 2, b-c: 3)")
+		So(eval("@debug keywords(1);"), ShouldEqual, "stdin:2:8: $args: 1 is not an argument list.")
+	})
+
+	Convey("checking for members", t, func() {
+		So(eval("$g: 1; a { $l: 2; @debug variable-exists(l) variable-exists(g) global-variable-exists(l) global-variable-exists(g); }"),
+			ShouldEqual, "true true false true")
+		So(eval("@function f() { @return 1; } @debug function-exists(f) function-exists(lighten) function-exists(nope);"),
+			ShouldEqual, "true true false")
+		So(eval("@use \"sass:math\"; @debug function-exists(div, math) global-variable-exists(pi, $module: math);"),
+			ShouldEqual, "true true")
+		So(eval("@mixin m { @debug content-exists(); } @include m; @include m { a: b; }"), ShouldEqual, "false\ntrue")
+		So(eval("@mixin m {} @debug mixin-exists(m) mixin-exists(load-css, meta);"), ShouldEqual, "true true")
+		So(eval("@debug content-exists();"), ShouldEqual, "stdin:2:8: content-exists() may only be called within a mixin.")
+	})
+
+	Convey("functions as values", t, func() {
+		So(eval("@function double($x) { @return $x * 2; } $f: get-function(double); @debug type-of($f) call($f, 2px);"),
+			ShouldEqual, "function 4px")
+		So(eval("@use \"sass:math\"; @debug call(get-function(div, $module: math), $number2: 4, $number1: 2);"), ShouldEqual, "0.5")
+		So(eval("@debug call(get-function(lighten), #000, 50%);"), ShouldEqual, "gray")
+		So(eval("@debug call(get-function(translate, $css: true), 1px, 2px);"), ShouldEqual, "translate(1px, 2px)")
+		So(eval("@debug get-function(nope);"), ShouldEqual, "stdin:2:8: Function not found: nope")
+	})
+
+	Convey("module members", t, func() {
+		fs := mapFS{
+			"main.scss": `@use "sass:meta"; @use "lib"; @debug meta.module-variables(lib); @debug map-keys(meta.module-functions(lib));`,
+			"_lib.scss": `$b: 2; $a: 1; @function f() { @return 1; }`,
+		}
+		l := &recordingLogger{}
+		_, err := Compile(fs, "main.scss", Options{Logger: l})
+		So(err, ShouldBeNil)
+		So(l.debugs, ShouldResemble, []string{"main.scss:1:31 (\"a\": 1, \"b\": 2)", "main.scss:1:66 \"f\","})
+	})
+
+	Convey("load-css", t, func() {
+		fs := mapFS{
+			"main.scss":  `@use "sass:meta"; .theme { @include meta.load-css("theme", $with: (color: red)); } a { @include meta.load-css("theme"); }`,
+			"theme.scss": `$color: blue !default; b { color: $color; } & { c: d; }`,
+			"loop.scss":  `@use "sass:meta"; @include meta.load-css("loop");`,
+			"bad.scss":   `@use "sass:meta"; a { @include meta.load-css("theme", $with: (size: 1px)); }`,
+		}
+		result, err := Compile(fs, "main.scss", Options{})
+		So(err, ShouldBeNil)
+		So(result.CSS, ShouldEqual, ".theme b {\n  color: red;\n}\n.theme {\n  c: d;\n}\n\na b {\n  color: blue;\n}\na {\n  c: d;\n}")
+
+		_, err = Compile(fs, "loop.scss", Options{})
+		So(err.Error(), ShouldContainSubstring, "Module loop")
+		_, err = Compile(fs, "bad.scss", Options{})
+		So(err.Error(), ShouldContainSubstring, "$size was not declared with !default")
+	})
+}
@@ -0,0 +1,545 @@
+package scss
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// scope holds the members declared in one block. Scopes of control flow
+// blocks at the top level are semi-global: assigning to a variable that
+// exists globally from within them assigns the global variable.
+type scope struct {
+	vars       map[string]Value
+	mixins     map[string]*callable
+	funcs      map[string]*callable
+	parent     *scope
+	semiGlobal bool
+}
+
+func newScope(parent *scope) *scope {
+	return &scope{
+		vars:   make(map[string]Value),
+		mixins: make(map[string]*callable),
+		funcs:  make(map[string]*callable),
+		parent: parent,
+	}
+}
+
+// newFlowScope returns a scope for the body of a control flow rule.
+func newFlowScope(parent *scope) *scope {
+	s := newScope(parent)
+	s.semiGlobal = parent.parent == nil || parent.semiGlobal
+	return s
+}
+
+// module is a stylesheet loaded with @use or @forward, or a built-in module.
+type module struct {
+	url      string
+	global   *scope
+	forwards []*forward
+}
+
+func newModule(url string) *module {
+	return &module{url: url, global: newScope(nil)}
+}
+
+// forward is a module whose members are made available through another by
+// @forward.
+type forward struct {
+	module     *module
+	prefix     string
+	show, hide map[string]bool
+}
+
+// visible reports whether a member, named without the prefix and with "$"
+// for variables, passes the forward's show and hide lists.
+func (f *forward) visible(member string) bool {
+	if f.show != nil {
+		return f.show[member]
+	}
+	return !f.hide[member]
+}
+
+func (f *forward) unprefix(name string) (string, bool) {
+	if !strings.HasPrefix(name, f.prefix) {
+		return "", false
+	}
+	return name[len(f.prefix):], true
+}
+
+// variableScope returns the scope in which a module variable is declared,
+// and the name it's declared under there.
+func (m *module) variableScope(name string) (*scope, string, bool) {
+	if _, ok := m.global.vars[name]; ok {
+		return m.global, name, true
+	}
+	for _, f := range m.forwards {
+		if inner, ok := f.unprefix(name); ok && f.visible("$"+name) {
+			if s, declared, ok := f.module.variableScope(inner); ok {
+				return s, declared, true
+			}
+		}
+	}
+	return nil, "", false
+}
+
+func (m *module) variable(name string) (Value, bool) {
+	if s, declared, ok := m.variableScope(name); ok {
+		return s.vars[declared], true
+	}
+	return nil, false
+}
+
+// variables returns the module's variables, including forwarded ones, by
+// name.
+func (m *module) variables() map[string]Value {
+	vars := make(map[string]Value)
+	for _, f := range m.forwards {
+		for name, v := range f.module.variables() {
+			if name = f.prefix + name; f.visible("$" + name) {
+				vars[name] = v
+			}
+		}
+	}
+	for name, v := range m.global.vars {
+		vars[name] = v
+	}
+	return vars
+}
+
+func (m *module) functions() map[string]*callable {
+	funcs := make(map[string]*callable)
+	for _, f := range m.forwards {
+		for name, c := range f.module.functions() {
+			if name = f.prefix + name; f.visible(name) {
+				funcs[name] = c
+			}
+		}
+	}
+	for name, c := range m.global.funcs {
+		funcs[name] = c
+	}
+	return funcs
+}
+
+func (m *module) mixin(name string) (*callable, bool) {
+	if c, ok := m.global.mixins[name]; ok {
+		return c, true
+	}
+	for _, f := range m.forwards {
+		if inner, ok := f.unprefix(name); ok && f.visible(name) {
+			if c, ok := f.module.mixin(inner); ok {
+				return c, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func (m *module) function(name string) (*callable, bool) {
+	if c, ok := m.global.funcs[name]; ok {
+		return c, true
+	}
+	for _, f := range m.forwards {
+		if inner, ok := f.unprefix(name); ok && f.visible(name) {
+			if c, ok := f.module.function(inner); ok {
+				return c, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// environment is the lexical context code is evaluated in: the module being
+// evaluated, the innermost scope, and the modules loaded with @use.
+type environment struct {
+	module   *module
+	scope    *scope
+	uses     map[string]*module
+	starUses []*module
+	config   map[string]*configValue
+}
+
+func newEnvironment(m *module, config map[string]*configValue) *environment {
+	return &environment{module: m, scope: m.global, uses: make(map[string]*module), config: config}
+}
+
+// withScope returns a copy of env whose innermost scope is s.
+func (env *environment) withScope(s *scope) *environment {
+	copy := *env
+	copy.scope = s
+	return &copy
+}
+
+type configValue struct {
+	value Value
+	loc   Location
+	used  bool
+}
+
+// builtinModules are the modules available as "sass:<name>".
+var builtinModules = make(map[string]*module)
+
+// builtinModule returns the built-in module "sass:<name>", creating it the
+// first time.
+func builtinModule(name string) *module {
+	m, ok := builtinModules[name]
+	if !ok {
+		m = newModule("sass:" + name)
+		builtinModules[name] = m
+	}
+	return m
+}
+
+// define adds a function implemented in Go to a built-in module, and makes
+// it available without @use under any global names given.
+func (m *module) define(sig string, fn Function, globals ...string) {
+	c := newFunction(sig, fn)
+	m.global.funcs[c.name] = c
+	for _, name := range globals {
+		globalFunctions[name] = c
+	}
+}
+
+// defineBuiltin adds a function that needs the evaluator to a built-in
+// module, as define does.
+func (m *module) defineBuiltin(sig string, fn builtinFunction, globals ...string) {
+	c := newBuiltin(sig, fn)
+	m.global.funcs[c.name] = c
+	for _, name := range globals {
+		globalFunctions[name] = c
+	}
+}
+
+func (m *module) defineMixin(sig string, fn builtinFunction) {
+	c := newBuiltin(sig, fn)
+	m.global.mixins[c.name] = c
+}
+
+// defineGlobal adds a function implemented in Go that's only available
+// without @use.
+func defineGlobal(sig string, fn Function) {
+	c := newFunction(sig, fn)
+	globalFunctions[c.name] = c
+}
+
+// loadedFile is a stylesheet found by the importer.
+type loadedFile struct {
+	name string
+	src  []byte
+}
+
+// importCandidates lists the file names a Sass import URL may refer to, in
+// order of preference.
+func importCandidates(url string) []string {
+	dir, base := path.Split(url)
+	switch path.Ext(base) {
+	case ".scss", ".css":
+		return []string{dir + "_" + base, url}
+	}
+	var names []string
+	for _, ext := range []string{".scss", ".css"} {
+		names = append(names, dir+"_"+base+ext, url+ext)
+	}
+	for _, ext := range []string{".scss", ".css"} {
+		names = append(names, url+"/_index"+ext, url+"/index"+ext)
+	}
+	return names
+}
+
+// readFile reads one of the candidate files for url under dir from the file
+// system, returning nil if none exists.
+func readFile(fs http.FileSystem, dir, url string) (*loadedFile, error) {
+	if fs == nil {
+		return nil, nil
+	}
+	for _, name := range importCandidates(path.Join(dir, url)) {
+		f, err := fs.Open(name)
+		if err != nil {
+			continue
+		}
+		file, err := openFile(fs, f, name)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		return &loadedFile{name: name, src: file.Bytes}, nil
+	}
+	return nil, nil
+}
+
+// resolve finds the stylesheet url refers to when loaded from the file
+// named prev. Files relative to prev are preferred, then those found by
+// importers, then those in the load paths.
+func (e *evaluator) resolve(url, prev string, loc Location) *loadedFile {
+	fail := func(err error) {
+		panic(errorf(loc, "%s", err.Error()))
+	}
+	if !e.fromImporter[prev] {
+		f, err := readFile(e.fs, path.Dir(prev), url)
+		if err != nil {
+			fail(err)
+		}
+		if f != nil {
+			return f
+		}
+	}
+	for _, importer := range e.opts.Importers {
+		name, src, err := importer.Import(url, prev)
+		if err != nil {
+			fail(err)
+		}
+		if name != "" {
+			e.fromImporter[name] = true
+			return &loadedFile{name: name, src: src}
+		}
+	}
+	for _, dir := range e.opts.LoadPaths {
+		f, err := readFile(e.fs, dir, url)
+		if err != nil {
+			fail(err)
+		}
+		if f != nil {
+			return f
+		}
+	}
+	panic(errorf(loc, "Can't find stylesheet to import."))
+}
+
+// parseFile parses a loaded stylesheet, recording it as a dependency of the
+// compilation.
+func (e *evaluator) parseFile(f *loadedFile) *stylesheet {
+	if sheet, ok := e.sheets[f.name]; ok {
+		return sheet
+	}
+	sheet, err := parseStylesheet(f.name, f.src)
+	if err != nil {
+		panic(err)
+	}
+	e.sheets[f.name] = sheet
+	e.loadedFiles = append(e.loadedFiles, f.name)
+	return sheet
+}
+
+// loadModule returns the module for url, evaluating it the first time it's
+// loaded.
+func (e *evaluator) loadModule(url string, config map[string]*configValue, loc Location) *module {
+	if strings.HasPrefix(url, "sass:") {
+		m, ok := builtinModules[url[len("sass:"):]]
+		if !ok {
+			panic(errorf(loc, "Can't find stylesheet to import."))
+		}
+		if len(config) > 0 {
+			panic(errorf(loc, "Built-in modules can't be configured."))
+		}
+		return m
+	}
+	f := e.resolve(url, loc.File, loc)
+	if m, ok := e.modules[f.name]; ok {
+		if len(config) > 0 {
+			panic(errorf(loc, "This module was already loaded, so it can't be configured using \"with\"."))
+		}
+		return m
+	}
+	if e.loading[f.name] {
+		panic(errorf(loc, "Module loop: this module is already being loaded."))
+	}
+	sheet := e.parseFile(f)
+	m := newModule(f.name)
+	e.loading[f.name] = true
+	defer delete(e.loading, f.name)
+
+	saved := e.evalState
+	e.evalState = evalState{env: newEnvironment(m, config), parent: e.root}
+	e.execStmts(sheet.stmts)
+	e.evalState = saved
+	e.modules[f.name] = m
+	return m
+}
+
+// configure evaluates the configuration given by @use or @forward "with".
+// Variables of @forward with !default yield to those already configured.
+func (e *evaluator) configure(vars []*configVar, inherited map[string]*configValue) map[string]*configValue {
+	config := make(map[string]*configValue)
+	for _, v := range vars {
+		if prev, ok := inherited[v.name]; ok && v.isDefault {
+			config[v.name] = prev
+			continue
+		}
+		if _, dup := config[v.name]; dup {
+			panic(errorf(v.loc, "The same variable may only be configured once."))
+		}
+		config[v.name] = &configValue{value: e.eval(v.value), loc: v.loc}
+	}
+	return config
+}
+
+func (e *evaluator) execUse(s *useStmt) {
+	config := e.configure(s.config, nil)
+	m := e.loadModule(s.url, config, s.loc)
+	for name, v := range config {
+		if !v.used {
+			panic(errorf(v.loc, "$%s was not declared with !default in the @used module.", name))
+		}
+	}
+	if s.namespace == "" {
+		e.env.starUses = append(e.env.starUses, m)
+		return
+	}
+	if _, dup := e.env.uses[s.namespace]; dup {
+		panic(errorf(s.loc, "There's already a module with namespace \"%s\".", s.namespace))
+	}
+	e.env.uses[s.namespace] = m
+}
+
+func (e *evaluator) execForward(s *forwardStmt) {
+	// Configuration of this module passes through to the forwarded module,
+	// minus the forward's prefix.
+	inherited := make(map[string]*configValue)
+	for name, v := range e.env.config {
+		if strings.HasPrefix(name, s.prefix) {
+			inherited[name[len(s.prefix):]] = v
+		}
+	}
+	config := e.configure(s.config, inherited)
+	for name, v := range inherited {
+		if _, ok := config[name]; !ok {
+			config[name] = v
+		}
+	}
+	m := e.loadModule(s.url, config, s.loc)
+	e.env.module.forwards = append(e.env.module.forwards, &forward{module: m, prefix: s.prefix, show: s.show, hide: s.hide})
+}
+
+func (e *evaluator) namespace(name string, loc Location) *module {
+	m, ok := e.env.uses[name]
+	if !ok {
+		panic(errorf(loc, "There is no module with the namespace \"%s\".", name))
+	}
+	return m
+}
+
+func (e *evaluator) lookupVariable(namespace, name string, loc Location) Value {
+	if namespace != "" {
+		if v, ok := e.namespace(namespace, loc).variable(name); ok {
+			return v
+		}
+		panic(errorf(loc, "Undefined variable."))
+	}
+	if v, ok := e.findVariable(name, e.env.scope); ok {
+		return v
+	}
+	panic(errorf(loc, "Undefined variable."))
+}
+
+// findVariable looks a variable up in s and its parents, then in the modules
+// loaded without a namespace.
+func (e *evaluator) findVariable(name string, s *scope) (Value, bool) {
+	for ; s != nil; s = s.parent {
+		if v, ok := s.vars[name]; ok {
+			return v, true
+		}
+	}
+	for _, m := range e.env.starUses {
+		if v, ok := m.variable(name); ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+func (e *evaluator) lookupMixin(namespace, name string, loc Location) *callable {
+	if c := e.findMixin(namespace, name, loc); c != nil {
+		return c
+	}
+	panic(errorf(loc, "Undefined mixin."))
+}
+
+// findMixin returns the mixin for an @include, or nil if there's none.
+func (e *evaluator) findMixin(namespace, name string, loc Location) *callable {
+	if namespace != "" {
+		c, _ := e.namespace(namespace, loc).mixin(name)
+		return c
+	}
+	for s := e.env.scope; s != nil; s = s.parent {
+		if c, ok := s.mixins[name]; ok {
+			return c
+		}
+	}
+	for _, m := range e.env.starUses {
+		if c, ok := m.mixin(name); ok {
+			return c
+		}
+	}
+	return nil
+}
+
+// lookupFunction returns the function for a call, or nil if it names no
+// function and should be output as a plain CSS function.
+func (e *evaluator) lookupFunction(namespace, name string, loc Location) *callable {
+	name = normName(name)
+	if namespace != "" {
+		if c, ok := e.namespace(namespace, loc).function(name); ok {
+			return c
+		}
+		panic(errorf(loc, "Undefined function."))
+	}
+	for s := e.env.scope; s != nil; s = s.parent {
+		if c, ok := s.funcs[name]; ok {
+			return c
+		}
+	}
+	for _, m := range e.env.starUses {
+		if c, ok := m.function(name); ok {
+			return c
+		}
+	}
+	if c, ok := e.functions[name]; ok {
+		return c
+	}
+	if c, ok := globalFunctions[name]; ok {
+		return c
+	}
+	return nil
+}
+
+// setVariable assigns a variable following Sass's scoping rules: a variable
+// that exists in an enclosing local scope is reassigned there, as is a
+// global variable assigned from a semi-global scope; otherwise the variable
+// is created in the innermost scope.
+func (e *evaluator) setVariable(s *varDecl, value Value) {
+	if s.namespace != "" {
+		target, declared, ok := e.namespace(s.namespace, s.loc).variableScope(s.name)
+		if !ok {
+			panic(errorf(s.loc, "Undefined variable."))
+		}
+		target.vars[declared] = value
+		return
+	}
+	target := e.env.scope
+	if s.isGlobal {
+		target = e.env.module.global
+	} else {
+		for sc := e.env.scope; sc != nil; sc = sc.parent {
+			if _, ok := sc.vars[s.name]; ok {
+				if sc.parent != nil || sc == e.env.scope || e.env.scope.semiGlobal {
+					target = sc
+				}
+				break
+			}
+		}
+	}
+	if s.isDefault {
+		if old, ok := target.vars[s.name]; ok && old != Null {
+			return
+		}
+		if target == e.env.module.global {
+			if v, ok := e.env.config[s.name]; ok {
+				v.used = true
+				value = v.value
+			}
+		}
+	}
+	target.vars[s.name] = value
+}
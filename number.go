@@ -0,0 +1,220 @@
+package scss
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/logan/scss/css3"
+)
+
+// Number is a number with units. Compound units like "px*px" or "px/s" have
+// several numerators or denominators.
+type Number struct {
+	Value        float64
+	Numerators   []string
+	Denominators []string
+
+	// slash holds the operands of a division that's output as "a/b", as in
+	// "font: 12px/1.5".
+	slash *[2]*Number
+}
+
+func NewNumber(value float64, unit string) *Number {
+	n := &Number{Value: value}
+	if unit != "" {
+		n.Numerators = []string{unit}
+	}
+	return n
+}
+
+func (n *Number) TypeName() string { return "number" }
+func (n *Number) Truthy() bool     { return true }
+
+func (n *Number) Equal(other Value) bool {
+	o, ok := other.(*Number)
+	if !ok || n.Unitless() != o.Unitless() {
+		return false
+	}
+	v, err := o.convert(n.Numerators, n.Denominators)
+	return err == nil && fuzzyEqual(n.Value, v)
+}
+
+func (n *Number) String() string {
+	if n.slash != nil {
+		return n.slash[0].String() + "/" + n.slash[1].String()
+	}
+	return formatNumber(n.Value, false) + n.Unit()
+}
+
+// Unit returns the number's units, as in "px" or "px*px/s".
+func (n *Number) Unit() string {
+	unit := strings.Join(n.Numerators, "*")
+	if len(n.Denominators) > 0 {
+		unit += "/" + strings.Join(n.Denominators, "*")
+	}
+	return unit
+}
+
+func (n *Number) Unitless() bool {
+	return len(n.Numerators) == 0 && len(n.Denominators) == 0
+}
+
+// HasUnit reports whether n has exactly the single unit given.
+func (n *Number) HasUnit(unit string) bool {
+	return len(n.Numerators) == 1 && len(n.Denominators) == 0 && strings.EqualFold(n.Numerators[0], unit)
+}
+
+// Int returns n's value if it's an integer.
+func (n *Number) Int() (int, bool) {
+	if !fuzzyIsInt(n.Value) {
+		return 0, false
+	}
+	return int(math.Round(n.Value)), true
+}
+
+// withoutSlash returns n without any record of having been written as
+// "a/b", as happens once it's used in arithmetic.
+func (n *Number) withoutSlash() *Number {
+	if n.slash == nil {
+		return n
+	}
+	return &Number{Value: n.Value, Numerators: n.Numerators, Denominators: n.Denominators}
+}
+
+// ConvertTo returns n converted to the given units. A unitless number
+// converts to anything.
+func (n *Number) ConvertTo(numerators, denominators []string) (*Number, error) {
+	if n.Unitless() {
+		return &Number{Value: n.Value, Numerators: numerators, Denominators: denominators}, nil
+	}
+	v, err := n.convert(numerators, denominators)
+	if err != nil {
+		return nil, err
+	}
+	return &Number{Value: v, Numerators: numerators, Denominators: denominators}, nil
+}
+
+// convert returns n's value in the given units.
+func (n *Number) convert(numerators, denominators []string) (float64, error) {
+	v := n.Value
+	var err error
+	if v, err = convertUnits(v, n.Numerators, numerators, false); err == nil {
+		v, err = convertUnits(v, n.Denominators, denominators, true)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("Incompatible units %s and %s.", unitString(n.Numerators, n.Denominators), unitString(numerators, denominators))
+	}
+	return v, nil
+}
+
+func unitString(numerators, denominators []string) string {
+	n := &Number{Numerators: numerators, Denominators: denominators}
+	return n.Unit()
+}
+
+func convertUnits(v float64, from, to []string, inverse bool) (float64, error) {
+	if len(from) != len(to) {
+		return 0, fmt.Errorf("incompatible units")
+	}
+	used := make([]bool, len(from))
+next:
+	for _, target := range to {
+		for i, unit := range from {
+			if used[i] {
+				continue
+			}
+			if factor, ok := conversionFactor(unit, target); ok {
+				used[i] = true
+				if inverse {
+					v /= factor
+				} else {
+					v *= factor
+				}
+				continue next
+			}
+		}
+		return 0, fmt.Errorf("incompatible units")
+	}
+	return v, nil
+}
+
+// conversionFactor returns the number of to units in one from unit.
+func conversionFactor(from, to string) (float64, bool) {
+	if from == to {
+		return 1, true
+	}
+	factor, ok := css3.ConversionFactor(from, to)
+	if !ok {
+		return 0, false
+	}
+	f, _ := factor.Float64()
+	return f, true
+}
+
+// multiplyUnits combines two sets of units, cancelling compatible units
+// between numerators and denominators. It returns the factor the value must
+// be scaled by to account for converted units.
+func multiplyUnits(nums1, dens1, nums2, dens2 []string) (float64, []string, []string) {
+	factor := 1.0
+	var nums, dens []string
+	dens = append(dens, dens1...)
+	dens = append(dens, dens2...)
+	for _, num := range append(append([]string(nil), nums1...), nums2...) {
+		cancelled := false
+		for i, den := range dens {
+			if f, ok := conversionFactor(num, den); ok {
+				factor *= f
+				dens = append(dens[:i:i], dens[i+1:]...)
+				cancelled = true
+				break
+			}
+		}
+		if !cancelled {
+			nums = append(nums, num)
+		}
+	}
+	return factor, nums, dens
+}
+
+// epsilon is the tolerance for comparing numbers, matching the ten digits
+// of precision numbers are output with.
+const epsilon = 1e-11
+
+func fuzzyEqual(a, b float64) bool {
+	return a == b || math.Abs(a-b) < epsilon
+}
+
+func fuzzyIsInt(v float64) bool {
+	return !math.IsInf(v, 0) && fuzzyEqual(v, math.Round(v))
+}
+
+func formatNumber(v float64, compressed bool) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "Infinity"
+	case math.IsInf(v, -1):
+		return "-Infinity"
+	case fuzzyIsInt(v):
+		if r := math.Round(v); r != 0 {
+			return strconv.FormatFloat(r, 'f', -1, 64)
+		}
+		return "0"
+	}
+	s := strconv.FormatFloat(v, 'f', 10, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" {
+		return "0"
+	}
+	if compressed {
+		if strings.HasPrefix(s, "0.") {
+			s = s[1:]
+		} else if strings.HasPrefix(s, "-0.") {
+			s = "-" + s[2:]
+		}
+	}
+	return s
+}
@@ -0,0 +1,125 @@
+package scss
+
+import (
+	"fmt"
+	"math"
+)
+
+func undefinedOperation(left Value, op string, right Value) error {
+	return fmt.Errorf("Undefined operation \"%s %s %s\".", left, op, right)
+}
+
+// binaryOperation applies an arithmetic, equality or relational operator.
+// The logical operators are handled by the evaluator since they don't always
+// evaluate their right operand.
+func binaryOperation(op string, left, right Value) (Value, error) {
+	switch op {
+	case "==":
+		return Bool(left.Equal(right)), nil
+	case "!=":
+		return Bool(!left.Equal(right)), nil
+	}
+	ln, lok := left.(*Number)
+	rn, rok := right.(*Number)
+	if lok && rok {
+		return numberOperation(op, ln.withoutSlash(), rn.withoutSlash())
+	}
+	// Calculations are only combined inside another calculation.
+	_, lcalc := left.(*Calculation)
+	_, rcalc := right.(*Calculation)
+	if lcalc || lok && rcalc {
+		return nil, undefinedOperation(left, op, right)
+	}
+	// Colors don't support arithmetic, and neither do numbers with colors.
+	_, lcolor := left.(*Color)
+	_, rcolor := right.(*Color)
+	if lcolor || lok && rcolor {
+		return nil, undefinedOperation(left, op, right)
+	}
+	switch op {
+	case "+":
+		if ls, ok := left.(*String); ok {
+			return &String{Text: ls.Text + cssString(right), Quoted: ls.Quoted}, nil
+		}
+		if rs, ok := right.(*String); ok {
+			return &String{Text: cssString(left) + rs.Text, Quoted: rs.Quoted}, nil
+		}
+		return &String{Text: cssString(left) + cssString(right)}, nil
+	case "-":
+		return &String{Text: cssString(left) + "-" + cssString(right)}, nil
+	case "/":
+		return &String{Text: cssString(left) + "/" + cssString(right), slash: &[2]Value{left, right}}, nil
+	}
+	return nil, undefinedOperation(left, op, right)
+}
+
+func numberOperation(op string, a, b *Number) (Value, error) {
+	switch op {
+	case "*":
+		factor, nums, dens := multiplyUnits(a.Numerators, a.Denominators, b.Numerators, b.Denominators)
+		return &Number{Value: a.Value * b.Value * factor, Numerators: nums, Denominators: dens}, nil
+	case "/":
+		factor, nums, dens := multiplyUnits(a.Numerators, a.Denominators, b.Denominators, b.Numerators)
+		return &Number{Value: a.Value / b.Value * factor, Numerators: nums, Denominators: dens}, nil
+	}
+	// The remaining operators need the operands in the same units. A
+	// unitless operand takes on the units of the other.
+	nums, dens := a.Numerators, a.Denominators
+	if a.Unitless() {
+		nums, dens = b.Numerators, b.Denominators
+	}
+	bv := b.Value
+	if !b.Unitless() && !a.Unitless() {
+		var err error
+		if bv, err = b.convert(nums, dens); err != nil {
+			return nil, fmt.Errorf("Incompatible units %s and %s.", b.Unit(), a.Unit())
+		}
+	}
+	av := a.Value
+	result := func(v float64) *Number {
+		return &Number{Value: v, Numerators: nums, Denominators: dens}
+	}
+	switch op {
+	case "+":
+		return result(av + bv), nil
+	case "-":
+		return result(av - bv), nil
+	case "%":
+		m := math.Mod(av, bv)
+		if m != 0 && (m < 0) != (bv < 0) {
+			m += bv
+		}
+		return result(m), nil
+	case "<":
+		return Bool(av < bv && !fuzzyEqual(av, bv)), nil
+	case "<=":
+		return Bool(av < bv || fuzzyEqual(av, bv)), nil
+	case ">":
+		return Bool(av > bv && !fuzzyEqual(av, bv)), nil
+	case ">=":
+		return Bool(av > bv || fuzzyEqual(av, bv)), nil
+	}
+	return nil, undefinedOperation(a, op, b)
+}
+
+func unaryOperation(op string, operand Value) (Value, error) {
+	switch op {
+	case "not":
+		return Bool(!operand.Truthy()), nil
+	case "-":
+		if n, ok := operand.(*Number); ok {
+			return &Number{Value: -n.Value, Numerators: n.Numerators, Denominators: n.Denominators}, nil
+		}
+	case "+":
+		if n, ok := operand.(*Number); ok {
+			return n.withoutSlash(), nil
+		}
+	}
+	switch operand.(type) {
+	case *Color, *Calculation:
+		if op != "/" {
+			return nil, fmt.Errorf("Undefined operation \"%s%s\".", op, operand)
+		}
+	}
+	return &String{Text: op + cssString(operand)}, nil
+}
@@ -0,0 +1,1074 @@
+package scss
+
+import (
+	"strings"
+
+	"github.com/logan/scss/css3"
+)
+
+type parser struct {
+	file      string
+	src       []byte
+	toks      []token
+	i         int
+	stopWords []string
+
+	// comments are the loud comments not yet reached, which become
+	// statements if they're between statements.
+	comments []token
+}
+
+func parseStylesheet(file string, src []byte) (sheet *stylesheet, err error) {
+	defer recoverError(&err)
+	p := &parser{file: file, src: src}
+	p.toks, p.comments = tokenize(file, src, css3.StartPosition)
+	sheet = &stylesheet{file: file, src: src, stmts: p.parseStatements(true)}
+	return sheet, nil
+}
+
+// subParser returns a parser over the text of the source between the given
+// offsets, keeping positions relative to the whole file.
+func (p *parser) subParser(start css3.Position, end int) *parser {
+	toks, _ := tokenize(p.file, p.src[start.Offset:end], start)
+	return &parser{file: p.file, src: p.src, toks: toks}
+}
+
+func (p *parser) peek() token { return p.peekAt(0) }
+
+func (p *parser) peekAt(k int) token {
+	if p.i+k >= len(p.toks) {
+		return p.toks[len(p.toks)-1]
+	}
+	return p.toks[p.i+k]
+}
+
+func (p *parser) prev() token { return p.toks[p.i-1] }
+
+func (p *parser) advance() token {
+	t := p.peek()
+	if p.i < len(p.toks)-1 {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) at(tt css3.TokenType) bool { return p.peek().TokenType == tt }
+
+func (p *parser) atDelim(ch rune) bool { return p.peek().isDelim(ch) }
+
+func (p *parser) atIdent(name string) bool { return p.peek().isIdent(name) }
+
+func (p *parser) atEOF() bool { return p.at(css3.EOFToken) }
+
+func (p *parser) skipWS() bool {
+	skipped := false
+	for p.at(css3.WhitespaceToken) {
+		p.advance()
+		skipped = true
+	}
+	return skipped
+}
+
+func adjacent(a, b token) bool { return a.end.Offset == b.start.Offset }
+
+// atInterp reports whether the next tokens open a #{} interpolation.
+func (p *parser) atInterp() bool {
+	return p.atDelim('#') && p.peekAt(1).TokenType == css3.LCurlyToken && adjacent(p.peek(), p.peekAt(1))
+}
+
+// atVariable reports whether the next tokens are a $variable.
+func (p *parser) atVariable() bool {
+	return p.atDelim('$') && p.peekAt(1).TokenType == css3.IdentToken && adjacent(p.peek(), p.peekAt(1))
+}
+
+// atNamespaced reports whether the next tokens are a namespace followed by a
+// member, as in "math.div(" or "config.$size".
+func (p *parser) atNamespaced() bool {
+	ns, dot, member := p.peek(), p.peekAt(1), p.peekAt(2)
+	if ns.TokenType != css3.IdentToken || !dot.isDelim('.') || !adjacent(ns, dot) || !adjacent(dot, member) {
+		return false
+	}
+	return member.TokenType == css3.FunctionToken ||
+		(member.isDelim('$') && p.peekAt(3).TokenType == css3.IdentToken && adjacent(member, p.peekAt(3)))
+}
+
+// atNamespacedIdent reports whether the next tokens are a namespaced mixin
+// name without arguments, as in "@include ns.name".
+func (p *parser) atNamespacedIdent() bool {
+	ns, dot, member := p.peek(), p.peekAt(1), p.peekAt(2)
+	return ns.TokenType == css3.IdentToken && dot.isDelim('.') && member.TokenType == css3.IdentToken &&
+		adjacent(ns, dot) && adjacent(dot, member)
+}
+
+func (p *parser) loc(t token) Location { return Location{p.file, t.start} }
+
+func (p *parser) errorf(t token, format string, args ...interface{}) {
+	panic(errorf(p.loc(t), format, args...))
+}
+
+func (p *parser) expect(tt css3.TokenType, what string) token {
+	if !p.at(tt) {
+		p.errorf(p.peek(), "expected %s.", what)
+	}
+	return p.advance()
+}
+
+func (p *parser) expectDelim(ch rune) token {
+	if !p.atDelim(ch) {
+		p.errorf(p.peek(), "expected \"%c\".", ch)
+	}
+	return p.advance()
+}
+
+func (p *parser) expectIdent() string {
+	return p.expect(css3.IdentToken, "identifier").ident()
+}
+
+func (p *parser) expectVariable() string {
+	if !p.atVariable() {
+		p.errorf(p.peek(), "expected \"$\".")
+	}
+	p.advance()
+	return normName(p.advance().ident())
+}
+
+func (p *parser) expectString() string {
+	return p.expect(css3.StringToken, "string").Value.(string)
+}
+
+func (p *parser) text(t token) string {
+	return string(p.src[t.start.Offset:t.end.Offset])
+}
+
+// endStatement consumes the semicolon ending a statement. A closing brace or
+// the end of the file also ends a statement but isn't consumed.
+func (p *parser) endStatement() {
+	p.skipWS()
+	switch {
+	case p.at(css3.SemicolonToken):
+		p.advance()
+	case p.at(css3.RCurlyToken), p.atEOF():
+	default:
+		p.errorf(p.peek(), "expected \";\".")
+	}
+}
+
+// normName returns the canonical form of a Sass identifier, in which
+// hyphens and underscores are interchangeable.
+func normName(name string) string {
+	return strings.Replace(name, "_", "-", -1)
+}
+
+func (p *parser) parseStatements(root bool) []stmt {
+	stmts := make([]stmt, 0)
+	for {
+		p.skipWS()
+		stmts = append(stmts, p.parseComments()...)
+		t := p.peek()
+		switch {
+		case t.TokenType == css3.EOFToken:
+			if !root {
+				p.errorf(t, "expected \"}\".")
+			}
+			return stmts
+		case t.TokenType == css3.RCurlyToken:
+			if root {
+				p.errorf(t, "unmatched \"}\".")
+			}
+			return stmts
+		case t.TokenType == css3.SemicolonToken, t.TokenType == css3.CDOToken, t.TokenType == css3.CDCToken:
+			p.advance()
+		case t.TokenType == css3.AtKeywordToken:
+			if s := p.parseAtRule(); s != nil {
+				stmts = append(stmts, s)
+			}
+		case p.atVariable():
+			stmts = append(stmts, p.parseVarDecl(""))
+		case p.atNamespaced() && p.peekAt(2).isDelim('$'):
+			ns := p.advance().ident()
+			p.advance()
+			stmts = append(stmts, p.parseVarDecl(ns))
+		default:
+			stmts = append(stmts, p.parseDeclarationOrRule())
+		}
+	}
+}
+
+// parseComments returns statements for the loud comments between the end
+// of the previous statement and the current token. Comments within
+// statements are dropped.
+func (p *parser) parseComments() []stmt {
+	after := 0
+	for i := p.i - 1; i >= 0; i-- {
+		if p.toks[i].TokenType != css3.WhitespaceToken {
+			after = p.toks[i].end.Offset
+			break
+		}
+	}
+	var stmts []stmt
+	for len(p.comments) > 0 && p.comments[0].start.Offset < p.peek().start.Offset {
+		if t := p.comments[0]; t.start.Offset >= after {
+			stmts = append(stmts, p.parseComment(t))
+		}
+		p.comments = p.comments[1:]
+	}
+	return stmts
+}
+
+// parseComment parses a loud comment, evaluating any interpolation it
+// contains.
+func (p *parser) parseComment(t token) stmt {
+	raw := p.text(t)
+	var it interp
+	lit := 0
+	for i := 0; i < len(raw); i++ {
+		if strings.HasPrefix(raw[i:], "#{") {
+			it.addText(raw[lit:i])
+			sub := p.subParser(advancePos(t.start, raw[:i+2]), t.end.Offset)
+			sub.skipWS()
+			it.addExpr(sub.parseExpression())
+			sub.skipWS()
+			end := sub.expect(css3.RCurlyToken, "\"}\"")
+			lit = end.end.Offset - t.start.Offset
+			i = lit - 1
+		}
+	}
+	it.addText(raw[lit:])
+	return &commentStmt{node: node{p.loc(t)}, text: it}
+}
+
+func (p *parser) parseBlock() []stmt {
+	p.skipWS()
+	p.expect(css3.LCurlyToken, "\"{\"")
+	stmts := p.parseStatements(false)
+	p.advance()
+	return stmts
+}
+
+func (p *parser) parseVarDecl(namespace string) stmt {
+	start := p.peek()
+	name := p.expectVariable()
+	p.skipWS()
+	p.expect(css3.ColonToken, "\":\"")
+	p.skipWS()
+	decl := &varDecl{node: node{p.loc(start)}, namespace: namespace, name: name, value: p.parseExpression()}
+	for {
+		p.skipWS()
+		if !p.atDelim('!') {
+			break
+		}
+		flag := p.peekAt(1)
+		switch {
+		case flag.isIdent("default"):
+			decl.isDefault = true
+		case flag.isIdent("global"):
+			decl.isGlobal = true
+		default:
+			p.errorf(flag, "Invalid flag name.")
+		}
+		p.advance()
+		p.advance()
+	}
+	p.endStatement()
+	return decl
+}
+
+// scanStatement finds the token that ends the statement starting at the
+// current token: a "{", ";" or "}" outside of any brackets or interpolation.
+func (p *parser) scanStatement() token {
+	depth := 0
+	for i := p.i; i < len(p.toks); i++ {
+		t := p.toks[i]
+		switch t.TokenType {
+		case css3.FunctionToken, css3.LParenToken, css3.LSquareToken:
+			depth++
+		case css3.RParenToken, css3.RSquareToken:
+			if depth > 0 {
+				depth--
+			}
+		case css3.DelimToken:
+			if t.isDelim('#') && i+1 < len(p.toks) && p.toks[i+1].TokenType == css3.LCurlyToken {
+				depth++
+				i++
+			}
+		case css3.RCurlyToken:
+			if depth > 0 {
+				depth--
+				continue
+			}
+			return t
+		case css3.LCurlyToken, css3.SemicolonToken:
+			if depth == 0 {
+				return t
+			}
+		case css3.EOFToken:
+			return t
+		}
+	}
+	return p.toks[len(p.toks)-1]
+}
+
+// looksLikeProperty reports whether the statement starting at the current
+// token is a property name followed by a colon and whitespace or a block,
+// which is how nested properties ("font: { family: x }") are told apart
+// from selectors like "a:hover".
+func (p *parser) looksLikeProperty() bool {
+	save := p.i
+	defer func() { p.i = save }()
+	named := false
+	for {
+		switch {
+		case p.atInterp():
+			p.skipInterp()
+		case p.at(css3.IdentToken), p.atDelim('-'), p.atDelim('*') && !named:
+			p.advance()
+		default:
+			if !named || !p.at(css3.ColonToken) {
+				return false
+			}
+			p.advance()
+			return p.at(css3.WhitespaceToken) || p.at(css3.LCurlyToken)
+		}
+		named = true
+	}
+}
+
+func (p *parser) skipInterp() {
+	p.advance()
+	p.advance()
+	for depth := 0; !p.atEOF(); p.advance() {
+		switch p.peek().TokenType {
+		case css3.LCurlyToken:
+			depth++
+		case css3.RCurlyToken:
+			if depth == 0 {
+				p.advance()
+				return
+			}
+			depth--
+		}
+	}
+}
+
+func (p *parser) parseDeclarationOrRule() stmt {
+	end := p.scanStatement()
+	if end.TokenType == css3.LCurlyToken && !p.looksLikeProperty() {
+		return p.parseStyleRule()
+	}
+	return p.parseDeclaration()
+}
+
+func (p *parser) parseStyleRule() stmt {
+	start := p.peek()
+	selector := p.parseInterp(func() bool { return p.at(css3.LCurlyToken) }, false)
+	return &styleRule{node: node{p.loc(start)}, selector: selector, children: p.parseBlock()}
+}
+
+func (p *parser) parseDeclaration() stmt {
+	start := p.peek()
+	name := p.parseInterp(func() bool {
+		return p.at(css3.ColonToken) || p.at(css3.SemicolonToken) || p.at(css3.LCurlyToken)
+	}, false)
+	p.expect(css3.ColonToken, "\":\"")
+	decl := &declaration{node: node{p.loc(start)}, name: name}
+	if s, ok := name.plain(); ok && strings.HasPrefix(s, "--") {
+		decl.custom = true
+		value := p.parseInterp(func() bool {
+			return p.at(css3.SemicolonToken) || p.at(css3.RCurlyToken)
+		}, true)
+		// Custom property values are kept as written, including the
+		// whitespace after the colon, with only SassScript interpolation
+		// evaluated.
+		decl.value = &stringExpr{node: node{p.loc(start)}, text: trimInterpRight(value)}
+		p.endStatement()
+		return decl
+	}
+	p.skipWS()
+	if !p.at(css3.LCurlyToken) {
+		decl.value = p.parseExpression()
+		p.skipWS()
+	}
+	if p.at(css3.LCurlyToken) {
+		decl.children = p.parseBlock()
+		return decl
+	}
+	p.endStatement()
+	return decl
+}
+
+// parseInterp reads source text up to a token for which stop returns true
+// outside of any brackets, evaluating #{} interpolation along the way.
+// Unless raw is set, comments are dropped and whitespace is collapsed, and
+// the result is trimmed.
+func (p *parser) parseInterp(stop func() bool, raw bool) interp {
+	var it interp
+	depth := 0
+	textStart := p.peek().start.Offset
+	flush := func(end int) {
+		if raw {
+			it.addText(string(p.src[textStart:end]))
+		}
+	}
+	for !p.atEOF() && (depth > 0 || !stop()) {
+		if p.atInterp() {
+			flush(p.peek().start.Offset)
+			it.addExpr(p.parseInterpolation())
+			textStart = p.prev().end.Offset
+			continue
+		}
+		t := p.advance()
+		switch t.TokenType {
+		case css3.FunctionToken, css3.LParenToken, css3.LSquareToken, css3.LCurlyToken:
+			depth++
+		case css3.RParenToken, css3.RSquareToken, css3.RCurlyToken:
+			if depth > 0 {
+				depth--
+			}
+		}
+		if !raw {
+			if t.TokenType == css3.WhitespaceToken {
+				it.addText(" ")
+			} else {
+				it.addText(p.text(t))
+			}
+		}
+	}
+	flush(p.peek().start.Offset)
+	if raw {
+		return it
+	}
+	return trimInterp(it)
+}
+
+// parseInterpolation parses "#{expr}".
+func (p *parser) parseInterpolation() expr {
+	p.advance()
+	p.advance()
+	p.skipWS()
+	e := p.parseExpression()
+	p.skipWS()
+	p.expect(css3.RCurlyToken, "\"}\"")
+	return e
+}
+
+func trimInterp(it interp) interp {
+	if len(it.parts) > 0 {
+		if s, ok := it.parts[0].(string); ok {
+			it.parts[0] = strings.TrimLeft(s, " \t\n")
+		}
+	}
+	return trimInterpRight(it)
+}
+
+func trimInterpRight(it interp) interp {
+	if n := len(it.parts); n > 0 {
+		if s, ok := it.parts[n-1].(string); ok {
+			it.parts[n-1] = strings.TrimRight(s, " \t\n")
+		}
+	}
+	result := interp{}
+	for _, part := range it.parts {
+		if s, ok := part.(string); ok {
+			result.addText(s)
+		} else {
+			result.addExpr(part.(expr))
+		}
+	}
+	return result
+}
+
+func (p *parser) parseAtRule() stmt {
+	start := p.advance()
+	loc := p.loc(start)
+	name := start.ident()
+	p.skipWS()
+	switch name {
+	case "use":
+		return p.parseUse(loc)
+	case "forward":
+		return p.parseForward(loc)
+	case "import":
+		return p.parseImport(loc)
+	case "mixin":
+		return &mixinDecl{p.parseCallableDecl(loc, false)}
+	case "function":
+		return &functionDecl{p.parseCallableDecl(loc, true)}
+	case "include":
+		return p.parseInclude(loc)
+	case "content":
+		s := &contentStmt{node: node{loc}}
+		if p.at(css3.LParenToken) {
+			p.advance()
+			s.args = p.parseArgInvocation()
+		}
+		p.endStatement()
+		return s
+	case "return":
+		s := &returnStmt{node: node{loc}, value: p.parseExpression()}
+		p.endStatement()
+		return s
+	case "debug":
+		s := &debugStmt{node: node{loc}, value: p.parseExpression()}
+		p.endStatement()
+		return s
+	case "warn":
+		s := &warnStmt{node: node{loc}, value: p.parseExpression()}
+		p.endStatement()
+		return s
+	case "error":
+		s := &errorStmt{node: node{loc}, value: p.parseExpression()}
+		p.endStatement()
+		return s
+	case "if":
+		return p.parseIf(loc)
+	case "else":
+		p.errorf(start, "This at-rule is not allowed here.")
+	case "each":
+		return p.parseEach(loc)
+	case "for":
+		return p.parseFor(loc)
+	case "while":
+		s := &whileStmt{node: node{loc}, cond: p.parseExpression()}
+		s.body = p.parseBlock()
+		return s
+	case "extend":
+		return p.parseExtend(loc)
+	case "at-root":
+		return p.parseAtRoot(loc)
+	case "media":
+		return &mediaRule{node: node{loc}, query: p.parseQuery(), children: p.parseBlock()}
+	case "supports":
+		return &supportsRule{node: node{loc}, condition: p.parseQuery(), children: p.parseBlock()}
+	case "charset":
+		p.expectString()
+		p.endStatement()
+		return nil
+	}
+	rule := &atRule{node: node{loc}, name: name}
+	rule.prelude = p.parseInterp(func() bool {
+		return p.at(css3.LCurlyToken) || p.at(css3.SemicolonToken) || p.at(css3.RCurlyToken)
+	}, false)
+	if p.at(css3.LCurlyToken) {
+		rule.hasBody = true
+		rule.children = p.parseBlock()
+	} else {
+		p.endStatement()
+	}
+	return rule
+}
+
+// parseQuery parses the prelude of @media or @supports, in which Sass
+// expressions may appear as feature values and as variables.
+func (p *parser) parseQuery() interp {
+	var it interp
+	for !p.atEOF() && !p.at(css3.LCurlyToken) && !p.at(css3.SemicolonToken) {
+		p.parseQueryPart(&it)
+	}
+	return trimInterp(it)
+}
+
+func (p *parser) parseQueryPart(it *interp) {
+	switch {
+	case p.atInterp():
+		it.addExpr(p.parseInterpolation())
+	case p.atVariable():
+		it.addExpr(p.parsePrimary())
+	case p.at(css3.WhitespaceToken):
+		p.skipWS()
+		it.addText(" ")
+	case p.at(css3.LParenToken):
+		p.advance()
+		it.addText("(")
+		p.skipWS()
+		for !p.atEOF() && !p.at(css3.RParenToken) && !p.at(css3.ColonToken) {
+			p.parseQueryPart(it)
+		}
+		if p.at(css3.ColonToken) {
+			p.advance()
+			p.skipWS()
+			it.addText(": ")
+			it.addExpr(p.parseExpression())
+			p.skipWS()
+		}
+		p.expect(css3.RParenToken, "\")\"")
+		if s, ok := it.parts[len(it.parts)-1].(string); ok {
+			it.parts[len(it.parts)-1] = strings.TrimRight(s, " ")
+		}
+		it.addText(")")
+	default:
+		t := p.advance()
+		it.addText(p.text(t))
+		if t.TokenType == css3.FunctionToken {
+			it.addText(p.text(p.skipBalanced()))
+		}
+	}
+}
+
+// skipBalanced skips to the token closing the current function or bracket
+// and returns a token spanning the skipped text.
+func (p *parser) skipBalanced() token {
+	start := p.peek()
+	for depth := 0; !p.atEOF(); {
+		t := p.advance()
+		switch t.TokenType {
+		case css3.FunctionToken, css3.LParenToken, css3.LSquareToken:
+			depth++
+		case css3.RParenToken, css3.RSquareToken:
+			if depth == 0 {
+				return token{t.Token, start.start, t.end}
+			}
+			depth--
+		}
+	}
+	return token{start.Token, start.start, p.peek().start}
+}
+
+func (p *parser) parseCallableDecl(loc Location, function bool) callableDecl {
+	decl := callableDecl{node: node{loc}}
+	switch {
+	case p.at(css3.FunctionToken):
+		decl.name = normName(p.advance().ident())
+		decl.params = p.parseParamList()
+	case !function && p.at(css3.IdentToken):
+		decl.name = normName(p.advance().ident())
+		p.skipWS()
+		if p.at(css3.LParenToken) {
+			p.advance()
+			decl.params = p.parseParamList()
+		} else {
+			decl.params = &paramList{}
+		}
+	default:
+		p.errorf(p.peek(), "Expected identifier.")
+	}
+	decl.body = p.parseBlock()
+	return decl
+}
+
+// parseParamList parses parameters up to and including the closing
+// parenthesis.
+func (p *parser) parseParamList() *paramList {
+	params := &paramList{}
+	seen := make(map[string]bool)
+	for {
+		p.skipWS()
+		if p.at(css3.RParenToken) {
+			p.advance()
+			return params
+		}
+		t := p.peek()
+		name := p.expectVariable()
+		if seen[name] {
+			p.errorf(t, "Duplicate argument.")
+		}
+		seen[name] = true
+		p.skipWS()
+		if p.atRest() {
+			params.rest = name
+			p.skipWS()
+			p.expect(css3.RParenToken, "\")\"")
+			return params
+		}
+		prm := param{name: name}
+		if p.at(css3.ColonToken) {
+			p.advance()
+			p.skipWS()
+			prm.def = p.parseSpaceList()
+			p.skipWS()
+		}
+		params.params = append(params.params, prm)
+		if p.at(css3.CommaToken) {
+			p.advance()
+			continue
+		}
+		p.expect(css3.RParenToken, "\")\"")
+		return params
+	}
+}
+
+func (p *parser) atRest() bool {
+	if p.atDelim('.') && p.peekAt(1).isDelim('.') && p.peekAt(2).isDelim('.') {
+		p.advance()
+		p.advance()
+		p.advance()
+		return true
+	}
+	return false
+}
+
+// parseArgInvocation parses arguments up to and including the closing
+// parenthesis.
+func (p *parser) parseArgInvocation() *argInvocation {
+	args := &argInvocation{named: make(map[string]expr)}
+	for {
+		p.skipWS()
+		if p.at(css3.RParenToken) {
+			p.advance()
+			return args
+		}
+		t := p.peek()
+		if p.atVariable() {
+			save := p.i
+			name := p.expectVariable()
+			p.skipWS()
+			if p.at(css3.ColonToken) {
+				p.advance()
+				p.skipWS()
+				if _, dup := args.named[name]; dup {
+					p.errorf(t, "Duplicate argument.")
+				}
+				args.named[name] = p.parseSpaceList()
+				args.names = append(args.names, name)
+				goto next
+			}
+			p.i = save
+		}
+		{
+			value := p.parseSpaceList()
+			p.skipWS()
+			switch {
+			case p.atRest():
+				if args.rest == nil {
+					args.rest = value
+				} else {
+					args.kwRest = value
+				}
+			case args.rest != nil || len(args.named) > 0:
+				p.errorf(t, "Positional arguments must come before keyword arguments.")
+			default:
+				args.positional = append(args.positional, value)
+			}
+		}
+	next:
+		p.skipWS()
+		if p.at(css3.CommaToken) {
+			p.advance()
+			continue
+		}
+		p.expect(css3.RParenToken, "\")\"")
+		return args
+	}
+}
+
+func (p *parser) parseInclude(loc Location) stmt {
+	s := &includeStmt{node: node{loc}}
+	if p.atNamespaced() || p.atNamespacedIdent() {
+		s.namespace = p.advance().ident()
+		p.advance()
+	}
+	switch {
+	case p.at(css3.FunctionToken):
+		s.name = normName(p.advance().ident())
+		s.args = p.parseArgInvocation()
+	case p.at(css3.IdentToken):
+		s.name = normName(p.advance().ident())
+		p.skipWS()
+		if p.at(css3.LParenToken) {
+			p.advance()
+			s.args = p.parseArgInvocation()
+		}
+	default:
+		p.errorf(p.peek(), "Expected identifier.")
+	}
+	if s.args == nil {
+		s.args = &argInvocation{named: make(map[string]expr)}
+	}
+	p.skipWS()
+	var params *paramList
+	if p.atIdent("using") {
+		p.advance()
+		p.skipWS()
+		p.expect(css3.LParenToken, "\"(\"")
+		params = p.parseParamList()
+		p.skipWS()
+	}
+	if p.at(css3.LCurlyToken) {
+		if params == nil {
+			params = &paramList{}
+		}
+		start := p.peek()
+		s.content = &callableDecl{node: node{p.loc(start)}, name: "@content", params: params, body: p.parseBlock()}
+		return s
+	}
+	if params != nil {
+		p.errorf(p.peek(), "expected \"{\".")
+	}
+	p.endStatement()
+	return s
+}
+
+func (p *parser) parseIf(loc Location) stmt {
+	s := &ifStmt{node: node{loc}}
+	cond := p.parseExpression()
+	s.clauses = append(s.clauses, ifClause{cond, p.parseBlock()})
+	for {
+		save := p.i
+		p.skipWS()
+		t := p.peek()
+		if t.TokenType != css3.AtKeywordToken || (t.ident() != "else" && t.ident() != "elseif") {
+			p.i = save
+			return s
+		}
+		p.advance()
+		p.skipWS()
+		if t.ident() == "elseif" || p.atIdent("if") {
+			if t.ident() == "else" {
+				p.advance()
+				p.skipWS()
+			}
+			cond := p.parseExpression()
+			s.clauses = append(s.clauses, ifClause{cond, p.parseBlock()})
+			continue
+		}
+		s.hasElse = true
+		s.elseBody = p.parseBlock()
+		return s
+	}
+}
+
+func (p *parser) parseEach(loc Location) stmt {
+	s := &eachStmt{node: node{loc}}
+	for {
+		s.vars = append(s.vars, p.expectVariable())
+		p.skipWS()
+		if !p.at(css3.CommaToken) {
+			break
+		}
+		p.advance()
+		p.skipWS()
+	}
+	if !p.atIdent("in") {
+		p.errorf(p.peek(), "Expected \"in\".")
+	}
+	p.advance()
+	p.skipWS()
+	s.list = p.parseExpression()
+	s.body = p.parseBlock()
+	return s
+}
+
+func (p *parser) parseFor(loc Location) stmt {
+	s := &forStmt{node: node{loc}, variable: p.expectVariable()}
+	p.skipWS()
+	if !p.atIdent("from") {
+		p.errorf(p.peek(), "Expected \"from\".")
+	}
+	p.advance()
+	p.skipWS()
+	p.stopWords = []string{"to", "through"}
+	s.from = p.parseExpression()
+	p.stopWords = nil
+	p.skipWS()
+	switch {
+	case p.atIdent("through"):
+		s.inclusive = true
+	case p.atIdent("to"):
+	default:
+		p.errorf(p.peek(), "Expected \"to\" or \"through\".")
+	}
+	p.advance()
+	p.skipWS()
+	s.to = p.parseExpression()
+	s.body = p.parseBlock()
+	return s
+}
+
+func (p *parser) parseExtend(loc Location) stmt {
+	s := &extendStmt{node: node{loc}}
+	s.selector = p.parseInterp(func() bool {
+		return p.at(css3.SemicolonToken) || p.at(css3.RCurlyToken) || p.atDelim('!')
+	}, false)
+	if p.atDelim('!') {
+		if !p.peekAt(1).isIdent("optional") {
+			p.errorf(p.peek(), "Expected \"optional\".")
+		}
+		p.advance()
+		p.advance()
+		s.optional = true
+	}
+	p.endStatement()
+	return s
+}
+
+func (p *parser) parseAtRoot(loc Location) stmt {
+	s := &atRootStmt{node: node{loc}}
+	if p.at(css3.LParenToken) {
+		p.advance()
+		p.skipWS()
+		kind := p.expectIdent()
+		switch kind {
+		case "with":
+			s.with = make(map[string]bool)
+		case "without":
+			s.without = make(map[string]bool)
+		default:
+			p.errorf(p.prev(), "Expected \"with\" or \"without\".")
+		}
+		p.skipWS()
+		p.expect(css3.ColonToken, "\":\"")
+		for {
+			p.skipWS()
+			if p.at(css3.RParenToken) {
+				p.advance()
+				break
+			}
+			name := strings.ToLower(p.expectIdent())
+			if s.with != nil {
+				s.with[name] = true
+			} else {
+				s.without[name] = true
+			}
+		}
+		p.skipWS()
+	}
+	if !p.at(css3.LCurlyToken) {
+		selector := p.parseInterp(func() bool { return p.at(css3.LCurlyToken) }, false)
+		s.selector = &selector
+	}
+	s.children = p.parseBlock()
+	return s
+}
+
+func (p *parser) parseImport(loc Location) stmt {
+	s := &importStmt{node: node{loc}}
+	for {
+		p.skipWS()
+		t := p.peek()
+		arg := &importArg{node: node{p.loc(t)}}
+		switch t.TokenType {
+		case css3.StringToken:
+			p.advance()
+			arg.url = t.Value.(string)
+			arg.plain.addText(p.text(t))
+			arg.isPlain = isPlainImport(arg.url)
+		case css3.UrlToken, css3.BadUrlToken, css3.FunctionToken:
+			arg.plain.addExpr(p.parsePrimary())
+			arg.isPlain = true
+		default:
+			p.errorf(t, "Expected string.")
+		}
+		arg.modifiers = p.parseInterp(func() bool {
+			return p.at(css3.CommaToken) || p.at(css3.SemicolonToken) || p.at(css3.RCurlyToken)
+		}, false)
+		if len(arg.modifiers.parts) > 0 {
+			arg.isPlain = true
+		}
+		s.imports = append(s.imports, arg)
+		if !p.at(css3.CommaToken) {
+			break
+		}
+		p.advance()
+	}
+	p.endStatement()
+	return s
+}
+
+func isPlainImport(url string) bool {
+	return strings.HasSuffix(url, ".css") || strings.HasPrefix(url, "http://") ||
+		strings.HasPrefix(url, "https://") || strings.HasPrefix(url, "//")
+}
+
+func (p *parser) parseUse(loc Location) stmt {
+	s := &useStmt{node: node{loc}, url: p.expectString()}
+	s.namespace = defaultNamespace(s.url)
+	p.skipWS()
+	if p.atIdent("as") {
+		p.advance()
+		p.skipWS()
+		if p.atDelim('*') {
+			p.advance()
+			s.namespace = ""
+		} else {
+			s.namespace = p.expectIdent()
+		}
+		p.skipWS()
+	}
+	if p.atIdent("with") {
+		s.config = p.parseConfig(false)
+	}
+	p.endStatement()
+	return s
+}
+
+func defaultNamespace(url string) string {
+	if strings.HasPrefix(url, "sass:") {
+		return url[len("sass:"):]
+	}
+	name := url[strings.LastIndex(url, "/")+1:]
+	if i := strings.Index(name, "."); i >= 0 {
+		name = name[:i]
+	}
+	return strings.TrimPrefix(name, "_")
+}
+
+func (p *parser) parseForward(loc Location) stmt {
+	s := &forwardStmt{node: node{loc}, url: p.expectString()}
+	p.skipWS()
+	if p.atIdent("as") {
+		p.advance()
+		p.skipWS()
+		s.prefix = normName(p.expectIdent())
+		p.expectDelim('*')
+		p.skipWS()
+	}
+	for p.atIdent("show") || p.atIdent("hide") {
+		members := make(map[string]bool)
+		if p.advance().ident() == "show" {
+			s.show = members
+		} else {
+			s.hide = members
+		}
+		for {
+			p.skipWS()
+			if p.atVariable() {
+				members["$"+p.expectVariable()] = true
+			} else {
+				members[normName(p.expectIdent())] = true
+			}
+			p.skipWS()
+			if !p.at(css3.CommaToken) {
+				break
+			}
+			p.advance()
+		}
+	}
+	if p.atIdent("with") {
+		s.config = p.parseConfig(true)
+	}
+	p.endStatement()
+	return s
+}
+
+func (p *parser) parseConfig(allowDefault bool) []*configVar {
+	p.advance()
+	p.skipWS()
+	p.expect(css3.LParenToken, "\"(\"")
+	var config []*configVar
+	for {
+		p.skipWS()
+		if p.at(css3.RParenToken) {
+			p.advance()
+			return config
+		}
+		t := p.peek()
+		v := &configVar{node: node{p.loc(t)}, name: p.expectVariable()}
+		p.skipWS()
+		p.expect(css3.ColonToken, "\":\"")
+		p.skipWS()
+		v.value = p.parseSpaceList()
+		p.skipWS()
+		if allowDefault && p.atDelim('!') && p.peekAt(1).isIdent("default") {
+			p.advance()
+			p.advance()
+			v.isDefault = true
+			p.skipWS()
+		}
+		config = append(config, v)
+		if p.at(css3.CommaToken) {
+			p.advance()
+			continue
+		}
+		p.expect(css3.RParenToken, "\")\"")
+		return config
+	}
+}
@@ -0,0 +1,313 @@
+package scss
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/logan/scss/css3"
+)
+
+// selectorText returns the text of a selector passed to a function: a
+// string, a list of strings, or a comma-separated list of such lists, as
+// returned by selector.parse() or "&".
+func selectorText(v Value) (string, bool) {
+	switch v := v.(type) {
+	case *String:
+		return v.Text, true
+	case *List:
+		parts := make([]string, len(v.Items))
+		for i, item := range v.Items {
+			if l, ok := item.(*List); ok && (v.Separator != CommaSeparator || l.Separator == CommaSeparator) {
+				return "", false
+			}
+			text, ok := selectorText(item)
+			if !ok {
+				return "", false
+			}
+			parts[i] = text
+		}
+		if v.Separator == CommaSeparator {
+			return strings.Join(parts, ", "), true
+		}
+		return strings.Join(parts, " "), true
+	}
+	return "", false
+}
+
+// expectSelector parses the selector passed as the argument name. It may
+// contain "&" only if allowParent is true.
+func expectSelector(v Value, name string, allowParent bool) (css3.SelectorList, error) {
+	text, ok := selectorText(v)
+	if !ok {
+		return nil, fmt.Errorf("$%s: %s is not a valid selector: it must be a string,\na list of strings, or a list of lists of strings.", name, v)
+	}
+	sel, err := css3.ParseSelector(text)
+	if err != nil {
+		return nil, fmt.Errorf("$%s: Invalid selector \"%s\": %s.", name, text, err)
+	}
+	if !allowParent && hasNesting(sel) {
+		return nil, fmt.Errorf("$%s: Parent selectors aren't allowed here.", name)
+	}
+	return sel, nil
+}
+
+// expectCompounds parses a selector list of compound selectors, as given
+// to selector.extend() as the extendee.
+func expectCompounds(v Value, name string) ([]css3.CompoundSelector, error) {
+	sel, err := expectSelector(v, name, false)
+	if err != nil {
+		return nil, err
+	}
+	compounds := make([]css3.CompoundSelector, len(sel))
+	for i, complex := range sel {
+		if len(complex.Components) != 1 || complex.Components[0].Combinator != css3.NoCombinator {
+			return nil, fmt.Errorf("Can't extend complex selector %s.", complex)
+		}
+		compounds[i] = complex.Components[0].Compound
+	}
+	return compounds, nil
+}
+
+// extensionsArgs returns the extensions selector.extend() and
+// selector.replace() apply to their first argument.
+func extensionsArgs(args []Value) (css3.SelectorList, []*extension, error) {
+	sel, err := expectSelector(args[0], "selector", false)
+	if err != nil {
+		return nil, nil, err
+	}
+	targets, err := expectCompounds(args[1], "extendee")
+	if err != nil {
+		return nil, nil, err
+	}
+	extender, err := expectSelector(args[2], "extender", false)
+	if err != nil {
+		return nil, nil, err
+	}
+	exts := make([]*extension, len(targets))
+	for i, target := range targets {
+		exts[i] = &extension{extender: extender, target: target}
+	}
+	return sel, exts, nil
+}
+
+// unifyComplex returns the selectors matching elements matched by both a
+// and b.
+func unifyComplex(a, b *css3.ComplexSelector) []*css3.ComplexSelector {
+	lastA := a.Components[len(a.Components)-1]
+	lastB := b.Components[len(b.Components)-1]
+	unified := unifyCompounds(lastA.Compound, lastB.Compound)
+	if unified == nil {
+		return nil
+	}
+	var result []*css3.ComplexSelector
+	for _, woven := range weave(a.Components[:len(a.Components)-1], b.Components[:len(b.Components)-1], lastA.Combinator, lastB.Combinator) {
+		components := append(woven.components, css3.ComplexComponent{Combinator: woven.combinator, Compound: unified})
+		result = append(result, &css3.ComplexSelector{Components: components})
+	}
+	return result
+}
+
+// compoundIsSuperselector reports whether every element matched by sub is
+// matched by super.
+func compoundIsSuperselector(super, sub css3.CompoundSelector) bool {
+	for _, simple := range super {
+		if simple.Type == css3.UniversalSelector && simple.Namespace == "" {
+			continue
+		}
+		if !compoundContains(sub, simple) {
+			return false
+		}
+	}
+	return true
+}
+
+func complexIsSuperselector(super, sub []css3.ComplexComponent) bool {
+	if len(super) == 0 {
+		return true
+	}
+	if len(sub) == 0 {
+		return false
+	}
+	last, subLast := super[len(super)-1], sub[len(sub)-1]
+	if !compoundIsSuperselector(last.Compound, subLast.Compound) {
+		return false
+	}
+	super, sub = super[:len(super)-1], sub[:len(sub)-1]
+	if len(super) == 0 {
+		return true
+	}
+	descendant := func(c css3.Combinator) bool {
+		return c == css3.DescendantCombinator || c == css3.ChildCombinator
+	}
+	if last.Combinator != css3.DescendantCombinator {
+		return subLast.Combinator == last.Combinator && complexIsSuperselector(super, sub)
+	}
+	if !descendant(subLast.Combinator) {
+		return false
+	}
+	// Any ancestor in sub may match the rest of super, as long as the
+	// compounds skipped over are ancestors too.
+	for i := len(sub); i > 0; i-- {
+		if i < len(sub) && !descendant(sub[i].Combinator) {
+			return false
+		}
+		if complexIsSuperselector(super, sub[:i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	m := builtinModule("selector")
+	m.define("parse($selector)", func(args []Value) (Value, error) {
+		sel, err := expectSelector(args[0], "selector", false)
+		if err != nil {
+			return nil, err
+		}
+		return selectorValue(sel), nil
+	}, "selector-parse")
+
+	m.define("nest($selectors...)", func(args []Value) (Value, error) {
+		selectors := args[0].(*ArgList).Items
+		if len(selectors) == 0 {
+			return nil, fmt.Errorf("$selectors: At least one selector must be passed.")
+		}
+		result, err := expectSelector(selectors[0], "selectors", false)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range selectors[1:] {
+			sel, err := expectSelector(v, "selectors", true)
+			if err != nil {
+				return nil, err
+			}
+			if result, err = nestSelector(result, sel, true); err != nil {
+				return nil, err
+			}
+		}
+		return selectorValue(result), nil
+	}, "selector-nest")
+
+	m.define("append($selectors...)", func(args []Value) (Value, error) {
+		selectors := args[0].(*ArgList).Items
+		if len(selectors) == 0 {
+			return nil, fmt.Errorf("$selectors: At least one selector must be passed.")
+		}
+		result, err := expectSelector(selectors[0], "selectors", false)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range selectors[1:] {
+			sel, err := expectSelector(v, "selectors", false)
+			if err != nil {
+				return nil, err
+			}
+			// Appending is nesting with "&" at the start of each selector,
+			// which absorbs a leading type selector as a suffix, as in
+			// ".a" and "-b" becoming ".a-b".
+			appended := make(css3.SelectorList, len(sel))
+			for i, complex := range sel {
+				first := complex.Components[0]
+				if first.Combinator != css3.NoCombinator || first.Compound[0].Type == css3.UniversalSelector {
+					return nil, fmt.Errorf("Can't append %s to %s.", complex, result)
+				}
+				parent := &css3.SimpleSelector{Type: css3.NestingSelector}
+				rest := first.Compound
+				if rest[0].Type == css3.TypeSelector && rest[0].Namespace == "" {
+					parent.Suffix = rest[0].Name
+					rest = rest[1:]
+				}
+				components := append([]css3.ComplexComponent(nil), complex.Components...)
+				components[0].Compound = append(css3.CompoundSelector{parent}, rest...)
+				appended[i] = &css3.ComplexSelector{Components: components}
+			}
+			if result, err = nestSelector(result, appended, true); err != nil {
+				return nil, err
+			}
+		}
+		return selectorValue(result), nil
+	}, "selector-append")
+
+	m.define("extend($selector, $extendee, $extender)", func(args []Value) (Value, error) {
+		sel, exts, err := extensionsArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		return selectorValue(extendList(sel, exts)), nil
+	}, "selector-extend")
+
+	m.define("replace($selector, $original, $replacement)", func(args []Value) (Value, error) {
+		sel, exts, err := extensionsArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		var result css3.SelectorList
+		for _, complex := range sel {
+			extended := extendList(css3.SelectorList{complex}, exts)
+			if len(extended) > 1 {
+				extended = extended[1:]
+			}
+			result = append(result, extended...)
+		}
+		return selectorValue(result), nil
+	}, "selector-replace")
+
+	m.define("unify($selector1, $selector2)", func(args []Value) (Value, error) {
+		sel1, err := expectSelector(args[0], "selector1", false)
+		if err != nil {
+			return nil, err
+		}
+		sel2, err := expectSelector(args[1], "selector2", false)
+		if err != nil {
+			return nil, err
+		}
+		var result css3.SelectorList
+		for _, a := range sel1 {
+			for _, b := range sel2 {
+				result = append(result, unifyComplex(a, b)...)
+			}
+		}
+		if len(result) == 0 {
+			return Null, nil
+		}
+		return selectorValue(result), nil
+	}, "selector-unify")
+
+	m.define("is-superselector($super, $sub)", func(args []Value) (Value, error) {
+		super, err := expectSelector(args[0], "super", false)
+		if err != nil {
+			return nil, err
+		}
+		sub, err := expectSelector(args[1], "sub", false)
+		if err != nil {
+			return nil, err
+		}
+	next:
+		for _, complex := range sub {
+			for _, candidate := range super {
+				if complexIsSuperselector(candidate.Components, complex.Components) {
+					continue next
+				}
+			}
+			return Bool(false), nil
+		}
+		return Bool(true), nil
+	}, "is-superselector")
+
+	m.define("simple-selectors($selector)", func(args []Value) (Value, error) {
+		sel, err := expectSelector(args[0], "selector", false)
+		if err != nil {
+			return nil, err
+		}
+		if len(sel) != 1 || len(sel[0].Components) != 1 || sel[0].Components[0].Combinator != css3.NoCombinator {
+			return nil, fmt.Errorf("$selector: %s is not a compound selector.", sel)
+		}
+		compound := sel[0].Components[0].Compound
+		items := make([]Value, len(compound))
+		for i, simple := range compound {
+			items[i] = &String{Text: simple.String()}
+		}
+		return NewList(items, CommaSeparator), nil
+	}, "simple-selectors")
+}
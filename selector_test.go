@@ -0,0 +1,56 @@
+package scss
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSelectorFunctions(t *testing.T) {
+	eval := func(expr string) string { return debugOutput("selector", "$v: "+expr+";\n@debug \"#{$v}\";") }
+
+	Convey("parsing", t, func() {
+		So(eval("selector.parse(\".a > .b, c\")"), ShouldEqual, ".a > .b, c")
+		So(eval("length(selector-parse(\".a > .b\"))"), ShouldEqual, "1")
+		So(eval("length(nth(selector-parse(\".a > .b\"), 1))"), ShouldEqual, "3")
+		So(eval("simple-selectors(\"a.b:hover\")"), ShouldEqual, "a, .b, :hover")
+		So(eval("selector.simple-selectors(\"a b\")"), ShouldEqual, "stdin:2:5: $selector: a b is not a compound selector.")
+		So(eval("selector.parse(1px)"), ShouldStartWith, "stdin:2:5: $selector: 1px is not a valid selector")
+	})
+
+	Convey("nesting and appending", t, func() {
+		So(eval("selector.nest(\".a\", \".b\")"), ShouldEqual, ".a .b")
+		So(eval("selector-nest(\".a, .b\", \"&:hover\")"), ShouldEqual, ".a:hover, .b:hover")
+		So(eval("selector.nest(\".a\", \"> .b\", \"c\")"), ShouldEqual, ".a > .b c")
+		So(eval("selector.nest(\"&\", \".b\")"), ShouldEqual, "stdin:2:5: $selectors: Parent selectors aren't allowed here.")
+		So(eval("selector.append(\".a\", \".b\")"), ShouldEqual, ".a.b")
+		So(eval("selector-append(\".a\", \"-suffix\")"), ShouldEqual, ".a-suffix")
+		So(eval("selector.append(\".a, .b\", \":hover\")"), ShouldEqual, ".a:hover, .b:hover")
+		So(eval("selector.append(\".a\", \"> .b\")"), ShouldEqual, "stdin:2:5: Can't append > .b to .a.")
+	})
+
+	Convey("extending", t, func() {
+		So(eval("selector.extend(\"a.disabled\", \".disabled\", \".inactive\")"), ShouldEqual, "a.disabled, a.inactive")
+		So(eval("selector-extend(\".a .b\", \".b\", \".c .d\")"), ShouldEqual, ".a .b, .a .c .d, .c .a .d")
+		So(eval("selector.replace(\"a.disabled\", \".disabled\", \".inactive\")"), ShouldEqual, "a.inactive")
+		So(eval("selector.replace(\"a, b\", \"a\", \"c\")"), ShouldEqual, "c, b")
+		So(eval("selector.extend(\"a\", \"b c\", \"d\")"), ShouldEqual, "stdin:2:5: Can't extend complex selector b c.")
+	})
+
+	Convey("unifying", t, func() {
+		So(eval("selector.unify(\".a\", \"b\")"), ShouldEqual, "b.a")
+		So(eval("selector-unify(\"a\", \"b\")"), ShouldEqual, "")
+		So(eval("selector.unify(\".a .b\", \".c\")"), ShouldEqual, ".a .b.c")
+		So(eval("selector.unify(\"#a\", \"#b\")"), ShouldEqual, "")
+	})
+
+	Convey("superselectors", t, func() {
+		So(eval("is-superselector(\"a\", \"a.disabled\")"), ShouldEqual, "true")
+		So(eval("is-superselector(\"a.disabled\", \"a\")"), ShouldEqual, "false")
+		So(eval("is-superselector(\".a .b\", \".a > .c .b.d\")"), ShouldEqual, "true")
+		So(eval("is-superselector(\".a > .b\", \".a .b\")"), ShouldEqual, "false")
+		So(eval("is-superselector(\".a .b\", \".a ~ .b\")"), ShouldEqual, "false")
+		So(eval("is-superselector(\"a, b\", \"b.c\")"), ShouldEqual, "true")
+		So(eval("selector.is-superselector(\"*\", \"a\")"), ShouldEqual, "true")
+	})
+}
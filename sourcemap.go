@@ -0,0 +1,135 @@
+package scss
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"unicode/utf8"
+)
+
+// mapping associates a position in the generated CSS with the location in a
+// stylesheet it was generated from. Lines and columns are counted from 0,
+// with columns measured in UTF-16 code units as source map consumers expect.
+type mapping struct {
+	line, column int
+	loc          Location
+}
+
+// sourceMap is the JSON form of a Source Map v3 document.
+type sourceMap struct {
+	Version        int      `json:"version"`
+	SourceRoot     string   `json:"sourceRoot,omitempty"`
+	Sources        []string `json:"sources"`
+	SourcesContent []string `json:"sourcesContent,omitempty"`
+	Names          []string `json:"names"`
+	Mappings       string   `json:"mappings"`
+}
+
+// utf16Len returns the length of b in UTF-16 code units.
+func utf16Len(b []byte) int {
+	n := 0
+	for len(b) > 0 {
+		ch, size := utf8.DecodeRune(b)
+		n++
+		if ch >= 0x10000 {
+			n++
+		}
+		b = b[size:]
+	}
+	return n
+}
+
+// sourceColumn returns the column of loc in UTF-16 code units from the start
+// of its line, counting from 0.
+func sourceColumn(src []byte, loc Location) int {
+	if loc.Offset > len(src) {
+		return loc.Column - 1
+	}
+	line := src[:loc.Offset]
+	if i := bytes.LastIndexByte(line, '\n'); i >= 0 {
+		line = line[i+1:]
+	}
+	return utf16Len(line)
+}
+
+// buildSourceMap returns the source map for the given mappings, which must
+// be in the order of the generated CSS.
+func (e *evaluator) buildSourceMap(mappings []mapping) string {
+	m := &sourceMap{Version: 3, SourceRoot: e.opts.SourceMapRoot, Names: []string{}}
+	sources := make(map[string]int)
+	var buf bytes.Buffer
+	var line, column, source, srcLine, srcColumn int
+	for i, mp := range mappings {
+		index, ok := sources[mp.loc.File]
+		if !ok {
+			index = len(m.Sources)
+			sources[mp.loc.File] = index
+			m.Sources = append(m.Sources, mp.loc.File)
+			if e.opts.SourceMapContents {
+				m.SourcesContent = append(m.SourcesContent, string(e.source(mp.loc.File)))
+			}
+		}
+		if mp.line > line {
+			buf.Write(bytes.Repeat([]byte{';'}, mp.line-line))
+			line, column = mp.line, 0
+		} else if i > 0 {
+			buf.WriteByte(',')
+		}
+		col := sourceColumn(e.source(mp.loc.File), mp.loc)
+		writeVLQ(&buf, mp.column-column)
+		writeVLQ(&buf, index-source)
+		writeVLQ(&buf, mp.loc.Line-1-srcLine)
+		writeVLQ(&buf, col-srcColumn)
+		column, source, srcLine, srcColumn = mp.column, index, mp.loc.Line-1, col
+	}
+	if m.Sources == nil {
+		m.Sources = []string{}
+	}
+	m.Mappings = buf.String()
+	js, _ := json.Marshal(m)
+	return string(js)
+}
+
+// source returns the text of a loaded stylesheet.
+func (e *evaluator) source(name string) []byte {
+	if sheet, ok := e.sheets[name]; ok {
+		return sheet.src
+	}
+	return nil
+}
+
+const base64Digits = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// writeVLQ writes n as a base64 variable-length quantity: the sign in the
+// lowest bit, then five bits per digit with the sixth bit set on all digits
+// but the last.
+func writeVLQ(buf *bytes.Buffer, n int) {
+	v := n << 1
+	if n < 0 {
+		v = -n<<1 | 1
+	}
+	for {
+		digit := v & 0x1f
+		v >>= 5
+		if v > 0 {
+			digit |= 0x20
+		}
+		buf.WriteByte(base64Digits[digit])
+		if v == 0 {
+			return
+		}
+	}
+}
+
+// sourceMappingURL returns the comment linking the CSS to its source map,
+// or "" if none was requested.
+func (e *evaluator) sourceMappingURL(sourceMap string) string {
+	url := e.opts.SourceMapURL
+	if e.opts.SourceMapEmbed {
+		url = "data:application/json;base64," + base64.StdEncoding.EncodeToString([]byte(sourceMap))
+	}
+	if url == "" {
+		return ""
+	}
+	return "/*# sourceMappingURL=" + url + " */"
+}
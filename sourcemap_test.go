@@ -0,0 +1,69 @@
+package scss
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSourceMap(t *testing.T) {
+	Convey("VLQ encoding", t, func() {
+		for n, expected := range map[int]string{0: "A", 1: "C", -1: "D", 15: "e", 16: "gB", -17: "jB", 1000: "w+B"} {
+			var buf bytes.Buffer
+			writeVLQ(&buf, n)
+			So(buf.String(), ShouldEqual, expected)
+		}
+	})
+
+	Convey("mappings", t, func() {
+		fs := mapFS{
+			"main.scss": "@import \"b\";\n@mixin m {\n  x: y;\n}\n.a {\n  color: red;\n  @include m;\n}\n",
+			"_b.scss":   "p { q: r; }",
+		}
+		result, err := Compile(fs, "main.scss", Options{SourceMap: true, SourceMapContents: true, SourceMapRoot: "/src/"})
+		So(err, ShouldBeNil)
+		So(result.CSS, ShouldEqual, "p {\n  q: r;\n}\n\n.a {\n  color: red;\n  x: y;\n}")
+
+		var m sourceMap
+		So(json.Unmarshal([]byte(result.SourceMap), &m), ShouldBeNil)
+		So(m.Version, ShouldEqual, 3)
+		So(m.SourceRoot, ShouldEqual, "/src/")
+		So(m.Sources, ShouldResemble, []string{"_b.scss", "main.scss"})
+		So(m.SourcesContent, ShouldResemble, []string{fs["_b.scss"], fs["main.scss"]})
+		// "x: y" maps back into the mixin that produced it.
+		So(m.Mappings, ShouldEqual, "AAAA;EAAI;;;ACIJ;EACE;EAHA")
+	})
+
+	Convey("charset shifts mappings", t, func() {
+		result, err := CompileString(`a { b: "é"; }`, Options{SourceMap: true})
+		So(err, ShouldBeNil)
+		So(result.SourceMap, ShouldContainSubstring, `"mappings":";AAAA;EAAI"`)
+
+		result, err = CompileString(`a { b: "é"; }`, Options{SourceMap: true, OutputStyle: Compressed})
+		So(err, ShouldBeNil)
+		So(result.SourceMap, ShouldContainSubstring, `"mappings":"CAAA,EAAI"`)
+	})
+
+	Convey("sourceMappingURL comment", t, func() {
+		result, err := CompileString("a { b: c; }", Options{SourceMap: true, SourceMapURL: "out.css.map"})
+		So(err, ShouldBeNil)
+		So(result.CSS, ShouldEqual, "a {\n  b: c;\n}\n\n/*# sourceMappingURL=out.css.map */")
+
+		result, err = CompileString("a { b: c; }", Options{SourceMapEmbed: true})
+		So(err, ShouldBeNil)
+		prefix := "a {\n  b: c;\n}\n\n/*# sourceMappingURL=data:application/json;base64,"
+		So(strings.HasPrefix(result.CSS, prefix), ShouldBeTrue)
+		encoded := strings.TrimSuffix(strings.TrimPrefix(result.CSS, prefix), " */")
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		So(err, ShouldBeNil)
+		So(string(decoded), ShouldEqual, result.SourceMap)
+
+		result, err = CompileString("a { b: c; }", Options{})
+		So(err, ShouldBeNil)
+		So(result.SourceMap, ShouldEqual, "")
+	})
+}
@@ -0,0 +1,183 @@
+package scss
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"unicode/utf8"
+)
+
+// codepointIndex returns the zero-based code point offset that the Sass
+// string index i refers to in a string of length code points. Negative
+// indices count from the end.
+func codepointIndex(i, length int, allowNegative bool) int {
+	switch {
+	case i == 0:
+		return 0
+	case i > 0:
+		return min(i-1, length)
+	}
+	if result := length + i; result >= 0 || allowNegative {
+		return result
+	}
+	return 0
+}
+
+// substring returns the code points of s from start up to but excluding end.
+func substring(s string, start, end int) string {
+	runes := []rune(s)
+	return string(runes[start:end])
+}
+
+// asciiCase returns a function that converts the ASCII letters of a string
+// with mapping, leaving other characters alone as Sass does.
+func asciiCase(mapping func(rune) rune) Function {
+	return func(args []Value) (Value, error) {
+		s, err := ExpectString(args[0], "string")
+		if err != nil {
+			return nil, err
+		}
+		text := strings.Map(func(r rune) rune {
+			if r < utf8.RuneSelf {
+				return mapping(r)
+			}
+			return r
+		}, s.Text)
+		return &String{Text: text, Quoted: s.Quoted}, nil
+	}
+}
+
+func init() {
+	m := builtinModule("string")
+	m.define("quote($string)", func(args []Value) (Value, error) {
+		s, err := ExpectString(args[0], "string")
+		if err != nil {
+			return nil, err
+		}
+		return &String{Text: s.Text, Quoted: true}, nil
+	}, "quote")
+
+	m.define("unquote($string)", func(args []Value) (Value, error) {
+		s, err := ExpectString(args[0], "string")
+		if err != nil {
+			return nil, err
+		}
+		return &String{Text: s.Text}, nil
+	}, "unquote")
+
+	m.define("length($string)", func(args []Value) (Value, error) {
+		s, err := ExpectString(args[0], "string")
+		if err != nil {
+			return nil, err
+		}
+		return NewNumber(float64(utf8.RuneCountInString(s.Text)), ""), nil
+	}, "str-length")
+
+	m.define("index($string, $substring)", func(args []Value) (Value, error) {
+		s, err := ExpectString(args[0], "string")
+		if err != nil {
+			return nil, err
+		}
+		sub, err := ExpectString(args[1], "substring")
+		if err != nil {
+			return nil, err
+		}
+		i := strings.Index(s.Text, sub.Text)
+		if i < 0 {
+			return Null, nil
+		}
+		return NewNumber(float64(utf8.RuneCountInString(s.Text[:i])+1), ""), nil
+	}, "str-index")
+
+	m.define("insert($string, $insert, $index)", func(args []Value) (Value, error) {
+		s, err := ExpectString(args[0], "string")
+		if err != nil {
+			return nil, err
+		}
+		insert, err := ExpectString(args[1], "insert")
+		if err != nil {
+			return nil, err
+		}
+		index, err := ExpectInt(args[2], "index")
+		if err != nil {
+			return nil, err
+		}
+		length := utf8.RuneCountInString(s.Text)
+		if index < 0 {
+			// A negative index inserts after the character it refers to.
+			index = length + index + 2
+		}
+		i := codepointIndex(index, length, false)
+		text := substring(s.Text, 0, i) + insert.Text + substring(s.Text, i, length)
+		return &String{Text: text, Quoted: s.Quoted}, nil
+	}, "str-insert")
+
+	m.define("slice($string, $start-at, $end-at: -1)", func(args []Value) (Value, error) {
+		s, err := ExpectString(args[0], "string")
+		if err != nil {
+			return nil, err
+		}
+		startAt, err := ExpectInt(args[1], "start-at")
+		if err != nil {
+			return nil, err
+		}
+		endAt, err := ExpectInt(args[2], "end-at")
+		if err != nil {
+			return nil, err
+		}
+		length := utf8.RuneCountInString(s.Text)
+		start := codepointIndex(startAt, length, false)
+		end := codepointIndex(endAt, length, true)
+		if end == length {
+			end--
+		}
+		if end < start {
+			return &String{Quoted: s.Quoted}, nil
+		}
+		return &String{Text: substring(s.Text, start, end+1), Quoted: s.Quoted}, nil
+	}, "str-slice")
+
+	m.define("to-upper-case($string)", asciiCase(func(r rune) rune {
+		if 'a' <= r && r <= 'z' {
+			return r - 'a' + 'A'
+		}
+		return r
+	}), "to-upper-case")
+	m.define("to-lower-case($string)", asciiCase(func(r rune) rune {
+		if 'A' <= r && r <= 'Z' {
+			return r - 'A' + 'a'
+		}
+		return r
+	}), "to-lower-case")
+
+	m.define("unique-id()", func(args []Value) (Value, error) {
+		return &String{Text: fmt.Sprintf("u%08x", rand.Uint32())}, nil
+	}, "unique-id")
+
+	m.define("split($string, $separator, $limit: null)", func(args []Value) (Value, error) {
+		s, err := ExpectString(args[0], "string")
+		if err != nil {
+			return nil, err
+		}
+		sep, err := ExpectString(args[1], "separator")
+		if err != nil {
+			return nil, err
+		}
+		limit := -1
+		if args[2] != Null {
+			if limit, err = ExpectInt(args[2], "limit"); err != nil {
+				return nil, err
+			}
+			if limit < 1 {
+				return nil, fmt.Errorf("$limit: Must be 1 or greater, was %d.", limit)
+			}
+			limit++
+		}
+		parts := strings.SplitN(s.Text, sep.Text, limit)
+		items := make([]Value, len(parts))
+		for i, part := range parts {
+			items[i] = &String{Text: part, Quoted: s.Quoted}
+		}
+		return &List{Items: items, Separator: CommaSeparator, Bracketed: true}, nil
+	})
+}
@@ -0,0 +1,46 @@
+package scss
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStringFunctions(t *testing.T) {
+	eval := moduleEval("string", "")
+
+	Convey("quoting", t, func() {
+		So(eval("quote(abc)"), ShouldEqual, "abc")
+		So(eval("unquote(\"a b\")"), ShouldEqual, "a b")
+		So(eval("string.quote(1px)"), ShouldEqual, "stdin:2:8: $string: 1px is not a string.")
+	})
+
+	Convey("indexing by code point", t, func() {
+		So(eval("str-length(\"héllo\")"), ShouldEqual, "5")
+		So(eval("string.length(\"👍🏽\")"), ShouldEqual, "2")
+		So(eval("str-index(\"héllo\", \"llo\")"), ShouldEqual, "3")
+		So(eval("str-index(abc, d)"), ShouldEqual, "null")
+		So(eval("str-insert(\"abcd\", \"X\", 1)"), ShouldEqual, "Xabcd")
+		So(eval("str-insert(\"abcd\", \"X\", -1)"), ShouldEqual, "abcdX")
+		So(eval("str-insert(\"abcd\", \"X\", 100)"), ShouldEqual, "abcdX")
+		So(eval("str-insert(\"abcd\", \"X\", -100)"), ShouldEqual, "Xabcd")
+		So(eval("str-slice(\"héllo\", 2, 3)"), ShouldEqual, "él")
+		So(eval("string.slice(\"héllo\", -3)"), ShouldEqual, "llo")
+		So(eval("string.slice(abc, 3, 1)"), ShouldEqual, "")
+	})
+
+	Convey("case and splitting", t, func() {
+		So(eval("to-upper-case(\"abç\")"), ShouldEqual, "ABç")
+		So(eval("to-lower-case(ABC)"), ShouldEqual, "abc")
+		So(eval("string.split(\"a b c\", \" \")"), ShouldEqual, `["a", "b", "c"]`)
+		So(eval("string.split(\"a b c\", \" \", 1)"), ShouldEqual, `["a", "b c"]`)
+		So(eval("string.split(abc, \"\")"), ShouldEqual, "[a, b, c]")
+		So(eval("string.split(abc, b, 0)"), ShouldEqual, "stdin:2:8: $limit: Must be 1 or greater, was 0.")
+	})
+
+	Convey("unique ids", t, func() {
+		id := eval("unique-id()")
+		So(id, ShouldStartWith, "u")
+		So(eval("unique-id() != unique-id()"), ShouldEqual, "true")
+	})
+}
@@ -0,0 +1,480 @@
+package scss
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/logan/scss/css3"
+)
+
+// Value is a SassScript value. String returns the value as it would be shown
+// by inspect(), which for most values matches its CSS form.
+type Value interface {
+	TypeName() string
+	Truthy() bool
+	Equal(other Value) bool
+	String() string
+}
+
+type Bool bool
+
+const (
+	True  = Bool(true)
+	False = Bool(false)
+)
+
+func (b Bool) TypeName() string { return "bool" }
+func (b Bool) Truthy() bool     { return bool(b) }
+func (b Bool) Equal(other Value) bool {
+	o, ok := other.(Bool)
+	return ok && o == b
+}
+func (b Bool) String() string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
+
+type nullValue struct{}
+
+var Null Value = nullValue{}
+
+func (nullValue) TypeName() string       { return "null" }
+func (nullValue) Truthy() bool           { return false }
+func (nullValue) Equal(other Value) bool { return other == Null }
+func (nullValue) String() string         { return "null" }
+
+type String struct {
+	Text   string
+	Quoted bool
+
+	// slash holds the operands of a "/" between values that aren't both
+	// numbers, as in "0 / var(--a)", so that color functions can read the
+	// second as an alpha channel.
+	slash *[2]Value
+}
+
+func NewString(text string) *String { return &String{Text: text, Quoted: true} }
+
+func (s *String) TypeName() string { return "string" }
+func (s *String) Truthy() bool     { return true }
+func (s *String) Equal(other Value) bool {
+	o, ok := other.(*String)
+	return ok && o.Text == s.Text
+}
+func (s *String) String() string {
+	if s.Quoted {
+		return quoteString(s.Text)
+	}
+	return s.Text
+}
+
+// quoteString quotes s the way Sass does, preferring double quotes unless s
+// contains them and no single quotes.
+func quoteString(s string) string {
+	quote := byte('"')
+	if strings.IndexByte(s, '"') >= 0 && strings.IndexByte(s, '\'') < 0 {
+		quote = '\''
+	}
+	var buf bytes.Buffer
+	buf.WriteByte(quote)
+	for i, ch := range s {
+		switch {
+		case ch == rune(quote) || ch == '\\':
+			buf.WriteByte('\\')
+			buf.WriteRune(ch)
+		case ch == '\n' || (ch < 0x20 && ch != '\t') || ch == 0x7f:
+			fmt.Fprintf(&buf, "\\%x", ch)
+			if next := s[i+1:]; next != "" && (isHex(next[0]) || next[0] == ' ') {
+				buf.WriteByte(' ')
+			}
+		default:
+			buf.WriteRune(ch)
+		}
+	}
+	buf.WriteByte(quote)
+	return buf.String()
+}
+
+func isHex(ch byte) bool {
+	return isDigit(ch) || (ch >= 'a' && ch <= 'f') || (ch >= 'A' && ch <= 'F')
+}
+
+// Color is a color value. Colors written literally remember how they were
+// written so they can be output the same way.
+type Color struct {
+	css3.Color
+}
+
+func NewColor(r, g, b, a float64) *Color {
+	return &Color{Color: *css3.RGBA(r/255, g/255, b/255, a)}
+}
+
+func (c *Color) TypeName() string { return "color" }
+func (c *Color) Truthy() bool     { return true }
+func (c *Color) Equal(other Value) bool {
+	o, ok := other.(*Color)
+	return ok && c.Red() == o.Red() && c.Green() == o.Green() && c.Blue() == o.Blue() && fuzzyEqual(c.A, o.A)
+}
+
+// Red, Green and Blue return the color's channels in the range 0-255.
+func (c *Color) Red() int   { return channel(c.R) }
+func (c *Color) Green() int { return channel(c.G) }
+func (c *Color) Blue() int  { return channel(c.B) }
+
+func channel(v float64) int {
+	return int(math.Floor(clamp(v, 0, 1)*255 + 0.5 + epsilon))
+}
+
+func clamp(v, min, max float64) float64 {
+	return math.Max(min, math.Min(max, v))
+}
+
+func (c *Color) String() string {
+	return c.Format(false)
+}
+
+// Format returns the CSS for c, as css3.Color.Format does once its channels
+// are rounded to whole numbers the way Sass outputs them.
+func (c *Color) Format(compressed bool) string {
+	rounded := c.Color
+	rounded.R, rounded.G, rounded.B = float64(c.Red())/255, float64(c.Green())/255, float64(c.Blue())/255
+	return rounded.Format(compressed)
+}
+
+type Separator int
+
+const (
+	UndecidedSeparator Separator = iota
+	SpaceSeparator
+	CommaSeparator
+	SlashSeparator
+)
+
+func (s Separator) String() string {
+	switch s {
+	case CommaSeparator:
+		return "comma"
+	case SlashSeparator:
+		return "slash"
+	default:
+		return "space"
+	}
+}
+
+type List struct {
+	Items     []Value
+	Separator Separator
+	Bracketed bool
+}
+
+func NewList(items []Value, sep Separator) *List {
+	return &List{Items: items, Separator: sep}
+}
+
+func (l *List) TypeName() string { return "list" }
+func (l *List) Truthy() bool     { return true }
+func (l *List) Equal(other Value) bool {
+	switch o := other.(type) {
+	case *List:
+		return l.Bracketed == o.Bracketed && listsEqual(l.Items, l.Separator, o.Items, o.Separator)
+	case *ArgList:
+		return !l.Bracketed && listsEqual(l.Items, l.Separator, o.Items, o.Separator)
+	case *Map:
+		return len(l.Items) == 0 && len(o.Keys) == 0
+	}
+	return false
+}
+
+func listsEqual(a []Value, asep Separator, b []Value, bsep Separator) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	if len(a) == 0 {
+		return true
+	}
+	if asep != bsep && len(a) > 1 {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *List) String() string {
+	return inspectList(l.Items, l.Separator, l.Bracketed)
+}
+
+func inspectList(items []Value, sep Separator, bracketed bool) string {
+	var buf bytes.Buffer
+	if bracketed {
+		buf.WriteByte('[')
+	} else if len(items) == 0 {
+		return "()"
+	}
+	for i, item := range items {
+		if i > 0 {
+			buf.WriteString(separatorText(sep, false))
+		}
+		needsParens := false
+		if l, ok := item.(*List); ok && !l.Bracketed && len(l.Items) > 1 {
+			needsParens = l.Separator == CommaSeparator || sep != CommaSeparator
+		}
+		if needsParens {
+			buf.WriteByte('(')
+		}
+		buf.WriteString(item.String())
+		if needsParens {
+			buf.WriteByte(')')
+		}
+	}
+	if len(items) == 1 && sep == CommaSeparator {
+		buf.WriteByte(',')
+	}
+	if bracketed {
+		buf.WriteByte(']')
+	}
+	return buf.String()
+}
+
+func separatorText(sep Separator, compressed bool) string {
+	switch {
+	case sep == CommaSeparator && compressed:
+		return ","
+	case sep == CommaSeparator:
+		return ", "
+	case sep == SlashSeparator:
+		return "/"
+	default:
+		return " "
+	}
+}
+
+// ArgList is the list bound to a rest parameter. Its keywords are any named
+// arguments that weren't bound to other parameters.
+type ArgList struct {
+	List
+	Keywords *Map
+}
+
+func (a *ArgList) TypeName() string { return "arglist" }
+func (a *ArgList) Equal(other Value) bool {
+	return (&a.List).Equal(other)
+}
+
+// Map is an ordered map of Sass values.
+type Map struct {
+	Keys   []Value
+	Values []Value
+}
+
+func NewMap() *Map { return &Map{} }
+
+func (m *Map) TypeName() string { return "map" }
+func (m *Map) Truthy() bool     { return true }
+
+func (m *Map) Equal(other Value) bool {
+	switch o := other.(type) {
+	case *Map:
+		if len(o.Keys) != len(m.Keys) {
+			return false
+		}
+		for i, key := range m.Keys {
+			v, ok := o.Get(key)
+			if !ok || !v.Equal(m.Values[i]) {
+				return false
+			}
+		}
+		return true
+	case *List:
+		return len(m.Keys) == 0 && len(o.Items) == 0 && !o.Bracketed
+	}
+	return false
+}
+
+func (m *Map) index(key Value) int {
+	for i, k := range m.Keys {
+		if k.Equal(key) {
+			return i
+		}
+	}
+	return -1
+}
+
+func (m *Map) Get(key Value) (Value, bool) {
+	if i := m.index(key); i >= 0 {
+		return m.Values[i], true
+	}
+	return nil, false
+}
+
+// Set associates value with key, keeping the position of an existing key.
+func (m *Map) Set(key, value Value) {
+	if i := m.index(key); i >= 0 {
+		m.Values[i] = value
+		return
+	}
+	m.Keys = append(m.Keys, key)
+	m.Values = append(m.Values, value)
+}
+
+func (m *Map) String() string {
+	var buf bytes.Buffer
+	buf.WriteByte('(')
+	for i, key := range m.Keys {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(inspectMapPart(key))
+		buf.WriteString(": ")
+		buf.WriteString(inspectMapPart(m.Values[i]))
+	}
+	buf.WriteByte(')')
+	return buf.String()
+}
+
+func inspectMapPart(v Value) string {
+	if l, ok := v.(*List); ok && l.Separator == CommaSeparator && len(l.Items) > 1 && !l.Bracketed {
+		return "(" + l.String() + ")"
+	}
+	return v.String()
+}
+
+// listItems returns the items of v viewed as a list. Maps are lists of
+// two-element key/value lists, and any other value is a list of one.
+func listItems(v Value) []Value {
+	switch v := v.(type) {
+	case *List:
+		return v.Items
+	case *ArgList:
+		return v.Items
+	case *Map:
+		items := make([]Value, len(v.Keys))
+		for i, key := range v.Keys {
+			items[i] = NewList([]Value{key, v.Values[i]}, SpaceSeparator)
+		}
+		return items
+	}
+	return []Value{v}
+}
+
+// listSeparator returns the separator of v viewed as a list, which is
+// undecided for values that aren't lists.
+func listSeparator(v Value) Separator {
+	switch v := v.(type) {
+	case *List:
+		return v.Separator
+	case *ArgList:
+		return v.Separator
+	case *Map:
+		if len(v.Keys) > 0 {
+			return CommaSeparator
+		}
+	}
+	return UndecidedSeparator
+}
+
+// FunctionValue is a reference to a function, as returned by
+// meta.get-function(). It refers to a plain CSS function if it has no
+// callable.
+type FunctionValue struct {
+	Name     string
+	callable *callable
+}
+
+func (f *FunctionValue) TypeName() string { return "function" }
+func (f *FunctionValue) Truthy() bool     { return true }
+func (f *FunctionValue) Equal(other Value) bool {
+	o, ok := other.(*FunctionValue)
+	return ok && o.Name == f.Name && o.callable == f.callable
+}
+func (f *FunctionValue) String() string {
+	return "get-function(" + quoteString(f.Name) + ")"
+}
+
+// toCSS serializes v as a CSS property value.
+func toCSS(v Value, compressed bool) (string, error) {
+	switch v := v.(type) {
+	case nullValue:
+		return "", nil
+	case *Number:
+		if v.slash != nil {
+			left, err := toCSS(v.slash[0], compressed)
+			if err != nil {
+				return "", err
+			}
+			right, err := toCSS(v.slash[1], compressed)
+			return left + "/" + right, err
+		}
+		if len(v.Denominators) > 0 || len(v.Numerators) > 1 {
+			return "", fmt.Errorf("%s isn't a valid CSS value.", v)
+		}
+		if !isFinite(v.Value) {
+			// As in dart-sass, these are written as calculations.
+			return "calc(" + nonFiniteString(v, compressed) + ")", nil
+		}
+		return formatNumber(v.Value, compressed) + v.Unit(), nil
+	case *Color:
+		return v.Format(compressed), nil
+	case *List:
+		return listToCSS(v.Items, v.Separator, v.Bracketed, compressed, v)
+	case *ArgList:
+		return listToCSS(v.Items, v.Separator, false, compressed, v)
+	case *Calculation:
+		return v.format(compressed, false)
+	case *Map, *FunctionValue:
+		return "", fmt.Errorf("%s isn't a valid CSS value.", v)
+	}
+	return v.String(), nil
+}
+
+func listToCSS(items []Value, sep Separator, bracketed, compressed bool, v Value) (string, error) {
+	if len(items) == 0 && !bracketed {
+		return "", fmt.Errorf("%s isn't a valid CSS value.", v)
+	}
+	var buf bytes.Buffer
+	if bracketed {
+		buf.WriteByte('[')
+	}
+	first := true
+	for _, item := range items {
+		if item == Null {
+			continue
+		}
+		s, err := toCSS(item, compressed)
+		if err != nil {
+			return "", err
+		}
+		if s == "" {
+			continue
+		}
+		if !first {
+			buf.WriteString(separatorText(sep, compressed))
+		}
+		first = false
+		buf.WriteString(s)
+	}
+	if bracketed {
+		buf.WriteByte(']')
+	}
+	return buf.String(), nil
+}
+
+// cssString serializes v for use in interpolation or string concatenation,
+// where quoted strings lose their quotes. Values that aren't valid CSS are
+// inspected instead.
+func cssString(v Value) string {
+	if s, ok := v.(*String); ok {
+		return s.Text
+	}
+	if s, err := toCSS(v, false); err == nil {
+		return s
+	}
+	return v.String()
+}